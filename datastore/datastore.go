@@ -14,6 +14,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/janelia-flyem/dvid/dvid"
 	"github.com/janelia-flyem/dvid/storage"
@@ -178,6 +179,16 @@ func GetNodeNote(uuid dvid.UUID) (string, error) {
 	return manager.getNodeNote(uuid)
 }
 
+// GetNodeTimes returns the creation time and the time of the last commit, note, or log
+// change for a version node.
+func GetNodeTimes(uuid dvid.UUID) (created, updated time.Time, err error) {
+	if manager == nil {
+		err = ErrManagerNotInitialized
+		return
+	}
+	return manager.getNodeTimes(uuid)
+}
+
 func SetNodeNote(uuid dvid.UUID, note string) error {
 	if manager == nil {
 		return ErrManagerNotInitialized
@@ -329,6 +340,14 @@ func GetDataByVersionName(v dvid.VersionID, name dvid.InstanceName) (DataService
 	return manager.getDataByVersionName(v, name)
 }
 
+// GetDataInstances returns all undeleted data instances in the repo containing the given UUID.
+func GetDataInstances(uuid dvid.UUID) ([]DataService, error) {
+	if manager == nil {
+		return nil, ErrManagerNotInitialized
+	}
+	return manager.getDataInstances(uuid)
+}
+
 // DeleteDataByName returns a data service given an instance name and UUID.
 func DeleteDataByName(uuid dvid.UUID, name dvid.InstanceName, passcode string) error {
 	if manager == nil {
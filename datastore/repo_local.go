@@ -1221,6 +1221,28 @@ func (m *repoManager) getNodeNote(uuid dvid.UUID) (string, error) {
 	return note, nil
 }
 
+func (m *repoManager) getNodeTimes(uuid dvid.UUID) (created, updated time.Time, err error) {
+	var r *repoT
+	if r, err = m.repoFromUUID(uuid); err != nil {
+		return
+	}
+	var v dvid.VersionID
+	if v, err = m.versionFromUUID(uuid); err != nil {
+		return
+	}
+
+	r.RLock()
+	node, found := r.dag.nodes[v]
+	if !found {
+		r.RUnlock()
+		err = ErrInvalidVersion
+		return
+	}
+	created, updated = node.created, node.updated
+	r.RUnlock()
+	return
+}
+
 func (m *repoManager) setNodeNote(uuid dvid.UUID, note string) error {
 	r, err := m.repoFromUUID(uuid)
 	if err != nil {
@@ -1887,6 +1909,23 @@ func (m *repoManager) getDataByVersionName(v dvid.VersionID, name dvid.InstanceN
 	return data, nil
 }
 
+func (m *repoManager) getDataInstances(uuid dvid.UUID) ([]DataService, error) {
+	r, err := m.repoFromUUID(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	r.RLock()
+	defer r.RUnlock()
+	instances := make([]DataService, 0, len(r.data))
+	for _, data := range r.data {
+		if !data.IsDeleted() {
+			instances = append(instances, data)
+		}
+	}
+	return instances, nil
+}
+
 func (m *repoManager) renameDataByName(uuid dvid.UUID, oldname, newname dvid.InstanceName, passcode string) error {
 	r, err := m.repoFromUUID(uuid)
 	if err != nil {
@@ -21,7 +21,6 @@ import (
 	"github.com/janelia-flyem/dvid/datatype/labelblk"
 	"github.com/janelia-flyem/dvid/datatype/labelmap"
 	"github.com/janelia-flyem/dvid/datatype/labelvol"
-	"github.com/janelia-flyem/dvid/datatype/roi"
 	"github.com/janelia-flyem/dvid/dvid"
 	"github.com/janelia-flyem/dvid/server"
 	"github.com/janelia-flyem/dvid/storage"
@@ -52,6 +51,32 @@ $ dvid repo <UUID> new annotation <data name> <settings...>
     UUID           Hexidecimal string with enough characters to uniquely identify a version node.
     data name      Name of data to create, e.g., "synapses"
     settings       Configuration settings in "key=value" format separated by spaces.
+
+    Configuration Settings (case-insensitive keys)
+
+    VoxelSize      Resolution of voxels for distance queries (default: 8.0,8.0,8.0)
+    IndexedProps   Comma-separated list of element "Prop" names to index, e.g., "user,conf,status".
+                   Can be changed by a PUT of the configuration to the data instance followed
+                   by a POST to the "reload" endpoint to index existing elements.
+
+$ dvid node <UUID> <data name> export <format> <path> <settings...>
+
+	Asynchronously exports all point annotations of the given version to a file or, for the
+	"precomputed" format, a directory on the server.  See the export HTTP endpoint below for
+	a description of the formats.
+
+	Example:
+
+	$ dvid node 3f8c synapses export csv /path/to/synapses.csv kind=PreSyn
+
+    Arguments:
+
+    UUID           Hexidecimal string with enough characters to uniquely identify a version node.
+    data name      Name of annotation data to export.
+    format         One of "csv", "jsonl", or "precomputed".
+    path           Output file path or, for "precomputed", output directory path.
+    settings       Optional "kind" and "tag" settings that restrict the exported elements as
+                   in the export HTTP endpoint.  These are ignored for "precomputed".
 	
     ------------------
 
@@ -103,6 +128,40 @@ POST <api URL>/node/<UUID>/<data name>/sync?<options>
 			   Default operation is false.
 
 
+GET    <api URL>/node/<UUID>/<data name>/schema
+POST   <api URL>/node/<UUID>/<data name>/schema
+DELETE <api URL>/node/<UUID>/<data name>/schema
+
+	Retrieves, sets, or removes an optional schema used to validate elements POSTed to the
+	"elements" endpoint.  If any posted element violates the schema, no elements are stored and
+	a 400 status is returned with a description of each violation (up to 100).  Existing elements
+	are not checked when a schema is set, and stored elements of a custom kind remain readable
+	after the kind is removed from the schema.  Example schema:
+
+	{
+		"Kinds": ["Bookmark", "Mito"],
+		"Properties": {
+			"conf": {"Type": "number", "Required": true, "Kinds": ["PreSyn", "PostSyn"]},
+			"user": {"Type": "string", "Required": true},
+			"status": {"Enum": ["ok", "review", "bad"]}
+		},
+		"AdditionalProperties": false,
+		"Relationships": [
+			{"Kind": "PreSyn", "Rel": "PreSynTo", "Min": 1},
+			{"Kind": "PostSyn", "Rel": "PostSynTo", "Min": 1, "Max": 1}
+		]
+	}
+
+	Kinds           Custom element kinds allowed in addition to the built-in kinds.  Elements with
+	                a custom kind not declared in the instance's schema are rejected.
+	Properties      Element "Prop" names and their "Type" ("string", "number", "integer", or
+	                "boolean", default "string"), whether they're "Required", an optional "Enum"
+	                of allowed values, and optional "Kinds" to which the property is restricted.
+	AdditionalProperties  If false, properties not in "Properties" are rejected.  Default is true.
+	Relationships   Rules giving the minimum and, if non-zero, maximum number of relationships
+	                of a type for an element kind.
+
+
 Note: For the following URL endpoints that return and accept POSTed JSON values, see the JSON format
 at end of this documentation.
 
@@ -121,6 +180,80 @@ GET <api URL>/node/<UUID>/<data name>/label/<label>[?<options>]
 	GET http://foo.com/api/node/83af/myannotations/label/23?relationships=true
 
 
+GET <api URL>/node/<UUID>/<data name>/partners/<label>[?<options>]
+
+	Returns the synaptic partners of a label, which requires a sync to label data like labelmap.
+	Outputs are derived from PreSynTo relationships of the label's PreSyn elements and Inputs
+	are derived from PostSynTo relationships of the label's PostSyn elements, with each
+	relationship's target resolved to the label at that point.  Partners are sorted by
+	descending weight:
+
+	{
+		"Label": 23,
+		"Outputs": [{"Partner": 87, "Weight": 12}, {"Partner": 14, "Weight": 3}],
+		"Inputs": [{"Partner": 14, "Weight": 7}]
+	}
+
+	Partners are kept in a denormalized index that is computed on first request and invalidated
+	by label and annotation changes.
+
+	GET Query-string Options:
+
+	n       Return only the top n output and input partners.  By default all partners are returned.
+	roi     Only count connections whose post-synaptic element is within the given ROI, specified
+	          as "roiname,uuid".  If just "roiname" is given, the UUID of the request is used.
+	          ROI-restricted requests are computed without the partner index.
+
+	Example:
+
+	GET http://foo.com/api/node/83af/myannotations/partners/23?n=10
+
+
+GET <api URL>/node/<UUID>/<data name>/connectivity[?roi=<ROI specification>]
+
+	Returns the weighted connections among the bodies given as a JSON array of labels in the
+	request body, e.g., [23, 87, 14].  Weights are the number of PreSynTo relationships from
+	PreSyn elements of one body to elements within another body.  Only non-zero connections
+	are returned, sorted by "From" and then "To" label:
+
+	[{"From": 14, "To": 23, "Weight": 7}, {"From": 23, "To": 87, "Weight": 12}]
+
+	GET Query-string Options:
+
+	roi     Only count connections whose post-synaptic element is within the given ROI, specified
+	          as "roiname,uuid".  If just "roiname" is given, the UUID of the request is used.
+
+
+GET <api URL>/node/<UUID>/<data name>/props/<prop>?<options>
+
+	Returns elements whose value for the given property matches the query, using the index of
+	a property in the IndexedProps configuration.  Property values that parse as numbers are
+	indexed numerically so range queries can be used.  The returned elements do not include
+	relationships and are sorted by property value and then position:
+
+	{
+		"Elements": [<element>, <element>, ...],
+		"Cursor": "<opaque cursor for next page>"
+	}
+
+	The "Cursor" is only returned if a limit was given and reached.
+
+	GET Query-string Options:
+
+	value   Return elements with exactly this property value.
+	min     Return elements with numeric property value >= min.
+	max     Return elements with numeric property value < max.
+	kind    Comma-separated list of element kinds to return, e.g., "PreSyn,PostSyn".
+	offset  Offset of subvolume in voxels as "x_y_z".  Must be used with "size".
+	size    Size of subvolume in voxels as "x_y_z".  Must be used with "offset".
+	limit   Maximum number of elements to return.
+	cursor  Cursor from a previous query's response to get the next page of elements.
+
+	Example:
+
+	GET http://foo.com/api/node/83af/myannotations/props/conf?max=0.5&kind=PreSyn&limit=1000
+
+
 GET <api URL>/node/<UUID>/<data name>/tag/<tag>[?<options>]
 
 	Returns all point annotations with the given tag as an array of elements.
@@ -152,15 +285,27 @@ DELETE <api URL>/node/<UUID>/<data name>/element/<coord>[?<options>]
 	kafkalog    Set to "off" if you don't want this mutation logged to kafka.
 
 
-GET <api URL>/node/<UUID>/<data name>/roi/<ROI specification>
+GET <api URL>/node/<UUID>/<data name>/roi/<ROI specification>[?<options>]
 
 	Returns all point annotations within the ROI.  The ROI specification must be specified
 	using a string of format "roiname,uuid".  If just "roiname" is specified without
-	a full UUID string, the current UUID of the request will be used.  Currently, this 
-	request will only work for ROIs that have same block size as the annotation data instance.
+	a full UUID string, the current UUID of the request will be used.  The ROI can have
+	any block size.  Multiple ROIs can be given by separating specifications with "+",
+	e.g., "mb,3f8c+lh", and are combined using the "op" query string below.
 
 	The returned point annotations will be an array of elements.
 
+	GET Query-string Options:
+
+	op      Either "union" (default) to return elements within any ROI or "intersect" to
+	          return elements within all ROIs.
+	kind    Comma-separated list of element kinds to return, e.g., "PreSyn,PostSyn".
+	tag     Only return elements with the given tag.
+
+	Example:
+
+	GET http://foo.com/api/node/83af/myannotations/roi/mb+lh,3f8c?op=intersect&kind=PreSyn
+
 GET <api URL>/node/<UUID>/<data name>/elements/<size>/<offset>
 
 	Returns all point annotations within subvolume of given size with upper left corner
@@ -169,6 +314,43 @@ GET <api URL>/node/<UUID>/<data name>/elements/<size>/<offset>
 
 	The returned point annotations will be an array of elements.
 
+GET <api URL>/node/<UUID>/<data name>/nearest/<coord>[?<options>]
+
+	Returns the point annotations nearest the given coordinate of form "x_y_z", sorted by
+	ascending distance.  Distances are physical, using the instance's VoxelSize setting.  Each
+	returned element has an additional "Dist" property giving its distance from the coordinate.
+	The search expands outward one shell of blocks at a time and stops after searching 32
+	blocks out from the block containing the coordinate.
+
+	GET Query-string Options:
+
+	k         Number of nearest elements to return (default 1).
+	maxdist   Only return elements within this physical distance.  Cannot exceed the distance
+	          spanned by 32 blocks along the shortest block dimension.
+	kind      Comma-separated list of element kinds to return, e.g., "PreSyn,PostSyn".
+	tag       Only return elements with the given tag.
+
+	Example:
+
+	GET http://foo.com/api/node/83af/myannotations/nearest/200_300_400?k=5&kind=PreSyn
+
+GET <api URL>/node/<UUID>/<data name>/radius/<coord>/<radius>[?<options>]
+
+	Returns all point annotations within the given physical radius of the coordinate of form
+	"x_y_z", sorted by ascending distance.  Distances are physical, using the instance's 
+	VoxelSize setting.  Each returned element has an additional "Dist" property giving its
+	distance from the coordinate.  The radius cannot exceed the distance spanned by 32 blocks
+	along the shortest block dimension.
+
+	GET Query-string Options:
+
+	kind      Comma-separated list of element kinds to return, e.g., "PreSyn,PostSyn".
+	tag       Only return elements with the given tag.
+
+	Example:
+
+	GET http://foo.com/api/node/83af/myannotations/radius/200_300_400/500
+
 POST <api URL>/node/<UUID>/<data name>/elements[?<options>]
 
 	Adds or modifies point annotations.  The POSTed content is an array of elements.
@@ -208,11 +390,111 @@ POST <api URL>/node/<UUID>/<data name>/move/<from_coord>/<to_coord>[?<options>]
 
 	kafkalog    Set to "off" if you don't want this mutation logged to kafka.
 
+
+
+POST <api URL>/node/<UUID>/<data name>/bulk-delete[?<options>]
+
+	Deletes all point annotations matching the POSTed JSON selection.  Elements must match all
+	given criteria and at least one criterion must be given.  All label, tag, and relationship
+	denormalizations are updated in a single batch.  Returns the number of deleted elements:
+	{"Deleted": 532}
+
+	Example selections:
+		{ "Points": [[15,27,35], [20,30,40]] }
+		{ "Offset": [0,0,0], "Size": [100,100,100], "Kinds": ["PreSyn"] }
+		{ "Tag": "bad-detections" }
+
+	If "Points" are given, all must correspond to existing elements or nothing is deleted.
+
+	Kafka JSON message generated by this request:
+		{ 
+			"Action": "element-bulk-delete",
+			"Points": [<3d point>, <3d point>, ...],
+			"UUID": <UUID on which delete was done>
+		}
+
+	POST Query-string Options:
+
+	kafkalog    Set to "off" if you don't want this mutation logged to kafka.
+
+
+POST <api URL>/node/<UUID>/<data name>/bulk-move[?<options>]
+
+	Moves point annotations given a POSTed JSON list of moves, which are applied in order.
+	All label, tag, and relationship denormalizations are updated in a single batch and if any
+	element is not found, no moves are made.  Returns the number of moved elements:
+	{"Moved": 2}
+
+	Example:
+		[ {"From": [15,27,35], "To": [16,27,35]}, {"From": [20,30,40], "To": [20,31,40]} ]
+
+	Kafka JSON message generated by this request:
+		{ 
+			"Action": "element-bulk-move",
+			"Moves": [{"From": <3d point>, "To": <3d point>}, ...],
+			"UUID": <UUID on which move was done>
+		}
+
+	POST Query-string Options:
+
+	kafkalog    Set to "off" if you don't want this mutation logged to kafka.
+
+
+GET <api URL>/node/<UUID>/<data name>/export/<format>[?<options>]
+
+	Streams all point annotations in block order using one of the following formats:
+
+	csv         Comma-separated values with a header row and columns x, y, z, kind, body, tags,
+	            partners, and partner_bodies.  Tags are separated by semicolons, as are the
+	            space-separated positions of PreSynTo and PostSynTo partners and the bodies at
+	            those positions.  Bodies are only given if the annotations are synced to labels.
+	jsonl       One JSON element per line with "Body" and relationship "ToBody" properties giving
+	            the labels at the element and relationship positions if synced to labels.
+
+	GET Query-string Options:
+
+	kind        Comma-separated list of element kinds to export, e.g., "PreSyn,PostSyn".
+	tag         Only export elements with this tag.
+
+GET <api URL>/node/<UUID>/<data name>/export/precomputed/<path>
+
+	Returns files of a virtual neuroglancer precomputed annotation directory, so the layer source
+	"precomputed://<api URL>/node/<UUID>/<data name>/export/precomputed" can be used in neuroglancer.
+	The directory has an "info" file, a single level spatial index with one cell per annotation
+	block, annotations by id, and if synced to labels, a "body" relationship to segment ids.
+	Each annotation has a "kind" enum property.  Annotation ids pack the element position with
+	21 bits per coordinate (offset by 2^20), so x, y, and z must be in [-2^20, 2^20).  Files that
+	don't exist return a 404 status.
+
+
+GET  <api URL>/node/<UUID>/<data name>/check
+POST <api URL>/node/<UUID>/<data name>/check
+
+	Verifies the label and tag denormalizations against the elements stored by block and checks
+	that relationship targets exist and that PreSynTo and PostSynTo relationships are reciprocal.
+	Labels are only checked if the annotations are synced to labels.  The check holds a read lock
+	so queries can proceed.  A POST also repairs the inconsistent label and tag indices, rebuilding
+	just those indices from current elements under a brief write lock, and notifies synced data of
+	the label changes.  Relationship inconsistencies are only reported.  Returns JSON like:
+
+	{
+		"NumElements": 3412,
+		"LabelsChecked": true,
+		"Labels": [{"Label": 23, "Missing": [[15,27,35]], "Extra": [[10,10,10]], "Stale": [[1,2,3]]}],
+		"Tags": [{"Tag": "Synapse1", "Missing": [[20,30,40]]}],
+		"Relationships": [{"From": [15,27,35], "Rel": "PreSynTo", "To": [8,9,10], "Problem": "missing target"}],
+		"RepairedLabels": [23],
+		"RepairedTags": ["Synapse1"]
+	}
+
+	Missing elements should be in the index but aren't, extra index entries have no element with
+	that label or tag, and stale index entries differ from the stored element.
+
 		
 POST <api URL>/node/<UUID>/<data name>/reload
 
-	Forces asynchornous denormalization of all annotations for labels and tags.  Can be 
-	used to initialize a newly added sync.  Note that the annotation will be locked until
+	Forces asynchornous denormalization of all annotations for labels, tags, and indexed
+	properties.  Can be used to initialize a newly added sync or property index.  Note that the annotation will be locked until
 	the denormalization is finished with a log message.
 
 ------
@@ -257,7 +539,8 @@ Example JSON Format of point annotation elements with ... marking omitted elemen
 	...
 ]
 
-The "Kind" property can be one of "Unknown", "PostSyn", "PreSyn", "Gap", or "Note".
+The "Kind" property can be one of "Unknown", "PostSyn", "PreSyn", "Gap", or "Note", or a custom
+kind declared in the instance's schema.
 
 The "Rel" property can be one of "UnknownRelationship", "PostSynTo", "PreSynTo", "ConvergentTo", or "GroupedWith".
 
@@ -315,8 +598,19 @@ func (e ElementType) IsSynaptic() bool {
 	}
 }
 
-// StringToElementType converts a string
+// StringToElementType converts a string, which can be a built-in kind or a custom kind
+// registered by an instance's schema.
 func StringToElementType(s string) ElementType {
+	if kind := builtinElementType(s); kind != UnknownElem {
+		return kind
+	}
+	if kind, found := customKindByName(s); found {
+		return kind
+	}
+	return UnknownElem
+}
+
+func builtinElementType(s string) ElementType {
 	switch s {
 	case "PostSyn":
 		return PostSyn
@@ -342,6 +636,9 @@ func (e ElementType) String() string {
 	case Note:
 		return "Note"
 	default:
+		if name, found := customKindName(e); found {
+			return name
+		}
 		return fmt.Sprintf("Unknown element type: %d", e)
 	}
 }
@@ -359,6 +656,9 @@ func (e ElementType) MarshalJSON() ([]byte, error) {
 	case Note:
 		return []byte(`"Note"`), nil
 	default:
+		if name, found := customKindName(e); found {
+			return json.Marshal(name)
+		}
 		return nil, fmt.Errorf("Unknown element type: %s", e)
 	}
 }
@@ -376,6 +676,13 @@ func (e *ElementType) UnmarshalJSON(b []byte) error {
 	case `"Note"`:
 		*e = Note
 	default:
+		var name string
+		if err := json.Unmarshal(b, &name); err == nil {
+			if kind, found := customKindByName(name); found {
+				*e = kind
+				return nil
+			}
+		}
 		return fmt.Errorf("Unknown element type in JSON: %s", string(b))
 	}
 	return nil
@@ -391,6 +698,23 @@ const (
 	GroupedWith
 )
 
+func (r RelationType) String() string {
+	switch r {
+	case UnknownRel:
+		return "UnknownRelationship"
+	case PostSynTo:
+		return "PostSynTo"
+	case PreSynTo:
+		return "PreSynTo"
+	case ConvergentTo:
+		return "ConvergentTo"
+	case GroupedWith:
+		return "GroupedWith"
+	default:
+		return fmt.Sprintf("Unknown relation type: %d", r)
+	}
+}
+
 func (r RelationType) MarshalJSON() ([]byte, error) {
 	switch r {
 	case UnknownRel:
@@ -491,7 +815,7 @@ type ElementNR struct {
 	Pos  dvid.Point3d
 	Kind ElementType
 	Tags Tags              // Indexed
-	Prop map[string]string // Non-Indexed
+	Prop map[string]string // Indexed only for properties in the instance's IndexedProps
 }
 
 func (e ElementNR) String() string {
@@ -784,6 +1108,9 @@ func NewData(uuid dvid.UUID, id dvid.InstanceID, name dvid.InstanceName, c dvid.
 		Data:       basedata,
 		Properties: Properties{},
 	}
+	if err := data.Properties.setByConfig(c); err != nil {
+		return nil, err
+	}
 	return data, nil
 }
 
@@ -909,7 +1236,76 @@ func getElementsInRLE(ctx *datastore.VersionedCtx, brles dvid.BlockRLEs) (Elemen
 
 // Properties are additional properties for data beyond those in standard datastore.Data.
 type Properties struct {
-	// Currently unused since block sizes are either default or taken from synced labelblk.
+	// IndexedProps are the names of element properties with a secondary index.
+	// Block sizes are either default or taken from synced labelblk.
+	IndexedProps []string
+
+	// VoxelSize is the physical size of a voxel used for distance queries.  If not set,
+	// the default resolution is used.
+	VoxelSize dvid.NdFloat32
+
+	// Schema, if set, is used to validate posted elements.
+	Schema *Schema
+
+	// CustomKinds are all custom element kinds ever declared by a schema of this instance.
+	// They are registered when the instance is loaded so stored elements of a kind remain
+	// readable after the kind is removed from the schema.
+	CustomKinds []string
+}
+
+// addCustomKinds adds any new custom element kinds to the kinds used by this instance.
+func (p *Properties) addCustomKinds(kinds []string) {
+	for _, kind := range kinds {
+		var found bool
+		for _, existing := range p.CustomKinds {
+			if existing == kind {
+				found = true
+				break
+			}
+		}
+		if !found {
+			p.CustomKinds = append(p.CustomKinds, kind)
+		}
+	}
+}
+
+// setByConfig sets the properties from a configuration if present.
+func (p *Properties) setByConfig(c dvid.Config) error {
+	s, found, err := c.GetString("VoxelSize")
+	if err != nil {
+		return err
+	}
+	if found {
+		voxelSize, err := dvid.StringToNdFloat32(s, ",")
+		if err != nil {
+			return err
+		}
+		if len(voxelSize) != 3 {
+			return fmt.Errorf("VoxelSize must be 3d, not %dd", len(voxelSize))
+		}
+		p.VoxelSize = voxelSize
+	}
+	s, found, err = c.GetString("IndexedProps")
+	if err != nil {
+		return err
+	}
+	if found {
+		props, err := parseIndexedProps(s)
+		if err != nil {
+			return err
+		}
+		p.IndexedProps = props
+	}
+	return nil
+}
+
+// ModifyConfig modifies the data instance configuration.  Changes to the indexed properties
+// only apply to subsequent mutations unless the data is reloaded.
+func (d *Data) ModifyConfig(config dvid.Config) error {
+	if err := d.Properties.setByConfig(config); err != nil {
+		return err
+	}
+	return d.Data.ModifyConfig(config)
 }
 
 // Data instance of labelvol, label sparse volumes.
@@ -928,6 +1324,9 @@ type Data struct {
 	cachedBlockSize *dvid.Point3d
 
 	sync.RWMutex // For CAS ops.  TODO: Make more specific (e.g., point locks) for efficiency.
+
+	// Guards computation and invalidation of the denormalized partner index.
+	partnerMu sync.Mutex
 }
 
 func (d *Data) Equals(d2 *Data) bool {
@@ -1578,50 +1977,9 @@ func (d *Data) GetRegionSynapses(ctx *datastore.VersionedCtx, ext *dvid.Extents3
 	return elements, nil
 }
 
-// GetROISynapses returns synapse elements for a given ROI.
+// GetROISynapses returns synapse elements for a given ROI, which may have any block size.
 func (d *Data) GetROISynapses(ctx *datastore.VersionedCtx, roiSpec storage.FilterSpec) (Elements, error) {
-	roidata, roiV, roiFound, err := roi.DataByFilter(roiSpec)
-	if err != nil {
-		return nil, fmt.Errorf("ROI specification was not parsable (%s): %v\n", roiSpec, err)
-	}
-	if !roiFound {
-		return nil, fmt.Errorf("No ROI found that matches specification %q", roiSpec)
-	}
-	roiSpans, err := roidata.GetSpans(roiV)
-	if err != nil {
-		return nil, fmt.Errorf("Unable to get ROI spans for %q: %v\n", roiSpec, err)
-	}
-	if !d.blockSize().Equals(roidata.BlockSize) {
-		return nil, fmt.Errorf("/roi endpoint currently requires ROI %q to have same block size as annotation %q", roidata.DataName(), d.DataName())
-	}
-
-	store, err := datastore.GetOrderedKeyValueDB(d)
-	if err != nil {
-		return nil, err
-	}
-
-	d.RLock()
-	defer d.RUnlock()
-
-	var elements Elements
-	for _, span := range roiSpans {
-		begBlockCoord := dvid.ChunkPoint3d{span[2], span[1], span[0]}
-		endBlockCoord := dvid.ChunkPoint3d{span[3], span[1], span[0]}
-		begTKey := NewBlockTKey(begBlockCoord)
-		endTKey := NewBlockTKey(endBlockCoord)
-		err = store.ProcessRange(ctx, begTKey, endTKey, nil, func(chunk *storage.Chunk) error {
-			var blockElems Elements
-			if err := json.Unmarshal(chunk.V, &blockElems); err != nil {
-				return err
-			}
-			elements = append(elements, blockElems...)
-			return nil
-		})
-		if err != nil {
-			return nil, fmt.Errorf("error retrieving annotations from %s -> %s: %v", begBlockCoord, endBlockCoord, err)
-		}
-	}
-	return elements, nil
+	return d.GetROIsSynapses(ctx, []storage.FilterSpec{roiSpec}, ROIUnion, ElementFilter{})
 }
 
 // StoreSynapses performs a synchronous store of synapses in JSON format, not
@@ -1639,6 +1997,10 @@ func (d *Data) StoreSynapses(ctx *datastore.VersionedCtx, r io.Reader, kafkaOff
 	d.Lock()
 	defer d.Unlock()
 
+	if err := d.validateElements(elems); err != nil {
+		return err
+	}
+
 	dvid.Infof("%d synaptic elements received via POST", len(elems))
 
 	blockSize := d.blockSize()
@@ -1677,6 +2039,11 @@ func (d *Data) StoreSynapses(ctx *datastore.VersionedCtx, r io.Reader, kafkaOff
 	}
 	batch := batcher.NewBatch(ctx)
 
+	// Replace the property indices of any modified elements
+	if err := d.storePropElements(ctx, batch, blockE); err != nil {
+		return err
+	}
+
 	// Store the new block elements
 	if err := d.storeBlockElements(ctx, batch, blockE); err != nil {
 		return err
@@ -1713,7 +2080,10 @@ func (d *Data) StoreSynapses(ctx *datastore.VersionedCtx, r io.Reader, kafkaOff
 		}
 	}
 
-	return batch.Commit()
+	if err := batch.Commit(); err != nil {
+		return err
+	}
+	return d.invalidateElementPartners(ctx, batcher, elems, false)
 }
 
 func (d *Data) DeleteElement(ctx *datastore.VersionedCtx, pt dvid.Point3d, kafkaOff bool) error {
@@ -1767,6 +2137,9 @@ func (d *Data) DeleteElement(ctx *datastore.VersionedCtx, pt dvid.Point3d, kafka
 		return err
 	}
 
+	// Delete element in any property indices
+	d.deleteBatchProps(batch, ElementsNR{deleted.ElementNR})
+
 	if !kafkaOff {
 		versionuuid, _ := datastore.UUIDFromVersion(ctx.VersionID())
 		msginfo := map[string]interface{}{
@@ -1782,7 +2155,10 @@ func (d *Data) DeleteElement(ctx *datastore.VersionedCtx, pt dvid.Point3d, kafka
 		}
 	}
 
-	return batch.Commit()
+	if err := batch.Commit(); err != nil {
+		return err
+	}
+	return d.invalidateElementPartners(ctx, batcher, Elements{*deleted}, true)
 }
 
 func (d *Data) MoveElement(ctx *datastore.VersionedCtx, from, to dvid.Point3d, kafkaOff bool) error {
@@ -1875,7 +2251,18 @@ func (d *Data) MoveElement(ctx *datastore.VersionedCtx, from, to dvid.Point3d, k
 		return err
 	}
 
-	return batch.Commit()
+	// Move element in any property indices
+	movedFrom := *moved
+	movedFrom.Pos = from
+	d.deleteBatchProps(batch, ElementsNR{movedFrom.ElementNR})
+	if err := d.putBatchProps(batch, ElementsNR{moved.ElementNR}); err != nil {
+		return err
+	}
+
+	if err := batch.Commit(); err != nil {
+		return err
+	}
+	return d.invalidateElementPartners(ctx, batcher, Elements{movedFrom, *moved}, true)
 }
 
 func (d *Data) storeTags(batcher storage.KeyValueBatcher, ctx *datastore.VersionedCtx, tagE map[Tag]Elements) error {
@@ -1936,6 +2323,24 @@ func (d *Data) resync(ctx *datastore.VersionedCtx) {
 		return
 	}
 
+	minPartnersTKey := storage.MinTKey(keyPartners)
+	maxPartnersTKey := storage.MaxTKey(keyPartners)
+	if err := store.DeleteRange(ctx, minPartnersTKey, maxPartnersTKey); err != nil {
+		dvid.Errorf("Unable to delete partner index for annotations %q: %v\n", d.DataName(), err)
+		d.Unlock()
+		d.StopUpdate()
+		return
+	}
+
+	minPropTKey := storage.MinTKey(keyProp)
+	maxPropTKey := storage.MaxTKey(keyProp)
+	if err := store.DeleteRange(ctx, minPropTKey, maxPropTKey); err != nil {
+		dvid.Errorf("Unable to delete property indices for annotations %q: %v\n", d.DataName(), err)
+		d.Unlock()
+		d.StopUpdate()
+		return
+	}
+
 	var numBlockE, numTagE int
 	var totBlockE, totTagE int
 
@@ -1968,6 +2373,20 @@ func (d *Data) resync(ctx *datastore.VersionedCtx) {
 		blockE[chunkPt.ToIZYXString()] = elems
 		numBlockE += len(elems)
 
+		if len(d.IndexedProps) != 0 {
+			propBatch := batcher.NewBatch(ctx)
+			elemsNR := make(ElementsNR, len(elems))
+			for i, elem := range elems {
+				elemsNR[i] = elem.ElementNR
+			}
+			if err := d.putBatchProps(propBatch, elemsNR); err != nil {
+				return err
+			}
+			if err := propBatch.Commit(); err != nil {
+				return fmt.Errorf("bad batch commit in reload of property indices for data %q: %v", d.DataName(), err)
+			}
+		}
+
 		// Iterate through elements, organizing them into blocks and tags.
 		// Note: we do not check for redundancy and guarantee uniqueness at this stage.
 		blockFixBatch := batcher.NewBatch(ctx)
@@ -2103,6 +2522,15 @@ func (d *Data) GobDecode(b []byte) error {
 	if err := dec.Decode(&(d.Properties)); err != nil {
 		return err
 	}
+	// Metadata saved before CustomKinds was added only has the kinds of the current schema.
+	if d.Schema != nil {
+		d.addCustomKinds(d.Schema.Kinds)
+	}
+	for _, name := range d.CustomKinds {
+		if _, err := registerCustomKind(name); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -2121,6 +2549,45 @@ func (d *Data) GobEncode() ([]byte, error) {
 // DoRPC acts as a switchboard for RPC commands.
 func (d *Data) DoRPC(request datastore.Request, reply *datastore.Response) error {
 	switch request.TypeCommand() {
+	case "export":
+		if len(request.Command) < 6 {
+			return fmt.Errorf("poorly formatted export command.  See command-line help")
+		}
+		var uuidStr, dataName, cmdStr, formatStr, outPath string
+		request.CommandArgs(1, &uuidStr, &dataName, &cmdStr, &formatStr, &outPath)
+
+		format, err := ParseExportFormat(formatStr)
+		if err != nil {
+			return err
+		}
+		uuid, v, err := datastore.MatchingUUID(uuidStr)
+		if err != nil {
+			return err
+		}
+		config := request.Settings()
+		kinds, _, err := config.GetString("kind")
+		if err != nil {
+			return err
+		}
+		tag, _, err := config.GetString("tag")
+		if err != nil {
+			return err
+		}
+		filter, err := NewElementFilter(kinds, tag)
+		if err != nil {
+			return err
+		}
+		go func() {
+			ctx := datastore.NewVersionedCtx(d, v)
+			if err := d.Export(ctx, format, outPath, filter); err != nil {
+				dvid.Errorf("unable to export annotation %q, uuid %s to %s file %q: %v\n", d.DataName(), uuid, format, outPath, err)
+				return
+			}
+			dvid.Infof("Finished export of annotation %q, uuid %s to %s file %q\n", d.DataName(), uuid, format, outPath)
+		}()
+		reply.Text = fmt.Sprintf("Asynchronously exporting annotation %q, uuid %s to %s file: %s\n", d.DataName(), uuid, format, outPath)
+		return nil
+
 	default:
 		return fmt.Errorf("unknown command.  Data type %q [%s] does not support %q command.",
 			d.DataName(), d.TypeName(), request.TypeCommand())
@@ -2193,6 +2660,51 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 		}
 		d.cachedBlockSize = nil
 
+	case "schema":
+		// GET, POST, or DELETE <api URL>/node/<UUID>/<data name>/schema
+		switch action {
+		case "get":
+			d.RLock()
+			jsonBytes, err := json.Marshal(d.Schema)
+			d.RUnlock()
+			if err != nil {
+				server.BadRequest(w, r, err)
+				return
+			}
+			w.Header().Set("Content-type", "application/json")
+			if _, err := w.Write(jsonBytes); err != nil {
+				server.BadRequest(w, r, err)
+				return
+			}
+		case "post", "delete":
+			var schema *Schema
+			if action == "post" {
+				data, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					server.BadRequest(w, r, err)
+					return
+				}
+				if schema, err = ParseSchema(data); err != nil {
+					server.BadRequest(w, r, err)
+					return
+				}
+			}
+			d.Lock()
+			d.Schema = schema
+			if schema != nil {
+				d.addCustomKinds(schema.Kinds)
+			}
+			d.Unlock()
+			if err := datastore.SaveDataByUUID(uuid, d); err != nil {
+				server.BadRequest(w, r, err)
+				return
+			}
+		default:
+			server.BadRequest(w, r, "Only GET, POST, or DELETE action is available on 'schema' endpoint.")
+			return
+		}
+		timedLog.Infof("HTTP %s: schema (%s)", r.Method, r.URL)
+
 	case "label":
 		if action != "get" {
 			server.BadRequest(w, r, "Only GET action is available on 'label' endpoint.")
@@ -2224,6 +2736,125 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 		}
 		timedLog.Infof("HTTP %s: get synaptic elements for label %d (%s)", r.Method, label, r.URL)
 
+	case "partners":
+		// GET <api URL>/node/<UUID>/<data name>/partners/<label>
+		if action != "get" {
+			server.BadRequest(w, r, "Only GET action is available on 'partners' endpoint.")
+			return
+		}
+		if len(parts) < 5 {
+			server.BadRequest(w, r, "Must include label after 'partners' endpoint.")
+			return
+		}
+		label, err := strconv.ParseUint(parts[4], 10, 64)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		if label == 0 {
+			server.BadRequest(w, r, "Label 0 is protected background value and cannot be used for query.")
+			return
+		}
+		queryStrings := r.URL.Query()
+		var n int
+		if nStr := queryStrings.Get("n"); nStr != "" {
+			if n, err = strconv.Atoi(nStr); err != nil {
+				server.BadRequest(w, r, "bad n query string %q: %v", nStr, err)
+				return
+			}
+		}
+		iROI, err := getImmutableROI(uuid, queryStrings.Get("roi"))
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		partners, err := d.GetPartners(ctx, label, n, iROI)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		jsonBytes, err := json.Marshal(partners)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-type", "application/json")
+		if _, err := w.Write(jsonBytes); err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		timedLog.Infof("HTTP %s: get partners for label %d (%s)", r.Method, label, r.URL)
+
+	case "connectivity":
+		// GET <api URL>/node/<UUID>/<data name>/connectivity
+		if action != "get" {
+			server.BadRequest(w, r, "Only GET action is available on 'connectivity' endpoint.")
+			return
+		}
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			server.BadRequest(w, r, "Bad GET request body for connectivity query: %v", err)
+			return
+		}
+		var bodies []uint64
+		if err := json.Unmarshal(data, &bodies); err != nil {
+			server.BadRequest(w, r, fmt.Sprintf("Bad connectivity request JSON: %v", err))
+			return
+		}
+		iROI, err := getImmutableROI(uuid, r.URL.Query().Get("roi"))
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		conns, err := d.GetConnectivity(ctx, bodies, iROI)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		jsonBytes, err := json.Marshal(conns)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-type", "application/json")
+		if _, err := w.Write(jsonBytes); err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		timedLog.Infof("HTTP %s: get connectivity among %d bodies (%s)", r.Method, len(bodies), r.URL)
+
+	case "props":
+		// GET <api URL>/node/<UUID>/<data name>/props/<prop>
+		if action != "get" {
+			server.BadRequest(w, r, "Only GET action is available on 'props' endpoint.")
+			return
+		}
+		if len(parts) < 5 {
+			server.BadRequest(w, r, "Must include property name after 'props' endpoint.")
+			return
+		}
+		q, err := ParsePropQuery(parts[4], r.URL.Query())
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		result, err := d.QueryProps(ctx, q)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		jsonBytes, err := json.Marshal(result)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-type", "application/json")
+		if _, err := w.Write(jsonBytes); err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		timedLog.Infof("HTTP %s: get %d elements for property %q (%s)", r.Method, len(result.Elements), q.Prop, r.URL)
+
 	case "tag":
 		if action != "get" {
 			server.BadRequest(w, r, "Only GET action is available on 'tag' endpoint.")
@@ -2255,19 +2886,36 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 				server.BadRequest(w, r, "Expect ROI specification to follow 'roi' in GET request")
 				return
 			}
-			roiParts := strings.Split(parts[4], ",")
-			var roiSpec string
-			roiSpec = "roi:"
-			switch len(roiParts) {
-			case 1:
-				roiSpec += roiParts[0] + "," + string(uuid)
-			case 2:
-				roiSpec += parts[4]
+			var roiSpecs []storage.FilterSpec
+			for _, spec := range strings.Split(parts[4], "+") {
+				roiParts := strings.Split(spec, ",")
+				switch len(roiParts) {
+				case 1:
+					roiSpecs = append(roiSpecs, storage.FilterSpec("roi:"+roiParts[0]+","+string(uuid)))
+				case 2:
+					roiSpecs = append(roiSpecs, storage.FilterSpec("roi:"+spec))
+				default:
+					server.BadRequest(w, r, "Bad ROI specification: %q", spec)
+					return
+				}
+			}
+			queryStrings := r.URL.Query()
+			var op ROISetOp
+			switch queryStrings.Get("op") {
+			case "", "union":
+				op = ROIUnion
+			case "intersect":
+				op = ROIIntersection
 			default:
-				server.BadRequest(w, r, "Bad ROI specification: %q", parts[4])
+				server.BadRequest(w, r, "Bad ROI op %q: must be 'union' or 'intersect'", queryStrings.Get("op"))
+				return
+			}
+			filter, err := NewElementFilter(queryStrings.Get("kind"), queryStrings.Get("tag"))
+			if err != nil {
+				server.BadRequest(w, r, err)
 				return
 			}
-			elems, err := d.GetROISynapses(ctx, storage.FilterSpec(roiSpec))
+			elems, err := d.GetROIsSynapses(ctx, roiSpecs, op, filter)
 			if err != nil {
 				server.BadRequest(w, r, err)
 				return
@@ -2331,6 +2979,109 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 			return
 		}
 
+	case "nearest":
+		// GET <api URL>/node/<UUID>/<data name>/nearest/<coord>
+		if action != "get" {
+			server.BadRequest(w, r, "Only GET action is available on 'nearest' endpoint.")
+			return
+		}
+		if len(parts) < 5 {
+			server.BadRequest(w, r, "Must include coordinate after 'nearest' endpoint.")
+			return
+		}
+		pt, err := dvid.StringToPoint3d(parts[4], "_")
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		queryStrings := r.URL.Query()
+		k := 1
+		if kStr := queryStrings.Get("k"); kStr != "" {
+			if k, err = strconv.Atoi(kStr); err != nil || k < 1 {
+				server.BadRequest(w, r, "bad k query string %q: must be positive integer", kStr)
+				return
+			}
+		}
+		var maxDist float64
+		if distStr := queryStrings.Get("maxdist"); distStr != "" {
+			if maxDist, err = strconv.ParseFloat(distStr, 64); err != nil || maxDist <= 0 {
+				server.BadRequest(w, r, "bad maxdist query string %q: must be positive number", distStr)
+				return
+			}
+			if err := d.checkSearchDist(maxDist); err != nil {
+				server.BadRequest(w, r, err)
+				return
+			}
+		}
+		filter, err := NewElementFilter(queryStrings.Get("kind"), queryStrings.Get("tag"))
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		elems, err := d.GetNearestSynapses(ctx, pt, k, maxDist, filter)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		jsonBytes, err := json.Marshal(elems)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-type", "application/json")
+		if _, err := w.Write(jsonBytes); err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		timedLog.Infof("HTTP %s: get %d nearest synaptic elements to %s (%s)", r.Method, k, pt, r.URL)
+
+	case "radius":
+		// GET <api URL>/node/<UUID>/<data name>/radius/<coord>/<radius>
+		if action != "get" {
+			server.BadRequest(w, r, "Only GET action is available on 'radius' endpoint.")
+			return
+		}
+		if len(parts) < 6 {
+			server.BadRequest(w, r, "Must include coordinate and radius after 'radius' endpoint.")
+			return
+		}
+		pt, err := dvid.StringToPoint3d(parts[4], "_")
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		radius, err := strconv.ParseFloat(parts[5], 64)
+		if err != nil || radius < 0 {
+			server.BadRequest(w, r, "bad radius %q: must be non-negative number", parts[5])
+			return
+		}
+		if err := d.checkSearchDist(radius); err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		queryStrings := r.URL.Query()
+		filter, err := NewElementFilter(queryStrings.Get("kind"), queryStrings.Get("tag"))
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		elems, err := d.GetRadiusSynapses(ctx, pt, radius, filter)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		jsonBytes, err := json.Marshal(elems)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-type", "application/json")
+		if _, err := w.Write(jsonBytes); err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		timedLog.Infof("HTTP %s: get synaptic elements within %f of %s (%s)", r.Method, radius, pt, r.URL)
+
 	case "element":
 		// DELETE <api URL>/node/<UUID>/<data name>/element/<coord>
 		if action != "delete" {
@@ -2380,6 +3131,119 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 		}
 		timedLog.Infof("HTTP %s: move synaptic element from %s to %s (%s)", r.Method, fromPt, toPt, r.URL)
 
+	case "bulk-delete":
+		// POST <api URL>/node/<UUID>/<data name>/bulk-delete
+		if action != "post" {
+			server.BadRequest(w, r, "Only POST action is available on 'bulk-delete' endpoint.")
+			return
+		}
+		kafkaOff := r.URL.Query().Get("kafkalog") == "off"
+		numDeleted, err := d.DeleteElements(ctx, r.Body, kafkaOff)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-type", "application/json")
+		fmt.Fprintf(w, `{"Deleted": %d}`, numDeleted)
+		timedLog.Infof("HTTP %s: bulk delete of %d synaptic elements (%s)", r.Method, numDeleted, r.URL)
+
+	case "bulk-move":
+		// POST <api URL>/node/<UUID>/<data name>/bulk-move
+		if action != "post" {
+			server.BadRequest(w, r, "Only POST action is available on 'bulk-move' endpoint.")
+			return
+		}
+		kafkaOff := r.URL.Query().Get("kafkalog") == "off"
+		numMoved, err := d.MoveElements(ctx, r.Body, kafkaOff)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-type", "application/json")
+		fmt.Fprintf(w, `{"Moved": %d}`, numMoved)
+		timedLog.Infof("HTTP %s: bulk move of %d synaptic elements (%s)", r.Method, numMoved, r.URL)
+
+	case "export":
+		// GET <api URL>/node/<UUID>/<data name>/export/<format>
+		if action != "get" {
+			server.BadRequest(w, r, "Only GET action is available on 'export' endpoint.")
+			return
+		}
+		if len(parts) < 5 {
+			server.BadRequest(w, r, "Expect export format to follow 'export' endpoint")
+			return
+		}
+		format, err := ParseExportFormat(parts[4])
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		if format == ExportPrecomputed {
+			data, err := d.GetPrecomputed(ctx, strings.Join(parts[5:], "/"))
+			if err != nil {
+				server.BadRequest(w, r, err)
+				return
+			}
+			if data == nil {
+				http.NotFound(w, r)
+				return
+			}
+			if len(parts) == 6 && parts[5] == "info" {
+				w.Header().Set("Content-type", "application/json")
+			} else {
+				w.Header().Set("Content-type", "application/octet-stream")
+			}
+			if _, err := w.Write(data); err != nil {
+				server.BadRequest(w, r, err)
+				return
+			}
+			timedLog.Infof("HTTP %s: precomputed annotation file %q (%s)", r.Method, strings.Join(parts[5:], "/"), r.URL)
+			return
+		}
+		queryStrings := r.URL.Query()
+		filter, err := NewElementFilter(queryStrings.Get("kind"), queryStrings.Get("tag"))
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		switch format {
+		case ExportCSV:
+			w.Header().Set("Content-type", "text/csv")
+			err = d.WriteCSV(ctx, w, filter)
+		case ExportJSONLines:
+			w.Header().Set("Content-type", "application/x-ndjson")
+			err = d.WriteJSONLines(ctx, w, filter)
+		}
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		timedLog.Infof("HTTP %s: export of annotations in %s format (%s)", r.Method, format, r.URL)
+
+	case "check":
+		// GET or POST <api URL>/node/<UUID>/<data name>/check
+		if action != "get" && action != "post" {
+			server.BadRequest(w, r, "Only GET or POST action is available on 'check' endpoint.")
+			return
+		}
+		report, err := d.CheckConsistency(ctx, action == "post")
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		jsonBytes, err := json.Marshal(report)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-type", "application/json")
+		if _, err := w.Write(jsonBytes); err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		timedLog.Infof("HTTP %s: consistency check found %d label, %d tag, and %d relationship inconsistencies (%s)",
+			r.Method, len(report.Labels), len(report.Tags), len(report.Relationships), r.URL)
+
 	case "reload":
 		// POST <api URL>/node/<UUID>/<data name>/reload
 		if action != "post" {
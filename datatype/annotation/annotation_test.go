@@ -3,9 +3,12 @@ package annotation
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
+	"net/http"
 	"reflect"
 	"runtime"
 	"strings"
@@ -13,6 +16,8 @@ import (
 	"testing"
 
 	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/datatype/common/downres"
+	"github.com/janelia-flyem/dvid/datatype/labelmap"
 	"github.com/janelia-flyem/dvid/dvid"
 	"github.com/janelia-flyem/dvid/server"
 )
@@ -611,6 +616,792 @@ func getTag(tag Tag, elems Elements) Elements {
 	return result
 }
 
+func elementPositions(elems Elements) map[string]struct{} {
+	pts := make(map[string]struct{}, len(elems))
+	for _, elem := range elems {
+		pts[elem.Pos.String()] = struct{}{}
+	}
+	return pts
+}
+
+func testROIQuery(t *testing.T, uuid dvid.UUID, query string, expected ...dvid.Point3d) {
+	url := fmt.Sprintf("%snode/%s/mysynapses/roi/%s", server.WebAPIPath, uuid, query)
+	var got Elements
+	if err := json.Unmarshal(server.TestHTTP(t, "GET", url, nil), &got); err != nil {
+		t.Fatal(err)
+	}
+	expectedPts := make(map[string]struct{}, len(expected))
+	for _, pt := range expected {
+		expectedPts[pt.String()] = struct{}{}
+	}
+	if gotPts := elementPositions(got); !reflect.DeepEqual(gotPts, expectedPts) {
+		t.Errorf("ROI query %q expected elements at %v, got %v\n", query, expectedPts, gotPts)
+	}
+}
+
+func TestROIQueries(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+	server.CreateTestInstance(t, uuid, "annotation", "mysynapses", dvid.Config{})
+	testJSON, err := json.Marshal(testData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := fmt.Sprintf("%snode/%s/mysynapses/elements", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", url, strings.NewReader(string(testJSON)))
+
+	// ROIs with a block size different from the annotation instance.
+	var config dvid.Config
+	config.Set("BlockSize", "16,16,16")
+	server.CreateTestInstance(t, uuid, "roi", "roiA", config)
+	server.CreateTestInstance(t, uuid, "roi", "roiB", config)
+	apiStr := fmt.Sprintf("%snode/%s/roiA/roi", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", apiStr, strings.NewReader("[[2, 1, 0, 2]]"))
+	apiStr = fmt.Sprintf("%snode/%s/roiB/roi", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", apiStr, strings.NewReader("[[2, 1, 1, 1], [6, 3, 5, 5]]"))
+
+	testROIQuery(t, uuid, "roiA", dvid.Point3d{15, 27, 35}, dvid.Point3d{20, 30, 40}, dvid.Point3d{14, 25, 37})
+	testROIQuery(t, uuid, "roiB,"+string(uuid), dvid.Point3d{20, 30, 40})
+	testROIQuery(t, uuid, "roiA+roiB", dvid.Point3d{15, 27, 35}, dvid.Point3d{20, 30, 40}, dvid.Point3d{14, 25, 37})
+	testROIQuery(t, uuid, "roiA+roiB?op=intersect", dvid.Point3d{20, 30, 40})
+	testROIQuery(t, uuid, "roiA?kind=PreSyn", dvid.Point3d{15, 27, 35})
+	testROIQuery(t, uuid, "roiA?kind=PostSyn&tag=Zlt90", dvid.Point3d{14, 25, 37})
+
+	// Voxel-precision ROI with only voxels x < 15 in block (0,1,2).
+	config.Set("VoxelPrecision", "true")
+	server.CreateTestInstance(t, uuid, "roi", "roiC", config)
+	mask := make([]byte, 16*16*16)
+	for i := range mask {
+		if i%16 < 15 {
+			mask[i] = 1
+		}
+	}
+	apiStr = fmt.Sprintf("%snode/%s/roiC/mask/0_1_2/16_16_16/0_16_32", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", apiStr, bytes.NewBuffer(mask))
+	testROIQuery(t, uuid, "roiC", dvid.Point3d{14, 25, 37})
+	testROIQuery(t, uuid, "roiA+roiC?op=intersect", dvid.Point3d{14, 25, 37})
+
+	badURL := fmt.Sprintf("%snode/%s/mysynapses/roi/roiA?kind=Foo", server.WebAPIPath, uuid)
+	server.TestBadHTTP(t, "GET", badURL, nil)
+}
+
+func testROIConstruct(t *testing.T, uuid dvid.UUID, request, expected string) {
+	url := fmt.Sprintf("%snode/%s/constructed/construct", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", url, strings.NewReader(request))
+	url = fmt.Sprintf("%snode/%s/constructed/roi", server.WebAPIPath, uuid)
+	spans := server.TestHTTP(t, "GET", url, nil)
+	if string(spans) != expected {
+		t.Errorf("bad ROI constructed from %s: got %s, expected %s\n", request, string(spans), expected)
+	}
+}
+
+func TestROIConstruct(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+	var config dvid.Config
+	config.Set("BlockSize", "32,32,32")
+	server.CreateTestInstance(t, uuid, "labelmap", "mylabelmap", config)
+	server.CreateTestInstance(t, uuid, "annotation", "mysynapses", config)
+	server.CreateTestInstance(t, uuid, "roi", "constructed", config)
+
+	// Body 1 occupies blocks (0,0,0) and (3,1,0) and body 2 the rest of a 4x2x1 block volume.
+	volume := newTestVolume(128, 64, 32)
+	var body1, body2 dvid.Spans
+	for z := int32(0); z < 32; z++ {
+		for y := int32(0); y < 32; y++ {
+			body1 = append(body1, dvid.Span{z, y, 0, 31}, dvid.Span{z, y + 32, 96, 127})
+			body2 = append(body2, dvid.Span{z, y, 32, 127}, dvid.Span{z, y + 32, 0, 95})
+		}
+	}
+	volume.add(testBody{voxelSpans: body1}, 1)
+	volume.add(testBody{voxelSpans: body2}, 2)
+	volume.put(t, uuid, "mylabelmap")
+	if err := datastore.BlockOnUpdating(uuid, "mylabelmap"); err != nil {
+		t.Fatalf("Error blocking on update for labels: %v\n", err)
+	}
+
+	testJSON, err := json.Marshal(testData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := fmt.Sprintf("%snode/%s/mysynapses/elements", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", url, strings.NewReader(string(testJSON)))
+
+	testROIConstruct(t, uuid, `{"Source":"mylabelmap","Bodies":[1]}`, `[[0,0,0,0],[0,1,3,3]]`)
+	testROIConstruct(t, uuid, `{"Source":"mylabelmap","Bodies":[1],"Hull":true}`, `[[0,0,0,2],[0,1,1,3]]`)
+	testROIConstruct(t, uuid, `{"Source":"mylabelmap","Bodies":[1],"Scale":1}`, `[[0,0,0,3],[0,1,0,3],[1,0,0,3],[1,1,0,3]]`)
+	testROIConstruct(t, uuid, `{"Source":"mylabelmap","Bodies":[1,2]}`, `[[0,0,0,3],[0,1,0,3]]`)
+	testROIConstruct(t, uuid, `{"Source":"mysynapses","Tag":"Synapse2"}`, `[[2,1,2,2],[3,1,3,3],[3,2,3,3]]`)
+	testROIConstruct(t, uuid, `{"Source":"mysynapses","Kinds":"PreSyn"}`, `[[1,0,0,0],[3,1,3,3]]`)
+	testROIConstruct(t, uuid, `{"Source":"mysynapses","Kinds":"PreSyn","Hull":true}`, `[[1,0,0,1],[2,0,1,2],[2,1,1,2],[3,1,2,3]]`)
+	testROIConstruct(t, uuid, `{"Source":"mysynapses","Kinds":"PreSyn","Tag":"Zlt90","Dilate":1,"Shape":"sphere"}`,
+		`[[0,0,0,0],[1,-1,0,0],[1,0,-1,1],[1,1,0,0],[2,0,0,0]]`)
+
+	badRequests := []string{
+		`{"Bodies":[1]}`,
+		`{"Source":"nonexistent","Bodies":[1]}`,
+		`{"Source":"mysynapses","Bodies":[1]}`,
+		`{"Source":"mylabelmap","Tag":"Synapse2"}`,
+		`{"Source":"mylabelmap","Bodies":[17]}`,
+		`{"Source":"mysynapses","Kinds":"Foo"}`,
+		`{"Source":"mysynapses","Tag":"Synapse2","Dilate":1,"Shape":"cone"}`,
+	}
+	url = fmt.Sprintf("%snode/%s/constructed/construct", server.WebAPIPath, uuid)
+	for _, req := range badRequests {
+		server.TestBadHTTP(t, "POST", url, strings.NewReader(req))
+	}
+}
+
+func queryProps(t *testing.T, uuid dvid.UUID, query string) PropResult {
+	url := fmt.Sprintf("%snode/%s/mysynapses/props/%s", server.WebAPIPath, uuid, query)
+	var result PropResult
+	if err := json.Unmarshal(server.TestHTTP(t, "GET", url, nil), &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func testPropQuery(t *testing.T, uuid dvid.UUID, query string, expected ...dvid.Point3d) {
+	result := queryProps(t, uuid, query)
+	if len(result.Elements) != len(expected) {
+		t.Fatalf("property query %q expected %d elements, got %v\n", query, len(expected), result.Elements)
+	}
+	for i, elem := range result.Elements {
+		if !elem.Pos.Equals(expected[i]) {
+			t.Errorf("property query %q expected element %d at %s, got %s\n", query, i, expected[i], elem.Pos)
+		}
+	}
+}
+
+func TestPropIndex(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+	var config dvid.Config
+	config.Set("IndexedProps", "status,conf")
+	server.CreateTestInstance(t, uuid, "annotation", "mysynapses", config)
+
+	elems := Elements{
+		{ElementNR{Pos: dvid.Point3d{10, 10, 10}, Kind: PreSyn, Prop: map[string]string{"conf": "0.9", "user": "a"}}, nil},
+		{ElementNR{Pos: dvid.Point3d{20, 10, 10}, Kind: PreSyn, Prop: map[string]string{"conf": "0.2"}}, nil},
+		{ElementNR{Pos: dvid.Point3d{30, 10, 10}, Kind: PostSyn, Prop: map[string]string{"conf": "0.4"}}, nil},
+		{ElementNR{Pos: dvid.Point3d{200, 10, 10}, Kind: PreSyn, Prop: map[string]string{"conf": "-1"}}, nil},
+		{ElementNR{Pos: dvid.Point3d{40, 10, 10}, Kind: Note, Prop: map[string]string{"status": "open"}}, nil},
+		{ElementNR{Pos: dvid.Point3d{50, 10, 10}, Kind: Note, Prop: map[string]string{"status": "closed"}}, nil},
+		{ElementNR{Pos: dvid.Point3d{60, 10, 10}, Kind: Note, Prop: map[string]string{"status": "open"}}, nil},
+	}
+	testJSON, err := json.Marshal(elems)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := fmt.Sprintf("%snode/%s/mysynapses/elements", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", url, strings.NewReader(string(testJSON)))
+
+	testPropQuery(t, uuid, "status?value=open", dvid.Point3d{40, 10, 10}, dvid.Point3d{60, 10, 10})
+	testPropQuery(t, uuid, "conf?max=0.5", dvid.Point3d{200, 10, 10}, dvid.Point3d{20, 10, 10}, dvid.Point3d{30, 10, 10})
+	testPropQuery(t, uuid, "conf?max=0.5&kind=PreSyn&offset=0_0_0&size=100_100_100", dvid.Point3d{20, 10, 10})
+	testPropQuery(t, uuid, "conf?min=0.4", dvid.Point3d{30, 10, 10}, dvid.Point3d{10, 10, 10})
+
+	// Page through results.
+	result := queryProps(t, uuid, "conf?min=-10&limit=2")
+	if len(result.Elements) != 2 || result.Cursor == "" {
+		t.Fatalf("expected 2 elements and a cursor for first page, got %v\n", result)
+	}
+	testPropQuery(t, uuid, "conf?min=-10&limit=2&cursor="+result.Cursor, dvid.Point3d{30, 10, 10}, dvid.Point3d{10, 10, 10})
+
+	// Unindexed properties cannot be queried.
+	badURL := fmt.Sprintf("%snode/%s/mysynapses/props/user?value=a", server.WebAPIPath, uuid)
+	server.TestBadHTTP(t, "GET", badURL, nil)
+
+	// Modification, move and delete should update the index.
+	modified := `[{"Pos":[40,10,10],"Kind":"Note","Prop":{"status":"closed"}}]`
+	server.TestHTTP(t, "POST", url, strings.NewReader(modified))
+	moveURL := fmt.Sprintf("%snode/%s/mysynapses/move/60_10_10/70_10_10", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", moveURL, nil)
+	testPropQuery(t, uuid, "status?value=open", dvid.Point3d{70, 10, 10})
+	delURL := fmt.Sprintf("%snode/%s/mysynapses/element/70_10_10", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "DELETE", delURL, nil)
+	testPropQuery(t, uuid, "status?value=open")
+	testPropQuery(t, uuid, "status?value=closed", dvid.Point3d{40, 10, 10}, dvid.Point3d{50, 10, 10})
+}
+
+func testDistQuery(t *testing.T, uuid dvid.UUID, query string, expected ...dvid.Point3d) ElementDists {
+	url := fmt.Sprintf("%snode/%s/mysynapses/%s", server.WebAPIPath, uuid, query)
+	var got ElementDists
+	if err := json.Unmarshal(server.TestHTTP(t, "GET", url, nil), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("query %q expected %d elements, got %v\n", query, len(expected), got)
+	}
+	for i, elem := range got {
+		if !elem.Pos.Equals(expected[i]) {
+			t.Errorf("query %q expected element %d at %s, got %s\n", query, i, expected[i], elem.Pos)
+		}
+	}
+	return got
+}
+
+func TestNearestQueries(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+	var config dvid.Config
+	config.Set("VoxelSize", "4,4,40")
+	server.CreateTestInstance(t, uuid, "annotation", "mysynapses", config)
+
+	elems := Elements{
+		{ElementNR{Pos: dvid.Point3d{110, 100, 100}, Kind: PreSyn}, nil},
+		{ElementNR{Pos: dvid.Point3d{100, 100, 102}, Kind: PostSyn}, nil},
+		{ElementNR{Pos: dvid.Point3d{100, 130, 100}, Kind: PreSyn}, nil},
+		{ElementNR{Pos: dvid.Point3d{300, 100, 100}, Kind: PreSyn}, nil},
+		{ElementNR{Pos: dvid.Point3d{101, 100, 100}, Kind: Note}, nil},
+	}
+	testJSON, err := json.Marshal(elems)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := fmt.Sprintf("%snode/%s/mysynapses/elements", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", url, strings.NewReader(string(testJSON)))
+
+	got := testDistQuery(t, uuid, "nearest/100_100_100?k=2", dvid.Point3d{101, 100, 100}, dvid.Point3d{110, 100, 100})
+	if got[1].Dist != 40 {
+		t.Errorf("expected physical distance 40 for nearest element, got %f\n", got[1].Dist)
+	}
+	testDistQuery(t, uuid, "nearest/100_100_100?k=3&kind=PreSyn", dvid.Point3d{110, 100, 100}, dvid.Point3d{100, 130, 100}, dvid.Point3d{300, 100, 100})
+	testDistQuery(t, uuid, "nearest/100_100_100?k=10&maxdist=100", dvid.Point3d{101, 100, 100}, dvid.Point3d{110, 100, 100}, dvid.Point3d{100, 100, 102})
+	testDistQuery(t, uuid, "radius/100_100_100/100", dvid.Point3d{101, 100, 100}, dvid.Point3d{110, 100, 100}, dvid.Point3d{100, 100, 102})
+	testDistQuery(t, uuid, "radius/100_100_100/100?kind=PreSyn", dvid.Point3d{110, 100, 100})
+	testDistQuery(t, uuid, "radius/0_0_0/10")
+
+	for _, query := range []string{"nearest/100_100_100?k=0", "nearest/100_100_100?maxdist=1e12",
+		"nearest/100_100_100?maxdist=NaN", "nearest/100_100_100?maxdist=Inf", "radius/100_100_100/1e12"} {
+		badURL := fmt.Sprintf("%snode/%s/mysynapses/%s", server.WebAPIPath, uuid, query)
+		server.TestBadHTTP(t, "GET", badURL, nil)
+	}
+}
+
+func getAllElements(t *testing.T, uuid dvid.UUID) map[string]Element {
+	url := fmt.Sprintf("%snode/%s/mysynapses/elements/1000_1000_1000/0_0_0", server.WebAPIPath, uuid)
+	var elems Elements
+	if err := json.Unmarshal(server.TestHTTP(t, "GET", url, nil), &elems); err != nil {
+		t.Fatal(err)
+	}
+	emap := make(map[string]Element, len(elems))
+	for _, elem := range elems {
+		emap[elem.Pos.String()] = elem
+	}
+	return emap
+}
+
+func TestBulkDeleteMove(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+	var config dvid.Config
+	config.Set("BlockSize", "32,32,32")
+	server.CreateTestInstance(t, uuid, "labelmap", "mylabelmap", config)
+	_ = createLabelTestVolume(t, uuid, "mylabelmap")
+	if err := datastore.BlockOnUpdating(uuid, "mylabelmap"); err != nil {
+		t.Fatalf("Error blocking on update for labels: %v\n", err)
+	}
+	server.CreateTestInstance(t, uuid, "annotation", "mysynapses", config)
+	server.CreateTestSync(t, uuid, "mysynapses", "mylabelmap")
+
+	testJSON, err := json.Marshal(testData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := fmt.Sprintf("%snode/%s/mysynapses/elements", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", url, strings.NewReader(string(testJSON)))
+
+	// Delete by tag and kind.
+	deleteURL := fmt.Sprintf("%snode/%s/mysynapses/bulk-delete", server.WebAPIPath, uuid)
+	r := server.TestHTTP(t, "POST", deleteURL, strings.NewReader(`{"Tag": "Synapse2", "Kinds": ["PostSyn"]}`))
+	var deleted struct{ Deleted int }
+	if err := json.Unmarshal(r, &deleted); err != nil {
+		t.Fatal(err)
+	}
+	if deleted.Deleted != 3 {
+		t.Errorf("expected 3 deleted elements, got %d\n", deleted.Deleted)
+	}
+	emap := getAllElements(t, uuid)
+	if len(emap) != 5 {
+		t.Errorf("expected 5 elements after bulk delete, got %v\n", emap)
+	}
+	if presyn, found := emap[dvid.Point3d{127, 63, 99}.String()]; !found || len(presyn.Rels) != 0 {
+		t.Errorf("expected element at (127,63,99) without relationships, got %v\n", presyn)
+	}
+	testResponseLabel(t, nil, "%snode/%s/mysynapses/label/4", server.WebAPIPath, uuid)
+	testResponse(t, Elements{getTag("Synapse2", testData)[0]}, "%snode/%s/mysynapses/tag/Synapse2", server.WebAPIPath, uuid)
+
+	// Move an element twice in one batch.
+	moveURL := fmt.Sprintf("%snode/%s/mysynapses/bulk-move", server.WebAPIPath, uuid)
+	moves := `[{"From": [15,27,35], "To": [16,27,35]}, {"From": [16,27,35], "To": [17,27,35]}]`
+	server.TestHTTP(t, "POST", moveURL, strings.NewReader(moves))
+	emap = getAllElements(t, uuid)
+	if _, found := emap[dvid.Point3d{17, 27, 35}.String()]; !found {
+		t.Errorf("expected moved element at (17,27,35), got %v\n", emap)
+	}
+	if _, found := emap[dvid.Point3d{15, 27, 35}.String()]; found {
+		t.Errorf("expected no element at (15,27,35) after move\n")
+	}
+	postsyn := emap[dvid.Point3d{20, 30, 40}.String()]
+	if len(postsyn.Rels) != 1 || !postsyn.Rels[0].To.Equals(dvid.Point3d{17, 27, 35}) {
+		t.Errorf("expected relationship to moved element, got %v\n", postsyn.Rels)
+	}
+	labelURL := fmt.Sprintf("%snode/%s/mysynapses/label/1", server.WebAPIPath, uuid)
+	var labelElems ElementsNR
+	if err := json.Unmarshal(server.TestHTTP(t, "GET", labelURL, nil), &labelElems); err != nil {
+		t.Fatal(err)
+	}
+	if len(labelElems) != 1 || !labelElems[0].Pos.Equals(dvid.Point3d{17, 27, 35}) {
+		t.Errorf("expected label 1 to have moved element, got %v\n", labelElems)
+	}
+
+	// Missing elements should fail the whole batch.
+	server.TestBadHTTP(t, "POST", deleteURL, strings.NewReader(`{"Points": [[17,27,35], [999,999,999]]}`))
+	server.TestBadHTTP(t, "POST", moveURL, strings.NewReader(`[{"From": [17,27,35], "To": [18,27,35]}, {"From": [1,2,3], "To": [4,5,6]}]`))
+	if emap = getAllElements(t, uuid); len(emap) != 5 {
+		t.Errorf("expected no change after failed bulk ops, got %v\n", emap)
+	}
+	if _, found := emap[dvid.Point3d{17, 27, 35}.String()]; !found {
+		t.Errorf("expected element at (17,27,35) after failed bulk ops\n")
+	}
+
+	// Delete by subvolume.
+	r = server.TestHTTP(t, "POST", deleteURL, strings.NewReader(`{"Offset": [0,0,0], "Size": [64,64,64]}`))
+	if err := json.Unmarshal(r, &deleted); err != nil {
+		t.Fatal(err)
+	}
+	if deleted.Deleted != 4 {
+		t.Errorf("expected 4 deleted elements in subvolume, got %d\n", deleted.Deleted)
+	}
+	if emap = getAllElements(t, uuid); len(emap) != 1 {
+		t.Errorf("expected 1 element after subvolume delete, got %v\n", emap)
+	}
+}
+
+func TestPrecomputedID(t *testing.T) {
+	for _, pt := range []dvid.Point3d{{0, 0, 0}, {15, 27, 35}, {-20, 1000, 999999}, {-1 << 20, 1<<20 - 1, 7}} {
+		id, err := PrecomputedID(pt)
+		if err != nil {
+			t.Fatalf("unable to get id for %s: %v\n", pt, err)
+		}
+		if got := PrecomputedIDToPoint(id); !got.Equals(pt) {
+			t.Errorf("expected id %d to decode to %s, got %s\n", id, pt, got)
+		}
+	}
+	if _, err := PrecomputedID(dvid.Point3d{1 << 20, 0, 0}); err == nil {
+		t.Errorf("expected error for position outside precomputed id range\n")
+	}
+}
+
+func TestExport(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+	var config dvid.Config
+	server.CreateTestInstance(t, uuid, "labelmap", "mylabelmap", config)
+	_ = createLabelTestVolume(t, uuid, "mylabelmap")
+	if err := datastore.BlockOnUpdating(uuid, "mylabelmap"); err != nil {
+		t.Fatalf("Error blocking on update for labels: %v\n", err)
+	}
+	server.CreateTestInstance(t, uuid, "annotation", "mysynapses", config)
+	server.CreateTestSync(t, uuid, "mysynapses", "mylabelmap")
+
+	testJSON, err := json.Marshal(testData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := fmt.Sprintf("%snode/%s/mysynapses/elements", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", url, strings.NewReader(string(testJSON)))
+	if err := datastore.BlockOnUpdating(uuid, "mysynapses"); err != nil {
+		t.Fatalf("Error blocking on sync of annotations: %v\n", err)
+	}
+
+	// Test CSV export.
+	url = fmt.Sprintf("%snode/%s/mysynapses/export/csv", server.WebAPIPath, uuid)
+	records, err := csv.NewReader(bytes.NewReader(server.TestHTTP(t, "GET", url, nil))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(testData)+1 {
+		t.Fatalf("expected %d CSV rows, got %d: %v\n", len(testData)+1, len(records), records)
+	}
+	if strings.Join(records[0], ",") != "x,y,z,kind,body,tags,partners,partner_bodies" {
+		t.Errorf("bad CSV header: %v\n", records[0])
+	}
+	var foundPreSyn bool
+	for _, record := range records[1:] {
+		if record[0] != "15" || record[1] != "27" || record[2] != "35" {
+			continue
+		}
+		foundPreSyn = true
+		if record[3] != "PreSyn" || record[4] != "1" {
+			t.Errorf("bad CSV kind or body for element at (15,27,35): %v\n", record)
+		}
+		if record[6] != "20 30 40;14 25 37;33 30 31" || record[7] != "2;3;0" {
+			t.Errorf("bad CSV partners for element at (15,27,35): %v\n", record)
+		}
+	}
+	if !foundPreSyn {
+		t.Errorf("expected CSV row for element at (15,27,35): %v\n", records)
+	}
+	url = fmt.Sprintf("%snode/%s/mysynapses/export/csv?kind=PreSyn", server.WebAPIPath, uuid)
+	records, err = csv.NewReader(bytes.NewReader(server.TestHTTP(t, "GET", url, nil))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Errorf("expected 2 PreSyn rows in CSV export, got %v\n", records)
+	}
+
+	// Test JSON lines export.
+	url = fmt.Sprintf("%snode/%s/mysynapses/export/jsonl?tag=Synapse2", server.WebAPIPath, uuid)
+	dec := json.NewDecoder(bytes.NewReader(server.TestHTTP(t, "GET", url, nil)))
+	var numLines int
+	for dec.More() {
+		var elem ExportElement
+		if err := dec.Decode(&elem); err != nil {
+			t.Fatal(err)
+		}
+		numLines++
+		if elem.Pos.Equals(dvid.Point3d{88, 47, 80}) {
+			if elem.Body != 4 {
+				t.Errorf("expected body 4 for element at (88,47,80), got %d\n", elem.Body)
+			}
+			for _, rel := range elem.Rels {
+				if rel.To.Equals(dvid.Point3d{127, 63, 99}) && rel.ToBody != 3 {
+					t.Errorf("expected relationship body 3 for (127,63,99), got %d\n", rel.ToBody)
+				}
+			}
+		}
+	}
+	if numLines != 4 {
+		t.Errorf("expected 4 Synapse2 elements in JSON lines export, got %d\n", numLines)
+	}
+	server.TestBadHTTP(t, "GET", fmt.Sprintf("%snode/%s/mysynapses/export/xml", server.WebAPIPath, uuid), nil)
+
+	// Test neuroglancer precomputed files.
+	precomputedURL := fmt.Sprintf("%snode/%s/mysynapses/export/precomputed", server.WebAPIPath, uuid)
+	var info struct {
+		AnnotationType string `json:"annotation_type"`
+		Relationships  []struct {
+			ID  string `json:"id"`
+			Key string `json:"key"`
+		} `json:"relationships"`
+		Spatial []struct {
+			Key       string       `json:"key"`
+			GridShape dvid.Point3d `json:"grid_shape"`
+		} `json:"spatial"`
+	}
+	if err := json.Unmarshal(server.TestHTTP(t, "GET", precomputedURL+"/info", nil), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.AnnotationType != "POINT" || len(info.Relationships) != 1 || info.Relationships[0].Key != "rel_body" {
+		t.Errorf("bad precomputed info: %v\n", info)
+	}
+	if len(info.Spatial) != 1 || info.Spatial[0].Key != "spatial/0_0_0" || !info.Spatial[0].GridShape.Equals(dvid.Point3d{2, 2, 2}) {
+		t.Errorf("bad precomputed spatial index: %v\n", info.Spatial)
+	}
+
+	id, err := PrecomputedID(dvid.Point3d{15, 27, 35})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := server.TestHTTP(t, "GET", fmt.Sprintf("%s/by_id/%d", precomputedURL, id), nil)
+	if len(data) != 28 {
+		t.Fatalf("expected 28 bytes for precomputed annotation, got %d\n", len(data))
+	}
+	if x := math.Float32frombits(binary.LittleEndian.Uint32(data[0:4])); x != 15 {
+		t.Errorf("expected x of 15 for precomputed annotation, got %f\n", x)
+	}
+	if data[12] != byte(PreSyn) {
+		t.Errorf("expected PreSyn kind for precomputed annotation, got %d\n", data[12])
+	}
+	if n, body := binary.LittleEndian.Uint32(data[16:20]), binary.LittleEndian.Uint64(data[20:28]); n != 1 || body != 1 {
+		t.Errorf("expected body 1 for precomputed annotation, got %d bodies with first %d\n", n, body)
+	}
+	id, _ = PrecomputedID(dvid.Point3d{1, 2, 3})
+	resp := server.TestHTTPResponse(t, "GET", fmt.Sprintf("%s/by_id/%d", precomputedURL, id), nil)
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("expected not found status for missing precomputed annotation, got %d\n", resp.Code)
+	}
+
+	data = server.TestHTTP(t, "GET", precomputedURL+"/rel_body/3", nil)
+	if n := binary.LittleEndian.Uint64(data[0:8]); n != 2 || len(data) != 8+2*16+2*8 {
+		t.Errorf("expected 2 precomputed annotations for body 3, got %d in %d bytes\n", n, len(data))
+	}
+	data = server.TestHTTP(t, "GET", precomputedURL+"/spatial/0_0_0/1_0_1", nil)
+	if n := binary.LittleEndian.Uint64(data[0:8]); n != 2 || len(data) != 8+2*16+2*8 {
+		t.Errorf("expected 2 precomputed annotations in spatial cell, got %d in %d bytes\n", n, len(data))
+	}
+
+	// Elements of unknown kind have the same kind name in CSV and JSON exports.
+	url = fmt.Sprintf("%snode/%s/mysynapses/elements", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", url, strings.NewReader(`[{"Pos":[5,5,5],"Kind":"Unknown"}]`))
+	records, err = csv.NewReader(bytes.NewReader(server.TestHTTP(t, "GET", fmt.Sprintf("%snode/%s/mysynapses/export/csv", server.WebAPIPath, uuid), nil))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var foundUnknown bool
+	for _, record := range records[1:] {
+		if record[0] == "5" && record[1] == "5" && record[2] == "5" {
+			foundUnknown = true
+			if record[3] != "Unknown" {
+				t.Errorf("expected CSV kind Unknown for element at (5,5,5), got %q\n", record[3])
+			}
+		}
+	}
+	if !foundUnknown {
+		t.Errorf("expected CSV row for element at (5,5,5): %v\n", records)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+	var config dvid.Config
+	server.CreateTestInstance(t, uuid, "annotation", "mysynapses", config)
+	server.CreateTestInstance(t, uuid, "annotation", "othersynapses", config)
+
+	schemaURL := fmt.Sprintf("%snode/%s/mysynapses/schema", server.WebAPIPath, uuid)
+	server.TestBadHTTP(t, "POST", schemaURL, strings.NewReader(`{"Properties": {"conf": {"Type": "float"}}}`))
+	server.TestBadHTTP(t, "POST", schemaURL, strings.NewReader(`{"Relationships": [{"Kind": "Mito", "Rel": "PreSynTo", "Min": 1}]}`))
+	schema := `{
+		"Kinds": ["Bookmark"],
+		"Properties": {
+			"conf": {"Type": "number", "Required": true, "Kinds": ["PreSyn"]},
+			"status": {"Enum": ["ok", "bad"]}
+		},
+		"AdditionalProperties": false,
+		"Relationships": [{"Kind": "PreSyn", "Rel": "PreSynTo", "Min": 1}]
+	}`
+	server.TestHTTP(t, "POST", schemaURL, strings.NewReader(schema))
+	var got Schema
+	if err := json.Unmarshal(server.TestHTTP(t, "GET", schemaURL, nil), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Kinds) != 1 || got.Kinds[0] != "Bookmark" || len(got.Properties) != 2 || len(got.Relationships) != 1 {
+		t.Errorf("bad schema returned: %v\n", got)
+	}
+
+	// Post valid elements including a custom kind.
+	url := fmt.Sprintf("%snode/%s/mysynapses/elements", server.WebAPIPath, uuid)
+	valid := `[
+		{"Pos": [10,10,10], "Kind": "PreSyn", "Prop": {"conf": "0.9"}, "Rels": [{"Rel": "PreSynTo", "To": [12,10,10]}]},
+		{"Pos": [12,10,10], "Kind": "PostSyn", "Rels": [{"Rel": "PostSynTo", "To": [10,10,10]}]},
+		{"Pos": [20,20,20], "Kind": "Bookmark", "Prop": {"status": "ok"}}
+	]`
+	server.TestHTTP(t, "POST", url, strings.NewReader(valid))
+	elemsURL := fmt.Sprintf("%snode/%s/mysynapses/elements/64_64_64/0_0_0", server.WebAPIPath, uuid)
+	var elems Elements
+	if err := json.Unmarshal(server.TestHTTP(t, "GET", elemsURL, nil), &elems); err != nil {
+		t.Fatal(err)
+	}
+	var foundBookmark bool
+	for _, elem := range elems {
+		if elem.Pos.Equals(dvid.Point3d{20, 20, 20}) {
+			foundBookmark = elem.Kind.String() == "Bookmark"
+		}
+	}
+	if len(elems) != 3 || !foundBookmark {
+		t.Errorf("expected 3 elements with a Bookmark, got %v\n", elems)
+	}
+
+	// Post invalid elements and check all violations are reported.
+	invalid := `[
+		{"Pos": [30,10,10], "Kind": "PreSyn", "Prop": {"conf": "high"}},
+		{"Pos": [31,10,10], "Kind": "PostSyn", "Prop": {"conf": "0.5", "foo": "bar"}},
+		{"Pos": [32,10,10], "Kind": "Bookmark", "Prop": {"status": "maybe"}},
+		{"Pos": [33,10,10], "Kind": "PreSyn", "Rels": [{"Rel": "PreSynTo", "To": [12,10,10]}]}
+	]`
+	resp := server.TestHTTPResponse(t, "POST", url, strings.NewReader(invalid))
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected bad request for invalid elements, got status %d\n", resp.Code)
+	}
+	body := resp.Body.String()
+	for _, msg := range []string{
+		`6 schema violations`,
+		`element at (30,10,10): property "conf" value "high" is not a number`,
+		`element at (30,10,10): has 0 PreSynTo relationships but needs at least 1`,
+		`element at (31,10,10): property "conf" is not allowed for kind PostSyn`,
+		`element at (31,10,10): property "foo" is not allowed`,
+		`element at (32,10,10): property "status" value "maybe" is not one of [ok, bad]`,
+		`element at (33,10,10): required property "conf" is missing`,
+	} {
+		if !strings.Contains(body, msg) {
+			t.Errorf("expected %q in response:\n%s\n", msg, body)
+		}
+	}
+	if err := json.Unmarshal(server.TestHTTP(t, "GET", elemsURL, nil), &elems); err != nil {
+		t.Fatal(err)
+	}
+	if len(elems) != 3 {
+		t.Errorf("expected no elements stored after invalid post, got %v\n", elems)
+	}
+
+	// Custom kinds are only allowed in instances whose schema declares them.
+	otherURL := fmt.Sprintf("%snode/%s/othersynapses/elements", server.WebAPIPath, uuid)
+	server.TestBadHTTP(t, "POST", otherURL, strings.NewReader(`[{"Pos": [20,20,20], "Kind": "Bookmark"}]`))
+	server.TestBadHTTP(t, "POST", otherURL, strings.NewReader(`[{"Pos": [20,20,20], "Kind": "Bogus"}]`))
+
+	// Remove the schema.
+	server.TestHTTP(t, "DELETE", schemaURL, nil)
+	if string(server.TestHTTP(t, "GET", schemaURL, nil)) != "null" {
+		t.Errorf("expected no schema after delete\n")
+	}
+	server.TestHTTP(t, "POST", url, strings.NewReader(`[{"Pos": [30,10,10], "Kind": "PreSyn", "Prop": {"conf": "high"}}]`))
+	server.TestBadHTTP(t, "POST", url, strings.NewReader(`[{"Pos": [32,10,10], "Kind": "Bookmark"}]`))
+
+	// Kinds removed from the schema are still registered on load so stored elements are readable.
+	d, err := GetByUUIDName(uuid, "mysynapses")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := d.GobEncode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded Data
+	if err := loaded.GobDecode(encoded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Schema != nil || len(loaded.CustomKinds) != 1 || loaded.CustomKinds[0] != "Bookmark" {
+		t.Errorf("expected persisted Bookmark kind without schema, got schema %v, kinds %v\n", loaded.Schema, loaded.CustomKinds)
+	}
+	if err := json.Unmarshal(server.TestHTTP(t, "GET", elemsURL, nil), &elems); err != nil {
+		t.Fatal(err)
+	}
+	if len(elems) != 4 {
+		t.Errorf("expected 4 elements including Bookmark after schema removal, got %v\n", elems)
+	}
+}
+
+func getConsistencyReport(t *testing.T, method string, uuid dvid.UUID) ConsistencyReport {
+	url := fmt.Sprintf("%snode/%s/mysynapses/check", server.WebAPIPath, uuid)
+	var report ConsistencyReport
+	if err := json.Unmarshal(server.TestHTTP(t, method, url, nil), &report); err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestConsistencyCheck(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, v := initTestRepo()
+	var config dvid.Config
+	server.CreateTestInstance(t, uuid, "labelmap", "mylabelmap", config)
+	_ = createLabelTestVolume(t, uuid, "mylabelmap")
+	if err := datastore.BlockOnUpdating(uuid, "mylabelmap"); err != nil {
+		t.Fatalf("Error blocking on update for labels: %v\n", err)
+	}
+	server.CreateTestInstance(t, uuid, "annotation", "mysynapses", config)
+	server.CreateTestSync(t, uuid, "mysynapses", "mylabelmap")
+
+	testJSON, err := json.Marshal(testData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := fmt.Sprintf("%snode/%s/mysynapses/elements", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", url, strings.NewReader(string(testJSON)))
+
+	report := getConsistencyReport(t, "GET", uuid)
+	if report.NumElements != len(testData) || !report.LabelsChecked {
+		t.Errorf("expected %d elements checked with labels, got %v\n", len(testData), report)
+	}
+	if len(report.Labels) != 0 || len(report.Tags) != 0 || len(report.Relationships) != 0 {
+		t.Errorf("expected no inconsistencies, got %v\n", report)
+	}
+
+	// Corrupt the label and tag indices and add a dangling relationship.
+	d, err := GetByUUIDName(uuid, "mysynapses")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := datastore.NewVersionedCtx(d, v)
+	if err := putElements(ctx, NewLabelTKey(1), ElementsNR{}); err != nil {
+		t.Fatal(err)
+	}
+	label2 := ElementsNR{
+		{Pos: dvid.Point3d{20, 30, 40}, Kind: PostSyn},
+		{Pos: dvid.Point3d{99, 99, 99}, Kind: PostSyn},
+	}
+	if err := putElements(ctx, NewLabelTKey(2), label2); err != nil {
+		t.Fatal(err)
+	}
+	tk, err := NewTagTKey("Synapse2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := putElements(ctx, tk, ElementsNR{}); err != nil {
+		t.Fatal(err)
+	}
+	dangling := `[{"Pos": [1,1,1], "Kind": "PreSyn", "Rels": [{"Rel": "PreSynTo", "To": [2,2,2]}]}]`
+	server.TestHTTP(t, "POST", url, strings.NewReader(dangling))
+
+	report = getConsistencyReport(t, "GET", uuid)
+	if len(report.Labels) != 2 {
+		t.Fatalf("expected 2 label inconsistencies, got %v\n", report.Labels)
+	}
+	if inc := report.Labels[0]; inc.Label != 1 || len(inc.Missing) != 1 || !inc.Missing[0].Equals(dvid.Point3d{15, 27, 35}) {
+		t.Errorf("bad label 1 inconsistency: %v\n", inc)
+	}
+	if inc := report.Labels[1]; inc.Label != 2 || len(inc.Extra) != 1 || !inc.Extra[0].Equals(dvid.Point3d{99, 99, 99}) ||
+		len(inc.Stale) != 1 || !inc.Stale[0].Equals(dvid.Point3d{20, 30, 40}) || len(inc.Missing) != 0 {
+		t.Errorf("bad label 2 inconsistency: %v\n", inc)
+	}
+	if len(report.Tags) != 1 || report.Tags[0].Tag != "Synapse2" || len(report.Tags[0].Missing) != 4 {
+		t.Errorf("bad tag inconsistencies: %v\n", report.Tags)
+	}
+	if len(report.Relationships) != 1 || report.Relationships[0].Problem != "missing target" ||
+		!report.Relationships[0].To.Equals(dvid.Point3d{2, 2, 2}) {
+		t.Errorf("bad relationship inconsistencies: %v\n", report.Relationships)
+	}
+
+	// Repair and verify.
+	report = getConsistencyReport(t, "POST", uuid)
+	if len(report.RepairedLabels) != 2 || len(report.RepairedTags) != 1 {
+		t.Errorf("expected 2 labels and 1 tag repaired, got %v\n", report)
+	}
+	report = getConsistencyReport(t, "GET", uuid)
+	if len(report.Labels) != 0 || len(report.Tags) != 0 || len(report.Relationships) != 1 {
+		t.Errorf("expected only relationship inconsistency after repair, got %v\n", report)
+	}
+	testResponseLabel(t, expectedLabel1, "%snode/%s/mysynapses/label/1?relationships=true", server.WebAPIPath, uuid)
+	testResponseLabel(t, expectedLabel2, "%snode/%s/mysynapses/label/2?relationships=true", server.WebAPIPath, uuid)
+	testResponse(t, getTag("Synapse2", testData), "%snode/%s/mysynapses/tag/Synapse2?relationships=true", server.WebAPIPath, uuid)
+}
+
 func testResponse(t *testing.T, expected Elements, template string, args ...interface{}) {
 	url := fmt.Sprintf(template, args...)
 	returnValue := server.TestHTTP(t, "GET", url, nil)
@@ -1205,6 +1996,55 @@ func TestOldLabels(t *testing.T) {
 	testLabels(t, uuid, "labels", "bodies")
 }
 
+func TestMigrateRepointsSync(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, v := initTestRepo()
+	var config dvid.Config
+	config.Set("BlockSize", "64,64,64")
+	server.CreateTestInstance(t, uuid, "labelblk", "legacy", config)
+	server.CreateTestInstance(t, uuid, "labelmap", "labels", config)
+	_ = createLabelTestVolume(t, uuid, "legacy")
+
+	server.CreateTestInstance(t, uuid, "annotation", "mysynapses", config)
+	server.CreateTestSync(t, uuid, "mysynapses", "legacy")
+
+	legacy, err := datastore.GetDataByUUIDName(uuid, "legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lm, err := labelmap.GetByUUIDName(uuid, "labels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := GetByUUIDName(uuid, "mysynapses")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := data.SyncedData()[legacy.DataUUID()]; !found || len(data.SyncedData()) != 1 {
+		t.Fatalf("Expected sync to labelblk %q only before migration, got %v\n", legacy.DataName(), data.SyncedData())
+	}
+
+	if err := lm.MigrateLegacy(uuid, v, "legacy", ""); err != nil {
+		t.Fatalf("unable to migrate labelblk: %v\n", err)
+	}
+	if err := downres.BlockOnUpdating(uuid, "labels"); err != nil {
+		t.Fatalf("Error blocking on update for labels: %v\n", err)
+	}
+
+	data, err = GetByUUIDName(uuid, "mysynapses")
+	if err != nil {
+		t.Fatal(err)
+	}
+	syncs := data.SyncedData()
+	if _, found := syncs[lm.DataUUID()]; !found || len(syncs) != 1 {
+		t.Errorf("Expected sync to labelmap %q only after migration, got %v\n", lm.DataName(), syncs)
+	}
+}
+
 func TestLabels(t *testing.T) {
 	if err := server.OpenTest(); err != nil {
 		t.Fatalf("can't open test server: %v\n", err)
@@ -1481,6 +2321,155 @@ func TestLabelsReload(t *testing.T) {
 	testLabelsReload(t, uuid, "labels", "labels")
 }
 
+var connectivityData = Elements{
+	{
+		ElementNR{Pos: dvid.Point3d{10, 10, 10}, Kind: PreSyn},
+		[]Relationship{{Rel: PreSynTo, To: dvid.Point3d{40, 10, 10}}, {Rel: PreSynTo, To: dvid.Point3d{70, 10, 10}}},
+	},
+	{
+		ElementNR{Pos: dvid.Point3d{12, 12, 12}, Kind: PreSyn},
+		[]Relationship{{Rel: PreSynTo, To: dvid.Point3d{42, 12, 12}}},
+	},
+	{
+		ElementNR{Pos: dvid.Point3d{45, 20, 20}, Kind: PreSyn},
+		[]Relationship{{Rel: PreSynTo, To: dvid.Point3d{75, 20, 20}}, {Rel: PreSynTo, To: dvid.Point3d{15, 20, 20}}},
+	},
+	{
+		ElementNR{Pos: dvid.Point3d{40, 10, 10}, Kind: PostSyn},
+		[]Relationship{{Rel: PostSynTo, To: dvid.Point3d{10, 10, 10}}},
+	},
+	{
+		ElementNR{Pos: dvid.Point3d{70, 10, 10}, Kind: PostSyn},
+		[]Relationship{{Rel: PostSynTo, To: dvid.Point3d{10, 10, 10}}},
+	},
+	{
+		ElementNR{Pos: dvid.Point3d{42, 12, 12}, Kind: PostSyn},
+		[]Relationship{{Rel: PostSynTo, To: dvid.Point3d{12, 12, 12}}},
+	},
+	{
+		ElementNR{Pos: dvid.Point3d{75, 20, 20}, Kind: PostSyn},
+		[]Relationship{{Rel: PostSynTo, To: dvid.Point3d{45, 20, 20}}},
+	},
+	{
+		ElementNR{Pos: dvid.Point3d{15, 20, 20}, Kind: PostSyn},
+		[]Relationship{{Rel: PostSynTo, To: dvid.Point3d{45, 20, 20}}},
+	},
+}
+
+func getPartnersHTTP(t *testing.T, uuid dvid.UUID, label uint64, query string) Partners {
+	url := fmt.Sprintf("%snode/%s/mysynapses/partners/%d%s", server.WebAPIPath, uuid, label, query)
+	r := server.TestHTTP(t, "GET", url, nil)
+	var partners Partners
+	if err := json.Unmarshal(r, &partners); err != nil {
+		t.Fatalf("bad partners response for label %d: %v\n", label, err)
+	}
+	return partners
+}
+
+func getConnectivityHTTP(t *testing.T, uuid dvid.UUID, bodies string) Connections {
+	url := fmt.Sprintf("%snode/%s/mysynapses/connectivity", server.WebAPIPath, uuid)
+	r := server.TestHTTP(t, "GET", url, strings.NewReader(bodies))
+	var conns Connections
+	if err := json.Unmarshal(r, &conns); err != nil {
+		t.Fatalf("bad connectivity response for bodies %s: %v\n", bodies, err)
+	}
+	return conns
+}
+
+func TestConnectivity(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+	var config dvid.Config
+	config.Set("BlockSize", "32,32,32")
+	server.CreateTestInstance(t, uuid, "labelmap", "mylabelmap", config)
+
+	// Three bodies along x, each one block in size.
+	volume := newTestVolume(96, 32, 32)
+	var spans [3]dvid.Spans
+	for z := int32(0); z < 32; z++ {
+		for y := int32(0); y < 32; y++ {
+			for i := int32(0); i < 3; i++ {
+				spans[i] = append(spans[i], dvid.Span{z, y, i * 32, i*32 + 31})
+			}
+		}
+	}
+	for i := 0; i < 3; i++ {
+		volume.add(testBody{voxelSpans: spans[i]}, uint64(i+1))
+	}
+	volume.put(t, uuid, "mylabelmap")
+	if err := datastore.BlockOnUpdating(uuid, "mylabelmap"); err != nil {
+		t.Fatalf("Error blocking on update for labels: %v\n", err)
+	}
+
+	server.CreateTestInstance(t, uuid, "annotation", "mysynapses", config)
+	server.CreateTestSync(t, uuid, "mysynapses", "mylabelmap")
+
+	testJSON, err := json.Marshal(connectivityData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := fmt.Sprintf("%snode/%s/mysynapses/elements", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", url, strings.NewReader(string(testJSON)))
+
+	partners := getPartnersHTTP(t, uuid, 1, "")
+	expectedOut := PartnerWeights{{Partner: 2, Weight: 2}, {Partner: 3, Weight: 1}}
+	expectedIn := PartnerWeights{{Partner: 2, Weight: 1}}
+	if !reflect.DeepEqual(partners.Outputs, expectedOut) || !reflect.DeepEqual(partners.Inputs, expectedIn) {
+		t.Errorf("expected label 1 outputs %v and inputs %v, got %v\n", expectedOut, expectedIn, partners)
+	}
+	partners = getPartnersHTTP(t, uuid, 1, "?n=1")
+	if len(partners.Outputs) != 1 || partners.Outputs[0].Partner != 2 {
+		t.Errorf("expected only top output partner 2 for label 1, got %v\n", partners)
+	}
+
+	conns := getConnectivityHTTP(t, uuid, "[1, 2, 3]")
+	expectedConns := Connections{{1, 2, 2}, {1, 3, 1}, {2, 1, 1}, {2, 3, 1}}
+	if !reflect.DeepEqual(conns, expectedConns) {
+		t.Errorf("expected connectivity %v, got %v\n", expectedConns, conns)
+	}
+
+	// Restrict to connections with post-synaptic elements in the first block.
+	server.CreateTestInstance(t, uuid, "roi", "myroi", dvid.Config{})
+	roiURL := fmt.Sprintf("%snode/%s/myroi/roi", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", roiURL, strings.NewReader("[[0, 0, 0, 0]]"))
+	partners = getPartnersHTTP(t, uuid, 1, "?roi=myroi")
+	if len(partners.Outputs) != 0 || !reflect.DeepEqual(partners.Inputs, expectedIn) {
+		t.Errorf("expected label 1 in ROI to have no outputs and inputs %v, got %v\n", expectedIn, partners)
+	}
+
+	// Merging 3 into 2 should update the cached partners of label 1.
+	mergeJSON("[2, 3]").send(t, uuid, "mylabelmap")
+	if err := datastore.BlockOnUpdating(uuid, "mylabelmap"); err != nil {
+		t.Fatalf("Error blocking on update for labels: %v\n", err)
+	}
+	if err := datastore.BlockOnUpdating(uuid, "mysynapses"); err != nil {
+		t.Fatalf("Error blocking on sync of synapses: %v\n", err)
+	}
+	partners = getPartnersHTTP(t, uuid, 1, "")
+	expectedOut = PartnerWeights{{Partner: 2, Weight: 3}}
+	if !reflect.DeepEqual(partners.Outputs, expectedOut) || !reflect.DeepEqual(partners.Inputs, expectedIn) {
+		t.Errorf("after merge expected label 1 outputs %v and inputs %v, got %v\n", expectedOut, expectedIn, partners)
+	}
+	conns = getConnectivityHTTP(t, uuid, "[1, 2]")
+	expectedConns = Connections{{1, 2, 3}, {2, 1, 1}, {2, 2, 1}}
+	if !reflect.DeepEqual(conns, expectedConns) {
+		t.Errorf("after merge expected connectivity %v, got %v\n", expectedConns, conns)
+	}
+
+	// Deleting a post-synaptic element should remove the connection from label 1.
+	delURL := fmt.Sprintf("%snode/%s/mysynapses/element/42_12_12", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "DELETE", delURL, nil)
+	partners = getPartnersHTTP(t, uuid, 1, "")
+	expectedOut = PartnerWeights{{Partner: 2, Weight: 2}}
+	if !reflect.DeepEqual(partners.Outputs, expectedOut) {
+		t.Errorf("after delete expected label 1 outputs %v, got %v\n", expectedOut, partners.Outputs)
+	}
+}
+
 // A single label block within the volume
 type testBody struct {
 	label        uint64
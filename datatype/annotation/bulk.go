@@ -0,0 +1,542 @@
+/*
+	This file supports bulk deletion and movement of annotation elements in a single batch.
+*/
+
+package annotation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// DeleteSelection specifies the elements to delete in a bulk delete.  Elements must match
+// all given criteria, and at least one criterion must be given.
+type DeleteSelection struct {
+	Points []dvid.Point3d `json:",omitempty"` // explicit element positions, all of which must exist
+	Offset *dvid.Point3d  `json:",omitempty"` // offset of subvolume in voxels
+	Size   *dvid.Point3d  `json:",omitempty"` // size of subvolume in voxels
+	Tag    Tag            `json:",omitempty"`
+	Kinds  []string       `json:",omitempty"` // e.g., "PreSyn", "PostSyn"
+}
+
+// ElementMove is a move of an element from one position to another.
+type ElementMove struct {
+	From dvid.Point3d
+	To   dvid.Point3d
+}
+
+// bulkMod accumulates modifications of block, label, and tag elements so many element
+// mutations can be written in a single batch.  Each key is read at most once.
+// This assumes outer locking.
+type bulkMod struct {
+	d   *Data
+	ctx *datastore.VersionedCtx
+
+	blocks map[dvid.IZYXString]Elements
+	labels map[uint64]ElementsNR
+	tags   map[Tag]ElementsNR
+
+	// label at each point of interest keyed by Point3d.MapKey()
+	ptLabels map[string]uint64
+
+	delta   DeltaModifyElements
+	propDel ElementsNR
+	propAdd ElementsNR
+	touched []dvid.Point3d // points whose labels need partner index invalidation
+}
+
+func (d *Data) newBulkMod(ctx *datastore.VersionedCtx, pts []dvid.Point3d) (*bulkMod, error) {
+	m := &bulkMod{
+		d:        d,
+		ctx:      ctx,
+		blocks:   make(map[dvid.IZYXString]Elements),
+		labels:   make(map[uint64]ElementsNR),
+		tags:     make(map[Tag]ElementsNR),
+		ptLabels: make(map[string]uint64),
+	}
+	if d.getSyncedLabels() != nil && len(pts) != 0 {
+		lbls, err := d.getLabelsAtPoints(ctx.VersionID(), pts)
+		if err != nil {
+			return nil, err
+		}
+		for i, pt := range pts {
+			m.ptLabels[pt.MapKey()] = lbls[i]
+		}
+	}
+	return m, nil
+}
+
+func (m *bulkMod) block(pt dvid.Point3d) (dvid.IZYXString, Elements, error) {
+	izyx := pt.ToBlockIZYXString(m.d.blockSize())
+	elems, found := m.blocks[izyx]
+	if found {
+		return izyx, elems, nil
+	}
+	bcoord, err := izyx.ToChunkPoint3d()
+	if err != nil {
+		return izyx, nil, err
+	}
+	if elems, err = getElements(m.ctx, NewBlockTKey(bcoord)); err != nil {
+		return izyx, nil, err
+	}
+	m.blocks[izyx] = elems
+	return izyx, elems, nil
+}
+
+func (m *bulkMod) label(label uint64) (ElementsNR, error) {
+	elems, found := m.labels[label]
+	if found {
+		return elems, nil
+	}
+	elems, err := getElementsNR(m.ctx, NewLabelTKey(label))
+	if err != nil {
+		return nil, fmt.Errorf("err getting elements for label %d: %v", label, err)
+	}
+	m.labels[label] = elems
+	return elems, nil
+}
+
+func (m *bulkMod) tag(tag Tag) (ElementsNR, error) {
+	elems, found := m.tags[tag]
+	if found {
+		return elems, nil
+	}
+	tk, err := NewTagTKey(tag)
+	if err != nil {
+		return nil, err
+	}
+	if elems, err = getElementsNR(m.ctx, tk); err != nil {
+		return nil, err
+	}
+	m.tags[tag] = elems
+	return elems, nil
+}
+
+// deleteElement deletes the element at the given point and all references to it.
+func (m *bulkMod) deleteElement(pt dvid.Point3d) error {
+	izyx, elems, err := m.block(pt)
+	if err != nil {
+		return err
+	}
+	deleted, _ := elems.delete(pt)
+	if deleted == nil {
+		return fmt.Errorf("did not find element %s in datastore", pt)
+	}
+	m.blocks[izyx] = elems
+
+	// Delete in label
+	if label := m.ptLabels[pt.MapKey()]; label != 0 {
+		labelElems, err := m.label(label)
+		if err != nil {
+			return err
+		}
+		if _, changed := labelElems.delete(pt); changed {
+			m.labels[label] = labelElems
+			m.delta.Del = append(m.delta.Del, ElementPos{Label: label, Kind: deleted.Kind, Pos: pt})
+		}
+	}
+
+	// Delete in tags
+	for _, tag := range deleted.Tags {
+		tagElems, err := m.tag(tag)
+		if err != nil {
+			return err
+		}
+		tagElems.delete(pt)
+		m.tags[tag] = tagElems
+	}
+
+	// Delete any reference in relationships
+	m.touched = append(m.touched, pt)
+	for _, rel := range deleted.Rels {
+		relIzyx, relElems, err := m.block(rel.To)
+		if err != nil {
+			return err
+		}
+		relElems.deleteRel(pt)
+		m.blocks[relIzyx] = relElems
+		m.touched = append(m.touched, rel.To)
+	}
+
+	m.propDel = append(m.propDel, deleted.ElementNR)
+	return nil
+}
+
+// moveElement moves the element at one point to another, including all references to it.
+func (m *bulkMod) moveElement(from, to dvid.Point3d) error {
+	fromIzyx, fromElems, err := m.block(from)
+	if err != nil {
+		return err
+	}
+	toIzyx := to.ToBlockIZYXString(m.d.blockSize())
+	deleteElement := fromIzyx != toIzyx
+	moved, _ := fromElems.move(from, to, deleteElement)
+	if moved == nil {
+		return fmt.Errorf("did not find moved element %s in datastore", from)
+	}
+	m.blocks[fromIzyx] = fromElems
+	if deleteElement {
+		_, toElems, err := m.block(to)
+		if err != nil {
+			return err
+		}
+		toElems.add(Elements{*moved})
+		m.blocks[toIzyx] = toElems
+	}
+
+	// Move in labels
+	oldLabel, newLabel := m.ptLabels[from.MapKey()], m.ptLabels[to.MapKey()]
+	if oldLabel != 0 {
+		labelElems, err := m.label(oldLabel)
+		if err != nil {
+			return err
+		}
+		if oldLabel == newLabel {
+			labelElems.move(from, to, false)
+		} else if _, changed := labelElems.delete(from); changed {
+			m.delta.Del = append(m.delta.Del, ElementPos{Label: oldLabel, Kind: moved.Kind, Pos: from})
+		}
+		m.labels[oldLabel] = labelElems
+	}
+	if newLabel != 0 && newLabel != oldLabel {
+		labelElems, err := m.label(newLabel)
+		if err != nil {
+			return err
+		}
+		labelElems.add(ElementsNR{moved.ElementNR})
+		m.labels[newLabel] = labelElems
+		m.delta.Add = append(m.delta.Add, ElementPos{Label: newLabel, Kind: moved.Kind, Pos: to})
+	}
+
+	// Move in tags
+	for _, tag := range moved.Tags {
+		tagElems, err := m.tag(tag)
+		if err != nil {
+			return err
+		}
+		tagElems.move(from, to, false)
+		m.tags[tag] = tagElems
+	}
+
+	// Move any reference in relationships
+	m.touched = append(m.touched, from, to)
+	for _, rel := range moved.Rels {
+		relIzyx, relElems, err := m.block(rel.To)
+		if err != nil {
+			return err
+		}
+		relElems.move(from, to, false)
+		m.blocks[relIzyx] = relElems
+		m.touched = append(m.touched, rel.To)
+	}
+
+	movedFrom := moved.ElementNR
+	movedFrom.Pos = from
+	m.propDel = append(m.propDel, movedFrom)
+	m.propAdd = append(m.propAdd, moved.ElementNR)
+	return nil
+}
+
+// write commits all accumulated modifications in a single batch, then notifies subscribers of
+// label changes and invalidates any affected partner indices.
+func (m *bulkMod) write(batcher storage.KeyValueBatcher) error {
+	batch := batcher.NewBatch(m.ctx)
+	for izyx, elems := range m.blocks {
+		bcoord, err := izyx.ToChunkPoint3d()
+		if err != nil {
+			return err
+		}
+		if err := putBatchElements(batch, NewBlockTKey(bcoord), elems); err != nil {
+			return err
+		}
+	}
+	for label, elems := range m.labels {
+		if len(elems) == 0 {
+			batch.Delete(NewLabelTKey(label))
+		} else if err := putBatchElements(batch, NewLabelTKey(label), elems); err != nil {
+			return err
+		}
+	}
+	for tag, elems := range m.tags {
+		tk, err := NewTagTKey(tag)
+		if err != nil {
+			return err
+		}
+		if err := putBatchElements(batch, tk, elems); err != nil {
+			return err
+		}
+	}
+	m.d.deleteBatchProps(batch, m.propDel)
+	if err := m.d.putBatchProps(batch, m.propAdd); err != nil {
+		return err
+	}
+	if err := batch.Commit(); err != nil {
+		return fmt.Errorf("bad commit in bulk modification of annotations %q: %v", m.d.DataName(), err)
+	}
+
+	if len(m.delta.Add) != 0 || len(m.delta.Del) != 0 {
+		evt := datastore.SyncEvent{Data: m.d.DataUUID(), Event: ModifyElementsEvent}
+		msg := datastore.SyncMessage{Event: ModifyElementsEvent, Version: m.ctx.VersionID(), Delta: m.delta}
+		if err := datastore.NotifySubscribers(evt, msg); err != nil {
+			dvid.Criticalf("unable to notify subscribers of event %s: %v\n", evt, err)
+		}
+	}
+	return m.d.deletePartners(m.ctx, batcher, make(map[uint64]struct{}), m.touched)
+}
+
+// selectElements returns the positions of elements matching the delete selection.
+func (d *Data) selectElements(ctx *datastore.VersionedCtx, sel DeleteSelection) ([]dvid.Point3d, error) {
+	filter, err := NewElementFilter("", string(sel.Tag))
+	if err != nil {
+		return nil, err
+	}
+	if len(sel.Kinds) != 0 {
+		filter.Kinds = make(map[ElementType]struct{}, len(sel.Kinds))
+		for _, kindStr := range sel.Kinds {
+			kind := StringToElementType(kindStr)
+			if kind == UnknownElem && kindStr != "Unknown" {
+				return nil, fmt.Errorf("unknown element kind %q", kindStr)
+			}
+			filter.Kinds[kind] = struct{}{}
+		}
+	}
+	var ext *dvid.Extents3d
+	if sel.Offset != nil || sel.Size != nil {
+		if sel.Offset == nil || sel.Size == nil {
+			return nil, fmt.Errorf("both Offset and Size must be given to select a subvolume")
+		}
+		endPt := dvid.Point3d{sel.Offset[0] + sel.Size[0] - 1, sel.Offset[1] + sel.Size[1] - 1, sel.Offset[2] + sel.Size[2] - 1}
+		ext = &dvid.Extents3d{MinPoint: *sel.Offset, MaxPoint: endPt}
+	}
+	matches := func(elem Element) bool {
+		return filter.matches(elem) && (ext == nil || ext.VoxelWithin(elem.Pos))
+	}
+
+	var pts []dvid.Point3d
+	switch {
+	case len(sel.Points) != 0:
+		blockSize := d.blockSize()
+		blockElems := make(map[dvid.IZYXString]Elements)
+		seen := make(map[string]struct{}, len(sel.Points))
+		for _, pt := range sel.Points {
+			if _, found := seen[pt.MapKey()]; found {
+				continue
+			}
+			seen[pt.MapKey()] = struct{}{}
+			izyx := pt.ToBlockIZYXString(blockSize)
+			elems, found := blockElems[izyx]
+			if !found {
+				bcoord, err := izyx.ToChunkPoint3d()
+				if err != nil {
+					return nil, err
+				}
+				if elems, err = getElements(ctx, NewBlockTKey(bcoord)); err != nil {
+					return nil, err
+				}
+				blockElems[izyx] = elems
+			}
+			var elem *Element
+			for i := range elems {
+				if pt.Equals(elems[i].Pos) {
+					elem = &elems[i]
+					break
+				}
+			}
+			if elem == nil {
+				return nil, fmt.Errorf("did not find element %s in datastore", pt)
+			}
+			if matches(*elem) {
+				pts = append(pts, pt)
+			}
+		}
+	case ext != nil:
+		minBlock := ext.MinPoint.Chunk(d.blockSize()).(dvid.ChunkPoint3d)
+		maxBlock := ext.MaxPoint.Chunk(d.blockSize()).(dvid.ChunkPoint3d)
+		store, err := datastore.GetOrderedKeyValueDB(d)
+		if err != nil {
+			return nil, err
+		}
+		for z := minBlock[2]; z <= maxBlock[2]; z++ {
+			for y := minBlock[1]; y <= maxBlock[1]; y++ {
+				err := processBlockRow(ctx, store, y, z, minBlock[0], maxBlock[0], func(elem Element) {
+					if matches(elem) {
+						pts = append(pts, elem.Pos)
+					}
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case sel.Tag != "":
+		tk, err := NewTagTKey(sel.Tag)
+		if err != nil {
+			return nil, err
+		}
+		elems, err := getElements(ctx, tk)
+		if err != nil {
+			return nil, err
+		}
+		for _, elem := range elems {
+			if matches(elem) {
+				pts = append(pts, elem.Pos)
+			}
+		}
+	case filter.Kinds != nil:
+		store, err := datastore.GetOrderedKeyValueDB(d)
+		if err != nil {
+			return nil, err
+		}
+		err = store.ProcessRange(ctx, storage.MinTKey(keyBlock), storage.MaxTKey(keyBlock), nil, func(chunk *storage.Chunk) error {
+			if chunk.V == nil {
+				return nil
+			}
+			var elems Elements
+			if err := json.Unmarshal(chunk.V, &elems); err != nil {
+				return err
+			}
+			for _, elem := range elems {
+				if matches(elem) {
+					pts = append(pts, elem.Pos)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("bulk delete requires Points, a subvolume, Tag, or Kinds")
+	}
+	return pts, nil
+}
+
+// DeleteElements deletes all elements matching the JSON-encoded DeleteSelection, updating all
+// denormalizations in a single batch.  It returns the number of deleted elements.
+func (d *Data) DeleteElements(ctx *datastore.VersionedCtx, r io.Reader, kafkaOff bool) (int, error) {
+	jsonBytes, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	var sel DeleteSelection
+	if err := json.Unmarshal(jsonBytes, &sel); err != nil {
+		return 0, fmt.Errorf("bad bulk delete JSON: %v", err)
+	}
+	batcher, err := d.getBatcher()
+	if err != nil {
+		return 0, err
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	pts, err := d.selectElements(ctx, sel)
+	if err != nil {
+		return 0, err
+	}
+	if len(pts) == 0 {
+		return 0, nil
+	}
+	m, err := d.newBulkMod(ctx, pts)
+	if err != nil {
+		return 0, err
+	}
+	for _, pt := range pts {
+		if err := m.deleteElement(pt); err != nil {
+			return 0, err
+		}
+	}
+	if err := m.write(batcher); err != nil {
+		return 0, err
+	}
+
+	if !kafkaOff {
+		versionuuid, _ := datastore.UUIDFromVersion(ctx.VersionID())
+		msginfo := map[string]interface{}{
+			"Action": "element-bulk-delete",
+			"Points": pts,
+			"UUID":   string(versionuuid),
+		}
+		jsonmsg, err := json.Marshal(msginfo)
+		if err != nil {
+			dvid.Errorf("error marshaling JSON for annotations %q bulk delete: %v\n", d.DataName(), err)
+		} else if err = d.ProduceKafkaMsg(jsonmsg); err != nil {
+			dvid.Errorf("error on sending bulk delete op to kafka: %v\n", err)
+		}
+	}
+	return len(pts), nil
+}
+
+// MoveElements performs the JSON-encoded list of element moves in order, updating all
+// denormalizations in a single batch.  If any element is not found, no moves are made.
+func (d *Data) MoveElements(ctx *datastore.VersionedCtx, r io.Reader, kafkaOff bool) (int, error) {
+	jsonBytes, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	var moves []ElementMove
+	if err := json.Unmarshal(jsonBytes, &moves); err != nil {
+		return 0, fmt.Errorf("bad bulk move JSON: %v", err)
+	}
+	if len(moves) == 0 {
+		return 0, nil
+	}
+	batcher, err := d.getBatcher()
+	if err != nil {
+		return 0, err
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	pts := make([]dvid.Point3d, 0, 2*len(moves))
+	for _, move := range moves {
+		pts = append(pts, move.From, move.To)
+	}
+	m, err := d.newBulkMod(ctx, pts)
+	if err != nil {
+		return 0, err
+	}
+	for _, move := range moves {
+		if err := m.moveElement(move.From, move.To); err != nil {
+			return 0, err
+		}
+	}
+	if err := m.write(batcher); err != nil {
+		return 0, err
+	}
+
+	if !kafkaOff {
+		versionuuid, _ := datastore.UUIDFromVersion(ctx.VersionID())
+		msginfo := map[string]interface{}{
+			"Action": "element-bulk-move",
+			"Moves":  moves,
+			"UUID":   string(versionuuid),
+		}
+		jsonmsg, err := json.Marshal(msginfo)
+		if err != nil {
+			dvid.Errorf("error marshaling JSON for annotations %q bulk move: %v\n", d.DataName(), err)
+		} else if err = d.ProduceKafkaMsg(jsonmsg); err != nil {
+			dvid.Errorf("error on sending bulk move op to kafka: %v\n", err)
+		}
+	}
+	return len(moves), nil
+}
+
+func (d *Data) getBatcher() (storage.KeyValueBatcher, error) {
+	store, err := d.KVStore()
+	if err != nil {
+		return nil, err
+	}
+	batcher, ok := store.(storage.KeyValueBatcher)
+	if !ok {
+		return nil, fmt.Errorf("Data type annotation requires batch-enabled store, which %q is not", store)
+	}
+	return batcher, nil
+}
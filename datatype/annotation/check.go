@@ -0,0 +1,465 @@
+/*
+	This file supports verification and repair of the label and tag denormalizations.
+*/
+
+package annotation
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// IndexInconsistency lists the positions where a label or tag index differs from the
+// elements stored by block.
+type IndexInconsistency struct {
+	Missing []dvid.Point3d `json:",omitempty"` // elements that should be in the index but aren't
+	Extra   []dvid.Point3d `json:",omitempty"` // index entries without a corresponding element
+	Stale   []dvid.Point3d `json:",omitempty"` // index entries that differ from the element
+}
+
+// LabelInconsistency is an inconsistency between a label index and elements.
+type LabelInconsistency struct {
+	Label uint64
+	IndexInconsistency
+}
+
+// TagInconsistency is an inconsistency between a tag index and elements.
+type TagInconsistency struct {
+	Tag Tag
+	IndexInconsistency
+}
+
+// RelInconsistency is a relationship whose target is missing or that lacks a
+// reciprocal relationship in the target.
+type RelInconsistency struct {
+	From    dvid.Point3d
+	Rel     RelationType
+	To      dvid.Point3d
+	Problem string
+}
+
+// ConsistencyReport describes all inconsistencies found in the denormalizations of
+// an annotation instance.
+type ConsistencyReport struct {
+	NumElements    int
+	LabelsChecked  bool // false if there are no synced labels
+	Labels         []LabelInconsistency
+	Tags           []TagInconsistency
+	Relationships  []RelInconsistency
+	RepairedLabels []uint64 `json:",omitempty"`
+	RepairedTags   []Tag    `json:",omitempty"`
+}
+
+type pointsByPos []dvid.Point3d
+
+func (p pointsByPos) Len() int {
+	return len(p)
+}
+
+func (p pointsByPos) Less(i, j int) bool {
+	return p[i].Less(p[j])
+}
+
+func (p pointsByPos) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+}
+
+type labelInconsistencies []LabelInconsistency
+
+func (l labelInconsistencies) Len() int {
+	return len(l)
+}
+
+func (l labelInconsistencies) Less(i, j int) bool {
+	return l[i].Label < l[j].Label
+}
+
+func (l labelInconsistencies) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+type tagInconsistencies []TagInconsistency
+
+func (t tagInconsistencies) Len() int {
+	return len(t)
+}
+
+func (t tagInconsistencies) Less(i, j int) bool {
+	return t[i].Tag < t[j].Tag
+}
+
+func (t tagInconsistencies) Swap(i, j int) {
+	t[i], t[j] = t[j], t[i]
+}
+
+type relInconsistencies []RelInconsistency
+
+func (r relInconsistencies) Len() int {
+	return len(r)
+}
+
+func (r relInconsistencies) Less(i, j int) bool {
+	if !r[i].From.Equals(r[j].From) {
+		return r[i].From.Less(r[j].From)
+	}
+	return r[i].To.Less(r[j].To)
+}
+
+func (r relInconsistencies) Swap(i, j int) {
+	r[i], r[j] = r[j], r[i]
+}
+
+// sameElementNR returns true if the elements have the same position, kind, tags and properties
+// regardless of tag order.
+func sameElementNR(a, b ElementNR) bool {
+	if !a.Pos.Equals(b.Pos) || a.Kind != b.Kind || len(a.Tags) != len(b.Tags) || len(a.Prop) != len(b.Prop) {
+		return false
+	}
+	tags := make(map[Tag]int, len(a.Tags))
+	for _, tag := range a.Tags {
+		tags[tag]++
+	}
+	for _, tag := range b.Tags {
+		if tags[tag] == 0 {
+			return false
+		}
+		tags[tag]--
+	}
+	for key, value := range a.Prop {
+		if value2, found := b.Prop[key]; !found || value != value2 {
+			return false
+		}
+	}
+	return true
+}
+
+// compareIndex compares stored index elements against the expected elements keyed by position.
+func compareIndex(expected map[string]ElementNR, stored ElementsNR) (inc IndexInconsistency, found bool) {
+	seen := make(map[string]struct{}, len(stored))
+	for _, elem := range stored {
+		key := elem.Pos.MapKey()
+		exp, ok := expected[key]
+		_, dup := seen[key]
+		switch {
+		case !ok || dup:
+			inc.Extra = append(inc.Extra, elem.Pos)
+		case !sameElementNR(exp, elem):
+			inc.Stale = append(inc.Stale, elem.Pos)
+		}
+		seen[key] = struct{}{}
+	}
+	for key, elem := range expected {
+		if _, ok := seen[key]; !ok {
+			inc.Missing = append(inc.Missing, elem.Pos)
+		}
+	}
+	sort.Sort(pointsByPos(inc.Missing))
+	sort.Sort(pointsByPos(inc.Extra))
+	sort.Sort(pointsByPos(inc.Stale))
+	found = len(inc.Missing) != 0 || len(inc.Extra) != 0 || len(inc.Stale) != 0
+	return
+}
+
+func hasRel(elem Element, rel RelationType, to dvid.Point3d) bool {
+	for _, r := range elem.Rels {
+		if r.Rel == rel && r.To.Equals(to) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRelationships returns relationships with missing targets or synaptic relationships
+// without a reciprocal PreSynTo/PostSynTo relationship.
+func checkRelationships(elems map[string]Element) []RelInconsistency {
+	var incs []RelInconsistency
+	for _, elem := range elems {
+		for _, rel := range elem.Rels {
+			target, found := elems[rel.To.MapKey()]
+			if !found {
+				incs = append(incs, RelInconsistency{elem.Pos, rel.Rel, rel.To, "missing target"})
+				continue
+			}
+			var reciprocal RelationType
+			switch rel.Rel {
+			case PreSynTo:
+				reciprocal = PostSynTo
+			case PostSynTo:
+				reciprocal = PreSynTo
+			default:
+				continue
+			}
+			if !hasRel(target, reciprocal, elem.Pos) {
+				incs = append(incs, RelInconsistency{elem.Pos, rel.Rel, rel.To, fmt.Sprintf("missing reciprocal %s", reciprocal)})
+			}
+		}
+	}
+	sort.Sort(relInconsistencies(incs))
+	return incs
+}
+
+// CheckConsistency compares the label and tag indices and relationships against the elements
+// stored by block and optionally repairs the inconsistent label and tag indices.  The check is
+// done under a read lock, and any repair only write locks the instance while the affected
+// labels and tags are rewritten.  Relationship inconsistencies are only reported.
+func (d *Data) CheckConsistency(ctx *datastore.VersionedCtx, repair bool) (*ConsistencyReport, error) {
+	store, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return nil, err
+	}
+	labelData := d.getSyncedLabels()
+	report := &ConsistencyReport{LabelsChecked: labelData != nil}
+	elems := make(map[string]Element)
+	expLabels := make(map[uint64]map[string]ElementNR)
+	expTags := make(map[Tag]map[string]ElementNR)
+
+	d.RLock()
+	err = store.ProcessRange(ctx, storage.MinTKey(keyBlock), storage.MaxTKey(keyBlock), nil, func(chunk *storage.Chunk) error {
+		if chunk.V == nil {
+			return nil
+		}
+		var blockElems Elements
+		if err := json.Unmarshal(chunk.V, &blockElems); err != nil {
+			return err
+		}
+		var lbls []uint64
+		if labelData != nil && len(blockElems) != 0 {
+			if lbls, err = d.getLabelsAtPoints(ctx.VersionID(), blockElems.positions()); err != nil {
+				return err
+			}
+		}
+		for i, elem := range blockElems {
+			key := elem.Pos.MapKey()
+			elems[key] = elem
+			if lbls != nil && lbls[i] != 0 {
+				if expLabels[lbls[i]] == nil {
+					expLabels[lbls[i]] = make(map[string]ElementNR)
+				}
+				expLabels[lbls[i]][key] = elem.ElementNR
+			}
+			for _, tag := range elem.Tags {
+				if expTags[tag] == nil {
+					expTags[tag] = make(map[string]ElementNR)
+				}
+				expTags[tag][key] = elem.ElementNR
+			}
+		}
+		return nil
+	})
+	if err == nil && labelData != nil {
+		seen := make(map[uint64]struct{})
+		err = store.ProcessRange(ctx, storage.MinTKey(keyLabel), storage.MaxTKey(keyLabel), nil, func(chunk *storage.Chunk) error {
+			if chunk.V == nil {
+				return nil
+			}
+			label, err := DecodeLabelTKey(chunk.K)
+			if err != nil {
+				return err
+			}
+			var stored ElementsNR
+			if err := json.Unmarshal(chunk.V, &stored); err != nil {
+				return err
+			}
+			seen[label] = struct{}{}
+			if inc, found := compareIndex(expLabels[label], stored); found {
+				report.Labels = append(report.Labels, LabelInconsistency{label, inc})
+			}
+			return nil
+		})
+		for label, expected := range expLabels {
+			if _, found := seen[label]; !found {
+				inc, _ := compareIndex(expected, nil)
+				report.Labels = append(report.Labels, LabelInconsistency{label, inc})
+			}
+		}
+	}
+	if err == nil {
+		seen := make(map[Tag]struct{})
+		err = store.ProcessRange(ctx, storage.MinTKey(keyTag), storage.MaxTKey(keyTag), nil, func(chunk *storage.Chunk) error {
+			if chunk.V == nil {
+				return nil
+			}
+			tag, err := DecodeTagTKey(chunk.K)
+			if err != nil {
+				return err
+			}
+			var stored ElementsNR
+			if err := json.Unmarshal(chunk.V, &stored); err != nil {
+				return err
+			}
+			seen[tag] = struct{}{}
+			if inc, found := compareIndex(expTags[tag], stored); found {
+				report.Tags = append(report.Tags, TagInconsistency{tag, inc})
+			}
+			return nil
+		})
+		for tag, expected := range expTags {
+			if _, found := seen[tag]; !found {
+				inc, _ := compareIndex(expected, nil)
+				report.Tags = append(report.Tags, TagInconsistency{tag, inc})
+			}
+		}
+	}
+	d.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("unable to check consistency of annotation %q: %v", d.DataName(), err)
+	}
+
+	report.NumElements = len(elems)
+	report.Relationships = checkRelationships(elems)
+	sort.Sort(labelInconsistencies(report.Labels))
+	sort.Sort(tagInconsistencies(report.Tags))
+
+	if repair && (len(report.Labels) != 0 || len(report.Tags) != 0) {
+		if err := d.repairIndices(ctx, report, expLabels, expTags); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// repairIndices rewrites the label and tag indices with inconsistencies in the report.  Since
+// elements may have changed since the check, each index is rebuilt from the current elements at
+// the positions in either the stored index or the index expected at check time.
+func (d *Data) repairIndices(ctx *datastore.VersionedCtx, report *ConsistencyReport, expLabels map[uint64]map[string]ElementNR, expTags map[Tag]map[string]ElementNR) error {
+	batcher, err := d.getBatcher()
+	if err != nil {
+		return err
+	}
+	blockSize := d.blockSize()
+
+	d.Lock()
+	defer d.Unlock()
+
+	blocks := make(map[dvid.IZYXString]Elements)
+	getElement := func(pt dvid.Point3d) (*Element, error) {
+		izyx := pt.ToBlockIZYXString(blockSize)
+		blockElems, found := blocks[izyx]
+		if !found {
+			bcoord, err := izyx.ToChunkPoint3d()
+			if err != nil {
+				return nil, err
+			}
+			if blockElems, err = getElements(ctx, NewBlockTKey(bcoord)); err != nil {
+				return nil, err
+			}
+			blocks[izyx] = blockElems
+		}
+		for i := range blockElems {
+			if blockElems[i].Pos.Equals(pt) {
+				return &blockElems[i], nil
+			}
+		}
+		return nil, nil
+	}
+	candidates := func(stored ElementsNR, expected map[string]ElementNR) []dvid.Point3d {
+		pts := make(map[string]dvid.Point3d)
+		for _, elem := range stored {
+			pts[elem.Pos.MapKey()] = elem.Pos
+		}
+		for key, elem := range expected {
+			pts[key] = elem.Pos
+		}
+		sorted := make([]dvid.Point3d, 0, len(pts))
+		for _, pt := range pts {
+			sorted = append(sorted, pt)
+		}
+		sort.Sort(pointsByPos(sorted))
+		return sorted
+	}
+
+	batch := batcher.NewBatch(ctx)
+	var delta DeltaModifyElements
+	repaired := make(map[uint64]struct{})
+	for _, inc := range report.Labels {
+		tk := NewLabelTKey(inc.Label)
+		stored, err := getElementsNR(ctx, tk)
+		if err != nil {
+			return err
+		}
+		pts := candidates(stored, expLabels[inc.Label])
+		lbls, err := d.getLabelsAtPoints(ctx.VersionID(), pts)
+		if err != nil {
+			return err
+		}
+		storedPts := make(map[string]struct{}, len(stored))
+		for _, elem := range stored {
+			storedPts[elem.Pos.MapKey()] = struct{}{}
+		}
+		rebuilt := ElementsNR{}
+		rebuiltPts := make(map[string]struct{})
+		for i, pt := range pts {
+			if lbls[i] != inc.Label {
+				continue
+			}
+			elem, err := getElement(pt)
+			if err != nil {
+				return err
+			}
+			if elem == nil {
+				continue
+			}
+			rebuilt = append(rebuilt, elem.ElementNR)
+			rebuiltPts[pt.MapKey()] = struct{}{}
+			if _, found := storedPts[pt.MapKey()]; !found {
+				delta.Add = append(delta.Add, ElementPos{Label: inc.Label, Kind: elem.Kind, Pos: pt})
+			}
+		}
+		for _, elem := range stored {
+			if _, found := rebuiltPts[elem.Pos.MapKey()]; !found {
+				delta.Del = append(delta.Del, ElementPos{Label: inc.Label, Kind: elem.Kind, Pos: elem.Pos})
+			}
+		}
+		if err := putBatchElements(batch, tk, rebuilt); err != nil {
+			return err
+		}
+		repaired[inc.Label] = struct{}{}
+		report.RepairedLabels = append(report.RepairedLabels, inc.Label)
+	}
+	for _, inc := range report.Tags {
+		tk, err := NewTagTKey(inc.Tag)
+		if err != nil {
+			return err
+		}
+		stored, err := getElementsNR(ctx, tk)
+		if err != nil {
+			return err
+		}
+		rebuilt := Elements{}
+		for _, pt := range candidates(stored, expTags[inc.Tag]) {
+			elem, err := getElement(pt)
+			if err != nil {
+				return err
+			}
+			if elem == nil {
+				continue
+			}
+			for _, tag := range elem.Tags {
+				if tag == inc.Tag {
+					rebuilt = append(rebuilt, *elem)
+					break
+				}
+			}
+		}
+		if err := putBatchElements(batch, tk, rebuilt); err != nil {
+			return err
+		}
+		report.RepairedTags = append(report.RepairedTags, inc.Tag)
+	}
+	if err := batch.Commit(); err != nil {
+		return fmt.Errorf("unable to commit index repairs for annotation %q: %v", d.DataName(), err)
+	}
+	if len(delta.Add) != 0 || len(delta.Del) != 0 {
+		evt := datastore.SyncEvent{Data: d.DataUUID(), Event: ModifyElementsEvent}
+		msg := datastore.SyncMessage{Event: ModifyElementsEvent, Version: ctx.VersionID(), Delta: delta}
+		if err := datastore.NotifySubscribers(evt, msg); err != nil {
+			return err
+		}
+	}
+	return d.deletePartners(ctx, batcher, repaired, nil)
+}
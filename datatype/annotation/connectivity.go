@@ -0,0 +1,408 @@
+/*
+	This file supports body-to-body connectivity queries and the denormalized partner index.
+*/
+
+package annotation
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/datatype/roi"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// PartnerWeight is the number of synaptic connections to a partner label.
+type PartnerWeight struct {
+	Partner uint64
+	Weight  uint32
+}
+
+// PartnerWeights is a slice of partner weights sortable by descending weight
+// and then ascending partner label.
+type PartnerWeights []PartnerWeight
+
+func (pw PartnerWeights) Len() int {
+	return len(pw)
+}
+
+func (pw PartnerWeights) Less(i, j int) bool {
+	if pw[i].Weight != pw[j].Weight {
+		return pw[i].Weight > pw[j].Weight
+	}
+	return pw[i].Partner < pw[j].Partner
+}
+
+func (pw PartnerWeights) Swap(i, j int) {
+	pw[i], pw[j] = pw[j], pw[i]
+}
+
+// top returns at most n of the heaviest partners, or all partners if n <= 0.
+func (pw PartnerWeights) top(n int) PartnerWeights {
+	if n <= 0 || n >= len(pw) {
+		return pw
+	}
+	return pw[:n]
+}
+
+// Partners describes the synaptic connections of a label.  Outputs are derived from the
+// PreSynTo relationships of the label's PreSyn elements while Inputs are derived from the
+// PostSynTo relationships of the label's PostSyn elements.  Each is sorted by descending weight.
+type Partners struct {
+	Label   uint64
+	Outputs PartnerWeights
+	Inputs  PartnerWeights
+}
+
+// Connection is a weighted directed connection between two bodies.
+type Connection struct {
+	From   uint64
+	To     uint64
+	Weight uint32
+}
+
+// Connections is a slice of connections sortable by From and then To labels.
+type Connections []Connection
+
+func (c Connections) Len() int {
+	return len(c)
+}
+
+func (c Connections) Less(i, j int) bool {
+	if c[i].From != c[j].From {
+		return c[i].From < c[j].From
+	}
+	return c[i].To < c[j].To
+}
+
+func (c Connections) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+func sortedWeights(counts map[uint64]uint32) PartnerWeights {
+	pw := make(PartnerWeights, 0, len(counts))
+	for partner, weight := range counts {
+		pw = append(pw, PartnerWeight{Partner: partner, Weight: weight})
+	}
+	sort.Sort(pw)
+	return pw
+}
+
+// getImmutableROI returns the ROI given a specification of "roiname,uuid" or just "roiname",
+// in which case the given uuid is used.  A nil ROI is returned for an empty specification.
+func getImmutableROI(uuid dvid.UUID, roiSpec string) (*roi.Immutable, error) {
+	if roiSpec == "" {
+		return nil, nil
+	}
+	roiParts := strings.Split(roiSpec, ",")
+	switch len(roiParts) {
+	case 1:
+		roiSpec += "," + string(uuid)
+	case 2:
+	default:
+		return nil, fmt.Errorf("bad ROI specification: %q", roiSpec)
+	}
+	iROI, err := roi.ImmutableBySpec(roiSpec)
+	if err != nil {
+		return nil, err
+	}
+	if iROI == nil {
+		return nil, fmt.Errorf("ROI %q not found", roiSpec)
+	}
+	return iROI, nil
+}
+
+// getLabelsAtPoints returns the label at each of the given points using the synced label data,
+// reading each label block at most once.
+func (d *Data) getLabelsAtPoints(v dvid.VersionID, pts []dvid.Point3d) ([]uint64, error) {
+	labelData := d.getSyncedLabels()
+	if labelData == nil {
+		return nil, fmt.Errorf("no synced labels for annotation %q", d.DataName())
+	}
+	blockSize := d.blockSize()
+	bX := blockSize[0] * 8
+	bY := blockSize[1] * bX
+	blockBytes := int(blockSize.Prod() * 8)
+
+	blockPts := make(map[dvid.IZYXString][]int)
+	for i, pt := range pts {
+		izyx := pt.ToBlockIZYXString(blockSize)
+		blockPts[izyx] = append(blockPts[izyx], i)
+	}
+	lbls := make([]uint64, len(pts))
+	for izyx, indices := range blockPts {
+		bcoord, err := izyx.ToChunkPoint3d()
+		if err != nil {
+			return nil, err
+		}
+		data, err := labelData.GetLabelBytes(v, bcoord)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 {
+			continue
+		}
+		if len(data) != blockBytes {
+			return nil, fmt.Errorf("expected %d bytes in %q label block, got %d instead", blockBytes, labelData.DataName(), len(data))
+		}
+		for _, i := range indices {
+			pt := pts[i].Point3dInChunk(blockSize)
+			n := pt[2]*bY + pt[1]*bX + pt[0]*8
+			lbls[i] = binary.LittleEndian.Uint64(data[n : n+8])
+		}
+	}
+	return lbls, nil
+}
+
+// computePartners calculates the partners of a label from its synaptic elements.  If an ROI is
+// given, only connections whose post-synaptic element is within the ROI are counted.
+func (d *Data) computePartners(ctx *datastore.VersionedCtx, label uint64, iROI *roi.Immutable) (*Partners, error) {
+	elems, err := d.getExpandedElements(ctx, NewLabelTKey(label))
+	if err != nil {
+		return nil, err
+	}
+	var pts []dvid.Point3d
+	var outgoing []bool
+	for _, elem := range elems {
+		var relType RelationType
+		switch elem.Kind {
+		case PreSyn:
+			relType = PreSynTo
+		case PostSyn:
+			relType = PostSynTo
+		default:
+			continue
+		}
+		for _, rel := range elem.Rels {
+			if rel.Rel != relType {
+				continue
+			}
+			if iROI != nil {
+				postPt := elem.Pos
+				if elem.Kind == PreSyn {
+					postPt = rel.To
+				}
+				if !iROI.VoxelWithin(postPt) {
+					continue
+				}
+			}
+			pts = append(pts, rel.To)
+			outgoing = append(outgoing, elem.Kind == PreSyn)
+		}
+	}
+	partnerLabels, err := d.getLabelsAtPoints(ctx.VersionID(), pts)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make(map[uint64]uint32)
+	inputs := make(map[uint64]uint32)
+	for i, partner := range partnerLabels {
+		if partner == 0 {
+			continue
+		}
+		if outgoing[i] {
+			outputs[partner]++
+		} else {
+			inputs[partner]++
+		}
+	}
+	return &Partners{
+		Label:   label,
+		Outputs: sortedWeights(outputs),
+		Inputs:  sortedWeights(inputs),
+	}, nil
+}
+
+// getPartners returns the partners of a label, using the denormalized partner index if available.
+// If the index is missing for the label, it is computed and, if the version is not locked, stored.
+// If an ROI is given, the partners are always computed without the index.
+func (d *Data) getPartners(ctx *datastore.VersionedCtx, label uint64, iROI *roi.Immutable) (*Partners, error) {
+	if iROI != nil {
+		return d.computePartners(ctx, label, iROI)
+	}
+	store, err := ctx.GetOrderedKeyValueDB()
+	if err != nil {
+		return nil, err
+	}
+
+	d.partnerMu.Lock()
+	defer d.partnerMu.Unlock()
+
+	tk := NewPartnersTKey(label)
+	val, err := store.Get(ctx, tk)
+	if err != nil {
+		return nil, err
+	}
+	if val != nil {
+		var p Partners
+		if err := json.Unmarshal(val, &p); err != nil {
+			return nil, err
+		}
+		return &p, nil
+	}
+	p, err := d.computePartners(ctx, label, nil)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := datastore.LockedVersion(ctx.VersionID())
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		if val, err = json.Marshal(p); err != nil {
+			return nil, err
+		}
+		if err := store.Put(ctx, tk, val); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// GetPartners returns the top n output and input partners of a label, optionally restricted
+// to connections with post-synaptic elements within an ROI.  If n <= 0, all partners are returned.
+func (d *Data) GetPartners(ctx *datastore.VersionedCtx, label uint64, n int, iROI *roi.Immutable) (*Partners, error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	p, err := d.getPartners(ctx, label, iROI)
+	if err != nil {
+		return nil, err
+	}
+	p.Outputs = p.Outputs.top(n)
+	p.Inputs = p.Inputs.top(n)
+	return p, nil
+}
+
+// GetConnectivity returns the weighted connections among the given bodies, optionally restricted
+// to connections with post-synaptic elements within an ROI.
+func (d *Data) GetConnectivity(ctx *datastore.VersionedCtx, bodies []uint64, iROI *roi.Immutable) (Connections, error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	bodySet := make(map[uint64]struct{}, len(bodies))
+	for _, body := range bodies {
+		bodySet[body] = struct{}{}
+	}
+	conns := Connections{}
+	for body := range bodySet {
+		p, err := d.getPartners(ctx, body, iROI)
+		if err != nil {
+			return nil, err
+		}
+		for _, pw := range p.Outputs {
+			if _, found := bodySet[pw.Partner]; found {
+				conns = append(conns, Connection{From: body, To: pw.Partner, Weight: pw.Weight})
+			}
+		}
+	}
+	sort.Sort(conns)
+	return conns, nil
+}
+
+// deletePartners removes the partner index for the given labels and for the labels at the given
+// points.  This should be called after any change that alters which label an element or the target
+// of an element's relationship belongs to.
+func (d *Data) deletePartners(ctx *datastore.VersionedCtx, batcher storage.KeyValueBatcher, lbls map[uint64]struct{}, pts []dvid.Point3d) error {
+	if d.getSyncedLabels() == nil {
+		return nil
+	}
+	if len(pts) != 0 {
+		ptLabels, err := d.getLabelsAtPoints(ctx.VersionID(), pts)
+		if err != nil {
+			return err
+		}
+		for _, label := range ptLabels {
+			lbls[label] = struct{}{}
+		}
+	}
+	delete(lbls, 0)
+	if len(lbls) == 0 {
+		return nil
+	}
+
+	d.partnerMu.Lock()
+	defer d.partnerMu.Unlock()
+
+	batch := batcher.NewBatch(ctx)
+	for label := range lbls {
+		batch.Delete(NewPartnersTKey(label))
+	}
+	if err := batch.Commit(); err != nil {
+		return fmt.Errorf("unable to commit partner index deletions for annotation %q: %v", d.DataName(), err)
+	}
+	return nil
+}
+
+// invalidatePartners removes the partner index made stale by a change in the labels of elements:
+// the old and new labels of the changed synaptic elements as well as the labels of their
+// related elements.
+func (d *Data) invalidatePartners(ctx *datastore.VersionedCtx, batcher storage.KeyValueBatcher, delta DeltaModifyElements) {
+	lbls := make(map[uint64]struct{})
+	blockSize := d.blockSize()
+	blockPts := make(map[dvid.IZYXString]map[string]struct{})
+	for _, elems := range [][]ElementPos{delta.Add, delta.Del} {
+		for _, elem := range elems {
+			if elem.Kind != PreSyn && elem.Kind != PostSyn {
+				continue
+			}
+			lbls[elem.Label] = struct{}{}
+			izyx := elem.Pos.ToBlockIZYXString(blockSize)
+			posSet, found := blockPts[izyx]
+			if !found {
+				posSet = make(map[string]struct{})
+				blockPts[izyx] = posSet
+			}
+			posSet[elem.Pos.MapKey()] = struct{}{}
+		}
+	}
+	if len(lbls) == 0 {
+		return
+	}
+
+	// Get the relationship targets of changed elements from the block-indexed elements.
+	var pts []dvid.Point3d
+	for izyx, posSet := range blockPts {
+		chunkPt, err := izyx.ToChunkPoint3d()
+		if err != nil {
+			dvid.Errorf("bad block coordinate for annotation %q: %v\n", d.DataName(), err)
+			return
+		}
+		elems, err := getElements(ctx, NewBlockTKey(chunkPt))
+		if err != nil {
+			dvid.Errorf("unable to get elements for block %s in annotation %q: %v\n", chunkPt, d.DataName(), err)
+			return
+		}
+		for _, elem := range elems {
+			if _, found := posSet[elem.Pos.MapKey()]; !found {
+				continue
+			}
+			for _, rel := range elem.Rels {
+				pts = append(pts, rel.To)
+			}
+		}
+	}
+	if err := d.deletePartners(ctx, batcher, lbls, pts); err != nil {
+		dvid.Errorf("unable to invalidate partner index for annotation %q: %v\n", d.DataName(), err)
+	}
+}
+
+// invalidateElementPartners removes the partner index for labels of the given elements and,
+// if withRels is true, the labels of their relationship targets.
+func (d *Data) invalidateElementPartners(ctx *datastore.VersionedCtx, batcher storage.KeyValueBatcher, elems Elements, withRels bool) error {
+	var pts []dvid.Point3d
+	for _, elem := range elems {
+		pts = append(pts, elem.Pos)
+		if withRels {
+			for _, rel := range elem.Rels {
+				pts = append(pts, rel.To)
+			}
+		}
+	}
+	return d.deletePartners(ctx, batcher, make(map[uint64]struct{}), pts)
+}
@@ -0,0 +1,584 @@
+/*
+	This file supports export of annotations to CSV, JSON lines, and neuroglancer precomputed formats.
+*/
+
+package annotation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// ExportFormat is a file format for exported annotations.
+type ExportFormat uint8
+
+const (
+	ExportCSV         ExportFormat = iota // comma-separated values, one element per row
+	ExportJSONLines                       // one JSON element per line
+	ExportPrecomputed                     // neuroglancer precomputed annotations
+)
+
+// ParseExportFormat returns the export format given its name.
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch strings.ToLower(s) {
+	case "csv":
+		return ExportCSV, nil
+	case "jsonl", "jsonlines":
+		return ExportJSONLines, nil
+	case "precomputed":
+		return ExportPrecomputed, nil
+	default:
+		return 0, fmt.Errorf("unknown annotation export format %q", s)
+	}
+}
+
+func (f ExportFormat) String() string {
+	switch f {
+	case ExportCSV:
+		return "csv"
+	case ExportJSONLines:
+		return "jsonl"
+	case ExportPrecomputed:
+		return "precomputed"
+	default:
+		return fmt.Sprintf("unknown export format %d", f)
+	}
+}
+
+// ExportRelationship is a relationship with the label at its target if labels are synced.
+type ExportRelationship struct {
+	Rel    RelationType
+	To     dvid.Point3d
+	ToBody uint64 `json:",omitempty"`
+}
+
+// ExportElement is an element with the labels at its position and relationship targets
+// if labels are synced.
+type ExportElement struct {
+	ElementNR
+	Body uint64 `json:",omitempty"`
+	Rels []ExportRelationship
+}
+
+// processExportElements calls f for each element passing the filter in block order.
+func (d *Data) processExportElements(ctx *datastore.VersionedCtx, filter ElementFilter, f func(ExportElement) error) error {
+	store, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return err
+	}
+	labelData := d.getSyncedLabels()
+	begTKey := storage.MinTKey(keyBlock)
+	endTKey := storage.MaxTKey(keyBlock)
+	return store.ProcessRange(ctx, begTKey, endTKey, nil, func(chunk *storage.Chunk) error {
+		if chunk.V == nil {
+			return nil
+		}
+		var elems Elements
+		if err := json.Unmarshal(chunk.V, &elems); err != nil {
+			return err
+		}
+		var exports []ExportElement
+		var pts []dvid.Point3d
+		for _, elem := range elems {
+			if !filter.matches(elem) {
+				continue
+			}
+			export := ExportElement{ElementNR: elem.ElementNR, Rels: make([]ExportRelationship, len(elem.Rels))}
+			pts = append(pts, elem.Pos)
+			for i, rel := range elem.Rels {
+				export.Rels[i] = ExportRelationship{Rel: rel.Rel, To: rel.To}
+				pts = append(pts, rel.To)
+			}
+			exports = append(exports, export)
+		}
+		if labelData != nil && len(pts) != 0 {
+			lbls, err := d.getLabelsAtPoints(ctx.VersionID(), pts)
+			if err != nil {
+				return err
+			}
+			n := 0
+			for i := range exports {
+				exports[i].Body = lbls[n]
+				n++
+				for j := range exports[i].Rels {
+					exports[i].Rels[j].ToBody = lbls[n]
+					n++
+				}
+			}
+		}
+		for _, export := range exports {
+			if err := f(export); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func formatPoint(pt dvid.Point3d) string {
+	return fmt.Sprintf("%d %d %d", pt[0], pt[1], pt[2])
+}
+
+// WriteCSV writes elements passing the filter as CSV with a header row.  Tags are separated
+// by semicolons, as are the positions of synaptic partners from PreSynTo and PostSynTo
+// relationships and the bodies at those positions.  Bodies are empty if labels are not synced.
+func (d *Data) WriteCSV(ctx *datastore.VersionedCtx, w io.Writer, filter ElementFilter) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"x", "y", "z", "kind", "body", "tags", "partners", "partner_bodies"}); err != nil {
+		return err
+	}
+	synced := d.getSyncedLabels() != nil
+	err := d.processExportElements(ctx, filter, func(elem ExportElement) error {
+		tags := make([]string, len(elem.Tags))
+		for i, tag := range elem.Tags {
+			tags[i] = string(tag)
+		}
+		var partners, partnerBodies []string
+		for _, rel := range elem.Rels {
+			if rel.Rel != PreSynTo && rel.Rel != PostSynTo {
+				continue
+			}
+			partners = append(partners, formatPoint(rel.To))
+			if synced {
+				partnerBodies = append(partnerBodies, strconv.FormatUint(rel.ToBody, 10))
+			}
+		}
+		var body string
+		if synced {
+			body = strconv.FormatUint(elem.Body, 10)
+		}
+		return cw.Write([]string{
+			strconv.Itoa(int(elem.Pos[0])),
+			strconv.Itoa(int(elem.Pos[1])),
+			strconv.Itoa(int(elem.Pos[2])),
+			kindName(elem.Kind),
+			body,
+			strings.Join(tags, ";"),
+			strings.Join(partners, ";"),
+			strings.Join(partnerBodies, ";"),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSONLines writes elements passing the filter as one JSON object per line.
+func (d *Data) WriteJSONLines(ctx *datastore.VersionedCtx, w io.Writer, filter ElementFilter) error {
+	enc := json.NewEncoder(w)
+	return d.processExportElements(ctx, filter, func(elem ExportElement) error {
+		return enc.Encode(elem)
+	})
+}
+
+// precomputedIDBias offsets coordinates so element positions can be packed into
+// neuroglancer annotation ids.
+const precomputedIDBias = 1 << 20
+
+// precomputedSpatialLimit is the maximum number of annotations per spatial index cell
+// given in the precomputed info.  Since there is a single spatial level, all annotations
+// in a cell are always returned.
+const precomputedSpatialLimit = 100000
+
+// PrecomputedID returns the neuroglancer precomputed annotation id for an element position,
+// which packs the x, y, and z coordinates offset by 2^20 into 21 bits each.
+func PrecomputedID(pt dvid.Point3d) (uint64, error) {
+	var id uint64
+	for i := 2; i >= 0; i-- {
+		c := int64(pt[i]) + precomputedIDBias
+		if c < 0 || c >= 1<<21 {
+			return 0, fmt.Errorf("position %s is outside range for precomputed annotation ids", pt)
+		}
+		id = id<<21 | uint64(c)
+	}
+	return id, nil
+}
+
+// PrecomputedIDToPoint returns the element position for a neuroglancer precomputed annotation id.
+func PrecomputedIDToPoint(id uint64) dvid.Point3d {
+	var pt dvid.Point3d
+	for i := 0; i < 3; i++ {
+		pt[i] = int32(id&(1<<21-1)) - precomputedIDBias
+		id >>= 21
+	}
+	return pt
+}
+
+// encodePrecomputedPoint appends the point geometry and kind property of an element.
+func encodePrecomputedPoint(buf *bytes.Buffer, elem ElementNR) {
+	for i := 0; i < 3; i++ {
+		binary.Write(buf, binary.LittleEndian, float32(elem.Pos[i]))
+	}
+	buf.Write([]byte{byte(elem.Kind), 0, 0, 0})
+}
+
+// encodePrecomputedMultiple returns the encoding of elements used for spatial index cells
+// and related object lists.
+func encodePrecomputedMultiple(elems ElementsNR) ([]byte, error) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint64(len(elems)))
+	for _, elem := range elems {
+		encodePrecomputedPoint(&buf, elem)
+	}
+	for _, elem := range elems {
+		id, err := PrecomputedID(elem.Pos)
+		if err != nil {
+			return nil, err
+		}
+		binary.Write(&buf, binary.LittleEndian, id)
+	}
+	return buf.Bytes(), nil
+}
+
+// encodePrecomputedByID returns the encoding of a single element and, if labels are synced,
+// its body relationship.
+func encodePrecomputedByID(elem ElementNR, synced bool, body uint64) []byte {
+	var buf bytes.Buffer
+	encodePrecomputedPoint(&buf, elem)
+	if synced {
+		if body == 0 {
+			binary.Write(&buf, binary.LittleEndian, uint32(0))
+		} else {
+			binary.Write(&buf, binary.LittleEndian, uint32(1))
+			binary.Write(&buf, binary.LittleEndian, body)
+		}
+	}
+	return buf.Bytes()
+}
+
+type precomputedProperty struct {
+	ID         string   `json:"id"`
+	Type       string   `json:"type"`
+	EnumValues []uint8  `json:"enum_values"`
+	EnumLabels []string `json:"enum_labels"`
+}
+
+type precomputedRelationship struct {
+	ID  string `json:"id"`
+	Key string `json:"key"`
+}
+
+type precomputedSpatial struct {
+	Key       string       `json:"key"`
+	GridShape dvid.Point3d `json:"grid_shape"`
+	ChunkSize dvid.Point3d `json:"chunk_size"`
+	Limit     int          `json:"limit"`
+}
+
+type precomputedInfo struct {
+	Type           string                    `json:"@type"`
+	Dimensions     map[string][]interface{}  `json:"dimensions"`
+	LowerBound     dvid.Point3d              `json:"lower_bound"`
+	UpperBound     dvid.Point3d              `json:"upper_bound"`
+	AnnotationType string                    `json:"annotation_type"`
+	Properties     []precomputedProperty     `json:"properties"`
+	Relationships  []precomputedRelationship `json:"relationships"`
+	ByID           struct {
+		Key string `json:"key"`
+	} `json:"by_id"`
+	Spatial []precomputedSpatial `json:"spatial"`
+}
+
+// getPrecomputedInfo returns the neuroglancer precomputed info for the annotations.  The
+// spatial index has one cell per annotation block, and the key of the spatial index includes
+// the coordinate of the first block so cells can be located without rescanning blocks.
+func (d *Data) getPrecomputedInfo(ctx *datastore.VersionedCtx) (*precomputedInfo, error) {
+	store, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := store.KeysInRange(ctx, storage.MinTKey(keyBlock), storage.MaxTKey(keyBlock))
+	if err != nil {
+		return nil, err
+	}
+	var minBlock, maxBlock dvid.ChunkPoint3d
+	for i, tk := range keys {
+		bcoord, err := DecodeBlockTKey(tk)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			minBlock, maxBlock = bcoord, bcoord
+			continue
+		}
+		for j := 0; j < 3; j++ {
+			if bcoord[j] < minBlock[j] {
+				minBlock[j] = bcoord[j]
+			}
+			if bcoord[j] > maxBlock[j] {
+				maxBlock[j] = bcoord[j]
+			}
+		}
+	}
+
+	blockSize := d.blockSize()
+	res := d.voxelSize()
+	info := &precomputedInfo{
+		Type: "neuroglancer_annotations_v1",
+		Dimensions: map[string][]interface{}{
+			"x": {res[0] * 1e-9, "m"},
+			"y": {res[1] * 1e-9, "m"},
+			"z": {res[2] * 1e-9, "m"},
+		},
+		AnnotationType: "POINT",
+		Properties: []precomputedProperty{
+			{
+				ID:         "kind",
+				Type:       "uint8",
+				EnumValues: []uint8{uint8(UnknownElem), uint8(PostSyn), uint8(PreSyn), uint8(Gap), uint8(Note)},
+				EnumLabels: []string{"unknown", "postsyn", "presyn", "gap", "note"},
+			},
+		},
+		Relationships: []precomputedRelationship{},
+	}
+	kind := &info.Properties[0]
+	for _, name := range d.CustomKinds {
+		if value, found := customKindByName(name); found {
+			kind.EnumValues = append(kind.EnumValues, uint8(value))
+			kind.EnumLabels = append(kind.EnumLabels, strings.ToLower(name))
+		}
+	}
+	if d.getSyncedLabels() != nil {
+		info.Relationships = append(info.Relationships, precomputedRelationship{ID: "body", Key: "rel_body"})
+	}
+	info.ByID.Key = "by_id"
+	spatial := precomputedSpatial{
+		Key:       fmt.Sprintf("spatial/%d_%d_%d", minBlock[0], minBlock[1], minBlock[2]),
+		ChunkSize: blockSize,
+		Limit:     precomputedSpatialLimit,
+	}
+	for i := 0; i < 3; i++ {
+		info.LowerBound[i] = minBlock[i] * blockSize[i]
+		info.UpperBound[i] = (maxBlock[i] + 1) * blockSize[i]
+		spatial.GridShape[i] = maxBlock[i] - minBlock[i] + 1
+	}
+	info.Spatial = []precomputedSpatial{spatial}
+	return info, nil
+}
+
+// getPrecomputedByID returns the by_id encoding of the element at the given position or nil
+// if there is no element there.
+func (d *Data) getPrecomputedByID(ctx *datastore.VersionedCtx, pt dvid.Point3d) ([]byte, error) {
+	elems, err := getElementsNR(ctx, NewBlockTKey(pt.Chunk(d.blockSize()).(dvid.ChunkPoint3d)))
+	if err != nil {
+		return nil, err
+	}
+	for _, elem := range elems {
+		if !elem.Pos.Equals(pt) {
+			continue
+		}
+		labelData := d.getSyncedLabels()
+		var body uint64
+		if labelData != nil {
+			if body, err = labelData.GetLabelAtPoint(ctx.VersionID(), pt); err != nil {
+				return nil, err
+			}
+		}
+		return encodePrecomputedByID(elem, labelData != nil, body), nil
+	}
+	return nil, nil
+}
+
+// GetPrecomputed returns the contents of a file in a neuroglancer precomputed annotation
+// directory, where the path is relative to the directory, e.g., "info", "by_id/<id>",
+// "rel_body/<label>", or a spatial index cell given by the key in the info.  A nil result
+// with no error means the file does not exist.
+func (d *Data) GetPrecomputed(ctx *datastore.VersionedCtx, path string) ([]byte, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "info":
+		info, err := d.getPrecomputedInfo(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(info)
+
+	case len(parts) == 2 && parts[0] == "by_id":
+		id, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad precomputed annotation id %q: %v", parts[1], err)
+		}
+		d.RLock()
+		defer d.RUnlock()
+		return d.getPrecomputedByID(ctx, PrecomputedIDToPoint(id))
+
+	case len(parts) == 2 && parts[0] == "rel_body":
+		if d.getSyncedLabels() == nil {
+			return nil, nil
+		}
+		label, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad precomputed body id %q: %v", parts[1], err)
+		}
+		d.RLock()
+		elems, err := getElementsNR(ctx, NewLabelTKey(label))
+		d.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+		return encodePrecomputedMultiple(elems)
+
+	case len(parts) == 3 && parts[0] == "spatial":
+		origin, err := dvid.StringToPoint3d(parts[1], "_")
+		if err != nil {
+			return nil, err
+		}
+		cell, err := dvid.StringToPoint3d(parts[2], "_")
+		if err != nil {
+			return nil, err
+		}
+		bcoord := dvid.ChunkPoint3d{origin[0] + cell[0], origin[1] + cell[1], origin[2] + cell[2]}
+		d.RLock()
+		elems, err := getElementsNR(ctx, NewBlockTKey(bcoord))
+		d.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+		return encodePrecomputedMultiple(elems)
+
+	default:
+		return nil, nil
+	}
+}
+
+// WritePrecomputed writes all elements as a neuroglancer precomputed annotation directory.
+func (d *Data) WritePrecomputed(ctx *datastore.VersionedCtx, dirPath string) error {
+	store, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return err
+	}
+	info, err := d.getPrecomputedInfo(ctx)
+	if err != nil {
+		return err
+	}
+	spatialKey := info.Spatial[0].Key
+	var origin dvid.Point3d
+	for i := 0; i < 3; i++ {
+		origin[i] = info.LowerBound[i] / info.Spatial[0].ChunkSize[i]
+	}
+	synced := len(info.Relationships) != 0
+	for _, dir := range []string{"by_id", spatialKey, "rel_body"} {
+		if dir == "rel_body" && !synced {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Join(dirPath, dir), 0755); err != nil {
+			return err
+		}
+	}
+	infoJSON, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dirPath, "info"), infoJSON, 0644); err != nil {
+		return err
+	}
+
+	// Write the spatial index cells and the elements by id.
+	err = store.ProcessRange(ctx, storage.MinTKey(keyBlock), storage.MaxTKey(keyBlock), nil, func(chunk *storage.Chunk) error {
+		if chunk.V == nil {
+			return nil
+		}
+		bcoord, err := DecodeBlockTKey(chunk.K)
+		if err != nil {
+			return err
+		}
+		var elems ElementsNR
+		if err := json.Unmarshal(chunk.V, &elems); err != nil {
+			return err
+		}
+		data, err := encodePrecomputedMultiple(elems)
+		if err != nil {
+			return err
+		}
+		cell := fmt.Sprintf("%d_%d_%d", bcoord[0]-origin[0], bcoord[1]-origin[1], bcoord[2]-origin[2])
+		if err := ioutil.WriteFile(filepath.Join(dirPath, spatialKey, cell), data, 0644); err != nil {
+			return err
+		}
+		lbls := make([]uint64, len(elems))
+		if synced && len(elems) != 0 {
+			pts := make([]dvid.Point3d, len(elems))
+			for i, elem := range elems {
+				pts[i] = elem.Pos
+			}
+			if lbls, err = d.getLabelsAtPoints(ctx.VersionID(), pts); err != nil {
+				return err
+			}
+		}
+		for i, elem := range elems {
+			id, err := PrecomputedID(elem.Pos)
+			if err != nil {
+				return err
+			}
+			data := encodePrecomputedByID(elem, synced, lbls[i])
+			if err := ioutil.WriteFile(filepath.Join(dirPath, "by_id", strconv.FormatUint(id, 10)), data, 0644); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil || !synced {
+		return err
+	}
+
+	// Write the elements related to each body.
+	return store.ProcessRange(ctx, storage.MinTKey(keyLabel), storage.MaxTKey(keyLabel), nil, func(chunk *storage.Chunk) error {
+		if chunk.V == nil {
+			return nil
+		}
+		label, err := DecodeLabelTKey(chunk.K)
+		if err != nil {
+			return err
+		}
+		var elems ElementsNR
+		if err := json.Unmarshal(chunk.V, &elems); err != nil {
+			return err
+		}
+		if len(elems) == 0 {
+			return nil
+		}
+		data, err := encodePrecomputedMultiple(elems)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dirPath, "rel_body", strconv.FormatUint(label, 10)), data, 0644)
+	})
+}
+
+// Export writes all elements passing the filter to a file, or for the precomputed format,
+// a directory.  The filter is ignored for the precomputed format.
+func (d *Data) Export(ctx *datastore.VersionedCtx, format ExportFormat, path string, filter ElementFilter) error {
+	if format == ExportPrecomputed {
+		return d.WritePrecomputed(ctx, path)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case ExportCSV:
+		err = d.WriteCSV(ctx, f, filter)
+	case ExportJSONLines:
+		err = d.WriteJSONLines(ctx, f, filter)
+	default:
+		err = fmt.Errorf("unknown annotation export format %d", format)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
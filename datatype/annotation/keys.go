@@ -5,8 +5,11 @@
 package annotation
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
+	"math"
+	"strconv"
 
 	"github.com/janelia-flyem/dvid/dvid"
 	"github.com/janelia-flyem/dvid/storage"
@@ -24,6 +27,13 @@ const (
 
 	// key is block coordinate.  value is serialization of synaptic elements.
 	keyBlock = 72
+
+	// key is label.  value is JSON serialization of the label's synaptic partners and weights.
+	keyPartners = 73
+
+	// key is property name, encoded property value, and element position.  value is
+	// serialization of the element without relationships.
+	keyProp = 74
 )
 
 // DescribeTKeyClass returns a string explanation of what a particular TKeyClass
@@ -36,6 +46,10 @@ func (d *Data) DescribeTKeyClass(tkc storage.TKeyClass) string {
 		return "annotation label key"
 	case keyBlock:
 		return "annotation block coord key"
+	case keyPartners:
+		return "annotation label partners key"
+	case keyProp:
+		return "annotation property index key"
 	default:
 	}
 	return "unknown annotation key"
@@ -97,3 +111,77 @@ func DecodeBlockTKey(tk storage.TKey) (pt dvid.ChunkPoint3d, err error) {
 	pt = dvid.ChunkPoint3d(idx)
 	return
 }
+
+func NewPartnersTKey(label uint64) storage.TKey {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, label)
+	return storage.NewTKey(keyPartners, buf)
+}
+
+func DecodePartnersTKey(tk storage.TKey) (label uint64, err error) {
+	ibytes, err := tk.ClassBytes(keyPartners)
+	if err != nil {
+		return
+	}
+	label = binary.BigEndian.Uint64(ibytes[0:8])
+	return
+}
+
+// NewPropTKey returns a TKey for an element's property value.  Numeric values are
+// encoded so that keys sort by numeric value.
+func NewPropTKey(prop, value string, pt dvid.Point3d) storage.TKey {
+	buf := propValuePrefix(prop, value)
+	idx := dvid.IndexZYX(pt)
+	return storage.NewTKey(keyProp, append(buf, idx.Bytes()...))
+}
+
+// DecodePropTKey returns the property name and element position from a property TKey.
+func DecodePropTKey(tk storage.TKey) (prop string, pt dvid.Point3d, err error) {
+	ibytes, err := tk.ClassBytes(keyProp)
+	if err != nil {
+		return
+	}
+	n := bytes.IndexByte(ibytes, 0)
+	if n < 0 || len(ibytes) < n+13 {
+		err = fmt.Errorf("bad annotation property key of %d bytes", len(ibytes))
+		return
+	}
+	prop = string(ibytes[:n])
+	var idx dvid.IndexZYX
+	if err = idx.IndexFromBytes(ibytes[len(ibytes)-12:]); err != nil {
+		return
+	}
+	pt = dvid.Point3d(idx)
+	return
+}
+
+// propValuePrefix returns the key bytes preceding the element position for a property value.
+// Values that parse as numbers sort numerically before all string values.
+func propValuePrefix(prop, value string) []byte {
+	buf := append([]byte(prop), 0)
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return append(buf, encodePropNumber(f)...)
+	}
+	buf = append(buf, propString)
+	buf = append(buf, []byte(value)...)
+	return append(buf, 0)
+}
+
+const (
+	propNumber byte = 1
+	propString byte = 2
+)
+
+// encodePropNumber returns an order-preserving encoding of a float64.
+func encodePropNumber(f float64) []byte {
+	bits := math.Float64bits(f)
+	if f < 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	buf := make([]byte, 9)
+	buf[0] = propNumber
+	binary.BigEndian.PutUint64(buf[1:], bits)
+	return buf
+}
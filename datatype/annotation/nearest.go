@@ -0,0 +1,228 @@
+/*
+	This file supports nearest neighbor and radius queries of point annotations.
+*/
+
+package annotation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// MaxNearestBlockRadius is the maximum number of blocks searched outward from the query
+// point in nearest neighbor and radius queries.
+const MaxNearestBlockRadius = 32
+
+// ElementDist is an element and its physical distance from a query point.
+type ElementDist struct {
+	Element
+	Dist float64
+}
+
+// ElementDists is a slice of elements sortable by ascending distance.
+type ElementDists []ElementDist
+
+func (e ElementDists) Len() int {
+	return len(e)
+}
+
+func (e ElementDists) Less(i, j int) bool {
+	if e[i].Dist != e[j].Dist {
+		return e[i].Dist < e[j].Dist
+	}
+	return e[i].Pos.Less(e[j].Pos)
+}
+
+func (e ElementDists) Swap(i, j int) {
+	e[i], e[j] = e[j], e[i]
+}
+
+// voxelSize returns the physical size of a voxel along each axis.
+func (d *Data) voxelSize() [3]float64 {
+	if len(d.VoxelSize) == 3 {
+		return [3]float64{float64(d.VoxelSize[0]), float64(d.VoxelSize[1]), float64(d.VoxelSize[2])}
+	}
+	res := float64(DefaultRes)
+	return [3]float64{res, res, res}
+}
+
+// MaxSearchDist returns the largest physical distance that nearest neighbor and radius
+// queries can search, i.e., the distance covered by MaxNearestBlockRadius blocks along the
+// shortest block dimension.
+func (d *Data) MaxSearchDist() float64 {
+	blockSize := d.blockSize()
+	res := d.voxelSize()
+	maxDist := math.MaxFloat64
+	for i := 0; i < 3; i++ {
+		maxDist = math.Min(maxDist, float64(MaxNearestBlockRadius*blockSize[i])*res[i])
+	}
+	return maxDist
+}
+
+// checkSearchDist returns an error if a query distance is not a finite, non-negative number
+// within the maximum search distance.
+func (d *Data) checkSearchDist(dist float64) error {
+	if math.IsNaN(dist) || math.IsInf(dist, 0) || dist < 0 {
+		return fmt.Errorf("search distance %v must be a finite, non-negative number", dist)
+	}
+	if maxDist := d.MaxSearchDist(); dist > maxDist {
+		return fmt.Errorf("search distance %v exceeds maximum of %v for annotation %q", dist, maxDist, d.DataName())
+	}
+	return nil
+}
+
+func physicalDist(a, b dvid.Point3d, res [3]float64) float64 {
+	var sum float64
+	for i := 0; i < 3; i++ {
+		diff := float64(a[i]-b[i]) * res[i]
+		sum += diff * diff
+	}
+	return math.Sqrt(sum)
+}
+
+// processBlockRow calls f for each element in blocks from x0 to x1 in the given block row.
+func processBlockRow(ctx *datastore.VersionedCtx, store storage.OrderedKeyValueDB, y, z, x0, x1 int32, f func(Element)) error {
+	begTKey := NewBlockTKey(dvid.ChunkPoint3d{x0, y, z})
+	endTKey := NewBlockTKey(dvid.ChunkPoint3d{x1, y, z})
+	return store.ProcessRange(ctx, begTKey, endTKey, nil, func(chunk *storage.Chunk) error {
+		if chunk.V == nil {
+			return nil
+		}
+		var elems Elements
+		if err := json.Unmarshal(chunk.V, &elems); err != nil {
+			return err
+		}
+		for _, elem := range elems {
+			f(elem)
+		}
+		return nil
+	})
+}
+
+// GetNearestSynapses returns up to k elements passing the filter that are nearest the given
+// point, sorted by ascending physical distance.  If maxDist > 0, only elements within that
+// physical distance are returned.  The search expands outward from the point's block one shell
+// of blocks at a time until the k nearest elements are found or MaxNearestBlockRadius blocks
+// have been searched.
+func (d *Data) GetNearestSynapses(ctx *datastore.VersionedCtx, pt dvid.Point3d, k int, maxDist float64, filter ElementFilter) (ElementDists, error) {
+	if err := d.checkSearchDist(maxDist); err != nil {
+		return nil, err
+	}
+	store, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return nil, err
+	}
+	blockSize := d.blockSize()
+	res := d.voxelSize()
+	center := pt.Chunk(blockSize).(dvid.ChunkPoint3d)
+
+	d.RLock()
+	defer d.RUnlock()
+
+	var found ElementDists
+	add := func(elem Element) {
+		if !filter.matches(elem) {
+			return
+		}
+		dist := physicalDist(pt, elem.Pos, res)
+		if maxDist > 0 && dist > maxDist {
+			return
+		}
+		found = append(found, ElementDist{elem, dist})
+	}
+	for r := int32(0); ; r++ {
+		// Read the shell of blocks at a Chebyshev distance of r blocks from the center block.
+		for z := center[2] - r; z <= center[2]+r; z++ {
+			for y := center[1] - r; y <= center[1]+r; y++ {
+				if z == center[2]-r || z == center[2]+r || y == center[1]-r || y == center[1]+r {
+					err = processBlockRow(ctx, store, y, z, center[0]-r, center[0]+r, add)
+				} else {
+					err = processBlockRow(ctx, store, y, z, center[0]-r, center[0]-r, add)
+					if err == nil && r != 0 {
+						err = processBlockRow(ctx, store, y, z, center[0]+r, center[0]+r, add)
+					}
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		// Any element not yet read is at least the distance to the searched region boundary.
+		minUnread := math.MaxFloat64
+		for i := 0; i < 3; i++ {
+			lo := float64(pt[i]-(center[i]-r)*blockSize[i]) * res[i]
+			hi := float64((center[i]+r+1)*blockSize[i]-pt[i]) * res[i]
+			minUnread = math.Min(minUnread, math.Min(lo, hi))
+		}
+		if maxDist > 0 && minUnread > maxDist {
+			break
+		}
+		if len(found) >= k {
+			sort.Sort(found)
+			if found[k-1].Dist <= minUnread {
+				break
+			}
+		}
+		if r >= MaxNearestBlockRadius {
+			break
+		}
+	}
+	sort.Sort(found)
+	if len(found) > k {
+		found = found[:k]
+	}
+	return found, nil
+}
+
+// GetRadiusSynapses returns elements passing the filter within the given physical distance of
+// a point, sorted by ascending distance.  The radius cannot exceed MaxSearchDist().
+func (d *Data) GetRadiusSynapses(ctx *datastore.VersionedCtx, pt dvid.Point3d, radius float64, filter ElementFilter) (ElementDists, error) {
+	if err := d.checkSearchDist(radius); err != nil {
+		return nil, err
+	}
+	store, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return nil, err
+	}
+	blockSize := d.blockSize()
+	res := d.voxelSize()
+
+	// Get the voxel bounding box of the sphere.
+	var minPt, maxPt dvid.Point3d
+	for i := 0; i < 3; i++ {
+		extent := int32(math.Ceil(radius / res[i]))
+		minPt[i] = pt[i] - extent
+		maxPt[i] = pt[i] + extent
+	}
+	minBlock := minPt.Chunk(blockSize).(dvid.ChunkPoint3d)
+	maxBlock := maxPt.Chunk(blockSize).(dvid.ChunkPoint3d)
+
+	d.RLock()
+	defer d.RUnlock()
+
+	found := ElementDists{}
+	for z := minBlock[2]; z <= maxBlock[2]; z++ {
+		for y := minBlock[1]; y <= maxBlock[1]; y++ {
+			err := processBlockRow(ctx, store, y, z, minBlock[0], maxBlock[0], func(elem Element) {
+				if !filter.matches(elem) {
+					return
+				}
+				if dist := physicalDist(pt, elem.Pos, res); dist <= radius {
+					found = append(found, ElementDist{elem, dist})
+				}
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Sort(found)
+	return found, nil
+}
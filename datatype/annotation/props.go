@@ -0,0 +1,272 @@
+/*
+	This file supports configurable indices on element properties.
+*/
+
+package annotation
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// parseIndexedProps returns the property names in a comma-separated list.
+func parseIndexedProps(s string) ([]string, error) {
+	var props []string
+	for _, prop := range strings.Split(s, ",") {
+		prop = strings.TrimSpace(prop)
+		if prop == "" {
+			continue
+		}
+		if strings.IndexByte(prop, 0) >= 0 {
+			return nil, fmt.Errorf("indexed property name %q cannot contain 0 byte", prop)
+		}
+		props = append(props, prop)
+	}
+	return props, nil
+}
+
+// propKeys returns the property index keys for an element.
+func (d *Data) propKeys(elem ElementNR) []storage.TKey {
+	var tks []storage.TKey
+	for _, prop := range d.IndexedProps {
+		if value, found := elem.Prop[prop]; found {
+			tks = append(tks, NewPropTKey(prop, value, elem.Pos))
+		}
+	}
+	return tks
+}
+
+// putBatchProps adds property index entries for the given elements to the batch.
+func (d *Data) putBatchProps(batch storage.Batch, elems ElementsNR) error {
+	for _, elem := range elems {
+		tks := d.propKeys(elem)
+		if len(tks) == 0 {
+			continue
+		}
+		val, err := json.Marshal(elem)
+		if err != nil {
+			return err
+		}
+		for _, tk := range tks {
+			batch.Put(tk, val)
+		}
+	}
+	return nil
+}
+
+// deleteBatchProps deletes any property index entries for the given elements in the batch.
+func (d *Data) deleteBatchProps(batch storage.Batch, elems ElementsNR) {
+	for _, elem := range elems {
+		for _, tk := range d.propKeys(elem) {
+			batch.Delete(tk)
+		}
+	}
+}
+
+// storePropElements replaces the property index entries of any existing elements at the
+// positions of the new block elements.  Must be called before the block elements are stored.
+func (d *Data) storePropElements(ctx *datastore.VersionedCtx, batch storage.Batch, be map[dvid.IZYXString]Elements) error {
+	if len(d.IndexedProps) == 0 {
+		return nil
+	}
+	var toDel, toAdd ElementsNR
+	for izyx, elems := range be {
+		bcoord, err := izyx.ToChunkPoint3d()
+		if err != nil {
+			return err
+		}
+		curElems, err := getElements(ctx, NewBlockTKey(bcoord))
+		if err != nil {
+			return err
+		}
+		posted := make(map[string]struct{}, len(elems))
+		for _, elem := range elems {
+			posted[elem.Pos.MapKey()] = struct{}{}
+			toAdd = append(toAdd, elem.ElementNR)
+		}
+		for _, elem := range curElems {
+			if _, found := posted[elem.Pos.MapKey()]; found {
+				toDel = append(toDel, elem.ElementNR)
+			}
+		}
+	}
+	d.deleteBatchProps(batch, toDel)
+	return d.putBatchProps(batch, toAdd)
+}
+
+// PropQuery describes a search of a property index.  Either Value is set for an exact match
+// or Min and/or Max are set for a numeric range query where Min is inclusive and Max is
+// exclusive.  Results can be further filtered by element kind and subvolume.
+type PropQuery struct {
+	Prop   string
+	Value  *string
+	Min    *float64
+	Max    *float64
+	Kinds  map[ElementType]struct{}
+	Extent *dvid.Extents3d
+	Limit  int    // if > 0, the maximum number of elements returned
+	Cursor string // if non-empty, the cursor returned by a previous query
+}
+
+// PropResult is a page of elements returned from a property index query.  If the query was
+// limited and more elements may be available, Cursor can be used to get the next page.
+type PropResult struct {
+	Elements ElementsNR
+	Cursor   string `json:",omitempty"`
+}
+
+var errPropLimit = errors.New("property query limit reached")
+
+// keyRange returns the begin and end keys to search for the query.
+func (q PropQuery) keyRange() (begTKey, endTKey storage.TKey, err error) {
+	prefix := append([]byte(q.Prop), 0)
+	var beg, end []byte
+	switch {
+	case q.Value != nil:
+		beg = propValuePrefix(q.Prop, *q.Value)
+		end = append(append([]byte{}, beg...), 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+	case q.Min != nil || q.Max != nil:
+		if q.Min != nil {
+			beg = append(append([]byte{}, prefix...), encodePropNumber(*q.Min)...)
+		} else {
+			beg = append(append([]byte{}, prefix...), propNumber)
+		}
+		if q.Max != nil {
+			end = append(append([]byte{}, prefix...), encodePropNumber(*q.Max)...)
+		} else {
+			end = append(append([]byte{}, prefix...), propString)
+		}
+	default:
+		err = fmt.Errorf("property query requires either a value or a min/max range")
+		return
+	}
+	if q.Cursor != "" {
+		var cursor []byte
+		if cursor, err = hex.DecodeString(q.Cursor); err != nil {
+			err = fmt.Errorf("bad cursor %q: %v", q.Cursor, err)
+			return
+		}
+		beg = append(cursor, 0)
+	}
+	return storage.NewTKey(keyProp, beg), storage.NewTKey(keyProp, end), nil
+}
+
+// QueryProps returns elements matching a property index query.
+func (d *Data) QueryProps(ctx *datastore.VersionedCtx, q PropQuery) (*PropResult, error) {
+	var indexed bool
+	for _, prop := range d.IndexedProps {
+		if prop == q.Prop {
+			indexed = true
+			break
+		}
+	}
+	if !indexed {
+		return nil, fmt.Errorf("property %q is not indexed for annotation %q", q.Prop, d.DataName())
+	}
+	begTKey, endTKey, err := q.keyRange()
+	if err != nil {
+		return nil, err
+	}
+	store, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return nil, err
+	}
+
+	d.RLock()
+	defer d.RUnlock()
+
+	result := &PropResult{Elements: ElementsNR{}}
+	err = store.ProcessRange(ctx, begTKey, endTKey, nil, func(chunk *storage.Chunk) error {
+		if chunk.V == nil {
+			return nil
+		}
+		if q.Limit > 0 && len(result.Elements) == q.Limit {
+			return errPropLimit
+		}
+		var elem ElementNR
+		if err := json.Unmarshal(chunk.V, &elem); err != nil {
+			return err
+		}
+		if q.Kinds != nil {
+			if _, found := q.Kinds[elem.Kind]; !found {
+				return nil
+			}
+		}
+		if q.Extent != nil && !q.Extent.VoxelWithin(elem.Pos) {
+			return nil
+		}
+		result.Elements = append(result.Elements, elem)
+		if q.Limit > 0 && len(result.Elements) == q.Limit {
+			classBytes, err := chunk.K.ClassBytes(keyProp)
+			if err != nil {
+				return err
+			}
+			result.Cursor = hex.EncodeToString(classBytes)
+		}
+		return nil
+	})
+	if err != nil && err != errPropLimit {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ParsePropQuery returns a property index query from HTTP query strings.
+func ParsePropQuery(prop string, queryStrings map[string][]string) (q PropQuery, err error) {
+	get := func(key string) string {
+		if vals := queryStrings[key]; len(vals) > 0 {
+			return vals[0]
+		}
+		return ""
+	}
+	q.Prop = prop
+	if vals, found := queryStrings["value"]; found && len(vals) > 0 {
+		q.Value = &vals[0]
+	}
+	for _, bound := range []struct {
+		name string
+		ptr  **float64
+	}{{"min", &q.Min}, {"max", &q.Max}} {
+		if s := get(bound.name); s != "" {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return q, fmt.Errorf("bad %s value %q: %v", bound.name, s, err)
+			}
+			*bound.ptr = &f
+		}
+	}
+	if q.Value != nil && (q.Min != nil || q.Max != nil) {
+		return q, fmt.Errorf("property query cannot have both a value and a min/max range")
+	}
+	if kinds := get("kind"); kinds != "" {
+		filter, err := NewElementFilter(kinds, "")
+		if err != nil {
+			return q, err
+		}
+		q.Kinds = filter.Kinds
+	}
+	sizeStr, offsetStr := get("size"), get("offset")
+	if sizeStr != "" || offsetStr != "" {
+		if sizeStr == "" || offsetStr == "" {
+			return q, fmt.Errorf("both size and offset must be given to restrict property query to subvolume")
+		}
+		if q.Extent, err = dvid.NewExtents3dFromStrings(offsetStr, sizeStr, "_"); err != nil {
+			return
+		}
+	}
+	if s := get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil {
+			return q, fmt.Errorf("bad limit %q: %v", s, err)
+		}
+	}
+	q.Cursor = get("cursor")
+	return q, nil
+}
@@ -0,0 +1,316 @@
+/*
+	This file supports queries of annotations within one or more ROIs of arbitrary block size.
+*/
+
+package annotation
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/datatype/roi"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// ROISetOp determines how multiple ROIs are combined in an ROI query.
+type ROISetOp uint8
+
+const (
+	ROIUnion        ROISetOp = iota // elements within any of the ROIs
+	ROIIntersection                 // elements within all of the ROIs
+)
+
+// ElementFilter restricts the elements returned by a query to the given kinds and tag.
+// An empty filter matches all elements.
+type ElementFilter struct {
+	Kinds map[ElementType]struct{}
+	Tag   Tag
+}
+
+// NewElementFilter returns a filter given a comma-separated list of element kinds and a tag,
+// either of which can be empty.
+func NewElementFilter(kinds string, tag string) (ElementFilter, error) {
+	var filter ElementFilter
+	if kinds != "" {
+		filter.Kinds = make(map[ElementType]struct{})
+		for _, kindStr := range strings.Split(kinds, ",") {
+			kind := StringToElementType(kindStr)
+			if kind == UnknownElem && kindStr != "Unknown" {
+				return filter, fmt.Errorf("unknown element kind %q", kindStr)
+			}
+			filter.Kinds[kind] = struct{}{}
+		}
+	}
+	filter.Tag = Tag(tag)
+	return filter, nil
+}
+
+func (f ElementFilter) matches(elem Element) bool {
+	if f.Kinds != nil {
+		if _, found := f.Kinds[elem.Kind]; !found {
+			return false
+		}
+	}
+	if f.Tag != "" {
+		for _, tag := range elem.Tags {
+			if tag == f.Tag {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// GetElementPoints returns the positions of elements of the given comma-separated kinds with
+// the given tag, limited to the given label if nonzero.  Empty kinds or tag match all elements.
+// Implements the roi.PointSource interface.
+func (d *Data) GetElementPoints(v dvid.VersionID, label uint64, tag, kinds string) ([]dvid.Point3d, error) {
+	filter, err := NewElementFilter(kinds, tag)
+	if err != nil {
+		return nil, err
+	}
+	ctx := datastore.NewVersionedCtx(d, v)
+
+	d.RLock()
+	defer d.RUnlock()
+
+	var pts []dvid.Point3d
+	addElems := func(elems ElementsNR) {
+		for _, elem := range elems {
+			if filter.matches(Element{ElementNR: elem}) {
+				pts = append(pts, elem.Pos)
+			}
+		}
+	}
+	var tk storage.TKey
+	switch {
+	case label != 0:
+		tk = NewLabelTKey(label)
+	case tag != "":
+		if tk, err = NewTagTKey(Tag(tag)); err != nil {
+			return nil, err
+		}
+	default:
+		store, err := datastore.GetOrderedKeyValueDB(d)
+		if err != nil {
+			return nil, err
+		}
+		err = store.ProcessRange(ctx, storage.MinTKey(keyBlock), storage.MaxTKey(keyBlock), nil, func(chunk *storage.Chunk) error {
+			if chunk.V == nil {
+				return nil
+			}
+			var elems ElementsNR
+			if err := json.Unmarshal(chunk.V, &elems); err != nil {
+				return err
+			}
+			addElems(elems)
+			return nil
+		})
+		return pts, err
+	}
+	elems, err := getElementsNR(ctx, tk)
+	if err != nil {
+		return nil, err
+	}
+	addElems(elems)
+	return pts, nil
+}
+
+// roiBlocks holds the blocks of an ROI at the ROI's own block size and the masks of any
+// boundary blocks for voxel-precision ROIs.
+type roiBlocks struct {
+	blockSize dvid.Point3d
+	spans     []dvid.Span
+	blocks    map[dvid.IZYXString]struct{}
+	masks     map[dvid.IZYXString]roi.BlockMask
+}
+
+func getROIBlocks(roiSpec storage.FilterSpec) (*roiBlocks, error) {
+	roidata, roiV, roiFound, err := roi.DataByFilter(roiSpec)
+	if err != nil {
+		return nil, fmt.Errorf("ROI specification was not parsable (%s): %v", roiSpec, err)
+	}
+	if !roiFound {
+		return nil, fmt.Errorf("No ROI found that matches specification %q", roiSpec)
+	}
+	spans, err := roidata.GetSpans(roiV)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get ROI spans for %q: %v", roiSpec, err)
+	}
+	masks, err := roidata.GetBlockMasks(roiV)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get ROI block masks for %q: %v", roiSpec, err)
+	}
+	rb := &roiBlocks{
+		blockSize: roidata.BlockSize,
+		spans:     spans,
+		blocks:    make(map[dvid.IZYXString]struct{}),
+		masks:     masks,
+	}
+	for _, span := range spans {
+		for x := span[2]; x <= span[3]; x++ {
+			rb.blocks[dvid.ChunkPoint3d{x, span[1], span[0]}.ToIZYXString()] = struct{}{}
+		}
+	}
+	return rb, nil
+}
+
+func (rb *roiBlocks) voxelWithin(pt dvid.Point3d) bool {
+	izyx := pt.ToBlockIZYXString(rb.blockSize)
+	if _, found := rb.blocks[izyx]; !found {
+		return false
+	}
+	if mask, found := rb.masks[izyx]; found {
+		return mask.VoxelWithin(pt, rb.blockSize)
+	}
+	return true
+}
+
+// floorDiv returns the floor of a / b for positive b.
+func floorDiv(a, b int32) int32 {
+	if a < 0 {
+		return (a - b + 1) / b
+	}
+	return a / b
+}
+
+// annotationBlocks returns the blocks at the given block size that intersect the ROI.
+func (rb *roiBlocks) annotationBlocks(blockSize dvid.Point3d) map[dvid.IZYXString]struct{} {
+	blocks := make(map[dvid.IZYXString]struct{})
+	for _, span := range rb.spans {
+		z0 := floorDiv(span[0]*rb.blockSize[2], blockSize[2])
+		z1 := floorDiv((span[0]+1)*rb.blockSize[2]-1, blockSize[2])
+		y0 := floorDiv(span[1]*rb.blockSize[1], blockSize[1])
+		y1 := floorDiv((span[1]+1)*rb.blockSize[1]-1, blockSize[1])
+		x0 := floorDiv(span[2]*rb.blockSize[0], blockSize[0])
+		x1 := floorDiv((span[3]+1)*rb.blockSize[0]-1, blockSize[0])
+		for z := z0; z <= z1; z++ {
+			for y := y0; y <= y1; y++ {
+				for x := x0; x <= x1; x++ {
+					blocks[dvid.ChunkPoint3d{x, y, z}.ToIZYXString()] = struct{}{}
+				}
+			}
+		}
+	}
+	return blocks
+}
+
+// blockRuns groups block coordinates into runs of consecutive blocks along x.
+func blockRuns(blocks map[dvid.IZYXString]struct{}) ([][2]dvid.ChunkPoint3d, error) {
+	coords := make([]string, 0, len(blocks))
+	for izyx := range blocks {
+		coords = append(coords, string(izyx))
+	}
+	sort.Strings(coords)
+
+	var runs [][2]dvid.ChunkPoint3d
+	for _, coord := range coords {
+		bcoord, err := dvid.IZYXString(coord).ToChunkPoint3d()
+		if err != nil {
+			return nil, err
+		}
+		n := len(runs) - 1
+		if n >= 0 {
+			last := runs[n][1]
+			if last[2] == bcoord[2] && last[1] == bcoord[1] && last[0]+1 == bcoord[0] {
+				runs[n][1] = bcoord
+				continue
+			}
+		}
+		runs = append(runs, [2]dvid.ChunkPoint3d{bcoord, bcoord})
+	}
+	return runs, nil
+}
+
+// GetROIsSynapses returns the elements within the union or intersection of the given ROIs that
+// also pass the filter.  The ROIs may have any block size.
+func (d *Data) GetROIsSynapses(ctx *datastore.VersionedCtx, roiSpecs []storage.FilterSpec, op ROISetOp, filter ElementFilter) (Elements, error) {
+	if len(roiSpecs) == 0 {
+		return nil, fmt.Errorf("no ROI specified for annotation %q query", d.DataName())
+	}
+	rois := make([]*roiBlocks, len(roiSpecs))
+	for i, roiSpec := range roiSpecs {
+		rb, err := getROIBlocks(roiSpec)
+		if err != nil {
+			return nil, err
+		}
+		rois[i] = rb
+	}
+
+	// Determine the annotation blocks that need to be read.
+	blockSize := d.blockSize()
+	var blocks map[dvid.IZYXString]struct{}
+	switch op {
+	case ROIUnion:
+		blocks = make(map[dvid.IZYXString]struct{})
+		for _, rb := range rois {
+			for izyx := range rb.annotationBlocks(blockSize) {
+				blocks[izyx] = struct{}{}
+			}
+		}
+	case ROIIntersection:
+		for _, rb := range rois {
+			rbBlocks := rb.annotationBlocks(blockSize)
+			if blocks == nil {
+				blocks = rbBlocks
+				continue
+			}
+			for izyx := range blocks {
+				if _, found := rbBlocks[izyx]; !found {
+					delete(blocks, izyx)
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown ROI set operation %d", op)
+	}
+	runs, err := blockRuns(blocks)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return nil, err
+	}
+
+	d.RLock()
+	defer d.RUnlock()
+
+	elements := Elements{}
+	for _, run := range runs {
+		begTKey := NewBlockTKey(run[0])
+		endTKey := NewBlockTKey(run[1])
+		err = store.ProcessRange(ctx, begTKey, endTKey, nil, func(chunk *storage.Chunk) error {
+			var blockElems Elements
+			if err := json.Unmarshal(chunk.V, &blockElems); err != nil {
+				return err
+			}
+			for _, elem := range blockElems {
+				if !filter.matches(elem) {
+					continue
+				}
+				within := (op == ROIIntersection)
+				for _, rb := range rois {
+					if rb.voxelWithin(elem.Pos) != within {
+						within = !within
+						break
+					}
+				}
+				if within {
+					elements = append(elements, elem)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving annotations from %s -> %s: %v", run[0], run[1], err)
+		}
+	}
+	return elements, nil
+}
@@ -0,0 +1,307 @@
+/*
+	This file supports optional per-instance schemas for validating posted elements.
+*/
+
+package annotation
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FirstCustomKind is the ElementType of the first registered custom element kind.
+const FirstCustomKind ElementType = 16
+
+// MaxValidationErrors is the maximum number of validation errors reported for a POST.
+const MaxValidationErrors = 100
+
+// Custom element kinds are registered across all annotation instances so element JSON can be
+// decoded without reference to an instance.  Kinds are serialized by name, so the in-memory
+// ElementType assigned to a custom kind can differ across server restarts.
+var (
+	customKindsMu   sync.RWMutex
+	customKinds     = make(map[string]ElementType)
+	customKindNames = make(map[ElementType]string)
+)
+
+// registerCustomKind returns the ElementType for a custom kind name, registering it if needed.
+func registerCustomKind(name string) (ElementType, error) {
+	if name == "" {
+		return UnknownElem, fmt.Errorf("custom element kind cannot be empty")
+	}
+	if name == "Unknown" || builtinElementType(name) != UnknownElem {
+		return UnknownElem, fmt.Errorf("custom element kind %q is a built-in kind", name)
+	}
+	customKindsMu.Lock()
+	defer customKindsMu.Unlock()
+	if kind, found := customKinds[name]; found {
+		return kind, nil
+	}
+	kind := FirstCustomKind + ElementType(len(customKinds))
+	if kind < FirstCustomKind {
+		return UnknownElem, fmt.Errorf("too many custom element kinds registered, cannot add %q", name)
+	}
+	customKinds[name] = kind
+	customKindNames[kind] = name
+	return kind, nil
+}
+
+func customKindByName(name string) (ElementType, bool) {
+	customKindsMu.RLock()
+	kind, found := customKinds[name]
+	customKindsMu.RUnlock()
+	return kind, found
+}
+
+func customKindName(kind ElementType) (string, bool) {
+	customKindsMu.RLock()
+	name, found := customKindNames[kind]
+	customKindsMu.RUnlock()
+	return name, found
+}
+
+// PropSchema describes an allowed element property.
+type PropSchema struct {
+	// Type is one of "string", "number", "integer", or "boolean".  Default is "string".
+	Type string `json:",omitempty"`
+
+	// Required properties must be present in elements of the given Kinds.
+	Required bool `json:",omitempty"`
+
+	// Enum, if non-empty, lists the only allowed values.
+	Enum []string `json:",omitempty"`
+
+	// Kinds, if non-empty, are the element kinds to which this property applies.
+	// The property is not allowed for other kinds.
+	Kinds []string `json:",omitempty"`
+}
+
+// RelSchema is a rule on the number of relationships of a given type for an element kind.
+type RelSchema struct {
+	Kind string
+	Rel  RelationType
+	Min  int
+	Max  int `json:",omitempty"` // if 0, there is no maximum
+}
+
+// Schema describes the allowed kinds, properties, and relationships of posted elements.
+type Schema struct {
+	// Kinds are custom element kinds allowed in addition to the built-in kinds.
+	Kinds []string `json:",omitempty"`
+
+	// Properties describes the allowed element properties by name.
+	Properties map[string]PropSchema `json:",omitempty"`
+
+	// AdditionalProperties allows properties not in Properties.  Default is true.
+	AdditionalProperties *bool `json:",omitempty"`
+
+	// Relationships are rules on the number of relationships for element kinds.
+	Relationships []RelSchema `json:",omitempty"`
+}
+
+func isKnownKind(name string, custom []string) bool {
+	if name == "Unknown" || builtinElementType(name) != UnknownElem {
+		return true
+	}
+	for _, kind := range custom {
+		if kind == name {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns an error if the schema itself is invalid.
+func (s *Schema) check() error {
+	for name, prop := range s.Properties {
+		if name == "" {
+			return fmt.Errorf("schema property names cannot be empty")
+		}
+		switch prop.Type {
+		case "", "string", "number", "integer", "boolean":
+		default:
+			return fmt.Errorf("schema property %q has unknown type %q", name, prop.Type)
+		}
+		for _, value := range prop.Enum {
+			if err := checkPropType(prop.Type, value); err != nil {
+				return fmt.Errorf("schema property %q enum value: %v", name, err)
+			}
+		}
+		for _, kind := range prop.Kinds {
+			if !isKnownKind(kind, s.Kinds) {
+				return fmt.Errorf("schema property %q has unknown kind %q", name, kind)
+			}
+		}
+	}
+	for _, rule := range s.Relationships {
+		if !isKnownKind(rule.Kind, s.Kinds) {
+			return fmt.Errorf("schema relationship rule has unknown kind %q", rule.Kind)
+		}
+		if rule.Min < 0 || rule.Max < 0 || (rule.Max != 0 && rule.Max < rule.Min) {
+			return fmt.Errorf("schema relationship rule for %s %s has bad bounds [%d, %d]", rule.Kind, rule.Rel, rule.Min, rule.Max)
+		}
+	}
+	return nil
+}
+
+// register registers the custom kinds of the schema.
+func (s *Schema) register() error {
+	for _, name := range s.Kinds {
+		if _, err := registerCustomKind(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseSchema returns a schema from JSON, checking its validity and registering its custom kinds.
+func ParseSchema(data []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("bad annotation schema: %v", err)
+	}
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	if err := s.register(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func checkPropType(propType, value string) error {
+	var err error
+	switch propType {
+	case "number":
+		_, err = strconv.ParseFloat(value, 64)
+	case "integer":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case "boolean":
+		_, err = strconv.ParseBool(value)
+	}
+	if err != nil {
+		return fmt.Errorf("value %q is not a %s", value, propType)
+	}
+	return nil
+}
+
+// kindName returns the name of an element kind as used in JSON.
+func kindName(kind ElementType) string {
+	if kind == UnknownElem {
+		return "Unknown"
+	}
+	return kind.String()
+}
+
+func kindIn(kind ElementType, names []string) bool {
+	for _, name := range names {
+		if kindName(kind) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateElement returns a description of each way the element violates the schema.
+func (s *Schema) validateElement(elem Element) []string {
+	var errs []string
+	if elem.Kind >= FirstCustomKind && !kindIn(elem.Kind, s.Kinds) {
+		errs = append(errs, fmt.Sprintf("kind %q is not allowed", elem.Kind))
+	}
+	propNames := make([]string, 0, len(elem.Prop))
+	for name := range elem.Prop {
+		propNames = append(propNames, name)
+	}
+	sort.Strings(propNames)
+	for _, name := range propNames {
+		value := elem.Prop[name]
+		prop, found := s.Properties[name]
+		if !found {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				errs = append(errs, fmt.Sprintf("property %q is not allowed", name))
+			}
+			continue
+		}
+		if len(prop.Kinds) != 0 && !kindIn(elem.Kind, prop.Kinds) {
+			errs = append(errs, fmt.Sprintf("property %q is not allowed for kind %s", name, elem.Kind))
+			continue
+		}
+		if err := checkPropType(prop.Type, value); err != nil {
+			errs = append(errs, fmt.Sprintf("property %q %v", name, err))
+			continue
+		}
+		if len(prop.Enum) != 0 {
+			var allowed bool
+			for _, enum := range prop.Enum {
+				if value == enum {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				errs = append(errs, fmt.Sprintf("property %q value %q is not one of [%s]", name, value, strings.Join(prop.Enum, ", ")))
+			}
+		}
+	}
+	var required []string
+	for name, prop := range s.Properties {
+		if !prop.Required || (len(prop.Kinds) != 0 && !kindIn(elem.Kind, prop.Kinds)) {
+			continue
+		}
+		if _, found := elem.Prop[name]; !found {
+			required = append(required, name)
+		}
+	}
+	sort.Strings(required)
+	for _, name := range required {
+		errs = append(errs, fmt.Sprintf("required property %q is missing", name))
+	}
+	for _, rule := range s.Relationships {
+		if kindName(elem.Kind) != rule.Kind {
+			continue
+		}
+		var n int
+		for _, rel := range elem.Rels {
+			if rel.Rel == rule.Rel {
+				n++
+			}
+		}
+		if n < rule.Min {
+			errs = append(errs, fmt.Sprintf("has %d %s relationships but needs at least %d", n, rule.Rel, rule.Min))
+		}
+		if rule.Max != 0 && n > rule.Max {
+			errs = append(errs, fmt.Sprintf("has %d %s relationships but can have at most %d", n, rule.Rel, rule.Max))
+		}
+	}
+	return errs
+}
+
+// validateElements returns an error describing up to MaxValidationErrors schema violations
+// among the elements.  Custom kinds are only allowed if they are in the instance's schema.
+func (d *Data) validateElements(elems Elements) error {
+	s := d.Schema
+	if s == nil {
+		s = &Schema{}
+	}
+	var errs []string
+	var numErrs int
+	for _, elem := range elems {
+		for _, msg := range s.validateElement(elem) {
+			numErrs++
+			if len(errs) < MaxValidationErrors {
+				errs = append(errs, fmt.Sprintf("element at %s: %s", elem.Pos, msg))
+			}
+		}
+	}
+	if numErrs == 0 {
+		return nil
+	}
+	if numErrs > len(errs) {
+		errs = append(errs, fmt.Sprintf("... and %d more errors", numErrs-len(errs)))
+	}
+	return fmt.Errorf("%d schema violations in posted elements for annotation %q:\n%s", numErrs, d.DataName(), strings.Join(errs, "\n"))
+}
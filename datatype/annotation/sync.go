@@ -384,6 +384,8 @@ func (d *Data) ingestBlock(ctx *datastore.VersionedCtx, chunkPt dvid.ChunkPoint3
 		return
 	}
 
+	d.invalidatePartners(ctx, batcher, delta)
+
 	// Notify any subscribers of label annotation changes.
 	evt := datastore.SyncEvent{Data: d.DataUUID(), Event: ModifyElementsEvent}
 	msg := datastore.SyncMessage{Event: ModifyElementsEvent, Version: ctx.VersionID(), Delta: delta}
@@ -466,6 +468,8 @@ func (d *Data) mutateBlock(ctx *datastore.VersionedCtx, chunkPt dvid.ChunkPoint3
 		return
 	}
 
+	d.invalidatePartners(ctx, batcher, delta)
+
 	// Notify any subscribers of label annotation changes.
 	evt := datastore.SyncEvent{Data: d.DataUUID(), Event: ModifyElementsEvent}
 	msg := datastore.SyncMessage{Event: ModifyElementsEvent, Version: ctx.VersionID(), Delta: delta}
@@ -523,6 +527,8 @@ func (d *Data) mergeLabels(batcher storage.KeyValueBatcher, v dvid.VersionID, op
 	}
 	d.StopUpdate()
 
+	d.invalidatePartners(ctx, batcher, delta)
+
 	// Notify any subscribers of label annotation changes.
 	evt := datastore.SyncEvent{Data: d.DataUUID(), Event: ModifyElementsEvent}
 	msg := datastore.SyncMessage{Event: ModifyElementsEvent, Version: ctx.VersionID(), Delta: delta}
@@ -622,6 +628,8 @@ func (d *Data) cleaveLabels(batcher storage.KeyValueBatcher, v dvid.VersionID, o
 		return fmt.Errorf("bad commit in annotations %q after split: %v", d.DataName(), err)
 	}
 
+	d.invalidatePartners(ctx, batcher, delta)
+
 	// Notify any subscribers of label annotation changes.
 	if len(delta.Add) != 0 || len(delta.Del) != 0 {
 		evt := datastore.SyncEvent{Data: d.DataUUID(), Event: ModifyElementsEvent}
@@ -714,6 +722,8 @@ func (d *Data) splitLabelsCoarse(batcher storage.KeyValueBatcher, v dvid.Version
 		return fmt.Errorf("bad commit in annotations %q after split: %v", d.DataName(), err)
 	}
 
+	d.invalidatePartners(ctx, batcher, delta)
+
 	// Notify any subscribers of label annotation changes.
 	evt := datastore.SyncEvent{Data: d.DataUUID(), Event: ModifyElementsEvent}
 	msg := datastore.SyncMessage{Event: ModifyElementsEvent, Version: ctx.VersionID(), Delta: delta}
@@ -814,6 +824,8 @@ func (d *Data) splitLabels(batcher storage.KeyValueBatcher, v dvid.VersionID, op
 		return fmt.Errorf("bad commit in annotations %q after split: %v", d.DataName(), err)
 	}
 
+	d.invalidatePartners(ctx, batcher, delta)
+
 	// Notify any subscribers of label annotation changes.
 	evt := datastore.SyncEvent{Data: d.DataUUID(), Event: ModifyElementsEvent}
 	msg := datastore.SyncMessage{Event: ModifyElementsEvent, Version: ctx.VersionID(), Delta: delta}
@@ -0,0 +1,138 @@
+package chunked
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/janelia-flyem/dvid/dvid"
+)
+
+// OffsetAttr is the array attribute holding the DVID voxel coordinate of the array's
+// first voxel at that array's scale.  It allows exported data to be imported back into
+// the same location, since chunked arrays can't have negative indices.
+const OffsetAttr = "dvidOffset"
+
+// ScaleName returns the array name used for a given scale, e.g., "s0" for scale 0.
+func ScaleName(scale uint8) string {
+	return fmt.Sprintf("s%d", scale)
+}
+
+// returns voxel size and units in x, y, z order for a scale, padding any missing
+// dimensions with unit resolution.
+func scaledResolution(res dvid.Resolution, scale uint8) ([]float32, []string) {
+	factor := float32(int(1) << scale)
+	size := make([]float32, 3)
+	units := make([]string, 3)
+	for i := 0; i < 3; i++ {
+		size[i] = factor
+		if i < len(res.VoxelSize) {
+			size[i] *= res.VoxelSize[i]
+		}
+		units[i] = "nanometers"
+		if i < len(res.VoxelUnits) {
+			units[i] = res.VoxelUnits[i]
+		}
+	}
+	return size, units
+}
+
+// ngffUnit converts DVID units like "nanometers" into OME-NGFF units like "nanometer".
+func ngffUnit(unit string) string {
+	return strings.TrimSuffix(strings.ToLower(unit), "s")
+}
+
+// ScaleAttrs returns the attributes for an array holding the given scale where scale 0
+// has the given resolution and each higher scale is 2x downsampled.  The offset is the
+// DVID voxel coordinate, at the given scale, of the array's first voxel.
+func ScaleAttrs(format Format, res dvid.Resolution, scale uint8, offset dvid.Point3d) map[string]interface{} {
+	size, units := scaledResolution(res, scale)
+	attrs := map[string]interface{}{
+		"resolution": size,
+		"units":      units,
+		OffsetAttr:   []int32{offset[0], offset[1], offset[2]},
+	}
+	if format == N5 {
+		factor := int(1) << scale
+		attrs["downsamplingFactors"] = []int{factor, factor, factor}
+		attrs["pixelResolution"] = map[string]interface{}{
+			"dimensions": size,
+			"unit":       units[0],
+		}
+	}
+	return attrs
+}
+
+// MultiscaleAttrs returns root group attributes describing arrays "s0", "s1", ... for
+// the given number of scales.  N5 stores use the "scales" convention of n5-viewer while
+// Zarr stores use OME-NGFF "multiscales" metadata.
+func MultiscaleAttrs(format Format, name string, res dvid.Resolution, numScales uint8) map[string]interface{} {
+	base, units := scaledResolution(res, 0)
+	if format == N5 {
+		scales := make([][]int, numScales)
+		for scale := uint8(0); scale < numScales; scale++ {
+			factor := int(1) << scale
+			scales[scale] = []int{factor, factor, factor}
+		}
+		return map[string]interface{}{
+			"scales":     scales,
+			"resolution": base,
+			"units":      units,
+		}
+	}
+	axes := []map[string]string{
+		{"name": "z", "type": "space", "unit": ngffUnit(units[2])},
+		{"name": "y", "type": "space", "unit": ngffUnit(units[1])},
+		{"name": "x", "type": "space", "unit": ngffUnit(units[0])},
+	}
+	datasets := make([]map[string]interface{}, numScales)
+	for scale := uint8(0); scale < numScales; scale++ {
+		size, _ := scaledResolution(res, scale)
+		datasets[scale] = map[string]interface{}{
+			"path": ScaleName(scale),
+			"coordinateTransformations": []map[string]interface{}{
+				{"type": "scale", "scale": []float32{size[2], size[1], size[0]}},
+			},
+		}
+	}
+	return map[string]interface{}{
+		"multiscales": []map[string]interface{}{
+			{
+				"version":  "0.4",
+				"name":     name,
+				"axes":     axes,
+				"datasets": datasets,
+			},
+		},
+	}
+}
+
+// OffsetFromAttrs returns the DVID voxel offset stored in array attributes, if present.
+func OffsetFromAttrs(attrs map[string]interface{}) (offset dvid.Point3d, found bool) {
+	vals, err := attrInts(attrs, OffsetAttr)
+	if err != nil || len(vals) != 3 {
+		return
+	}
+	return dvid.Point3d{int32(vals[0]), int32(vals[1]), int32(vals[2])}, true
+}
+
+// ScaleOffset returns the block-aligned DVID voxel offset of an imported scale array.  The
+// offset is read from the array attributes if present.  Otherwise scale 0 uses the given
+// offset, and lower-resolution scales use it downsampled by the scale's factor, which must
+// be block aligned.
+func ScaleOffset(attrs map[string]interface{}, scale uint8, offset0, blockSize dvid.Point3d) (dvid.Point3d, error) {
+	offset, found := OffsetFromAttrs(attrs)
+	if !found {
+		offset = dvid.Point3d{offset0[0] >> scale, offset0[1] >> scale, offset0[2] >> scale}
+	}
+	for i := 0; i < 3; i++ {
+		if offset[i]%blockSize[i] == 0 {
+			continue
+		}
+		if !found && scale > 0 {
+			return offset, fmt.Errorf("array %q has no %q attribute and scale 0 offset %s downsampled by %d to %s is not block aligned; add the attribute or import only %q so lower scales are computed",
+				ScaleName(scale), OffsetAttr, offset0, 1<<scale, offset, ScaleName(0))
+		}
+		return offset, fmt.Errorf("offset %s for array %q is not block aligned", offset, ScaleName(scale))
+	}
+	return offset, nil
+}
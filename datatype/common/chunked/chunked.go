@@ -0,0 +1,717 @@
+/*
+	Package chunked reads and writes directory-based chunked arrays in the Zarr v2 and N5
+	formats so DVID block data can be exchanged with tools like zarr-python, z5py, tensorstore
+	and neuroglancer.  Chunk shape is always set to the DVID block size so each stored block
+	corresponds to exactly one chunk file.
+
+	All chunk data passed into or returned from this package uses the DVID block layout:
+	little-endian values with x varying most rapidly, and full block-sized buffers even for
+	chunks on the upper boundary of an array.  Conversion to the on-disk byte order and
+	any cropping of edge chunks is handled internally.
+*/
+package chunked
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/janelia-flyem/dvid/dvid"
+)
+
+// Format is a directory-based chunked array format.
+type Format uint8
+
+const (
+	// Zarr is the Zarr v2 storage specification.
+	Zarr Format = iota
+
+	// N5 is the N5 storage specification, version 2.
+	N5
+)
+
+const n5Version = "2.0.0"
+
+func (f Format) String() string {
+	switch f {
+	case Zarr:
+		return "zarr"
+	case N5:
+		return "n5"
+	default:
+		return "unknown chunked format"
+	}
+}
+
+// ParseFormat returns the Format given a string, e.g., "zarr" or "n5".
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "zarr":
+		return Zarr, nil
+	case "n5":
+		return N5, nil
+	default:
+		return Zarr, fmt.Errorf("unknown chunked array format %q: must be \"zarr\" or \"n5\"", s)
+	}
+}
+
+// ParseCompression returns true if the given compression setting requires gzip chunks.
+// The default for an empty setting is gzip.
+func ParseCompression(s string) (gzip bool, err error) {
+	switch strings.ToLower(s) {
+	case "", "gzip":
+		return true, nil
+	case "raw", "none":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown chunk compression %q: must be \"gzip\" or \"raw\"", s)
+	}
+}
+
+// ArrayMeta describes a 3d scalar array using DVID (x, y, z) axis order.
+type ArrayMeta struct {
+	Size      dvid.Point3d // size of the array in voxels
+	ChunkSize dvid.Point3d // size of each chunk in voxels; should be DVID block size
+	DataType  dvid.DataType
+	Gzip      bool // if true, chunks are gzip compressed.
+
+	// Attrs are user attributes stored with the array, e.g., resolution.
+	Attrs map[string]interface{}
+}
+
+// ChunkBytes returns the number of bytes in a full, uncompressed chunk.
+func (m ArrayMeta) ChunkBytes() int {
+	return int(m.ChunkSize.Prod()) * int(dvid.DataTypeBytes(m.DataType))
+}
+
+// NumChunks returns the number of chunks along each axis.
+func (m ArrayMeta) NumChunks() dvid.ChunkPoint3d {
+	var n dvid.ChunkPoint3d
+	for i := 0; i < 3; i++ {
+		n[i] = (m.Size[i] + m.ChunkSize[i] - 1) / m.ChunkSize[i]
+	}
+	return n
+}
+
+// ScaledBounds returns the block-aligned voxel offset and the voxel size of an array at
+// the given scale that covers the scale 0 voxel extents given by minPt and maxPt.
+func ScaledBounds(minPt, maxPt, blockSize dvid.Point3d, scale uint8) (offset, size dvid.Point3d) {
+	for i := 0; i < 3; i++ {
+		lo := minPt[i] >> scale
+		hi := maxPt[i] >> scale
+		offset[i] = lo - mod(lo, blockSize[i])
+		size[i] = hi - offset[i] + 1
+	}
+	return
+}
+
+// returns the non-negative remainder of a / b.
+func mod(a, b int32) int32 {
+	r := a % b
+	if r < 0 {
+		r += b
+	}
+	return r
+}
+
+// ToUint64 converts little-endian unsigned integer data of the given type into
+// little-endian uint64 data, as used by DVID label blocks.
+func ToUint64(data []byte, t dvid.DataType) ([]byte, error) {
+	var valueBytes int
+	switch t {
+	case dvid.T_uint8:
+		valueBytes = 1
+	case dvid.T_uint16:
+		valueBytes = 2
+	case dvid.T_uint32:
+		valueBytes = 4
+	case dvid.T_uint64:
+		return data, nil
+	default:
+		return nil, fmt.Errorf("can't convert data type %q to uint64 labels", n5Types[t])
+	}
+	numValues := len(data) / valueBytes
+	out := make([]byte, numValues*8)
+	for i := 0; i < numValues; i++ {
+		copy(out[i*8:i*8+valueBytes], data[i*valueBytes:(i+1)*valueBytes])
+	}
+	return out, nil
+}
+
+// Store is a chunked array store rooted at a directory.
+type Store struct {
+	Root   string
+	Format Format
+}
+
+// Create initializes a new store at the given directory, which is created if necessary.
+// The given attributes are stored as root group attributes.
+func Create(root string, format Format, attrs map[string]interface{}) (*Store, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	s := &Store{Root: root, Format: format}
+	switch format {
+	case Zarr:
+		if err := writeJSON(filepath.Join(root, ".zgroup"), map[string]interface{}{"zarr_format": 2}); err != nil {
+			return nil, err
+		}
+		if len(attrs) != 0 {
+			if err := writeJSON(filepath.Join(root, ".zattrs"), attrs); err != nil {
+				return nil, err
+			}
+		}
+	case N5:
+		rootAttrs := map[string]interface{}{"n5": n5Version}
+		for k, v := range attrs {
+			rootAttrs[k] = v
+		}
+		if err := writeJSON(filepath.Join(root, "attributes.json"), rootAttrs); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unable to create store with unknown format %d", format)
+	}
+	return s, nil
+}
+
+// Open returns a Store for an existing directory, detecting its format.
+func Open(root string) (*Store, error) {
+	if _, err := os.Stat(filepath.Join(root, ".zgroup")); err == nil {
+		return &Store{Root: root, Format: Zarr}, nil
+	}
+	if _, err := os.Stat(filepath.Join(root, ".zarray")); err == nil {
+		return &Store{Root: root, Format: Zarr}, nil
+	}
+	if _, err := os.Stat(filepath.Join(root, "attributes.json")); err == nil {
+		return &Store{Root: root, Format: N5}, nil
+	}
+	return nil, fmt.Errorf("directory %q is not a Zarr or N5 store", root)
+}
+
+// Attrs returns the root group attributes of the store.
+func (s *Store) Attrs() (map[string]interface{}, error) {
+	var fname string
+	if s.Format == Zarr {
+		fname = filepath.Join(s.Root, ".zattrs")
+	} else {
+		fname = filepath.Join(s.Root, "attributes.json")
+	}
+	attrs := make(map[string]interface{})
+	if err := readJSON(fname, &attrs); err != nil {
+		if os.IsNotExist(err) {
+			return attrs, nil
+		}
+		return nil, err
+	}
+	return attrs, nil
+}
+
+// HasArray returns true if an array with the given name exists in the store.
+func (s *Store) HasArray(name string) bool {
+	var fname string
+	if s.Format == Zarr {
+		fname = filepath.Join(s.Root, name, ".zarray")
+	} else {
+		fname = filepath.Join(s.Root, name, "attributes.json")
+	}
+	_, err := os.Stat(fname)
+	return err == nil
+}
+
+// Array is a 3d array within a Store.
+type Array struct {
+	ArrayMeta
+
+	store     *Store
+	dir       string
+	bigEndian bool   // only true for Zarr arrays with big-endian dtype
+	separator string // chunk key separator for Zarr
+}
+
+type zarrCompressor struct {
+	ID    string `json:"id"`
+	Level int    `json:"level"`
+}
+
+type zarrArray struct {
+	ZarrFormat         int             `json:"zarr_format"`
+	Shape              []int64         `json:"shape"`
+	Chunks             []int64         `json:"chunks"`
+	DType              string          `json:"dtype"`
+	Compressor         *zarrCompressor `json:"compressor"`
+	FillValue          int             `json:"fill_value"`
+	Order              string          `json:"order"`
+	Filters            []interface{}   `json:"filters"`
+	DimensionSeparator string          `json:"dimension_separator,omitempty"`
+}
+
+// CreateArray creates a new array with the given name, overwriting any array metadata
+// that may already be present.
+func (s *Store) CreateArray(name string, meta ArrayMeta) (*Array, error) {
+	dir := filepath.Join(s.Root, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	a := &Array{ArrayMeta: meta, store: s, dir: dir, separator: "."}
+	switch s.Format {
+	case Zarr:
+		dtype, err := zarrDType(meta.DataType)
+		if err != nil {
+			return nil, err
+		}
+		za := zarrArray{
+			ZarrFormat: 2,
+			Shape:      []int64{int64(meta.Size[2]), int64(meta.Size[1]), int64(meta.Size[0])},
+			Chunks:     []int64{int64(meta.ChunkSize[2]), int64(meta.ChunkSize[1]), int64(meta.ChunkSize[0])},
+			DType:      dtype,
+			Order:      "C",
+		}
+		if meta.Gzip {
+			za.Compressor = &zarrCompressor{ID: "gzip", Level: 5}
+		}
+		if err := writeJSON(filepath.Join(dir, ".zarray"), za); err != nil {
+			return nil, err
+		}
+		if len(meta.Attrs) != 0 {
+			if err := writeJSON(filepath.Join(dir, ".zattrs"), meta.Attrs); err != nil {
+				return nil, err
+			}
+		}
+	case N5:
+		attrs := make(map[string]interface{}, len(meta.Attrs)+4)
+		for k, v := range meta.Attrs {
+			attrs[k] = v
+		}
+		attrs["dimensions"] = []int64{int64(meta.Size[0]), int64(meta.Size[1]), int64(meta.Size[2])}
+		attrs["blockSize"] = []int32{meta.ChunkSize[0], meta.ChunkSize[1], meta.ChunkSize[2]}
+		attrs["dataType"] = n5Types[meta.DataType]
+		if meta.Gzip {
+			attrs["compression"] = map[string]interface{}{"type": "gzip", "level": -1}
+		} else {
+			attrs["compression"] = map[string]interface{}{"type": "raw"}
+		}
+		if err := writeJSON(filepath.Join(dir, "attributes.json"), attrs); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// OpenArray opens an existing array with the given name.
+func (s *Store) OpenArray(name string) (*Array, error) {
+	dir := filepath.Join(s.Root, name)
+	a := &Array{store: s, dir: dir, separator: "."}
+	switch s.Format {
+	case Zarr:
+		var za zarrArray
+		if err := readJSON(filepath.Join(dir, ".zarray"), &za); err != nil {
+			return nil, err
+		}
+		if len(za.Shape) != 3 || len(za.Chunks) != 3 {
+			return nil, fmt.Errorf("zarr array %q is not 3d", dir)
+		}
+		if za.Order != "" && za.Order != "C" {
+			return nil, fmt.Errorf("zarr array %q has unsupported order %q", dir, za.Order)
+		}
+		if len(za.Filters) != 0 {
+			return nil, fmt.Errorf("zarr array %q uses filters, which are not supported", dir)
+		}
+		var err error
+		if a.DataType, a.bigEndian, err = parseZarrDType(za.DType); err != nil {
+			return nil, err
+		}
+		a.Size = dvid.Point3d{int32(za.Shape[2]), int32(za.Shape[1]), int32(za.Shape[0])}
+		a.ChunkSize = dvid.Point3d{int32(za.Chunks[2]), int32(za.Chunks[1]), int32(za.Chunks[0])}
+		if za.Compressor != nil {
+			if za.Compressor.ID != "gzip" {
+				return nil, fmt.Errorf("zarr array %q uses unsupported compressor %q", dir, za.Compressor.ID)
+			}
+			a.Gzip = true
+		}
+		if za.DimensionSeparator != "" {
+			a.separator = za.DimensionSeparator
+		}
+		a.Attrs = make(map[string]interface{})
+		if err := readJSON(filepath.Join(dir, ".zattrs"), &(a.Attrs)); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	case N5:
+		var attrs map[string]interface{}
+		if err := readJSON(filepath.Join(dir, "attributes.json"), &attrs); err != nil {
+			return nil, err
+		}
+		dims, err := attrInts(attrs, "dimensions")
+		if err != nil {
+			return nil, fmt.Errorf("n5 dataset %q: %v", dir, err)
+		}
+		bsize, err := attrInts(attrs, "blockSize")
+		if err != nil {
+			return nil, fmt.Errorf("n5 dataset %q: %v", dir, err)
+		}
+		if len(dims) != 3 || len(bsize) != 3 {
+			return nil, fmt.Errorf("n5 dataset %q is not 3d", dir)
+		}
+		a.Size = dvid.Point3d{int32(dims[0]), int32(dims[1]), int32(dims[2])}
+		a.ChunkSize = dvid.Point3d{int32(bsize[0]), int32(bsize[1]), int32(bsize[2])}
+		typeStr, _ := attrs["dataType"].(string)
+		if a.DataType, err = parseDataType(typeStr); err != nil {
+			return nil, fmt.Errorf("n5 dataset %q: %v", dir, err)
+		}
+		var compression string
+		switch c := attrs["compression"].(type) {
+		case map[string]interface{}:
+			compression, _ = c["type"].(string)
+		case nil:
+			compression, _ = attrs["compressionType"].(string) // pre-2.0 N5
+		}
+		switch compression {
+		case "", "raw":
+		case "gzip":
+			a.Gzip = true
+		default:
+			return nil, fmt.Errorf("n5 dataset %q uses unsupported compression %q", dir, compression)
+		}
+		for _, key := range []string{"dimensions", "blockSize", "dataType", "compression", "compressionType"} {
+			delete(attrs, key)
+		}
+		a.Attrs = attrs
+	}
+	for i := 0; i < 3; i++ {
+		if a.ChunkSize[i] <= 0 {
+			return nil, fmt.Errorf("array %q has illegal chunk size %s", dir, a.ChunkSize)
+		}
+	}
+	return a, nil
+}
+
+// chunkPath returns the file path for the given chunk index.
+func (a *Array) chunkPath(c dvid.ChunkPoint3d) string {
+	if a.store.Format == N5 {
+		return filepath.Join(a.dir, strconv.Itoa(int(c[0])), strconv.Itoa(int(c[1])), strconv.Itoa(int(c[2])))
+	}
+	key := fmt.Sprintf("%d%s%d%s%d", c[2], a.separator, c[1], a.separator, c[0])
+	return filepath.Join(a.dir, filepath.FromSlash(key))
+}
+
+// validChunk returns an error if the chunk index is outside the array.
+func (a *Array) validChunk(c dvid.ChunkPoint3d) error {
+	n := a.NumChunks()
+	for i := 0; i < 3; i++ {
+		if c[i] < 0 || c[i] >= n[i] {
+			return fmt.Errorf("chunk %s is outside array %q with %s chunks", c, a.dir, n)
+		}
+	}
+	return nil
+}
+
+// edgeSize returns the size of the given chunk after cropping to the array bounds.
+func (a *Array) edgeSize(c dvid.ChunkPoint3d) dvid.Point3d {
+	size := a.ChunkSize
+	for i := 0; i < 3; i++ {
+		if remain := a.Size[i] - c[i]*a.ChunkSize[i]; remain < size[i] {
+			size[i] = remain
+		}
+	}
+	return size
+}
+
+// WriteChunk writes a full chunk of little-endian data in DVID layout to the given
+// chunk index.
+func (a *Array) WriteChunk(c dvid.ChunkPoint3d, data []byte) error {
+	if err := a.validChunk(c); err != nil {
+		return err
+	}
+	if len(data) != a.ChunkBytes() {
+		return fmt.Errorf("expected %d bytes for chunk %s, got %d bytes", a.ChunkBytes(), c, len(data))
+	}
+	valueBytes := int(dvid.DataTypeBytes(a.DataType))
+
+	var buf bytes.Buffer
+	var payload []byte
+	if a.store.Format == N5 {
+		size := a.edgeSize(c)
+		if size == a.ChunkSize {
+			payload = make([]byte, len(data)) // don't byte swap the caller's data
+			copy(payload, data)
+		} else {
+			payload = cropChunk(data, a.ChunkSize, size, valueBytes)
+		}
+		swapBytes(payload, valueBytes)
+		hdr := make([]byte, 16)
+		binary.BigEndian.PutUint16(hdr[0:2], 0) // default mode
+		binary.BigEndian.PutUint16(hdr[2:4], 3)
+		binary.BigEndian.PutUint32(hdr[4:8], uint32(size[0]))
+		binary.BigEndian.PutUint32(hdr[8:12], uint32(size[1]))
+		binary.BigEndian.PutUint32(hdr[12:16], uint32(size[2]))
+		buf.Write(hdr)
+	} else {
+		payload = data
+	}
+	if a.Gzip {
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(payload); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	} else {
+		buf.Write(payload)
+	}
+
+	fname := a.chunkPath(c)
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fname, buf.Bytes(), 0644)
+}
+
+// ReadChunk returns a full chunk of little-endian data in DVID layout for the given chunk
+// index.  If the chunk is not stored, nil data and no error is returned.
+func (a *Array) ReadChunk(c dvid.ChunkPoint3d) ([]byte, error) {
+	if err := a.validChunk(c); err != nil {
+		return nil, err
+	}
+	raw, err := ioutil.ReadFile(a.chunkPath(c))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	valueBytes := int(dvid.DataTypeBytes(a.DataType))
+
+	size := a.ChunkSize
+	if a.store.Format == N5 {
+		if len(raw) < 4 {
+			return nil, fmt.Errorf("n5 chunk %s is too short (%d bytes)", c, len(raw))
+		}
+		mode := binary.BigEndian.Uint16(raw[0:2])
+		ndims := int(binary.BigEndian.Uint16(raw[2:4]))
+		if ndims != 3 {
+			return nil, fmt.Errorf("n5 chunk %s has %d dimensions, expected 3", c, ndims)
+		}
+		hdrLen := 4 + 4*ndims
+		if mode == 1 {
+			hdrLen += 4 // varlength mode stores the number of elements
+		} else if mode != 0 {
+			return nil, fmt.Errorf("n5 chunk %s has unsupported mode %d", c, mode)
+		}
+		if len(raw) < hdrLen {
+			return nil, fmt.Errorf("n5 chunk %s has truncated header", c)
+		}
+		for i := 0; i < 3; i++ {
+			size[i] = int32(binary.BigEndian.Uint32(raw[4+4*i : 8+4*i]))
+		}
+		raw = raw[hdrLen:]
+	}
+
+	payload := raw
+	if a.Gzip {
+		zr, err := gzip.NewReader(bytes.NewBuffer(raw))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read gzip chunk %s: %v", c, err)
+		}
+		if payload, err = ioutil.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("unable to decompress chunk %s: %v", c, err)
+		}
+		if err := zr.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if expected := int(size.Prod()) * valueBytes; len(payload) != expected {
+		return nil, fmt.Errorf("chunk %s has %d bytes, expected %d", c, len(payload), expected)
+	}
+	if a.store.Format == N5 || a.bigEndian {
+		swapBytes(payload, valueBytes)
+	}
+	if size != a.ChunkSize {
+		payload = padChunk(payload, size, a.ChunkSize, valueBytes)
+	}
+	return payload, nil
+}
+
+// Chunks returns the indices of all chunks stored for this array.
+func (a *Array) Chunks() ([]dvid.ChunkPoint3d, error) {
+	var chunks []dvid.ChunkPoint3d
+	err := filepath.Walk(a.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(a.dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		var parts []string
+		if a.store.Format == N5 {
+			parts = strings.Split(rel, "/")
+		} else {
+			parts = strings.Split(rel, a.separator)
+		}
+		if len(parts) != 3 {
+			return nil
+		}
+		var c dvid.ChunkPoint3d
+		for i, part := range parts {
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return nil // metadata or unrelated file
+			}
+			c[i] = int32(n)
+		}
+		if a.store.Format == Zarr {
+			c[0], c[2] = c[2], c[0]
+		}
+		chunks = append(chunks, c)
+		return nil
+	})
+	return chunks, err
+}
+
+// cropChunk returns a copy of a full chunk's data cropped to the given smaller size.
+func cropChunk(data []byte, full, size dvid.Point3d, valueBytes int) []byte {
+	out := make([]byte, int(size.Prod())*valueBytes)
+	rowBytes := int(size[0]) * valueBytes
+	var dst int
+	for z := int32(0); z < size[2]; z++ {
+		for y := int32(0); y < size[1]; y++ {
+			src := (int(z)*int(full[1]*full[0]) + int(y)*int(full[0])) * valueBytes
+			copy(out[dst:dst+rowBytes], data[src:src+rowBytes])
+			dst += rowBytes
+		}
+	}
+	return out
+}
+
+// padChunk expands a cropped edge chunk to full size, with zero values outside the crop.
+func padChunk(data []byte, size, full dvid.Point3d, valueBytes int) []byte {
+	out := make([]byte, int(full.Prod())*valueBytes)
+	rowBytes := int(size[0]) * valueBytes
+	var src int
+	for z := int32(0); z < size[2]; z++ {
+		for y := int32(0); y < size[1]; y++ {
+			dst := (int(z)*int(full[1]*full[0]) + int(y)*int(full[0])) * valueBytes
+			copy(out[dst:dst+rowBytes], data[src:src+rowBytes])
+			src += rowBytes
+		}
+	}
+	return out
+}
+
+// swapBytes reverses the byte order of each value in place.
+func swapBytes(data []byte, valueBytes int) {
+	if valueBytes < 2 {
+		return
+	}
+	for i := 0; i+valueBytes <= len(data); i += valueBytes {
+		for j, k := i, i+valueBytes-1; j < k; j, k = j+1, k-1 {
+			data[j], data[k] = data[k], data[j]
+		}
+	}
+}
+
+var zarrKinds = map[dvid.DataType]string{
+	dvid.T_uint8:   "u1",
+	dvid.T_int8:    "i1",
+	dvid.T_uint16:  "u2",
+	dvid.T_int16:   "i2",
+	dvid.T_uint32:  "u4",
+	dvid.T_int32:   "i4",
+	dvid.T_uint64:  "u8",
+	dvid.T_int64:   "i8",
+	dvid.T_float32: "f4",
+	dvid.T_float64: "f8",
+}
+
+func zarrDType(t dvid.DataType) (string, error) {
+	kind, found := zarrKinds[t]
+	if !found {
+		return "", fmt.Errorf("data type %d has no zarr equivalent", t)
+	}
+	if kind[1] == '1' {
+		return "|" + kind, nil
+	}
+	return "<" + kind, nil
+}
+
+func parseZarrDType(s string) (t dvid.DataType, bigEndian bool, err error) {
+	if len(s) != 3 {
+		err = fmt.Errorf("unsupported zarr dtype %q", s)
+		return
+	}
+	bigEndian = s[0] == '>'
+	for dt, kind := range zarrKinds {
+		if kind == s[1:] {
+			return dt, bigEndian, nil
+		}
+	}
+	err = fmt.Errorf("unsupported zarr dtype %q", s)
+	return
+}
+
+var n5Types = map[dvid.DataType]string{
+	dvid.T_uint8:   "uint8",
+	dvid.T_int8:    "int8",
+	dvid.T_uint16:  "uint16",
+	dvid.T_int16:   "int16",
+	dvid.T_uint32:  "uint32",
+	dvid.T_int32:   "int32",
+	dvid.T_uint64:  "uint64",
+	dvid.T_int64:   "int64",
+	dvid.T_float32: "float32",
+	dvid.T_float64: "float64",
+}
+
+func parseDataType(s string) (dvid.DataType, error) {
+	for dt, name := range n5Types {
+		if name == s {
+			return dt, nil
+		}
+	}
+	return dvid.T_uint8, fmt.Errorf("unsupported data type %q", s)
+}
+
+// attrInts returns an integer slice from a JSON-decoded attribute.
+func attrInts(attrs map[string]interface{}, key string) ([]int64, error) {
+	vals, ok := attrs[key].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("attribute %q missing or not an array", key)
+	}
+	out := make([]int64, len(vals))
+	for i, val := range vals {
+		f, ok := val.(float64)
+		if !ok {
+			return nil, fmt.Errorf("attribute %q has non-numeric value %v", key, val)
+		}
+		out[i] = int64(f)
+	}
+	return out, nil
+}
+
+func writeJSON(fname string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fname, data, 0644)
+}
+
+func readJSON(fname string, v interface{}) error {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,228 @@
+package chunked
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/janelia-flyem/dvid/dvid"
+)
+
+// makeChunk returns a full chunk of uint64 values where each voxel has a value
+// derived from its global voxel coordinate.
+func makeChunk(c dvid.ChunkPoint3d, chunkSize dvid.Point3d) []byte {
+	data := make([]byte, chunkSize.Prod()*8)
+	var i int
+	for z := int32(0); z < chunkSize[2]; z++ {
+		for y := int32(0); y < chunkSize[1]; y++ {
+			for x := int32(0); x < chunkSize[0]; x++ {
+				gx := uint64(c[0]*chunkSize[0] + x)
+				gy := uint64(c[1]*chunkSize[1] + y)
+				gz := uint64(c[2]*chunkSize[2] + z)
+				binary.LittleEndian.PutUint64(data[i:i+8], gz<<32|gy<<16|gx)
+				i += 8
+			}
+		}
+	}
+	return data
+}
+
+func testRoundTrip(t *testing.T, format Format, gzip bool) {
+	dir, err := ioutil.TempDir("", "dvid-chunked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	res := dvid.Resolution{
+		VoxelSize:  dvid.NdFloat32{4, 4, 40},
+		VoxelUnits: dvid.NdString{"nanometers", "nanometers", "nanometers"},
+	}
+	store, err := Create(dir, format, MultiscaleAttrs(format, "test", res, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := dvid.Point3d{-16, 0, 32}
+	meta := ArrayMeta{
+		Size:      dvid.Point3d{40, 16, 20}, // non-multiple of chunk size to test edge chunks
+		ChunkSize: dvid.Point3d{16, 16, 8},
+		DataType:  dvid.T_uint64,
+		Gzip:      gzip,
+		Attrs:     ScaleAttrs(format, res, 0, offset),
+	}
+	arr, err := store.CreateArray(ScaleName(0), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := arr.NumChunks(); n != (dvid.ChunkPoint3d{3, 1, 3}) {
+		t.Fatalf("expected 3 x 1 x 3 chunks, got %s", n)
+	}
+	written := []dvid.ChunkPoint3d{{0, 0, 0}, {2, 0, 1}, {2, 0, 2}}
+	for _, c := range written {
+		if err := arr.WriteChunk(c, makeChunk(c, meta.ChunkSize)); err != nil {
+			t.Fatalf("error writing chunk %s: %v", c, err)
+		}
+	}
+	if err := arr.WriteChunk(dvid.ChunkPoint3d{3, 0, 0}, makeChunk(dvid.ChunkPoint3d{3, 0, 0}, meta.ChunkSize)); err == nil {
+		t.Errorf("expected error writing chunk outside array")
+	}
+
+	// Reopen the store and make sure metadata and chunks are preserved.
+	store2, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store2.Format != format {
+		t.Fatalf("expected format %s, got %s", format, store2.Format)
+	}
+	if !store2.HasArray("s0") || store2.HasArray("s1") {
+		t.Fatalf("bad array detection in reopened %s store", format)
+	}
+	arr2, err := store2.OpenArray("s0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arr2.Size != meta.Size || arr2.ChunkSize != meta.ChunkSize || arr2.DataType != meta.DataType || arr2.Gzip != gzip {
+		t.Fatalf("reopened %s array has bad metadata: %v", format, arr2.ArrayMeta)
+	}
+	if attrOffset, found := OffsetFromAttrs(arr2.Attrs); !found || attrOffset != offset {
+		t.Errorf("expected offset %s from attributes, got %s (found %t)", offset, attrOffset, found)
+	}
+	chunks, err := arr2.Chunks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != len(written) {
+		t.Fatalf("expected %d chunks, got %v", len(written), chunks)
+	}
+	for _, c := range written {
+		data, err := arr2.ReadChunk(c)
+		if err != nil {
+			t.Fatalf("error reading chunk %s: %v", c, err)
+		}
+		expected := makeChunk(c, meta.ChunkSize)
+		if format == N5 {
+			// N5 crops edge chunks, so voxels outside array are read back as zero.
+			var i int
+			for z := int32(0); z < meta.ChunkSize[2]; z++ {
+				for y := int32(0); y < meta.ChunkSize[1]; y++ {
+					for x := int32(0); x < meta.ChunkSize[0]; x++ {
+						if c[0]*meta.ChunkSize[0]+x >= meta.Size[0] || c[2]*meta.ChunkSize[2]+z >= meta.Size[2] {
+							copy(expected[i:i+8], make([]byte, 8))
+						}
+						i += 8
+					}
+				}
+			}
+		}
+		if !bytes.Equal(data, expected) {
+			t.Errorf("chunk %s in %s format not read back correctly", c, format)
+		}
+	}
+	if data, err := arr2.ReadChunk(dvid.ChunkPoint3d{1, 0, 0}); err != nil || data != nil {
+		t.Errorf("expected nil data for unwritten chunk, got %d bytes, err %v", len(data), err)
+	}
+}
+
+func TestZarrRoundTrip(t *testing.T) {
+	testRoundTrip(t, Zarr, true)
+	testRoundTrip(t, Zarr, false)
+}
+
+func TestN5RoundTrip(t *testing.T) {
+	testRoundTrip(t, N5, true)
+	testRoundTrip(t, N5, false)
+}
+
+func TestN5ChunkLayout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dvid-chunked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := Create(dir, N5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := ArrayMeta{
+		Size:      dvid.Point3d{3, 2, 2},
+		ChunkSize: dvid.Point3d{2, 2, 2},
+		DataType:  dvid.T_uint16,
+	}
+	arr, err := store.CreateArray("s0", meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 16)
+	for i := 0; i < 8; i++ {
+		binary.LittleEndian.PutUint16(data[i*2:], uint16(i+1))
+	}
+	if err := arr.WriteChunk(dvid.ChunkPoint3d{1, 0, 0}, data); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ioutil.ReadFile(filepath.Join(dir, "s0", "1", "0", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// header (mode 0, 3 dims, 1 x 2 x 2 cropped size) then big-endian values for x = 0.
+	expected := []byte{0, 0, 0, 3, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 2, 0, 1, 0, 3, 0, 5, 0, 7}
+	if !bytes.Equal(raw, expected) {
+		t.Errorf("bad n5 chunk encoding:\nexpected %v\ngot      %v", expected, raw)
+	}
+}
+
+func TestScaledBounds(t *testing.T) {
+	blockSize := dvid.Point3d{64, 64, 64}
+	offset, size := ScaledBounds(dvid.Point3d{-10, 70, 128}, dvid.Point3d{200, 300, 400}, blockSize, 1)
+	if offset != (dvid.Point3d{-64, 0, 64}) {
+		t.Errorf("bad scaled offset: %s", offset)
+	}
+	if size != (dvid.Point3d{165, 151, 137}) {
+		t.Errorf("bad scaled size: %s", size)
+	}
+}
+
+func TestScaleOffset(t *testing.T) {
+	blockSize := dvid.Point3d{64, 64, 64}
+	offset0 := dvid.Point3d{0, 128, -256}
+	offset, err := ScaleOffset(nil, 1, offset0, blockSize)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if offset != (dvid.Point3d{0, 64, -128}) {
+		t.Errorf("bad scale 1 offset: %s", offset)
+	}
+	if _, err := ScaleOffset(nil, 2, offset0, blockSize); err == nil {
+		t.Errorf("expected error for unaligned scale 2 offset without attribute")
+	}
+	attrs := map[string]interface{}{OffsetAttr: []interface{}{0.0, 0.0, 64.0}}
+	if offset, err = ScaleOffset(attrs, 2, offset0, blockSize); err != nil {
+		t.Fatalf("unexpected error with offset attribute: %v", err)
+	}
+	if offset != (dvid.Point3d{0, 0, 64}) {
+		t.Errorf("bad scale 2 offset from attribute: %s", offset)
+	}
+	if _, err := ScaleOffset(nil, 0, dvid.Point3d{0, 32, 0}, blockSize); err == nil {
+		t.Errorf("expected error for unaligned scale 0 offset")
+	}
+}
+
+func TestToUint64(t *testing.T) {
+	in := []byte{1, 0, 0, 0, 0xff, 0xff, 0xff, 0xff}
+	out, err := ToUint64(in, dvid.T_uint32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{1, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("bad uint32 -> uint64 conversion: %v", out)
+	}
+	if _, err := ToUint64(in, dvid.T_float32); err == nil {
+		t.Errorf("expected error converting float32 to labels")
+	}
+}
@@ -56,6 +56,16 @@ type CleaveOp struct {
 	CleavedSupervoxels []uint64
 }
 
+// EraseOp represents the erasure of a label or supervoxel, optionally within bounds.
+type EraseOp struct {
+	MutID      uint64
+	Label      uint64
+	Supervoxel bool     // true if Label is a supervoxel, not a body
+	Erased     []uint64 // supervoxels with erased voxels
+	Voxels     uint64   // number of erased voxels
+	Bounds     *dvid.OptionalBounds
+}
+
 // SplitSupervoxelOp describes a supervoxel split.
 type SplitSupervoxelOp struct {
 	MutID            uint64
@@ -5,6 +5,7 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/janelia-flyem/dvid/datatype/common/proto"
 	"github.com/janelia-flyem/dvid/dvid"
 )
 
@@ -320,3 +321,35 @@ func TestDirtyCache(t *testing.T) {
 		t.Errorf("Label is not marked dirty when it is.")
 	}
 }
+
+func TestSerializeErase(t *testing.T) {
+	bounds := new(dvid.OptionalBounds)
+	bounds.SetMinX(10)
+	bounds.SetMaxZ(59)
+	op := EraseOp{MutID: 23, Label: 7, Supervoxel: true, Erased: []uint64{7}, Voxels: 1000, Bounds: bounds}
+	data, err := serializeErase(op)
+	if err != nil {
+		t.Fatalf("unable to serialize erase op: %v\n", err)
+	}
+	var pop proto.EraseOp
+	if err := pop.Unmarshal(data); err != nil {
+		t.Fatalf("unable to deserialize erase op: %v\n", err)
+	}
+	if pop.Mutid != 23 || pop.Label != 7 || !pop.Supervoxel || len(pop.Erased) != 1 || pop.Erased[0] != 7 || pop.Voxels != 1000 {
+		t.Errorf("bad erase op after serialization: %s\n", pop.String())
+	}
+	if pop.Bounds != "minx=10&maxz=59" {
+		t.Errorf("expected bounds %q, got %q\n", "minx=10&maxz=59", pop.Bounds)
+	}
+	op.Bounds = nil
+	if data, err = serializeErase(op); err != nil {
+		t.Fatalf("unable to serialize erase op: %v\n", err)
+	}
+	pop.Reset()
+	if err := pop.Unmarshal(data); err != nil {
+		t.Fatalf("unable to deserialize erase op: %v\n", err)
+	}
+	if pop.Bounds != "" {
+		t.Errorf("expected no bounds for unbounded erase, got %q\n", pop.Bounds)
+	}
+}
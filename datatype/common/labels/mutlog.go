@@ -3,6 +3,8 @@
 package labels
 
 import (
+	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/janelia-flyem/dvid/datastore"
@@ -106,6 +108,28 @@ func LogCleave(d dvid.Data, v dvid.VersionID, op CleaveOp) error {
 	return log.Append(d.DataUUID(), uuid, msg)
 }
 
+// LogErase logs the erasure of a label or supervoxel.
+func LogErase(d dvid.Data, v dvid.VersionID, op EraseOp) error {
+	uuid, err := datastore.UUIDFromVersion(v)
+	if err != nil {
+		return err
+	}
+	logable, ok := d.(storage.LogWritable)
+	if !ok {
+		return nil // skip logging
+	}
+	log := logable.GetWriteLog()
+	if log == nil {
+		return nil
+	}
+	data, err := serializeErase(op)
+	if err != nil {
+		return err
+	}
+	msg := storage.LogMessage{EntryType: proto.EraseOpType, Data: data}
+	return log.Append(d.DataUUID(), uuid, msg)
+}
+
 // LogMapping logs the mapping of supervoxels to a label.
 func LogMapping(d dvid.Data, v dvid.VersionID, op MappingOp) error {
 	uuid, err := datastore.UUIDFromVersion(v)
@@ -284,6 +308,33 @@ func serializeCleave(op CleaveOp) (serialization []byte, err error) {
 	return pop.Marshal()
 }
 
+func serializeErase(op EraseOp) (serialization []byte, err error) {
+	pop := &proto.EraseOp{
+		Mutid:      op.MutID,
+		Label:      op.Label,
+		Supervoxel: op.Supervoxel,
+		Erased:     op.Erased,
+		Voxels:     op.Voxels,
+	}
+	if op.Bounds != nil {
+		var bounds []string
+		for _, b := range []struct {
+			name string
+			get  func() (int32, bool)
+		}{
+			{"minx", op.Bounds.MinX}, {"maxx", op.Bounds.MaxX},
+			{"miny", op.Bounds.MinY}, {"maxy", op.Bounds.MaxY},
+			{"minz", op.Bounds.MinZ}, {"maxz", op.Bounds.MaxZ},
+		} {
+			if val, ok := b.get(); ok {
+				bounds = append(bounds, fmt.Sprintf("%s=%d", b.name, val))
+			}
+		}
+		pop.Bounds = strings.Join(bounds, "&")
+	}
+	return pop.Marshal()
+}
+
 func serializeAffinity(aff Affinity) (serialization []byte, err error) {
 	pop := &proto.Affinity{
 		Label1: aff.Label1,
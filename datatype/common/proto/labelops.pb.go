@@ -22,6 +22,7 @@
 		SVCount
 		LabelIndex
 		LabelIndices
+		EraseOp
 */
 package proto
 
@@ -460,6 +461,61 @@ func (m *LabelIndices) GetIndices() []*LabelIndex {
 	return nil
 }
 
+type EraseOp struct {
+	Mutid      uint64   `protobuf:"varint,1,opt,name=mutid,proto3" json:"mutid,omitempty"`
+	Label      uint64   `protobuf:"varint,2,opt,name=label,proto3" json:"label,omitempty"`
+	Supervoxel bool     `protobuf:"varint,3,opt,name=supervoxel,proto3" json:"supervoxel,omitempty"`
+	Erased     []uint64 `protobuf:"varint,4,rep,packed,name=erased" json:"erased,omitempty"`
+	Voxels     uint64   `protobuf:"varint,5,opt,name=voxels,proto3" json:"voxels,omitempty"`
+	Bounds     string   `protobuf:"bytes,6,opt,name=bounds,proto3" json:"bounds,omitempty"`
+}
+
+func (m *EraseOp) Reset()                    { *m = EraseOp{} }
+func (*EraseOp) ProtoMessage()               {}
+func (*EraseOp) Descriptor() ([]byte, []int) { return fileDescriptorLabelops, []int{14} }
+
+func (m *EraseOp) GetMutid() uint64 {
+	if m != nil {
+		return m.Mutid
+	}
+	return 0
+}
+
+func (m *EraseOp) GetLabel() uint64 {
+	if m != nil {
+		return m.Label
+	}
+	return 0
+}
+
+func (m *EraseOp) GetSupervoxel() bool {
+	if m != nil {
+		return m.Supervoxel
+	}
+	return false
+}
+
+func (m *EraseOp) GetErased() []uint64 {
+	if m != nil {
+		return m.Erased
+	}
+	return nil
+}
+
+func (m *EraseOp) GetVoxels() uint64 {
+	if m != nil {
+		return m.Voxels
+	}
+	return 0
+}
+
+func (m *EraseOp) GetBounds() string {
+	if m != nil {
+		return m.Bounds
+	}
+	return ""
+}
+
 func init() {
 	proto1.RegisterType((*MergeOp)(nil), "proto.MergeOp")
 	proto1.RegisterType((*CleaveOp)(nil), "proto.CleaveOp")
@@ -475,6 +531,7 @@ func init() {
 	proto1.RegisterType((*SVCount)(nil), "proto.SVCount")
 	proto1.RegisterType((*LabelIndex)(nil), "proto.LabelIndex")
 	proto1.RegisterType((*LabelIndices)(nil), "proto.LabelIndices")
+	proto1.RegisterType((*EraseOp)(nil), "proto.EraseOp")
 }
 func (this *MergeOp) Equal(that interface{}) bool {
 	if that == nil {
@@ -942,6 +999,50 @@ func (this *LabelIndices) Equal(that interface{}) bool {
 	}
 	return true
 }
+func (this *EraseOp) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*EraseOp)
+	if !ok {
+		that2, ok := that.(EraseOp)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Mutid != that1.Mutid {
+		return false
+	}
+	if this.Label != that1.Label {
+		return false
+	}
+	if this.Supervoxel != that1.Supervoxel {
+		return false
+	}
+	if len(this.Erased) != len(that1.Erased) {
+		return false
+	}
+	for i := range this.Erased {
+		if this.Erased[i] != that1.Erased[i] {
+			return false
+		}
+	}
+	if this.Voxels != that1.Voxels {
+		return false
+	}
+	if this.Bounds != that1.Bounds {
+		return false
+	}
+	return true
+}
 func (this *MergeOp) GoString() string {
 	if this == nil {
 		return "nil"
@@ -1159,6 +1260,21 @@ func (this *LabelIndices) GoString() string {
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
+func (this *EraseOp) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 10)
+	s = append(s, "&proto.EraseOp{")
+	s = append(s, "Mutid: "+fmt.Sprintf("%#v", this.Mutid)+",\n")
+	s = append(s, "Label: "+fmt.Sprintf("%#v", this.Label)+",\n")
+	s = append(s, "Supervoxel: "+fmt.Sprintf("%#v", this.Supervoxel)+",\n")
+	s = append(s, "Erased: "+fmt.Sprintf("%#v", this.Erased)+",\n")
+	s = append(s, "Voxels: "+fmt.Sprintf("%#v", this.Voxels)+",\n")
+	s = append(s, "Bounds: "+fmt.Sprintf("%#v", this.Bounds)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
 func valueToGoStringLabelops(v interface{}, typ string) string {
 	rv := reflect.ValueOf(v)
 	if rv.IsNil() {
@@ -1768,6 +1884,72 @@ func (m *LabelIndices) MarshalTo(dAtA []byte) (int, error) {
 	return i, nil
 }
 
+func (m *EraseOp) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *EraseOp) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Mutid != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintLabelops(dAtA, i, uint64(m.Mutid))
+	}
+	if m.Label != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintLabelops(dAtA, i, uint64(m.Label))
+	}
+	if m.Supervoxel {
+		dAtA[i] = 0x18
+		i++
+		if m.Supervoxel {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if len(m.Erased) > 0 {
+		dAtA26 := make([]byte, len(m.Erased)*10)
+		var j25 int
+		for _, num := range m.Erased {
+			for num >= 1<<7 {
+				dAtA26[j25] = uint8(uint64(num)&0x7f | 0x80)
+				num >>= 7
+				j25++
+			}
+			dAtA26[j25] = uint8(num)
+			j25++
+		}
+		dAtA[i] = 0x22
+		i++
+		i = encodeVarintLabelops(dAtA, i, uint64(j25))
+		i += copy(dAtA[i:], dAtA26[:j25])
+	}
+	if m.Voxels != 0 {
+		dAtA[i] = 0x28
+		i++
+		i = encodeVarintLabelops(dAtA, i, uint64(m.Voxels))
+	}
+	if len(m.Bounds) > 0 {
+		dAtA[i] = 0x32
+		i++
+		i = encodeVarintLabelops(dAtA, i, uint64(len(m.Bounds)))
+		i += copy(dAtA[i:], m.Bounds)
+	}
+	return i, nil
+}
+
 func encodeVarintLabelops(dAtA []byte, offset int, v uint64) int {
 	for v >= 1<<7 {
 		dAtA[offset] = uint8(v&0x7f | 0x80)
@@ -2040,6 +2222,35 @@ func (m *LabelIndices) Size() (n int) {
 	return n
 }
 
+func (m *EraseOp) Size() (n int) {
+	var l int
+	_ = l
+	if m.Mutid != 0 {
+		n += 1 + sovLabelops(uint64(m.Mutid))
+	}
+	if m.Label != 0 {
+		n += 1 + sovLabelops(uint64(m.Label))
+	}
+	if m.Supervoxel {
+		n += 2
+	}
+	if len(m.Erased) > 0 {
+		l = 0
+		for _, e := range m.Erased {
+			l += sovLabelops(uint64(e))
+		}
+		n += 1 + sovLabelops(uint64(l)) + l
+	}
+	if m.Voxels != 0 {
+		n += 1 + sovLabelops(uint64(m.Voxels))
+	}
+	l = len(m.Bounds)
+	if l > 0 {
+		n += 1 + l + sovLabelops(uint64(l))
+	}
+	return n
+}
+
 func sovLabelops(x uint64) (n int) {
 	for {
 		n++
@@ -2258,6 +2469,21 @@ func (this *LabelIndices) String() string {
 	}, "")
 	return s
 }
+func (this *EraseOp) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&EraseOp{`,
+		`Mutid:` + fmt.Sprintf("%v", this.Mutid) + `,`,
+		`Label:` + fmt.Sprintf("%v", this.Label) + `,`,
+		`Supervoxel:` + fmt.Sprintf("%v", this.Supervoxel) + `,`,
+		`Erased:` + fmt.Sprintf("%v", this.Erased) + `,`,
+		`Voxels:` + fmt.Sprintf("%v", this.Voxels) + `,`,
+		`Bounds:` + fmt.Sprintf("%v", this.Bounds) + `,`,
+		`}`,
+	}, "")
+	return s
+}
 func valueToStringLabelops(v interface{}) string {
 	rv := reflect.ValueOf(v)
 	if rv.IsNil() {
@@ -4331,6 +4557,224 @@ func (m *LabelIndices) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *EraseOp) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowLabelops
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: EraseOp: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: EraseOp: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Mutid", wireType)
+			}
+			m.Mutid = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLabelops
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Mutid |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Label", wireType)
+			}
+			m.Label = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLabelops
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Label |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Supervoxel", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLabelops
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Supervoxel = bool(v != 0)
+		case 4:
+			if wireType == 0 {
+				var v uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowLabelops
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					v |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				m.Erased = append(m.Erased, v)
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowLabelops
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= (int(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthLabelops
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex > l {
+					return io.ErrUnexpectedEOF
+				}
+				for iNdEx < postIndex {
+					var v uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowLabelops
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						v |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					m.Erased = append(m.Erased, v)
+				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field Erased", wireType)
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Voxels", wireType)
+			}
+			m.Voxels = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLabelops
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Voxels |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Bounds", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLabelops
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthLabelops
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Bounds = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipLabelops(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthLabelops
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func skipLabelops(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
@@ -4439,53 +4883,56 @@ var (
 func init() { proto1.RegisterFile("labelops.proto", fileDescriptorLabelops) }
 
 var fileDescriptorLabelops = []byte{
-	// 759 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0x4d, 0x4f, 0xdb, 0x4a,
-	0x14, 0x8d, 0xf3, 0x9d, 0x9b, 0x04, 0xc1, 0x08, 0x3d, 0x59, 0xd1, 0x7b, 0x7e, 0x96, 0x55, 0xa9,
-	0x91, 0x8a, 0x22, 0x35, 0x15, 0x12, 0xd0, 0x15, 0xd0, 0x2e, 0x10, 0x8d, 0xa8, 0x0c, 0x74, 0x8b,
-	0x9c, 0x78, 0x88, 0x46, 0xf8, 0x63, 0xe4, 0x19, 0xa7, 0xb0, 0xeb, 0xaa, 0x9b, 0x6e, 0x2a, 0xf5,
-	0x4f, 0x74, 0xd5, 0xdf, 0xd1, 0x25, 0xcb, 0x2e, 0x4b, 0xba, 0xe9, 0x92, 0x9f, 0x50, 0xcd, 0x87,
-	0x8d, 0x53, 0x28, 0x15, 0x1b, 0x67, 0xce, 0xbd, 0xe7, 0xde, 0x7b, 0x7c, 0x7c, 0x27, 0xb0, 0x14,
-	0x78, 0x63, 0x1c, 0xc4, 0x94, 0x0d, 0x68, 0x12, 0xf3, 0x18, 0xd5, 0xe4, 0x8f, 0x73, 0x00, 0x8d,
-	0x11, 0x4e, 0xa6, 0xf8, 0x80, 0xa2, 0x55, 0xa8, 0x85, 0x29, 0x27, 0xbe, 0x69, 0xd8, 0x46, 0xbf,
-	0xea, 0x2a, 0x80, 0xfe, 0x81, 0x3a, 0xf7, 0x92, 0x29, 0xe6, 0x66, 0x59, 0x86, 0x35, 0x12, 0xf1,
-	0x50, 0x14, 0xfa, 0x66, 0xc5, 0xae, 0x88, 0xb8, 0x42, 0xce, 0x0c, 0x9a, 0xbb, 0x01, 0xf6, 0x66,
-	0x0f, 0xef, 0xe8, 0x40, 0x67, 0x22, 0x2b, 0x7d, 0x29, 0xd5, 0xac, 0xc8, 0xec, 0x42, 0x0c, 0x99,
-	0xd0, 0xd0, 0xd8, 0xac, 0xca, 0xb1, 0x19, 0x74, 0x8e, 0xa1, 0x35, 0xf2, 0x28, 0x25, 0xd1, 0xf4,
-	0xbe, 0xc1, 0xa1, 0x47, 0x29, 0xf6, 0xb3, 0xc1, 0x0a, 0xa1, 0x1e, 0x34, 0xe3, 0x84, 0x4c, 0x49,
-	0xe4, 0x05, 0xfa, 0x65, 0x72, 0xec, 0x6c, 0x01, 0xe4, 0x6d, 0x19, 0x5a, 0x83, 0x66, 0xa8, 0x10,
-	0x33, 0x0d, 0xbb, 0xd2, 0x6f, 0x0f, 0x97, 0x95, 0x9d, 0x83, 0x9c, 0xe4, 0xe6, 0x0c, 0xe7, 0x7d,
-	0x19, 0x1a, 0x87, 0x34, 0x20, 0xfc, 0xc1, 0x56, 0xf4, 0xa0, 0x19, 0xe1, 0xb7, 0x45, 0x1b, 0x72,
-	0x2c, 0x6a, 0x26, 0xb1, 0x97, 0x30, 0x6c, 0x56, 0x6d, 0xa3, 0xdf, 0x74, 0x35, 0x42, 0x08, 0xaa,
-	0x49, 0x80, 0x99, 0x59, 0xb3, 0x8d, 0x7e, 0xc7, 0x95, 0x67, 0xb4, 0x01, 0x4d, 0x36, 0x63, 0x42,
-	0x02, 0x33, 0xeb, 0x52, 0xef, 0xbf, 0x5a, 0xaf, 0xd6, 0x35, 0x38, 0xd4, 0xe9, 0x97, 0x11, 0x4f,
-	0x2e, 0xdc, 0x9c, 0xdd, 0xdb, 0x87, 0xee, 0x42, 0x0a, 0x2d, 0x43, 0xe5, 0x0c, 0x5f, 0x68, 0xf9,
-	0xe2, 0x88, 0x1e, 0x41, 0x6d, 0xe6, 0x05, 0x29, 0x96, 0xda, 0xdb, 0xc3, 0xa5, 0xac, 0xf3, 0x1b,
-	0xd9, 0xdb, 0x55, 0xc9, 0xad, 0xf2, 0x86, 0xe1, 0xec, 0x43, 0x43, 0x47, 0x91, 0x05, 0x20, 0xbb,
-	0xaa, 0x77, 0x53, 0xdd, 0x0a, 0x11, 0x64, 0x43, 0x3b, 0xc1, 0xa1, 0x47, 0x22, 0x45, 0x50, 0xb6,
-	0x14, 0x43, 0xce, 0x07, 0x03, 0x56, 0x0e, 0x53, 0x8a, 0x93, 0x59, 0x7c, 0x8e, 0x83, 0xfb, 0xfd,
-	0x15, 0xd3, 0x72, 0xaa, 0x6e, 0x56, 0x88, 0xfc, 0xa6, 0xa6, 0xf2, 0x37, 0x35, 0xd5, 0xdb, 0x6a,
-	0x36, 0xa1, 0x7d, 0x40, 0x77, 0xe3, 0x90, 0x06, 0x98, 0x63, 0xff, 0x0f, 0x32, 0x56, 0xa1, 0xc6,
-	0xb8, 0x37, 0x55, 0x4e, 0xb5, 0x5c, 0x05, 0x9c, 0xd7, 0xd0, 0xdc, 0x3e, 0x3d, 0x25, 0x11, 0xe1,
-	0x17, 0xe2, 0xa3, 0xca, 0x7e, 0x4f, 0x75, 0xa1, 0x46, 0x79, 0x7c, 0x98, 0x2d, 0x88, 0x42, 0xa2,
-	0xa3, 0xf2, 0x5e, 0x68, 0x2e, 0x6b, 0xaf, 0x9d, 0x17, 0x00, 0xba, 0x23, 0xc1, 0x2c, 0xaf, 0x55,
-	0xab, 0x9a, 0xd5, 0x32, 0xf1, 0xd2, 0x5e, 0xce, 0x32, 0xcb, 0x76, 0xa5, 0x5f, 0x76, 0x0b, 0x11,
-	0xe7, 0x93, 0x01, 0xdd, 0x4c, 0xd8, 0x91, 0x37, 0x0e, 0x30, 0x5a, 0x87, 0x1a, 0x17, 0x07, 0xbd,
-	0xf3, 0xff, 0xeb, 0x2f, 0xbd, 0x40, 0x1a, 0xc8, 0xa7, 0x5a, 0x23, 0xc5, 0xee, 0xed, 0x03, 0xdc,
-	0x04, 0xef, 0x58, 0xa0, 0xc7, 0x8b, 0x0b, 0xb4, 0xb2, 0xd8, 0x96, 0x60, 0x56, 0xdc, 0xa1, 0x73,
-	0xb1, 0x43, 0xbb, 0x71, 0x1a, 0x71, 0x34, 0x14, 0x37, 0x20, 0x8d, 0x78, 0x76, 0x07, 0x7b, 0xf9,
-	0xe6, 0xc9, 0xfc, 0x40, 0x3e, 0xf5, 0x46, 0x6b, 0x66, 0x6f, 0x13, 0xda, 0x85, 0xf0, 0x1d, 0x62,
-	0x56, 0x8b, 0x62, 0xba, 0xc5, 0xc9, 0x5f, 0xca, 0x00, 0xaf, 0x84, 0x75, 0x7b, 0x91, 0x8f, 0xcf,
-	0xd1, 0x3a, 0xd4, 0xc7, 0x41, 0x3c, 0x39, 0xcb, 0xa6, 0xff, 0xa7, 0xa7, 0xdf, 0x50, 0x06, 0x3b,
-	0x32, 0xaf, 0x05, 0x28, 0xb2, 0xe8, 0x5f, 0x5c, 0x69, 0x05, 0x90, 0x05, 0xed, 0xc0, 0x63, 0xfc,
-	0x24, 0x4c, 0xf9, 0x09, 0xf1, 0xf5, 0x06, 0xb6, 0x44, 0x68, 0x94, 0xf2, 0x3d, 0x1f, 0x39, 0xd0,
-	0x55, 0xf9, 0xd8, 0x3f, 0xe1, 0x24, 0x54, 0x77, 0xbe, 0xe5, 0xca, 0xa2, 0x51, 0xec, 0x1f, 0x91,
-	0x10, 0x2f, 0x70, 0x52, 0x86, 0x13, 0xf9, 0x0f, 0x70, 0xc3, 0x39, 0x66, 0x38, 0x41, 0x36, 0x74,
-	0x72, 0x8e, 0x47, 0xa9, 0x59, 0x97, 0x14, 0xd0, 0x94, 0x6d, 0x4a, 0x7b, 0x7b, 0xd0, 0x2e, 0xc8,
-	0x7e, 0xc8, 0x75, 0x97, 0xbe, 0x16, 0x0d, 0x7b, 0x0e, 0x9d, 0xcc, 0x0c, 0x32, 0xc1, 0x0c, 0x3d,
-	0x81, 0x06, 0x51, 0x47, 0x6d, 0xd9, 0xca, 0x2d, 0xcb, 0xdc, 0x8c, 0xb1, 0xb3, 0x76, 0x79, 0x65,
-	0x95, 0xbe, 0x5d, 0x59, 0xa5, 0xeb, 0x2b, 0xcb, 0x78, 0x37, 0xb7, 0x8c, 0xcf, 0x73, 0xcb, 0xf8,
-	0x3a, 0xb7, 0x8c, 0xcb, 0xb9, 0x65, 0x7c, 0x9f, 0x5b, 0xc6, 0xcf, 0xb9, 0x55, 0xba, 0x9e, 0x5b,
-	0xc6, 0xc7, 0x1f, 0x56, 0x69, 0x5c, 0x97, 0x8d, 0x9e, 0xfd, 0x0a, 0x00, 0x00, 0xff, 0xff, 0xc5,
-	0x62, 0xdc, 0x21, 0xde, 0x06, 0x00, 0x00,
+	// 802 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x55, 0xbd, 0x4f, 0xdb, 0x4e,
+	0x18, 0xce, 0xc5, 0xf9, 0x7c, 0x93, 0x20, 0x38, 0xa1, 0x9f, 0xac, 0xe8, 0x57, 0xd7, 0xb2, 0x2a,
+	0x35, 0x52, 0x51, 0xa4, 0xa6, 0x42, 0x02, 0x3a, 0x01, 0x65, 0x40, 0x34, 0xa2, 0x32, 0xd0, 0x15,
+	0x39, 0xf1, 0x11, 0x59, 0xf8, 0x4b, 0xbe, 0x73, 0x0a, 0x5b, 0xa7, 0x2e, 0x5d, 0x2a, 0x75, 0xee,
+	0xde, 0xa9, 0x7f, 0x47, 0x47, 0xc6, 0x8e, 0x25, 0x5d, 0x3a, 0xf2, 0x27, 0x54, 0xf7, 0x61, 0xe3,
+	0x94, 0x8f, 0x8a, 0x25, 0xb9, 0xe7, 0xb9, 0xe7, 0xde, 0x7b, 0xee, 0xf1, 0x7b, 0x36, 0x2c, 0xf8,
+	0xce, 0x88, 0xf8, 0x51, 0x4c, 0xfb, 0x71, 0x12, 0xb1, 0x08, 0x57, 0xc5, 0x9f, 0xb5, 0x0f, 0xf5,
+	0x21, 0x49, 0x26, 0x64, 0x3f, 0xc6, 0xcb, 0x50, 0x0d, 0x52, 0xe6, 0xb9, 0x3a, 0x32, 0x51, 0xaf,
+	0x62, 0x4b, 0x80, 0xff, 0x83, 0x1a, 0x73, 0x92, 0x09, 0x61, 0x7a, 0x59, 0xd0, 0x0a, 0x71, 0x3e,
+	0xe0, 0x0b, 0x5d, 0x5d, 0x33, 0x35, 0xce, 0x4b, 0x64, 0x4d, 0xa1, 0xb1, 0xed, 0x13, 0x67, 0xfa,
+	0xf0, 0x8a, 0x16, 0xb4, 0xc7, 0x62, 0xa5, 0x2b, 0xac, 0xea, 0x9a, 0x98, 0x9d, 0xe3, 0xb0, 0x0e,
+	0x75, 0x85, 0xf5, 0x8a, 0xd8, 0x36, 0x83, 0xd6, 0x11, 0x34, 0x87, 0x4e, 0x1c, 0x7b, 0xe1, 0xe4,
+	0xbe, 0x8d, 0x03, 0x27, 0x8e, 0x89, 0x9b, 0x6d, 0x2c, 0x11, 0xee, 0x42, 0x23, 0x4a, 0xbc, 0x89,
+	0x17, 0x3a, 0xbe, 0x3a, 0x4c, 0x8e, 0xad, 0x0d, 0x80, 0xbc, 0x2c, 0xc5, 0x2b, 0xd0, 0x08, 0x24,
+	0xa2, 0x3a, 0x32, 0xb5, 0x5e, 0x6b, 0xb0, 0x28, 0xe3, 0xec, 0xe7, 0x22, 0x3b, 0x57, 0x58, 0x1f,
+	0xca, 0x50, 0x3f, 0x88, 0x7d, 0x8f, 0x3d, 0x38, 0x8a, 0x2e, 0x34, 0x42, 0xf2, 0xae, 0x18, 0x43,
+	0x8e, 0xf9, 0x9a, 0x71, 0xe4, 0x24, 0x94, 0xe8, 0x15, 0x13, 0xf5, 0x1a, 0xb6, 0x42, 0x18, 0x43,
+	0x25, 0xf1, 0x09, 0xd5, 0xab, 0x26, 0xea, 0xb5, 0x6d, 0x31, 0xc6, 0x6b, 0xd0, 0xa0, 0x53, 0xca,
+	0x2d, 0x50, 0xbd, 0x26, 0xfc, 0xfe, 0xaf, 0xfc, 0x2a, 0x5f, 0xfd, 0x03, 0x35, 0xbd, 0x13, 0xb2,
+	0xe4, 0xdc, 0xce, 0xd5, 0xdd, 0x3d, 0xe8, 0xcc, 0x4d, 0xe1, 0x45, 0xd0, 0x4e, 0xc9, 0xb9, 0xb2,
+	0xcf, 0x87, 0xf8, 0x09, 0x54, 0xa7, 0x8e, 0x9f, 0x12, 0xe1, 0xbd, 0x35, 0x58, 0xc8, 0x2a, 0xbf,
+	0x15, 0xb5, 0x6d, 0x39, 0xb9, 0x51, 0x5e, 0x43, 0xd6, 0x1e, 0xd4, 0x15, 0x8b, 0x0d, 0x00, 0x51,
+	0x55, 0x9e, 0x4d, 0x56, 0x2b, 0x30, 0xd8, 0x84, 0x56, 0x42, 0x02, 0xc7, 0x0b, 0xa5, 0x40, 0xc6,
+	0x52, 0xa4, 0xac, 0x8f, 0x08, 0x96, 0x0e, 0xd2, 0x98, 0x24, 0xd3, 0xe8, 0x8c, 0xf8, 0xf7, 0xe7,
+	0xcb, 0x77, 0xcb, 0xa5, 0xaa, 0x58, 0x81, 0xf9, 0xcb, 0x8d, 0xf6, 0x2f, 0x37, 0x95, 0x9b, 0x6e,
+	0xd6, 0xa1, 0xb5, 0x1f, 0x6f, 0x47, 0x41, 0xec, 0x13, 0x46, 0xdc, 0x3b, 0x6c, 0x2c, 0x43, 0x95,
+	0x32, 0x67, 0x22, 0x93, 0x6a, 0xda, 0x12, 0x58, 0x6f, 0xa0, 0xb1, 0x79, 0x72, 0xe2, 0x85, 0x1e,
+	0x3b, 0xe7, 0x0f, 0x55, 0xd4, 0x7b, 0xae, 0x16, 0x2a, 0x94, 0xf3, 0x83, 0xac, 0x41, 0x24, 0xe2,
+	0x15, 0x65, 0xf6, 0xdc, 0x73, 0x59, 0x65, 0x6d, 0xbd, 0x02, 0x50, 0x15, 0x3d, 0x42, 0xf3, 0xb5,
+	0xb2, 0x55, 0xb3, 0xb5, 0x94, 0x1f, 0xda, 0xc9, 0x55, 0x7a, 0xd9, 0xd4, 0x7a, 0x65, 0xbb, 0xc0,
+	0x58, 0x9f, 0x11, 0x74, 0x32, 0x63, 0x87, 0xce, 0xc8, 0x27, 0x78, 0x15, 0xaa, 0x8c, 0x0f, 0x54,
+	0xcf, 0x3f, 0x56, 0x4f, 0x7a, 0x4e, 0xd4, 0x17, 0xbf, 0xb2, 0x8d, 0xa4, 0xba, 0xbb, 0x07, 0x70,
+	0x4d, 0xde, 0xd2, 0x40, 0x4f, 0xe7, 0x1b, 0x68, 0x69, 0xbe, 0xac, 0x47, 0x68, 0xb1, 0x87, 0xce,
+	0x78, 0x0f, 0x6d, 0x47, 0x69, 0xc8, 0xf0, 0x80, 0xdf, 0x80, 0x34, 0x64, 0xd9, 0x1d, 0xec, 0xe6,
+	0x9d, 0x27, 0xe6, 0xfb, 0xe2, 0x57, 0x75, 0xb4, 0x52, 0x76, 0xd7, 0xa1, 0x55, 0xa0, 0x6f, 0x31,
+	0xb3, 0x5c, 0x34, 0xd3, 0x29, 0xee, 0xfc, 0xad, 0x0c, 0xf0, 0x9a, 0x47, 0xb7, 0x1b, 0xba, 0xe4,
+	0x0c, 0xaf, 0x42, 0x6d, 0xe4, 0x47, 0xe3, 0xd3, 0x6c, 0xf7, 0x47, 0x6a, 0xf7, 0x6b, 0x49, 0x7f,
+	0x4b, 0xcc, 0x2b, 0x03, 0x52, 0xcc, 0xeb, 0x17, 0x5b, 0x5a, 0x02, 0x6c, 0x40, 0xcb, 0x77, 0x28,
+	0x3b, 0x0e, 0x52, 0x76, 0xec, 0xb9, 0xaa, 0x03, 0x9b, 0x9c, 0x1a, 0xa6, 0x6c, 0xd7, 0xc5, 0x16,
+	0x74, 0xe4, 0x7c, 0xe4, 0x1e, 0x33, 0x2f, 0x90, 0x77, 0xbe, 0x69, 0x8b, 0x45, 0xc3, 0xc8, 0x3d,
+	0xf4, 0x02, 0x32, 0xa7, 0x49, 0x29, 0x49, 0xf4, 0xea, 0x9c, 0xe6, 0x88, 0x92, 0x04, 0x9b, 0xd0,
+	0xce, 0x35, 0x4e, 0x1c, 0xeb, 0x35, 0x21, 0x01, 0x25, 0xd9, 0x8c, 0xe3, 0xee, 0x2e, 0xb4, 0x0a,
+	0xb6, 0x1f, 0x72, 0xdd, 0x45, 0xae, 0xc5, 0xc0, 0x5e, 0x42, 0x3b, 0x0b, 0xc3, 0x1b, 0x13, 0x8a,
+	0x9f, 0x41, 0xdd, 0x93, 0x43, 0x15, 0xd9, 0xd2, 0x8d, 0xc8, 0xec, 0x4c, 0x61, 0x7d, 0x41, 0x50,
+	0xdf, 0x49, 0x1c, 0x7a, 0xf7, 0xf7, 0xe3, 0xae, 0x24, 0x8b, 0x57, 0x5d, 0x13, 0xaf, 0xc6, 0x02,
+	0xc3, 0x6f, 0x03, 0xe1, 0x65, 0xb3, 0x0f, 0x87, 0x42, 0x9c, 0x17, 0x02, 0xf9, 0xe2, 0xac, 0xd8,
+	0x0a, 0x71, 0x7e, 0x14, 0xa5, 0xa1, 0x4b, 0x55, 0x56, 0x0a, 0x6d, 0xad, 0x5c, 0x5c, 0x1a, 0xa5,
+	0x1f, 0x97, 0x46, 0xe9, 0xea, 0xd2, 0x40, 0xef, 0x67, 0x06, 0xfa, 0x3a, 0x33, 0xd0, 0xf7, 0x99,
+	0x81, 0x2e, 0x66, 0x06, 0xfa, 0x39, 0x33, 0xd0, 0xef, 0x99, 0x51, 0xba, 0x9a, 0x19, 0xe8, 0xd3,
+	0x2f, 0xa3, 0x34, 0xaa, 0x89, 0x83, 0xbe, 0xf8, 0x33, 0x00, 0x6c, 0x07, 0x6d, 0x01, 0x7e, 0x07,
+	0x00, 0x00,
 }
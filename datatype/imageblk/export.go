@@ -0,0 +1,202 @@
+/*
+	This file supports export and import of image block data as Zarr or N5 chunked arrays.
+*/
+
+package imageblk
+
+import (
+	"fmt"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/datatype/common/chunked"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// returns the data type of the single value per voxel, since chunked arrays exported
+// by DVID are scalar arrays.
+func (d *Data) chunkedDataType() (dvid.DataType, error) {
+	if len(d.Properties.Values) != 1 {
+		return 0, fmt.Errorf("data %q has %d values per voxel: only single-channel data can be exported or imported as chunked arrays", d.DataName(), len(d.Properties.Values))
+	}
+	return d.Properties.Values[0].T, nil
+}
+
+// ExportChunked writes a version of image block data into a directory-based Zarr or N5
+// store as the array "s0" whose chunk shape is the block size.
+func (d *Data) ExportChunked(v dvid.VersionID, dirPath string, format chunked.Format, gzip bool) error {
+	timedLog := dvid.NewTimeLog()
+	dataType, err := d.chunkedDataType()
+	if err != nil {
+		return err
+	}
+	ctx := datastore.NewVersionedCtx(d, v)
+	extents, err := d.GetExtents(ctx)
+	if err != nil {
+		return err
+	}
+	if extents.MinPoint == nil || extents.MaxPoint == nil {
+		return fmt.Errorf("data %q has no extents to export", d.DataName())
+	}
+	minPt, ok1 := extents.MinPoint.(dvid.Point3d)
+	maxPt, ok2 := extents.MaxPoint.(dvid.Point3d)
+	blockSize, ok3 := d.BlockSize().(dvid.Point3d)
+	if !ok1 || !ok2 || !ok3 {
+		return fmt.Errorf("data %q must be 3d to export", d.DataName())
+	}
+
+	db, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return err
+	}
+	rootAttrs := chunked.MultiscaleAttrs(format, string(d.DataName()), d.Properties.Resolution, 1)
+	store, err := chunked.Create(dirPath, format, rootAttrs)
+	if err != nil {
+		return err
+	}
+	offset, size := chunked.ScaledBounds(minPt, maxPt, blockSize, 0)
+	meta := chunked.ArrayMeta{
+		Size:      size,
+		ChunkSize: blockSize,
+		DataType:  dataType,
+		Gzip:      gzip,
+		Attrs:     chunked.ScaleAttrs(format, d.Properties.Resolution, 0, offset),
+	}
+	arr, err := store.CreateArray(chunked.ScaleName(0), meta)
+	if err != nil {
+		return err
+	}
+	offsetBlock := offset.Chunk(blockSize).(dvid.ChunkPoint3d)
+
+	var numBlocks int
+	begTKey := NewTKey(&dvid.MinIndexZYX)
+	endTKey := NewTKey(&dvid.MaxIndexZYX)
+	err = db.ProcessRange(ctx, begTKey, endTKey, nil, func(c *storage.Chunk) error {
+		if c == nil || c.TKeyValue == nil || c.V == nil {
+			return nil
+		}
+		idx, err := DecodeTKey(c.K)
+		if err != nil {
+			return err
+		}
+		data, _, err := dvid.DeserializeData(c.V, true)
+		if err != nil {
+			return fmt.Errorf("unable to deserialize block %s in %q: %v", idx, d.DataName(), err)
+		}
+		bcoord := dvid.ChunkPoint3d(*idx)
+		chunkPt := dvid.ChunkPoint3d{
+			bcoord[0] - offsetBlock[0],
+			bcoord[1] - offsetBlock[1],
+			bcoord[2] - offsetBlock[2],
+		}
+		numBlocks++
+		return arr.WriteChunk(chunkPt, data)
+	})
+	if err != nil {
+		return fmt.Errorf("error exporting data %q: %v", d.DataName(), err)
+	}
+	timedLog.Infof("Exported %d blocks of data %q to %s store %q", numBlocks, d.DataName(), format, dirPath)
+	return nil
+}
+
+// ImportChunked ingests the "s0" array of a Zarr or N5 store into the given version.  The
+// array must be 3d with the same data type as this instance and chunk shape equal to the
+// block size.  The voxel offset is read from the array attributes if written by DVID,
+// otherwise the given offset is used.
+func (d *Data) ImportChunked(v dvid.VersionID, dirPath string, offset dvid.Point3d) error {
+	timedLog := dvid.NewTimeLog()
+	dataType, err := d.chunkedDataType()
+	if err != nil {
+		return err
+	}
+	blockSize, ok := d.BlockSize().(dvid.Point3d)
+	if !ok {
+		return fmt.Errorf("data %q must be 3d to import", d.DataName())
+	}
+	store, err := chunked.Open(dirPath)
+	if err != nil {
+		return err
+	}
+	arr, err := store.OpenArray(chunked.ScaleName(0))
+	if err != nil {
+		return err
+	}
+	if arr.ChunkSize != blockSize {
+		return fmt.Errorf("array chunk size %s doesn't match data %q block size %s", arr.ChunkSize, d.DataName(), blockSize)
+	}
+	if arr.DataType != dataType {
+		return fmt.Errorf("array data type doesn't match the %s data type of data %q", d.Properties.Values, d.DataName())
+	}
+	if attrOffset, found := chunked.OffsetFromAttrs(arr.Attrs); found {
+		offset = attrOffset
+	}
+	for i := 0; i < 3; i++ {
+		if offset[i]%blockSize[i] != 0 {
+			return fmt.Errorf("offset %s is not block aligned", offset)
+		}
+	}
+	offsetBlock := offset.Chunk(blockSize).(dvid.ChunkPoint3d)
+	chunks, err := arr.Chunks()
+	if err != nil {
+		return err
+	}
+
+	batcher, err := datastore.GetKeyValueBatcher(d)
+	if err != nil {
+		return err
+	}
+	ctx := datastore.NewVersionedCtx(d, v)
+	batch := batcher.NewBatch(ctx)
+
+	const BatchSize = 1000
+	mutID := d.NewMutationID()
+	var extents dvid.Extents
+	var numBlocks int
+	for _, chunkPt := range chunks {
+		data, err := arr.ReadChunk(chunkPt)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			continue
+		}
+		bcoord := dvid.ChunkPoint3d{
+			chunkPt[0] + offsetBlock[0],
+			chunkPt[1] + offsetBlock[1],
+			chunkPt[2] + offsetBlock[2],
+		}
+		minPt, maxPt := bcoord.BoundingVoxels(blockSize)
+		extents.AdjustPoints(minPt, maxPt)
+
+		serialization, err := dvid.SerializeData(data, d.Compression(), d.Checksum())
+		if err != nil {
+			return err
+		}
+		zyx := dvid.IndexZYX(bcoord)
+		batch.Put(NewTKey(&zyx), serialization)
+
+		evt := datastore.SyncEvent{d.DataUUID(), IngestBlockEvent}
+		msg := datastore.SyncMessage{IngestBlockEvent, v, Block{&zyx, data, mutID}}
+		if err := datastore.NotifySubscribers(evt, msg); err != nil {
+			return err
+		}
+
+		numBlocks++
+		if numBlocks%BatchSize == 0 {
+			if err := batch.Commit(); err != nil {
+				return fmt.Errorf("error on batch commit, block %d: %v", numBlocks, err)
+			}
+			batch = batcher.NewBatch(ctx)
+		}
+	}
+	if err := batch.Commit(); err != nil {
+		return fmt.Errorf("error on batch commit, block %d: %v", numBlocks, err)
+	}
+	if numBlocks != 0 {
+		if err := d.PostExtents(ctx, extents.StartPoint(), extents.EndPoint()); err != nil {
+			return err
+		}
+	}
+	timedLog.Infof("Imported %d chunks from %s store %q into data %q", numBlocks, store.Format, dirPath, d.DataName())
+	return nil
+}
@@ -21,6 +21,7 @@ import (
 	"sync"
 
 	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/datatype/common/chunked"
 	"github.com/janelia-flyem/dvid/datatype/roi"
 	"github.com/janelia-flyem/dvid/dvid"
 	"github.com/janelia-flyem/dvid/server"
@@ -110,6 +111,50 @@ $ dvid node <UUID> <data name> roi <new roi data name> <background values separa
 
     $ dvid node 3f8c mygrayscale roi grayscale_roi 0,255
 
+$ dvid node <UUID> <data name> export <format> <directory path> <settings...>
+
+    Exports the data at the given version into a directory-based chunked array store as
+    an array named "s0" with chunk shape equal to the block size.  Voxel resolution and the
+    DVID voxel offset of the array are written as attributes so the data can be imported back
+    into the same location.  Only single-channel data like uint8blk can be exported.
+
+    Example:
+
+    $ dvid node 3f8c mygrayscale export zarr /path/to/grayscale.zarr
+
+    Arguments:
+
+    UUID            Hexidecimal string with enough characters to uniquely identify a version node.
+    data name       Name of data to export.
+    format          Either "zarr" (Zarr v2 with OME-NGFF multiscales metadata) or "n5".
+    directory path  Absolute path to a directory that the dvid server has write privileges to.
+
+    Configuration Settings (case-insensitive keys)
+
+    compression     "gzip" (default) or "raw" chunk compression.
+
+$ dvid node <UUID> <data name> import <directory path> <settings...>
+
+    Imports the "s0" array of a Zarr v2 or N5 store whose data type matches the data instance
+    and whose chunk shape is equal to the block size.  The format is detected from the store's
+    metadata.
+
+    Example:
+
+    $ dvid node 3f8c mygrayscale import /path/to/grayscale.n5 offset=0,0,1024
+
+    Arguments:
+
+    UUID            Hexidecimal string with enough characters to uniquely identify a version node.
+    data name       Name of data to import into.
+    directory path  Absolute path to a store directory readable by the dvid server.
+
+    Configuration Settings (case-insensitive keys)
+
+    offset          Block-aligned voxel coordinate "x,y,z" of the first voxel of the array.  Ignored
+                      if the array was exported by DVID and has a "dvidOffset" attribute.
+                      (Default 0,0,0)
+
     
     ------------------
 
@@ -1700,6 +1745,67 @@ func (d *Data) DoRPC(req datastore.Request, reply *datastore.Response) error {
 		}
 		return d.ForegroundROI(req, reply)
 
+	case "export":
+		if len(req.Command) < 6 {
+			return fmt.Errorf("Poorly formatted export command.  See command-line help.")
+		}
+		var uuidStr, dataName, cmdStr, formatStr, dirPath string
+		req.CommandArgs(1, &uuidStr, &dataName, &cmdStr, &formatStr, &dirPath)
+
+		format, err := chunked.ParseFormat(formatStr)
+		if err != nil {
+			return err
+		}
+		_, versionID, err := datastore.MatchingUUID(uuidStr)
+		if err != nil {
+			return err
+		}
+		compression, _, err := req.Settings().GetString("compression")
+		if err != nil {
+			return err
+		}
+		gzip, err := chunked.ParseCompression(compression)
+		if err != nil {
+			return err
+		}
+		reply.Text = fmt.Sprintf("Exporting data instance %q @ node %s to %s store %q...\n", dataName, uuidStr, format, dirPath)
+		go func() {
+			if err := d.ExportChunked(versionID, dirPath, format, gzip); err != nil {
+				dvid.Errorf("Cannot export data instance %q @ node %s: %v\n", dataName, uuidStr, err)
+			}
+		}()
+
+	case "import":
+		if len(req.Command) < 5 {
+			return fmt.Errorf("Poorly formatted import command.  See command-line help.")
+		}
+		var uuidStr, dataName, cmdStr, dirPath string
+		req.CommandArgs(1, &uuidStr, &dataName, &cmdStr, &dirPath)
+
+		uuid, versionID, err := datastore.MatchingUUID(uuidStr)
+		if err != nil {
+			return err
+		}
+		var offset dvid.Point3d
+		offsetStr, found, err := req.Settings().GetString("offset")
+		if err != nil {
+			return err
+		}
+		if found {
+			if offset, err = dvid.StringToPoint3d(offsetStr, ","); err != nil {
+				return fmt.Errorf("Illegal offset specification: %s: %v", offsetStr, err)
+			}
+		}
+		if err = datastore.AddToNodeLog(uuid, []string{req.Command.String()}); err != nil {
+			return err
+		}
+		reply.Text = fmt.Sprintf("Importing %q into data instance %q @ node %s...\n", dirPath, dataName, uuidStr)
+		go func() {
+			if err := d.ImportChunked(versionID, dirPath, offset); err != nil {
+				dvid.Errorf("Cannot import %q into data instance %q @ node %s: %v\n", dirPath, dataName, uuidStr, err)
+			}
+		}()
+
 	default:
 		return fmt.Errorf("Unknown command.  Data instance '%s' [%s] does not support '%s' command.",
 			d.DataName(), d.TypeName(), req.TypeCommand())
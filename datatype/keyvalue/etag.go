@@ -0,0 +1,121 @@
+/*
+	This file supports entity tags, conditional writes, and compare-and-swap for the keyvalue
+	data type.
+*/
+
+package keyvalue
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// ErrPreconditionFailed is returned when a conditional write's precondition is not met.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// ETag returns the quoted entity tag for a value, which is a hash of its content.
+func ETag(value []byte) string {
+	return fmt.Sprintf("\"%x\"", md5.Sum(value))
+}
+
+// Precondition gives the entity tags required for a conditional write in the format of the
+// HTTP If-Match and If-None-Match headers, i.e., "*" or a comma-separated list of tags.
+// An empty string means the condition is not used.
+type Precondition struct {
+	IfMatch     string
+	IfNoneMatch string
+}
+
+// IsSet returns true if there is any condition.
+func (p Precondition) IsSet() bool {
+	return p.IfMatch != "" || p.IfNoneMatch != ""
+}
+
+// etagListMatches returns true if the given entity tag is in the list or the list is "*".
+// Weak tags are compared by their opaque tag.
+func etagListMatches(list, etag string) bool {
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Satisfied returns true if the precondition holds for a key with the given entity tag,
+// where found is false if the key doesn't exist.
+func (p Precondition) Satisfied(etag string, found bool) bool {
+	if p.IfMatch != "" && (!found || !etagListMatches(p.IfMatch, etag)) {
+		return false
+	}
+	if p.IfNoneMatch != "" && found && etagListMatches(p.IfNoneMatch, etag) {
+		return false
+	}
+	return true
+}
+
+// NewLockTKey returns the type-specific key used to lock a key for compare-and-swap.
+func NewLockTKey(key string) storage.TKey {
+	return storage.NewTKey(keyLock, append([]byte(key), 0))
+}
+
+// lockKey locks a key for a compare-and-swap and returns a function that unlocks it.  If the
+// store supports transactions, the lock is held in the store so it is respected by other
+// servers.  Otherwise, an instance-level lock is used.
+func (d *Data) lockKey(ctx storage.Context, keyStr string) (unlock func(), err error) {
+	store, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return nil, err
+	}
+	if transdb, ok := store.(storage.TransactionDB); ok {
+		lk := ctx.ConstructKey(NewLockTKey(keyStr))
+		if err := transdb.LockKey(lk); err != nil {
+			return nil, err
+		}
+		return func() {
+			if err := transdb.UnlockKey(lk); err != nil {
+				dvid.Errorf("unable to unlock key %q of keyvalue %q: %v\n", keyStr, d.DataName(), err)
+			}
+		}, nil
+	}
+	d.casMu.Lock()
+	return d.casMu.Unlock, nil
+}
+
+// CompareAndSwap atomically checks the precondition against the current value of a key and,
+// if satisfied, replaces the value or deletes the key if del is true.  ErrPreconditionFailed
+// is returned if the precondition is not satisfied.  The entity tag of the new value is
+// returned, which is empty on deletion.
+func (d *Data) CompareAndSwap(ctx storage.Context, keyStr string, cond Precondition, value []byte, del bool) (etag string, err error) {
+	unlock, err := d.lockKey(ctx, keyStr)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	cur, found, err := d.GetData(ctx, keyStr)
+	if err != nil {
+		return "", err
+	}
+	var curETag string
+	if found {
+		curETag = ETag(cur)
+	}
+	if !cond.Satisfied(curETag, found) {
+		return "", ErrPreconditionFailed
+	}
+	if del {
+		return "", d.DeleteData(ctx, keyStr)
+	}
+	if err := d.PutData(ctx, keyStr, value); err != nil {
+		return "", err
+	}
+	return ETag(value), nil
+}
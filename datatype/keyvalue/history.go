@@ -0,0 +1,161 @@
+/*
+	This file supports the version history of keys for the keyvalue data type.
+*/
+
+package keyvalue
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// KeyVersion describes the value of a key at a version node where it changed from the
+// version's parent, including deletions, which are stored as tombstones.
+type KeyVersion struct {
+	UUID        dvid.UUID
+	Note        string
+	Locked      bool
+	NodeUpdated time.Time // time of the node's last commit, note, or log change
+	Deleted     bool
+	Size        int
+	ETag        string `json:",omitempty"`
+	Modified    string `json:",omitempty"` // modification time if the store supports it
+	Value       []byte `json:",omitempty"`
+}
+
+// keyState is the value of a key as seen from a version.
+type keyState struct {
+	value []byte
+	found bool
+	etag  string
+}
+
+func (s keyState) equals(s2 keyState) bool {
+	return s.found == s2.found && s.etag == s2.etag
+}
+
+func (d *Data) getKeyState(v dvid.VersionID, keyStr string) (keyState, error) {
+	ctx := datastore.NewVersionedCtx(d, v)
+	value, found, err := d.GetData(ctx, keyStr)
+	if err != nil {
+		return keyState{}, err
+	}
+	state := keyState{value: value, found: found}
+	if found {
+		state.etag = ETag(value)
+	}
+	return state, nil
+}
+
+// keyVersion returns the description of a key's state at a version.
+func (d *Data) keyVersion(v dvid.VersionID, keyStr string, state keyState, withValue bool) (*KeyVersion, error) {
+	uuid, err := datastore.UUIDFromVersion(v)
+	if err != nil {
+		return nil, err
+	}
+	kv := &KeyVersion{UUID: uuid, Deleted: !state.found, Size: len(state.value), ETag: state.etag}
+	if kv.Note, err = datastore.GetNodeNote(uuid); err != nil {
+		return nil, err
+	}
+	if kv.Locked, err = datastore.LockedVersion(v); err != nil {
+		return nil, err
+	}
+	if _, kv.NodeUpdated, err = datastore.GetNodeTimes(uuid); err != nil {
+		return nil, err
+	}
+	if withValue {
+		kv.Value = state.value
+	}
+	if !state.found {
+		return kv, nil
+	}
+	db, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return nil, err
+	}
+	if tsdb, ok := db.(storage.KeyValueTimestampGetter); ok {
+		tk, err := NewTKey(keyStr)
+		if err != nil {
+			return nil, err
+		}
+		_, modTime, err := tsdb.GetWithTimestamp(datastore.NewVersionedCtx(d, v), tk)
+		if err != nil {
+			return nil, err
+		}
+		if !modTime.IsZero() {
+			kv.Modified = modTime.Format(time.RFC3339)
+		}
+	}
+	return kv, nil
+}
+
+// GetKeyHistory returns the versions where a key changed along the ancestry from the root to
+// the given version, ordered from the root.  If descendants is true, changes in all versions
+// descended from the given version are appended in breadth-first order, where each version
+// is compared to the version it was reached from.  If withValues is true, the value at each
+// version is included.
+func (d *Data) GetKeyHistory(v dvid.VersionID, keyStr string, descendants, withValues bool) ([]KeyVersion, error) {
+	if !d.Versioned() {
+		return nil, fmt.Errorf("keyvalue %q is unversioned and has no key history", d.DataName())
+	}
+	ancestry, err := datastore.GetAncestry(v)
+	if err != nil {
+		return nil, err
+	}
+	history := []KeyVersion{}
+	addIfChanged := func(ver dvid.VersionID, prev keyState) (keyState, error) {
+		state, err := d.getKeyState(ver, keyStr)
+		if err != nil {
+			return state, err
+		}
+		if !state.equals(prev) {
+			kv, err := d.keyVersion(ver, keyStr, state, withValues)
+			if err != nil {
+				return state, err
+			}
+			history = append(history, *kv)
+		}
+		return state, nil
+	}
+
+	var state keyState
+	for i := len(ancestry) - 1; i >= 0; i-- {
+		if state, err = addIfChanged(ancestry[i], state); err != nil {
+			return nil, err
+		}
+	}
+	if !descendants {
+		return history, nil
+	}
+
+	type visit struct {
+		v     dvid.VersionID
+		state keyState
+	}
+	visited := map[dvid.VersionID]struct{}{v: {}}
+	queue := []visit{{v, state}}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		children, err := datastore.GetChildrenByVersion(cur.v)
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			if _, found := visited[child]; found {
+				continue
+			}
+			visited[child] = struct{}{}
+			childState, err := addIfChanged(child, cur.state)
+			if err != nil {
+				return nil, err
+			}
+			queue = append(queue, visit{child, childState})
+		}
+	}
+	return history, nil
+}
@@ -0,0 +1,533 @@
+/*
+	This file supports the JSON mode of keyvalue instances with indexed fields.
+*/
+
+package keyvalue
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// Properties are additional properties for keyvalue data beyond those in standard
+// datastore.Data.
+type Properties struct {
+	// JSON is true if values must be valid JSON.
+	JSON bool
+
+	// IndexedFields are dot-separated paths of JSON fields with a secondary index, e.g.,
+	// "status" or "review.user".  Indexing fields requires JSON mode.
+	IndexedFields []string
+}
+
+// setByConfig sets the properties from a configuration if present.  The properties are
+// unchanged if the configuration is invalid.
+func (p *Properties) setByConfig(c dvid.Config) error {
+	jsonMode, found, err := c.GetBool("JSON")
+	if err != nil {
+		return err
+	}
+	if !found {
+		jsonMode = p.JSON
+	}
+	s, found, err := c.GetString("IndexedFields")
+	if err != nil {
+		return err
+	}
+	fields := p.IndexedFields
+	if found {
+		if fields, err = parseIndexedFields(s); err != nil {
+			return err
+		}
+	}
+	if len(fields) != 0 && !jsonMode {
+		return fmt.Errorf("IndexedFields requires JSON mode; set JSON=true")
+	}
+	p.JSON = jsonMode
+	p.IndexedFields = fields
+	return nil
+}
+
+// ModifyConfig modifies the data instance configuration.  Changes to the indexed fields only
+// apply to subsequent writes unless the data is reindexed.
+func (d *Data) ModifyConfig(config dvid.Config) error {
+	d.propMu.Lock()
+	props := d.Properties
+	if err := props.setByConfig(config); err != nil {
+		d.propMu.Unlock()
+		return err
+	}
+	d.Properties = props
+	d.propMu.Unlock()
+	return d.Data.ModifyConfig(config)
+}
+
+// properties returns a copy of the keyvalue properties that is safe to use concurrently
+// with configuration changes.
+func (d *Data) properties() Properties {
+	d.propMu.RLock()
+	defer d.propMu.RUnlock()
+	return d.Properties
+}
+
+// parseIndexedFields returns the field paths in a comma-separated list.
+func parseIndexedFields(s string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if strings.IndexByte(field, 0) >= 0 {
+			return nil, fmt.Errorf("indexed field %q cannot contain 0 byte", field)
+		}
+		for _, name := range strings.Split(field, ".") {
+			if name == "" {
+				return nil, fmt.Errorf("indexed field %q has an empty path component", field)
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// decodeJSON returns the decoded JSON value or an error if it isn't valid JSON.
+func decodeJSON(value []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(value))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+	return doc, nil
+}
+
+// fieldValues returns the scalar values of a field path within a JSON document as strings.
+// Arrays are traversed, so each scalar within an array at or along the path is returned.
+func fieldValues(doc interface{}, path []string) []string {
+	switch v := doc.(type) {
+	case []interface{}:
+		var values []string
+		for _, elem := range v {
+			values = append(values, fieldValues(elem, path)...)
+		}
+		return values
+	case map[string]interface{}:
+		if len(path) == 0 {
+			return nil
+		}
+		return fieldValues(v[path[0]], path[1:])
+	}
+	if len(path) != 0 {
+		return nil
+	}
+	switch v := doc.(type) {
+	case string:
+		if strings.IndexByte(v, 0) >= 0 {
+			return nil
+		}
+		return []string{v}
+	case json.Number:
+		return []string{string(v)}
+	case bool:
+		return []string{strconv.FormatBool(v)}
+	}
+	return nil
+}
+
+// indexTKeys returns the index keys of the given fields for a JSON value of a key.
+func indexTKeys(fields []string, keyStr string, doc interface{}) []storage.TKey {
+	var tks []storage.TKey
+	for _, field := range fields {
+		for _, value := range fieldValues(doc, strings.Split(field, ".")) {
+			tks = append(tks, NewIndexTKey(field, value, keyStr))
+		}
+	}
+	return tks
+}
+
+// indexMarker is the value stored for each index entry.
+var indexMarker = []byte{1}
+
+// putIndexed writes or deletes a value and updates the index of the given fields in one batch.
+func (d *Data) putIndexed(ctx storage.Context, fields []string, keyStr string, value []byte, del bool) error {
+	db, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return err
+	}
+	batcher, ok := db.(storage.KeyValueBatcher)
+	if !ok {
+		return fmt.Errorf("store for keyvalue %q does not support batches required for indexing", d.DataName())
+	}
+	tk, err := NewTKey(keyStr)
+	if err != nil {
+		return err
+	}
+	var newTKs []storage.TKey
+	var serialization []byte
+	if !del {
+		doc, err := decodeJSON(value)
+		if err != nil {
+			return fmt.Errorf("value for key %q is not valid JSON: %v", keyStr, err)
+		}
+		newTKs = indexTKeys(fields, keyStr, doc)
+		if serialization, err = dvid.SerializeData(value, d.Compression(), d.Checksum()); err != nil {
+			return fmt.Errorf("Unable to serialize data: %v\n", err)
+		}
+	}
+
+	d.indexMu.Lock()
+	defer d.indexMu.Unlock()
+
+	oldValue, found, err := d.GetData(ctx, keyStr)
+	if err != nil {
+		return err
+	}
+	batch := batcher.NewBatch(ctx)
+	if found {
+		if oldDoc, err := decodeJSON(oldValue); err == nil {
+			keep := make(map[string]struct{}, len(newTKs))
+			for _, newTK := range newTKs {
+				keep[string(newTK)] = struct{}{}
+			}
+			for _, oldTK := range indexTKeys(fields, keyStr, oldDoc) {
+				if _, found := keep[string(oldTK)]; !found {
+					batch.Delete(oldTK)
+				}
+			}
+		}
+	}
+	for _, newTK := range newTKs {
+		batch.Put(newTK, indexMarker)
+	}
+	if del {
+		batch.Delete(tk)
+	} else {
+		batch.Put(tk, serialization)
+	}
+	return batch.Commit()
+}
+
+// Reindex rebuilds the field index for all keys at the given version, e.g., after the
+// indexed fields are changed.  The index is deleted and rebuilt in batches, and indexMu is
+// only held while each batch is written, so indexed writes are blocked for the length of a
+// batch rather than the whole rebuild.  Each key is re-read under the lock so values
+// written during the rebuild are indexed correctly.  The number of indexed keys is returned.
+func (d *Data) Reindex(ctx *datastore.VersionedCtx) (numKeys int, err error) {
+	db, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return 0, err
+	}
+	batcher, ok := db.(storage.KeyValueBatcher)
+	if !ok {
+		return 0, fmt.Errorf("store for keyvalue %q does not support batches required for indexing", d.DataName())
+	}
+
+	fields := d.properties().IndexedFields
+
+	const batchSize = 1000
+	oldTKs, err := db.KeysInRange(ctx, storage.MinTKey(keyIndex), storage.MaxTKey(keyIndex))
+	if err != nil {
+		return 0, err
+	}
+	for start := 0; start < len(oldTKs); start += batchSize {
+		end := start + batchSize
+		if end > len(oldTKs) {
+			end = len(oldTKs)
+		}
+		batch := batcher.NewBatch(ctx)
+		for _, tk := range oldTKs[start:end] {
+			batch.Delete(tk)
+		}
+		d.indexMu.Lock()
+		err := batch.Commit()
+		d.indexMu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+	}
+	if len(fields) == 0 {
+		return 0, nil
+	}
+
+	keyStrs := make([]string, 0, batchSize)
+	err = db.ProcessRange(ctx, storage.MinTKey(keyStandard), storage.MaxTKey(keyStandard), nil, func(c *storage.Chunk) error {
+		if c == nil || c.TKeyValue == nil {
+			return nil
+		}
+		keyStr, err := DecodeTKey(c.K)
+		if err != nil {
+			return err
+		}
+		if keyStrs = append(keyStrs, keyStr); len(keyStrs) < batchSize {
+			return nil
+		}
+		n, err := d.reindexKeys(ctx, batcher, fields, keyStrs)
+		numKeys += n
+		keyStrs = keyStrs[:0]
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	n, err := d.reindexKeys(ctx, batcher, fields, keyStrs)
+	if err != nil {
+		return 0, err
+	}
+	return numKeys + n, nil
+}
+
+// reindexKeys writes the index for the current values of the given keys while holding
+// indexMu, returning the number of keys indexed.  Keys deleted since they were read are
+// skipped.
+func (d *Data) reindexKeys(ctx *datastore.VersionedCtx, batcher storage.KeyValueBatcher, fields []string, keyStrs []string) (numKeys int, err error) {
+	if len(keyStrs) == 0 {
+		return 0, nil
+	}
+	d.indexMu.Lock()
+	defer d.indexMu.Unlock()
+
+	batch := batcher.NewBatch(ctx)
+	for _, keyStr := range keyStrs {
+		value, found, err := d.GetData(ctx, keyStr)
+		if err != nil {
+			return 0, err
+		}
+		if !found {
+			continue
+		}
+		doc, err := decodeJSON(value)
+		if err != nil {
+			dvid.Errorf("skipping index of key %q in keyvalue %q with invalid JSON: %v\n", keyStr, d.DataName(), err)
+			continue
+		}
+		for _, tk := range indexTKeys(fields, keyStr, doc) {
+			batch.Put(tk, indexMarker)
+		}
+		numKeys++
+	}
+	if err := batch.Commit(); err != nil {
+		return 0, err
+	}
+	return numKeys, nil
+}
+
+// FieldQuery describes a search of a field index.  Either Values is set for matches of any
+// of the values or Min and/or Max are set for a numeric range query where Min is inclusive
+// and Max is exclusive.
+type FieldQuery struct {
+	Field      string
+	Values     []string
+	Min        *float64
+	Max        *float64
+	Limit      int    // if > 0, the maximum number of keys returned
+	Cursor     string // if non-empty, the cursor returned by a previous query
+	WithValues bool   // return the JSON value of each key
+}
+
+// FieldResult is a page of keys returned from a field index query, sorted by field value and
+// then key.  If the query was limited and more keys may be available, Cursor can be used to
+// get the next page.
+type FieldResult struct {
+	Keys   []string
+	Values map[string]json.RawMessage `json:",omitempty"`
+	Cursor string                     `json:",omitempty"`
+}
+
+var errQueryLimit = errors.New("field query limit reached")
+
+// indexRange is an inclusive range of index key bytes following the class.
+type indexRange struct {
+	beg, end []byte
+}
+
+type indexRanges []indexRange
+
+func (r indexRanges) Len() int           { return len(r) }
+func (r indexRanges) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r indexRanges) Less(i, j int) bool { return bytes.Compare(r[i].beg, r[j].beg) < 0 }
+
+// keyRanges returns the sorted ranges of index keys to search for the query.
+func (q FieldQuery) keyRanges() ([]indexRange, error) {
+	prefix := append([]byte(q.Field), 0)
+	var ranges []indexRange
+	switch {
+	case len(q.Values) != 0:
+		seen := make(map[string]struct{}, len(q.Values))
+		for _, value := range q.Values {
+			beg := indexValuePrefix(q.Field, value)
+			if _, found := seen[string(beg)]; found {
+				continue
+			}
+			seen[string(beg)] = struct{}{}
+			ranges = append(ranges, indexRange{beg, bytesSuccessor(beg)})
+		}
+		sort.Sort(indexRanges(ranges))
+	case q.Min != nil || q.Max != nil:
+		var r indexRange
+		if q.Min != nil {
+			r.beg = append(append([]byte{}, prefix...), encodeIndexNumber(*q.Min)...)
+		} else {
+			r.beg = append(append([]byte{}, prefix...), indexNumber)
+		}
+		if q.Max != nil {
+			r.end = append(append([]byte{}, prefix...), encodeIndexNumber(*q.Max)...)
+		} else {
+			r.end = append(append([]byte{}, prefix...), indexString)
+		}
+		ranges = append(ranges, r)
+	default:
+		return nil, fmt.Errorf("field query requires either values or a min/max range")
+	}
+	if q.Cursor == "" {
+		return ranges, nil
+	}
+	cursor, err := hex.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("bad cursor %q: %v", q.Cursor, err)
+	}
+	var remaining []indexRange
+	for _, r := range ranges {
+		if bytes.Compare(cursor, r.end) >= 0 {
+			continue
+		}
+		if bytes.Compare(cursor, r.beg) >= 0 {
+			r.beg = append(append([]byte{}, cursor...), 0)
+		}
+		remaining = append(remaining, r)
+	}
+	return remaining, nil
+}
+
+// bytesSuccessor returns the shortest byte slice that sorts after all slices with the given
+// prefix, or nil if there is none.
+func bytesSuccessor(prefix []byte) []byte {
+	succ := append([]byte{}, prefix...)
+	for len(succ) > 0 && succ[len(succ)-1] == 0xFF {
+		succ = succ[:len(succ)-1]
+	}
+	if len(succ) == 0 {
+		return nil
+	}
+	succ[len(succ)-1]++
+	return succ
+}
+
+// QueryFields returns keys whose JSON value matches a field index query.
+func (d *Data) QueryFields(ctx *datastore.VersionedCtx, q FieldQuery) (*FieldResult, error) {
+	var indexed bool
+	for _, field := range d.properties().IndexedFields {
+		if field == q.Field {
+			indexed = true
+			break
+		}
+	}
+	if !indexed {
+		return nil, fmt.Errorf("field %q is not indexed for keyvalue %q", q.Field, d.DataName())
+	}
+	ranges, err := q.keyRanges()
+	if err != nil {
+		return nil, err
+	}
+	db, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &FieldResult{Keys: []string{}}
+	for _, r := range ranges {
+		begTKey := storage.NewTKey(keyIndex, r.beg)
+		endTKey := storage.MaxTKey(keyIndex)
+		if r.end != nil {
+			endTKey = storage.NewTKey(keyIndex, r.end)
+		}
+		err = db.ProcessRange(ctx, begTKey, endTKey, nil, func(c *storage.Chunk) error {
+			if c == nil || c.TKeyValue == nil || c.V == nil {
+				return nil
+			}
+			if q.Limit > 0 && len(result.Keys) == q.Limit {
+				return errQueryLimit
+			}
+			_, keyStr, err := DecodeIndexTKey(c.K)
+			if err != nil {
+				return err
+			}
+			result.Keys = append(result.Keys, keyStr)
+			if q.Limit > 0 && len(result.Keys) == q.Limit {
+				classBytes, err := c.K.ClassBytes(keyIndex)
+				if err != nil {
+					return err
+				}
+				result.Cursor = hex.EncodeToString(classBytes)
+			}
+			return nil
+		})
+		if err == errQueryLimit {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if q.WithValues {
+		result.Values = make(map[string]json.RawMessage, len(result.Keys))
+		for _, keyStr := range result.Keys {
+			value, found, err := d.GetData(ctx, keyStr)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				result.Values[keyStr] = json.RawMessage(value)
+			}
+		}
+	}
+	return result, nil
+}
+
+// ParseFieldQuery returns a field index query from HTTP query strings.
+func ParseFieldQuery(field string, queryStrings map[string][]string) (q FieldQuery, err error) {
+	get := func(key string) string {
+		if vals := queryStrings[key]; len(vals) > 0 {
+			return vals[0]
+		}
+		return ""
+	}
+	q.Field = field
+	q.Values = queryStrings["value"]
+	for _, bound := range []struct {
+		name string
+		ptr  **float64
+	}{{"min", &q.Min}, {"max", &q.Max}} {
+		if s := get(bound.name); s != "" {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return q, fmt.Errorf("bad %s value %q: %v", bound.name, s, err)
+			}
+			*bound.ptr = &f
+		}
+	}
+	if len(q.Values) != 0 && (q.Min != nil || q.Max != nil) {
+		return q, fmt.Errorf("field query cannot have both values and a min/max range")
+	}
+	if s := get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil {
+			return q, fmt.Errorf("bad limit %q: %v", s, err)
+		}
+	}
+	q.Cursor = get("cursor")
+	q.WithValues = get("values") == "true"
+	return q, nil
+}
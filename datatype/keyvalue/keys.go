@@ -5,7 +5,11 @@
 package keyvalue
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"math"
+	"strconv"
 
 	"github.com/janelia-flyem/dvid/storage"
 )
@@ -16,13 +20,24 @@ const (
 
 	// the byte id for a standard key of a keyvalue
 	keyStandard = 177
+
+	// the byte id for a lock used in compare-and-swap of a keyvalue key
+	keyLock = 178
+
+	// the byte id for an index entry of a JSON field value
+	keyIndex = 179
 )
 
 // DescribeTKeyClass returns a string explanation of what a particular TKeyClass
 // is used for.  Implements the datastore.TKeyClassDescriber interface.
 func (d *Data) DescribeTKeyClass(tkc storage.TKeyClass) string {
-	if tkc == keyStandard {
+	switch tkc {
+	case keyStandard:
 		return "keyvalue generic key"
+	case keyLock:
+		return "keyvalue compare-and-swap lock key"
+	case keyIndex:
+		return "keyvalue JSON field index key"
 	}
 	return "unknown keyvalue key"
 }
@@ -47,3 +62,81 @@ func DecodeTKey(tk storage.TKey) (string, error) {
 	}
 	return string(ibytes[:sz]), nil
 }
+
+// NewIndexTKey returns the TKey for an index entry of a key's JSON field value.  Values
+// that parse as numbers are encoded so that keys sort by numeric value.
+func NewIndexTKey(field, value, key string) storage.TKey {
+	buf := indexValuePrefix(field, value)
+	buf = append(buf, []byte(key)...)
+	return storage.NewTKey(keyIndex, append(buf, 0))
+}
+
+// DecodeIndexTKey returns the field name and key from an index TKey.
+func DecodeIndexTKey(tk storage.TKey) (field, key string, err error) {
+	ibytes, err := tk.ClassBytes(keyIndex)
+	if err != nil {
+		return
+	}
+	n := bytes.IndexByte(ibytes, 0)
+	if n < 0 || len(ibytes) < n+3 {
+		err = fmt.Errorf("bad keyvalue index key of %d bytes", len(ibytes))
+		return
+	}
+	field = string(ibytes[:n])
+	rest := ibytes[n+1:]
+	switch rest[0] {
+	case indexNumber:
+		if len(rest) < 10 {
+			err = fmt.Errorf("bad numeric keyvalue index key for field %q", field)
+			return
+		}
+		rest = rest[9:]
+	case indexString:
+		end := bytes.IndexByte(rest, 0)
+		if end < 0 {
+			err = fmt.Errorf("bad string keyvalue index key for field %q", field)
+			return
+		}
+		rest = rest[end+1:]
+	default:
+		err = fmt.Errorf("bad value type %d in keyvalue index key for field %q", rest[0], field)
+		return
+	}
+	if len(rest) < 2 || rest[len(rest)-1] != 0 {
+		err = fmt.Errorf("bad key in keyvalue index key for field %q", field)
+		return
+	}
+	key = string(rest[:len(rest)-1])
+	return
+}
+
+const (
+	indexNumber byte = 1
+	indexString byte = 2
+)
+
+// indexValuePrefix returns the index key bytes preceding the key for a field value.
+// Values that parse as numbers sort numerically before all string values.
+func indexValuePrefix(field, value string) []byte {
+	buf := append([]byte(field), 0)
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return append(buf, encodeIndexNumber(f)...)
+	}
+	buf = append(buf, indexString)
+	buf = append(buf, []byte(value)...)
+	return append(buf, 0)
+}
+
+// encodeIndexNumber returns an order-preserving encoding of a float64.
+func encodeIndexNumber(f float64) []byte {
+	bits := math.Float64bits(f)
+	if f < 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	buf := make([]byte, 9)
+	buf[0] = indexNumber
+	binary.BigEndian.PutUint64(buf[1:], bits)
+	return buf
+}
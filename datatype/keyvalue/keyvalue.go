@@ -8,9 +8,12 @@ import (
 	"encoding/gob"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/janelia-flyem/dvid/datastore"
 	"github.com/janelia-flyem/dvid/dvid"
@@ -51,11 +54,34 @@ $ dvid repo <UUID> new keyvalue <data name> <settings...>
 				   not differentiate between versions in the same repo.  Note that unlike
 				   versioned data, distribution (push/pull) of unversioned data is not defined 
 				   at this time.
+	JSON           Set to "true" if values must be valid JSON.  Writes of other values are rejected.
+	IndexedFields  Comma-separated list of JSON field paths to index, e.g., "status,review.user",
+	               where nested fields are separated by periods.  Requires JSON mode.  Can be
+	               changed by a PUT of the configuration to the data instance followed by a POST
+	               to the "reindex" endpoint to index existing values.
 
 $ dvid -stdin node <UUID> <data name> put <key> < data
 
 	Puts stdin data into the keyvalue data instance under the given key.
 
+$ dvid node <UUID> <data name> export <path> <settings...>
+
+	Asynchronously exports keys of the given version to a tar file on the server, where each
+	key is a file named by the key.  The tar file is gzip compressed if the path ends in ".gz"
+	or ".tgz".  See the "tarfile" HTTP endpoint below.
+
+	Example:
+
+	$ dvid node 3f8c stuff export /path/to/meshes.tar.gz prefix=meshes/
+
+	Arguments:
+
+	UUID           Hexidecimal string with enough characters to uniquely identify a version node.
+	data name      Name of keyvalue data to export.
+	path           Output tar file path on the server.
+	settings       Optional "prefix", "start", and "end" settings that restrict the exported keys
+	               as in the "tarfile" HTTP endpoint.
+
 	
 	------------------
 
@@ -105,6 +131,46 @@ GET  <api URL>/node/<UUID>/<data name>/keyrange/<key1>/<key2>
 	key1          Lexicographically lowest alphanumeric key in range.
 	key2          Lexicographically highest alphanumeric key in range.
 
+GET  <api URL>/node/<UUID>/<data name>/listkeys?<options>
+
+	Returns keys in lexicographic order, optionally restricted to a prefix, one page at a time.
+	By default, the response is JSON:
+
+	{
+		"Keys": [
+			{ "Key": "meshes/101.ngmesh", "Size": 23413, "Modified": "2018-04-05T14:31:02-04:00" },
+			...
+		],
+		"NextCursor": "meshes/2345.ngmesh"
+	}
+
+	"NextCursor" is only present if there are more keys and should be passed as the "cursor"
+	of the next request.  "Size" is the number of bytes in the value and "Modified" its last
+	modification time, which are only returned if "meta=true".  "Modified" is only available
+	for stores that track modification times, e.g., filestore.
+
+	If "format=jsonl", the keys are streamed as JSON lines with one key object per line and no
+	limit unless one is given, which is suitable for full scans of large instances.  The last
+	key streamed can be used as a cursor to resume an interrupted scan.
+
+	Example: 
+
+	GET <api URL>/node/3f8c/stuff/listkeys?prefix=meshes/&limit=100&meta=true
+
+	Arguments:
+
+	UUID          Hexidecimal string with enough characters to uniquely identify a version node.
+	data name     Name of keyvalue data instance.
+
+	Query-string Options:
+
+	prefix        Only list keys with this prefix.
+	cursor        Only list keys lexicographically after this key.
+	limit         Maximum number of keys to return.  Default is %d for JSON and no limit for
+	                JSON lines.
+	meta          If "true", return the size and modification time of each key's value.
+	format        "json" (default) or "jsonl" for streaming JSON lines.
+
 GET  <api URL>/node/<UUID>/<data name>/key/<key>
 POST <api URL>/node/<UUID>/<data name>/key/<key>
 DEL  <api URL>/node/<UUID>/<data name>/key/<key> 
@@ -121,6 +187,15 @@ DEL  <api URL>/node/<UUID>/<data name>/key/<key>
 	The "Content-type" of the HTTP response (and usually the request) are
 	"application/octet-stream" for arbitrary binary data.
 
+	GET responses include an "ETag" header with a hash of the value's content, and POST
+	responses include the "ETag" of the new value.  POST and DELETE honor the "If-Match" and
+	"If-None-Match" headers, which make the request conditional on the current "ETag" of the
+	key, or on the key existing if "*" is given.  The check and the write are performed as an
+	atomic compare-and-swap, and a 412 (Precondition Failed) status is returned if the
+	condition isn't met.  For example, to safely update a JSON document, GET it and then
+	POST the modified document with "If-Match" set to the ETag from the GET, retrying on 412.
+	To only create a key if it doesn't exist, POST with "If-None-Match: *".
+
 	Arguments:
 
 	UUID          Hexidecimal string with enough characters to uniquely identify a version node.
@@ -135,6 +210,118 @@ DEL  <api URL>/node/<UUID>/<data name>/key/<key>
 		"UUID": <UUID on which POST was done>
 	}
 
+GET  <api URL>/node/<UUID>/<data name>/key/<key>/history?<options>
+
+	Returns JSON describing each version node, from the root to the given version, where the
+	value of the key changed from the node's parent, including deletions:
+
+	[
+		{
+			"UUID": "3f8c...",
+			"Note": "initial import",
+			"Locked": true,
+			"NodeUpdated": "2018-04-05T14:31:02-04:00",
+			"Deleted": false,
+			"Size": 1024,
+			"ETag": "\"9e107d9d372bb6826bd81d3542a419d6\"",
+			"Modified": "2018-04-05T14:20:10-04:00"
+		},
+		...
+	]
+
+	"NodeUpdated" is the time of the node's last commit, note, or log change, and "Modified"
+	is the modification time of the value, which is only available for stores that track it,
+	e.g., filestore.  A deleted key has "Deleted" true and no "ETag".  The value at any listed
+	version can be retrieved via the "key" endpoint with the version's UUID, or included in the
+	history as base64-encoded "Value" using the "values" option.  History is not available for
+	unversioned instances.
+
+	Query-string Options:
+
+	descendants   If "true", changes in all versions descended from the given version are
+	                appended in breadth-first order, each compared with the version it was
+	                reached from.
+	values        If "true", include the value at each version.
+
+GET  <api URL>/node/<UUID>/<data name>/tarfile?<options>
+GET  <api URL>/node/<UUID>/<data name>/tarfile/<key1>/<key2>?<options>
+
+	Returns a tar file where each key is a file named by the key with the key's value as
+	content.  If "key1" and "key2" are given, only keys between them, inclusive, are included.
+	The tar file is streamed, so an error after streaming has started results in a truncated
+	tar file.
+
+	Example: 
+
+	GET <api URL>/node/3f8c/stuff/tarfile?prefix=meshes/&gzip=true
+
+	The "Content-type" of the HTTP response is "application/tar" or, if compressed,
+	"application/gzip".
+
+	Arguments:
+
+	UUID          Hexidecimal string with enough characters to uniquely identify a version node.
+	data name     Name of keyvalue data instance.
+	key1          Lexicographically lowest alphanumeric key in range.
+	key2          Lexicographically highest alphanumeric key in range.
+
+	Query-string Options:
+
+	prefix        Only include keys with this prefix.
+	gzip          If "true", the tar file is gzip compressed.
+
+POST <api URL>/node/<UUID>/<data name>/load
+
+	Allows bulk-loading of a tar file, optionally gzip compressed, where each file is stored
+	under a key given by its file name.  Directory and other non-regular entries are ignored.
+	This is the inverse of the "tarfile" endpoint.
+
+	Arguments:
+
+	UUID          Hexidecimal string with enough characters to uniquely identify a version node.
+	data name     Name of keyvalue data instance.
+
+	POSTs will be logged as a series of Kafka JSON messages, each with the format equivalent
+	to the single POST /key.
+
+GET <api URL>/node/<UUID>/<data name>/query/<field>?<options>
+
+	Returns keys whose JSON value matches the query, using the index of a field in the
+	IndexedFields configuration.  Field values that parse as numbers are indexed numerically
+	so range queries can be used.  String, number, and boolean values are indexed, and each
+	value within an array is indexed separately.  Keys are sorted by field value and then key:
+
+	{
+		"Keys": ["key1", "key2", ...],
+		"Values": {"key1": <JSON value>, ...},
+		"Cursor": "<opaque cursor for next page>"
+	}
+
+	The "Values" are only returned if requested, and "Cursor" is only returned if a limit was
+	given and reached.
+
+	GET Query-string Options:
+
+	value   Return keys with exactly this field value.  Can be repeated to return keys
+	        matching any of the values, e.g., "value=open&value=review".
+	min     Return keys with numeric field value >= min.
+	max     Return keys with numeric field value < max.
+	limit   Maximum number of keys to return.
+	cursor  Cursor from a previous query's response to get the next page of keys.
+	values  If "true", also return the JSON value of each key.
+
+	Example:
+
+	GET http://foo.com/api/node/83af/stuff/query/review.user?value=alice&limit=1000
+
+POST <api URL>/node/<UUID>/<data name>/reindex
+
+	Asynchronously rebuilds the index of the JSON fields in the IndexedFields configuration for
+	all keys of the given version.  The index is deleted and rebuilt in batches of 1000 keys,
+	and writes and deletes of keys through any endpoint, which update the index, are blocked
+	only while each batch is written.  Reads and field queries are not blocked, but queries may return
+	incomplete results until the reindex finishes, which is noted in the server log.
+
 POST <api URL>/node/<UUID>/<data name>/keyvalues
 
 	Allows bulk-loading of keyvalue data using a protobuf-encoded payload.  
@@ -201,11 +388,15 @@ func (dtype *Type) NewDataService(uuid dvid.UUID, id dvid.InstanceID, name dvid.
 	if err != nil {
 		return nil, err
 	}
-	return &Data{basedata}, nil
+	data := &Data{Data: basedata}
+	if err := data.Properties.setByConfig(c); err != nil {
+		return nil, err
+	}
+	return data, nil
 }
 
 func (dtype *Type) Help() string {
-	return fmt.Sprintf(helpMessage)
+	return fmt.Sprintf(helpMessage, DefaultListLimit)
 }
 
 // GetByUUIDName returns a pointer to labelblk data given a UUID and data name.
@@ -221,25 +412,40 @@ func GetByUUIDName(uuid dvid.UUID, name dvid.InstanceName) (*Data, error) {
 	return data, nil
 }
 
-// Data embeds the datastore's Data and extends it with keyvalue properties (none for now).
+// Data embeds the datastore's Data and extends it with keyvalue properties.
 type Data struct {
 	*datastore.Data
+	Properties
+	datastore.Updater
+
+	// casMu serializes compare-and-swap operations for stores without transactions.
+	casMu sync.Mutex
+
+	// indexMu serializes writes that modify the JSON field index.
+	indexMu sync.Mutex
+
+	// propMu guards the Properties against concurrent configuration changes.
+	propMu sync.RWMutex
 }
 
 func (d *Data) Equals(d2 *Data) bool {
 	if !d.Data.Equals(d2.Data) {
 		return false
 	}
+	p, p2 := d.properties(), d2.properties()
+	if p.JSON != p2.JSON || strings.Join(p.IndexedFields, ",") != strings.Join(p2.IndexedFields, ",") {
+		return false
+	}
 	return true
 }
 
 func (d *Data) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
 		Base     *datastore.Data
-		Extended struct{}
+		Extended Properties
 	}{
 		d.Data,
-		struct{}{},
+		d.properties(),
 	})
 }
 
@@ -249,6 +455,10 @@ func (d *Data) GobDecode(b []byte) error {
 	if err := dec.Decode(&(d.Data)); err != nil {
 		return err
 	}
+	// Metadata stored before JSON mode has no properties.
+	if err := dec.Decode(&(d.Properties)); err != nil && err != io.EOF {
+		return err
+	}
 	return nil
 }
 
@@ -258,6 +468,9 @@ func (d *Data) GobEncode() ([]byte, error) {
 	if err := enc.Encode(d.Data); err != nil {
 		return nil, err
 	}
+	if err := enc.Encode(d.properties()); err != nil {
+		return nil, err
+	}
 	return buf.Bytes(), nil
 }
 
@@ -341,8 +554,17 @@ func (d *Data) GetData(ctx storage.Context, keyStr string) ([]byte, bool, error)
 	return value, true, nil
 }
 
-// PutData puts a key-value at a given uuid
+// PutData puts a key-value at a given uuid.  In JSON mode, the value must be valid JSON.
 func (d *Data) PutData(ctx storage.Context, keyStr string, value []byte) error {
+	props := d.properties()
+	if len(props.IndexedFields) != 0 {
+		return d.putIndexed(ctx, props.IndexedFields, keyStr, value, false)
+	}
+	if props.JSON {
+		if _, err := decodeJSON(value); err != nil {
+			return fmt.Errorf("value for key %q is not valid JSON: %v", keyStr, err)
+		}
+	}
 	db, err := datastore.GetOrderedKeyValueDB(d)
 	if err != nil {
 		return err
@@ -360,6 +582,9 @@ func (d *Data) PutData(ctx storage.Context, keyStr string, value []byte) error {
 
 // DeleteData deletes a key-value pair
 func (d *Data) DeleteData(ctx storage.Context, keyStr string) error {
+	if fields := d.properties().IndexedFields; len(fields) != 0 {
+		return d.putIndexed(ctx, fields, keyStr, nil, true)
+	}
 	db, err := datastore.GetOrderedKeyValueDB(d)
 	if err != nil {
 		return err
@@ -417,7 +642,7 @@ func (d *Data) JSONString() (jsonStr string, err error) {
 // --- DataService interface ---
 
 func (d *Data) Help() string {
-	return fmt.Sprintf(helpMessage)
+	return fmt.Sprintf(helpMessage, DefaultListLimit)
 }
 
 // DoRPC acts as a switchboard for RPC commands.
@@ -425,6 +650,8 @@ func (d *Data) DoRPC(request datastore.Request, reply *datastore.Response) error
 	switch request.TypeCommand() {
 	case "put":
 		return d.put(request, reply)
+	case "export":
+		return d.exportTar(request, reply)
 	default:
 		return fmt.Errorf("Unknown command.  Data '%s' [%s] does not support '%s' command.",
 			d.DataName(), d.TypeName(), request.TypeCommand())
@@ -442,14 +669,33 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 		parts = parts[:len(parts)-1]
 	}
 
+	var comment string
+	action := strings.ToLower(r.Method)
+
+	// Handle PUT on data -> setting of configuration
+	if len(parts) == 3 && action == "put" {
+		config, err := server.DecodeJSON(r)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		if err := d.ModifyConfig(config); err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		if err := datastore.SaveDataByUUID(uuid, d); err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		fmt.Fprintf(w, "Changed %q based on received configuration:\n%s\n", d.DataName(), config)
+		return
+	}
+
 	if len(parts) < 4 {
 		server.BadRequest(w, r, "incomplete API specification")
 		return
 	}
 
-	var comment string
-	action := strings.ToLower(r.Method)
-
 	switch parts[3] {
 	case "help":
 		w.Header().Set("Content-Type", "text/plain")
@@ -504,6 +750,161 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 		fmt.Fprintf(w, string(jsonBytes))
 		comment = fmt.Sprintf("HTTP GET keyrange [%q, %q]", keyBeg, keyEnd)
 
+	case "listkeys":
+		if action != "get" {
+			server.BadRequest(w, r, "only GET action is supported for the 'listkeys' endpoint")
+			return
+		}
+		queryStrings := r.URL.Query()
+		opts := ListOptions{
+			Prefix: queryStrings.Get("prefix"),
+			Cursor: queryStrings.Get("cursor"),
+			Meta:   queryStrings.Get("meta") == "true",
+		}
+		format := queryStrings.Get("format")
+		if format != "" && format != "json" && format != "jsonl" {
+			server.BadRequest(w, r, "unknown format %q for 'listkeys', expected 'json' or 'jsonl'", format)
+			return
+		}
+		if limitStr := queryStrings.Get("limit"); limitStr != "" {
+			limit, err := strconv.Atoi(limitStr)
+			if err != nil || limit < 0 {
+				server.BadRequest(w, r, "bad limit %q for 'listkeys'", limitStr)
+				return
+			}
+			opts.Limit = limit
+		} else if format != "jsonl" {
+			opts.Limit = DefaultListLimit
+		}
+		if format == "jsonl" {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			enc := json.NewEncoder(w)
+			var numKeys int
+			_, err := d.ListKeys(ctx, opts, func(info KeyInfo) error {
+				numKeys++
+				return enc.Encode(info)
+			})
+			if err != nil {
+				if numKeys == 0 {
+					server.BadRequest(w, r, err)
+				} else {
+					dvid.Errorf("Error streaming keys for keyvalue %q after %d keys: %v\n", d.DataName(), numKeys, err)
+				}
+				return
+			}
+			comment = fmt.Sprintf("HTTP GET listkeys streamed %d keys with prefix %q", numKeys, opts.Prefix)
+			break
+		}
+		list, err := d.GetKeyList(ctx, opts)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		jsonBytes, err := json.Marshal(list)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(jsonBytes); err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		comment = fmt.Sprintf("HTTP GET listkeys returned %d keys with prefix %q", len(list.Keys), opts.Prefix)
+
+	case "tarfile":
+		if action != "get" {
+			server.BadRequest(w, r, "only GET action is supported for the 'tarfile' endpoint")
+			return
+		}
+		queryStrings := r.URL.Query()
+		opts := TarOptions{
+			Prefix: queryStrings.Get("prefix"),
+			Gzip:   queryStrings.Get("gzip") == "true",
+		}
+		if len(parts) >= 6 {
+			opts.StartKey, opts.EndKey = parts[4], parts[5]
+		} else if len(parts) == 5 {
+			server.BadRequest(w, r, "expect beginning and end keys to follow 'tarfile' endpoint")
+			return
+		}
+		if opts.Gzip {
+			w.Header().Set("Content-type", "application/gzip")
+		} else {
+			w.Header().Set("Content-type", "application/tar")
+		}
+		numKeys, err := d.WriteTar(ctx, w, opts)
+		if err != nil {
+			if numKeys == 0 {
+				server.BadRequest(w, r, err)
+			} else {
+				dvid.Errorf("Error writing tarfile for keyvalue %q after %d keys: %v\n", d.DataName(), numKeys, err)
+			}
+			return
+		}
+		comment = fmt.Sprintf("HTTP GET tarfile of %d keys from keyvalue %q", numKeys, d.DataName())
+
+	case "load":
+		if action != "post" {
+			server.BadRequest(w, r, "only POST action is supported for the 'load' endpoint")
+			return
+		}
+		numKeys, err := d.LoadTar(ctx, r.Body)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		comment = fmt.Sprintf("HTTP POST load of %d keys into keyvalue %q", numKeys, d.DataName())
+
+	case "query":
+		if action != "get" {
+			server.BadRequest(w, r, "only GET action is supported for the 'query' endpoint")
+			return
+		}
+		if len(parts) < 5 {
+			server.BadRequest(w, r, "expect field to follow 'query' endpoint")
+			return
+		}
+		q, err := ParseFieldQuery(parts[4], r.URL.Query())
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		result, err := d.QueryFields(ctx, q)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		jsonBytes, err := json.Marshal(result)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(jsonBytes); err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		comment = fmt.Sprintf("HTTP GET query of field %q returned %d keys", q.Field, len(result.Keys))
+
+	case "reindex":
+		if action != "post" {
+			server.BadRequest(w, r, "only POST action is supported for the 'reindex' endpoint")
+			return
+		}
+		d.StartUpdate()
+		go func() {
+			defer d.StopUpdate()
+			timedLog := dvid.NewTimeLog()
+			numKeys, err := d.Reindex(ctx)
+			if err != nil {
+				dvid.Errorf("unable to reindex keyvalue %q, uuid %s: %v\n", d.DataName(), uuid, err)
+				return
+			}
+			timedLog.Infof("Finished reindex of %d keys in keyvalue %q, uuid %s", numKeys, d.DataName(), uuid)
+		}()
+		comment = fmt.Sprintf("HTTP POST started asynchronous reindex of keyvalue %q", d.DataName())
+
 	case "keyvalues":
 		if action != "post" {
 			server.BadRequest(w, r, "only POST action is supported for the 'keyvalues' endpoint")
@@ -521,6 +922,35 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 			return
 		}
 		keyStr := parts[4]
+		if len(parts) > 5 {
+			if parts[5] != "history" || action != "get" {
+				server.BadRequest(w, r, "only GET of 'history' is supported after key %q", keyStr)
+				return
+			}
+			queryStrings := r.URL.Query()
+			descendants := queryStrings.Get("descendants") == "true"
+			history, err := d.GetKeyHistory(ctx.VersionID(), keyStr, descendants, queryStrings.Get("values") == "true")
+			if err != nil {
+				server.BadRequest(w, r, err)
+				return
+			}
+			jsonBytes, err := json.Marshal(history)
+			if err != nil {
+				server.BadRequest(w, r, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			if _, err := w.Write(jsonBytes); err != nil {
+				server.BadRequest(w, r, err)
+				return
+			}
+			comment = fmt.Sprintf("HTTP GET history of key %q of keyvalue %q: %d versions", keyStr, d.DataName(), len(history))
+			break
+		}
+		cond := Precondition{
+			IfMatch:     r.Header.Get("If-Match"),
+			IfNoneMatch: r.Header.Get("If-None-Match"),
+		}
 
 		switch action {
 		case "get":
@@ -534,18 +964,28 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 				http.Error(w, fmt.Sprintf("Key %q not found", keyStr), http.StatusNotFound)
 				return
 			}
+			w.Header().Set("ETag", ETag(value))
 			if value != nil || len(value) > 0 {
+				w.Header().Set("Content-Type", "application/octet-stream")
 				_, err = w.Write(value)
 				if err != nil {
 					server.BadRequest(w, r, err)
 					return
 				}
-				w.Header().Set("Content-Type", "application/octet-stream")
 			}
 			comment = fmt.Sprintf("HTTP GET key %q of keyvalue %q: %d bytes (%s)\n", keyStr, d.DataName(), len(value), url)
 
 		case "delete":
-			if err := d.DeleteData(ctx, keyStr); err != nil {
+			if cond.IsSet() {
+				if _, err := d.CompareAndSwap(ctx, keyStr, cond, nil, true); err != nil {
+					if err == ErrPreconditionFailed {
+						http.Error(w, fmt.Sprintf("Key %q does not satisfy the request's conditions", keyStr), http.StatusPreconditionFailed)
+					} else {
+						server.BadRequest(w, r, err)
+					}
+					return
+				}
+			} else if err := d.DeleteData(ctx, keyStr); err != nil {
 				server.BadRequest(w, r, err)
 				return
 			}
@@ -558,6 +998,21 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 				return
 			}
 
+			if cond.IsSet() {
+				if _, err := d.CompareAndSwap(ctx, keyStr, cond, data, false); err != nil {
+					if err == ErrPreconditionFailed {
+						http.Error(w, fmt.Sprintf("Key %q does not satisfy the request's conditions", keyStr), http.StatusPreconditionFailed)
+					} else {
+						server.BadRequest(w, r, err)
+					}
+					return
+				}
+			} else if err := d.PutData(ctx, keyStr, data); err != nil {
+				server.BadRequest(w, r, err)
+				return
+			}
+			w.Header().Set("ETag", ETag(data))
+
 			go func() {
 				msginfo := map[string]interface{}{
 					"Action": "postkv",
@@ -566,16 +1021,10 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 					"UUID":   string(uuid),
 				}
 				jsonmsg, _ := json.Marshal(msginfo)
-				if err = d.ProduceKafkaMsg(jsonmsg); err != nil {
+				if err := d.ProduceKafkaMsg(jsonmsg); err != nil {
 					dvid.Errorf("Error on sending keyvalue POST op to kafka: %v\n", err)
 				}
 			}()
-
-			err = d.PutData(ctx, keyStr, data)
-			if err != nil {
-				server.BadRequest(w, r, err)
-				return
-			}
 			comment = fmt.Sprintf("HTTP POST keyvalue '%s': %d bytes (%s)\n", d.DataName(), len(data), url)
 		default:
 			server.BadRequest(w, r, "key endpoint does not support %q HTTP verb", action)
@@ -605,16 +1054,7 @@ func (d *Data) handleIngest(r *http.Request, uuid dvid.UUID, ctx *datastore.Vers
 			return err
 		}
 
-		msginfo := map[string]interface{}{
-			"Action": "postkv",
-			"Key":    kv.Key,
-			"Bytes":  len(kv.Value),
-			"UUID":   string(uuid),
-		}
-		jsonmsg, _ := json.Marshal(msginfo)
-		if err = d.ProduceKafkaMsg(jsonmsg); err != nil {
-			dvid.Errorf("Error on sending keyvalue POST op to kafka: %v\n", err)
-		}
+		d.logPost(uuid, kv.Key, len(kv.Value))
 	}
 	return nil
 }
@@ -1,10 +1,17 @@
 package keyvalue
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -757,3 +764,539 @@ func TestDiamondGetOnMerge(t *testing.T) {
 		t.Errorf("Error on merged child, key %q: expected %q, got %q\n", key1, value1, string(returnValue))
 	}
 }
+
+func TestKeyvalueListKeys(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+	config := dvid.NewConfig()
+	if _, err := datastore.NewData(uuid, kvtype, "listing", config); err != nil {
+		t.Fatalf("Error creating new keyvalue instance: %v\n", err)
+	}
+	keys := []string{"a1", "meshes-1", "meshes-2", "meshes-3", "meshes-30", "skeletons-1", "z"}
+	for i, key := range keys {
+		keyreq := fmt.Sprintf("%snode/%s/listing/key/%s", server.WebAPIPath, uuid, key)
+		server.TestHTTP(t, "POST", keyreq, strings.NewReader(strings.Repeat("x", i+1)))
+	}
+
+	getList := func(query string) KeyList {
+		req := fmt.Sprintf("%snode/%s/listing/listkeys?%s", server.WebAPIPath, uuid, query)
+		var list KeyList
+		if err := json.Unmarshal(server.TestHTTP(t, "GET", req, nil), &list); err != nil {
+			t.Fatalf("couldn't unmarshal key list for %q: %v\n", query, err)
+		}
+		return list
+	}
+	keysOf := func(list KeyList) string {
+		var names []string
+		for _, info := range list.Keys {
+			names = append(names, info.Key)
+		}
+		return strings.Join(names, ",")
+	}
+
+	list := getList("")
+	if keysOf(list) != strings.Join(keys, ",") || list.NextCursor != "" {
+		t.Errorf("bad list of all keys: %v\n", list)
+	}
+	if list.Keys[0].Size != nil || list.Keys[0].Modified != "" {
+		t.Errorf("expected no metadata without meta option: %v\n", list.Keys[0])
+	}
+
+	// Page through keys with a prefix.
+	list = getList("prefix=meshes-&limit=2&meta=true")
+	if keysOf(list) != "meshes-1,meshes-2" || list.NextCursor != "meshes-2" {
+		t.Errorf("bad first page of prefix listing: %v\n", list)
+	}
+	if list.Keys[1].Size == nil || *list.Keys[1].Size != 3 {
+		t.Errorf("bad size metadata for key %q: %v\n", list.Keys[1].Key, list.Keys[1].Size)
+	}
+	list = getList("prefix=meshes-&limit=2&cursor=" + list.NextCursor)
+	if keysOf(list) != "meshes-3,meshes-30" || list.NextCursor != "" {
+		t.Errorf("bad second page of prefix listing: %v\n", list)
+	}
+	if list = getList("prefix=meshes-&limit=2&cursor=meshes-30"); len(list.Keys) != 0 || list.NextCursor != "" {
+		t.Errorf("expected empty page after last key, got %v\n", list)
+	}
+	if list = getList("prefix=nothing"); len(list.Keys) != 0 {
+		t.Errorf("expected no keys for unmatched prefix, got %v\n", list)
+	}
+
+	// Stream keys as JSON lines.
+	req := fmt.Sprintf("%snode/%s/listing/listkeys?format=jsonl&cursor=meshes-3", server.WebAPIPath, uuid)
+	lines := strings.Split(strings.TrimSpace(string(server.TestHTTP(t, "GET", req, nil))), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 JSON lines, got %d: %v\n", len(lines), lines)
+	}
+	for i, line := range lines {
+		var info KeyInfo
+		if err := json.Unmarshal([]byte(line), &info); err != nil {
+			t.Fatalf("bad JSON line %q: %v\n", line, err)
+		}
+		if info.Key != keys[i+4] {
+			t.Errorf("expected key %q in JSON line %d, got %q\n", keys[i+4], i, info.Key)
+		}
+	}
+
+	server.TestBadHTTP(t, "GET", fmt.Sprintf("%snode/%s/listing/listkeys?limit=-1", server.WebAPIPath, uuid), nil)
+	server.TestBadHTTP(t, "GET", fmt.Sprintf("%snode/%s/listing/listkeys?format=xml", server.WebAPIPath, uuid), nil)
+}
+
+func conditionalRequest(t *testing.T, method, urlStr, payload, header, etag string) *httptest.ResponseRecorder {
+	var body io.Reader
+	if payload != "" {
+		body = strings.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, urlStr, body)
+	if err != nil {
+		t.Fatalf("Unsuccessful %s on %q: %v\n", method, urlStr, err)
+	}
+	if header != "" {
+		req.Header.Set(header, etag)
+	}
+	resp := httptest.NewRecorder()
+	server.ServeSingleHTTP(resp, req)
+	return resp
+}
+
+func TestKeyvalueConditionalWrites(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, versionID := initTestRepo()
+	config := dvid.NewConfig()
+	if _, err := datastore.NewData(uuid, kvtype, "docs", config); err != nil {
+		t.Fatalf("Error creating new keyvalue instance: %v\n", err)
+	}
+	keyreq := fmt.Sprintf("%snode/%s/docs/key/doc1", server.WebAPIPath, uuid)
+
+	// Create only if missing.
+	resp := conditionalRequest(t, "POST", keyreq, `{"version":1}`, "If-None-Match", "*")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected create with If-None-Match: * to succeed, got %d\n", resp.Code)
+	}
+	etag1 := resp.Header().Get("ETag")
+	if etag1 != ETag([]byte(`{"version":1}`)) {
+		t.Errorf("bad ETag on POST: %q\n", etag1)
+	}
+	if resp = conditionalRequest(t, "POST", keyreq, `{"version":0}`, "If-None-Match", "*"); resp.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 on create of existing key, got %d\n", resp.Code)
+	}
+	resp = conditionalRequest(t, "GET", keyreq, "", "", "")
+	if resp.Header().Get("ETag") != etag1 || resp.Body.String() != `{"version":1}` {
+		t.Errorf("bad GET after conditional create: ETag %q, body %q\n", resp.Header().Get("ETag"), resp.Body.String())
+	}
+
+	// Update only if unchanged.
+	resp = conditionalRequest(t, "POST", keyreq, `{"version":2}`, "If-Match", etag1)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected update with matching If-Match to succeed, got %d\n", resp.Code)
+	}
+	etag2 := resp.Header().Get("ETag")
+	if resp = conditionalRequest(t, "POST", keyreq, `{"version":3}`, "If-Match", etag1); resp.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 on update with stale ETag, got %d\n", resp.Code)
+	}
+	if value := server.TestHTTP(t, "GET", keyreq, nil); string(value) != `{"version":2}` {
+		t.Errorf("stale update modified value: %s\n", string(value))
+	}
+	if resp = conditionalRequest(t, "POST", keyreq, `{"version":3}`, "If-None-Match", etag2); resp.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 on If-None-Match with current ETag, got %d\n", resp.Code)
+	}
+	missingreq := fmt.Sprintf("%snode/%s/docs/key/missing", server.WebAPIPath, uuid)
+	if resp = conditionalRequest(t, "POST", missingreq, `{}`, "If-Match", "*"); resp.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 on If-Match: * for missing key, got %d\n", resp.Code)
+	}
+
+	// Conditional deletes.
+	if resp = conditionalRequest(t, "DELETE", keyreq, "", "If-Match", etag1); resp.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 on delete with stale ETag, got %d\n", resp.Code)
+	}
+	if resp = conditionalRequest(t, "DELETE", keyreq, "", "If-Match", `"abc", `+etag2); resp.Code != http.StatusOK {
+		t.Errorf("expected delete with matching ETag in list to succeed, got %d\n", resp.Code)
+	}
+	server.TestBadHTTP(t, "GET", keyreq, nil)
+
+	// Concurrent read-modify-write increments using compare-and-swap must not be lost.
+	d, err := GetByUUIDName(uuid, "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := datastore.NewVersionedCtx(d, versionID)
+	if _, err := d.CompareAndSwap(ctx, "counter", Precondition{IfNoneMatch: "*"}, []byte("0"), false); err != nil {
+		t.Fatal(err)
+	}
+	const numIncrements = 20
+	var wg sync.WaitGroup
+	for i := 0; i < numIncrements; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				value, _, err := d.GetData(ctx, "counter")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				n, err := strconv.Atoi(string(value))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				newValue := []byte(strconv.Itoa(n + 1))
+				_, err = d.CompareAndSwap(ctx, "counter", Precondition{IfMatch: ETag(value)}, newValue, false)
+				if err == nil {
+					return
+				}
+				if err != ErrPreconditionFailed {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if value, _, err := d.GetData(ctx, "counter"); err != nil || string(value) != strconv.Itoa(numIncrements) {
+		t.Errorf("expected counter %d after concurrent compare-and-swaps, got %q: %v\n", numIncrements, string(value), err)
+	}
+}
+
+func TestKeyvalueKeyHistory(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid1, _ := initTestRepo()
+	config := dvid.NewConfig()
+	if _, err := datastore.NewData(uuid1, kvtype, "history", config); err != nil {
+		t.Fatalf("Error creating new keyvalue instance: %v\n", err)
+	}
+	keyURL := func(uuid dvid.UUID) string {
+		return fmt.Sprintf("%snode/%s/history/key/doc", server.WebAPIPath, uuid)
+	}
+	newVersion := func(parent dvid.UUID, note, branch string) dvid.UUID {
+		if err := datastore.Commit(parent, "commit of "+string(parent), nil); err != nil {
+			if locked, _ := datastore.LockedUUID(parent); !locked {
+				t.Fatalf("Unable to commit node %s: %v\n", parent, err)
+			}
+		}
+		child, err := datastore.NewVersion(parent, note, branch, nil)
+		if err != nil {
+			t.Fatalf("Unable to create new version off node %s: %v\n", parent, err)
+		}
+		return child
+	}
+	getHistory := func(uuid dvid.UUID, query string) []KeyVersion {
+		var history []KeyVersion
+		resp := server.TestHTTP(t, "GET", keyURL(uuid)+"/history?"+query, nil)
+		if err := json.Unmarshal(resp, &history); err != nil {
+			t.Fatalf("couldn't unmarshal key history %s: %v\n", string(resp), err)
+		}
+		return history
+	}
+
+	// root: v1, uuid2: v2, uuid3: deleted, uuid4: unchanged, uuid5 (branch off uuid2): v5
+	server.TestHTTP(t, "POST", keyURL(uuid1), strings.NewReader("v1"))
+	uuid2 := newVersion(uuid1, "second", "")
+	server.TestHTTP(t, "POST", keyURL(uuid2), strings.NewReader("v2"))
+	uuid3 := newVersion(uuid2, "third", "")
+	server.TestHTTP(t, "DELETE", keyURL(uuid3), nil)
+	uuid4 := newVersion(uuid3, "fourth", "")
+	uuid5 := newVersion(uuid2, "fifth", "alt")
+	server.TestHTTP(t, "POST", keyURL(uuid5), strings.NewReader("v5"))
+
+	history := getHistory(uuid4, "")
+	if len(history) != 3 {
+		t.Fatalf("expected 3 changes in key history, got %v\n", history)
+	}
+	if history[0].UUID != uuid1 || history[0].Deleted || history[0].Size != 2 || history[0].ETag != ETag([]byte("v1")) || !history[0].Locked {
+		t.Errorf("bad root key history: %v\n", history[0])
+	}
+	if history[1].UUID != uuid2 || history[1].Note != "commit of "+string(uuid2) || history[1].ETag != ETag([]byte("v2")) {
+		t.Errorf("bad second key history: %v\n", history[1])
+	}
+	if history[2].UUID != uuid3 || !history[2].Deleted || history[2].ETag != "" {
+		t.Errorf("expected deletion in third key history: %v\n", history[2])
+	}
+	if history[0].Value != nil {
+		t.Errorf("expected no values in key history without values option\n")
+	}
+
+	history = getHistory(uuid2, "descendants=true&values=true")
+	if len(history) != 4 {
+		t.Fatalf("expected 4 changes in key history with descendants, got %v\n", history)
+	}
+	if string(history[0].Value) != "v1" || string(history[1].Value) != "v2" {
+		t.Errorf("bad values in key history: %v\n", history)
+	}
+	var foundDeleted, foundBranch bool
+	for _, kv := range history[2:] {
+		switch kv.UUID {
+		case uuid3:
+			foundDeleted = kv.Deleted
+		case uuid5:
+			foundBranch = string(kv.Value) == "v5" && !kv.Locked
+		}
+	}
+	if !foundDeleted || !foundBranch {
+		t.Errorf("bad descendant key history: %v\n", history[2:])
+	}
+
+	if history = getHistory(uuid1, ""); len(history) != 1 || history[0].UUID != uuid1 {
+		t.Errorf("bad key history at root: %v\n", history)
+	}
+	server.TestBadHTTP(t, "POST", keyURL(uuid4)+"/history", nil)
+	server.TestBadHTTP(t, "GET", keyURL(uuid4)+"/foo", nil)
+}
+
+func readTar(t *testing.T, r io.Reader) map[string]string {
+	files := make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("bad tar file: %v\n", err)
+		}
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, tr); err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name] = buf.String()
+	}
+	return files
+}
+
+func TestKeyvalueTarfile(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+	config := dvid.NewConfig()
+	for _, name := range []dvid.InstanceName{"source", "dest"} {
+		if _, err := datastore.NewData(uuid, kvtype, name, config); err != nil {
+			t.Fatalf("Error creating new keyvalue instance %q: %v\n", name, err)
+		}
+	}
+	kvs := map[string]string{
+		"a1":       "first",
+		"meshes-1": "mesh one",
+		"meshes-2": "mesh two",
+		"meshes-3": "",
+		"z":        "last",
+	}
+	for key, value := range kvs {
+		keyreq := fmt.Sprintf("%snode/%s/source/key/%s", server.WebAPIPath, uuid, key)
+		server.TestHTTP(t, "POST", keyreq, strings.NewReader(value))
+	}
+
+	tarreq := fmt.Sprintf("%snode/%s/source/tarfile", server.WebAPIPath, uuid)
+	if files := readTar(t, bytes.NewReader(server.TestHTTP(t, "GET", tarreq, nil))); !reflect.DeepEqual(files, kvs) {
+		t.Errorf("bad tarfile of all keys: %v\n", files)
+	}
+	files := readTar(t, bytes.NewReader(server.TestHTTP(t, "GET", tarreq+"?prefix=meshes-", nil)))
+	if len(files) != 3 || files["meshes-2"] != "mesh two" {
+		t.Errorf("bad tarfile of prefixed keys: %v\n", files)
+	}
+	files = readTar(t, bytes.NewReader(server.TestHTTP(t, "GET", tarreq+"/meshes-2/z?prefix=meshes-", nil)))
+	if len(files) != 2 || files["meshes-3"] != "" {
+		t.Errorf("bad tarfile of key range with prefix: %v\n", files)
+	}
+
+	// Round-trip gzip-compressed tar into another instance, ignoring directories.
+	gzipped := server.TestHTTP(t, "GET", tarreq+"?gzip=true", nil)
+	gr, err := gzip.NewReader(bytes.NewReader(gzipped))
+	if err != nil {
+		t.Fatalf("expected gzip-compressed tarfile: %v\n", err)
+	}
+	if files = readTar(t, gr); !reflect.DeepEqual(files, kvs) {
+		t.Errorf("bad gzip-compressed tarfile: %v\n", files)
+	}
+	loadreq := fmt.Sprintf("%snode/%s/dest/load", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", loadreq, bytes.NewReader(gzipped))
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "subdir/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "extra", Size: 5, Mode: 0644}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte("added")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	server.TestHTTP(t, "POST", loadreq, &buf)
+
+	keysreq := fmt.Sprintf("%snode/%s/dest/keys", server.WebAPIPath, uuid)
+	if keys := string(server.TestHTTP(t, "GET", keysreq, nil)); keys != `["a1","extra","meshes-1","meshes-2","meshes-3","z"]` {
+		t.Errorf("bad keys after tar loads: %s\n", keys)
+	}
+	valuereq := fmt.Sprintf("%snode/%s/dest/key/meshes-1", server.WebAPIPath, uuid)
+	if value := string(server.TestHTTP(t, "GET", valuereq, nil)); value != "mesh one" {
+		t.Errorf("bad value after tar load: %q\n", value)
+	}
+
+	server.TestBadHTTP(t, "POST", tarreq, nil)
+	server.TestBadHTTP(t, "GET", tarreq+"/meshes-1", nil)
+	server.TestBadHTTP(t, "POST", loadreq, strings.NewReader("not a tar file"))
+}
+
+func TestKeyvalueJSONIndex(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid1, _ := initTestRepo()
+	config := dvid.NewConfig()
+	config.Set("IndexedFields", "status")
+	if _, err := datastore.NewData(uuid1, kvtype, "badjson", config); err == nil {
+		t.Fatalf("expected error creating keyvalue with IndexedFields but no JSON mode\n")
+	}
+	config.Set("JSON", "true")
+	config.Set("IndexedFields", "status, review.user,size")
+	if _, err := datastore.NewData(uuid1, kvtype, "docs", config); err != nil {
+		t.Fatalf("Error creating new keyvalue instance: %v\n", err)
+	}
+	keyURL := func(uuid dvid.UUID, key string) string {
+		return fmt.Sprintf("%snode/%s/docs/key/%s", server.WebAPIPath, uuid, key)
+	}
+	query := func(uuid dvid.UUID, field, q string) FieldResult {
+		var result FieldResult
+		url := fmt.Sprintf("%snode/%s/docs/query/%s?%s", server.WebAPIPath, uuid, field, q)
+		resp := server.TestHTTP(t, "GET", url, nil)
+		if err := json.Unmarshal(resp, &result); err != nil {
+			t.Fatalf("couldn't unmarshal query result %s: %v\n", string(resp), err)
+		}
+		return result
+	}
+	checkKeys := func(result FieldResult, expected ...string) {
+		if len(expected) == 0 {
+			expected = []string{}
+		}
+		if !reflect.DeepEqual(result.Keys, expected) {
+			t.Errorf("expected keys %v, got %v\n", expected, result.Keys)
+		}
+	}
+
+	docs := map[string]string{
+		"a": `{"status": "open", "size": 10, "review": {"user": "alice"}}`,
+		"b": `{"status": "closed", "size": 2.5, "review": [{"user": "bob"}, {"user": "alice"}]}`,
+		"c": `{"status": "open", "size": -3}`,
+		"d": `{"status": "review", "size": 100, "tags": ["x"]}`,
+		"e": `{"status": ["open", "review"], "size": "big"}`,
+	}
+	for key, doc := range docs {
+		server.TestHTTP(t, "POST", keyURL(uuid1, key), strings.NewReader(doc))
+	}
+	server.TestBadHTTP(t, "POST", keyURL(uuid1, "bad"), strings.NewReader(`{"status": "open"`))
+	server.TestBadHTTP(t, "POST", keyURL(uuid1, "bad"), strings.NewReader(`not json`))
+
+	// equality, IN-list, and range queries
+	checkKeys(query(uuid1, "status", "value=open"), "a", "c", "e")
+	checkKeys(query(uuid1, "status", "value=review&value=closed&value=review"), "b", "d", "e")
+	checkKeys(query(uuid1, "review.user", "value=alice"), "a", "b")
+	checkKeys(query(uuid1, "size", "min=0&max=100"), "b", "a")
+	checkKeys(query(uuid1, "size", "max=10"), "c", "b")
+	checkKeys(query(uuid1, "size", "min=100"), "d")
+	checkKeys(query(uuid1, "size", "value=big"), "e")
+	checkKeys(query(uuid1, "status", "value=none"))
+	server.TestBadHTTP(t, "GET", fmt.Sprintf("%snode/%s/docs/query/tags?value=x", server.WebAPIPath, uuid1), nil)
+	server.TestBadHTTP(t, "GET", fmt.Sprintf("%snode/%s/docs/query/status", server.WebAPIPath, uuid1), nil)
+	server.TestBadHTTP(t, "GET", fmt.Sprintf("%snode/%s/docs/query/size?value=1&min=0", server.WebAPIPath, uuid1), nil)
+
+	result := query(uuid1, "status", "value=open&values=true")
+	if len(result.Values) != 3 || string(result.Values["c"]) != docs["c"] {
+		t.Errorf("bad values returned from query: %v\n", result.Values)
+	}
+
+	// pagination across an IN-list
+	var paged []string
+	cursor := ""
+	for i := 0; i < 10; i++ {
+		q := "value=open&value=review&limit=2"
+		if cursor != "" {
+			q += "&cursor=" + cursor
+		}
+		result := query(uuid1, "status", q)
+		paged = append(paged, result.Keys...)
+		if cursor = result.Cursor; cursor == "" {
+			break
+		}
+	}
+	expected := []string{"a", "c", "e", "d", "e"}
+	if !reflect.DeepEqual(paged, expected) {
+		t.Errorf("expected paged keys %v, got %v\n", expected, paged)
+	}
+
+	// overwrites and deletes update the index
+	server.TestHTTP(t, "POST", keyURL(uuid1, "a"), strings.NewReader(`{"status": "closed", "size": 10}`))
+	server.TestHTTP(t, "DELETE", keyURL(uuid1, "c"), nil)
+	checkKeys(query(uuid1, "status", "value=open"), "e")
+	checkKeys(query(uuid1, "status", "value=closed"), "a", "b")
+	checkKeys(query(uuid1, "review.user", "value=alice"), "b")
+
+	// index is versioned with the values
+	if err := datastore.Commit(uuid1, "first", nil); err != nil {
+		t.Fatalf("Unable to commit node %s: %v\n", uuid1, err)
+	}
+	uuid2, err := datastore.NewVersion(uuid1, "second", "", nil)
+	if err != nil {
+		t.Fatalf("Unable to create new version off node %s: %v\n", uuid1, err)
+	}
+	server.TestHTTP(t, "POST", keyURL(uuid2, "b"), strings.NewReader(`{"status": "open"}`))
+	server.TestHTTP(t, "DELETE", keyURL(uuid2, "d"), nil)
+	checkKeys(query(uuid2, "status", "value=open"), "b", "e")
+	checkKeys(query(uuid2, "status", "value=review"), "e")
+	checkKeys(query(uuid1, "status", "value=open"), "e")
+	checkKeys(query(uuid1, "status", "value=review"), "d", "e")
+
+	// changing the indexed fields requires a reindex for existing values
+	server.TestHTTP(t, "POST", keyURL(uuid2, "g"), strings.NewReader(`{"tags": ["z"]}`))
+	configURL := fmt.Sprintf("%snode/%s/docs", server.WebAPIPath, uuid2)
+	server.TestBadHTTP(t, "PUT", configURL, strings.NewReader(`{"JSON": "false", "IndexedFields": "status,tags"}`))
+	kvData, err := GetByUUIDName(uuid2, "docs")
+	if err != nil {
+		t.Fatalf("can't get keyvalue docs: %v\n", err)
+	}
+	if props := kvData.properties(); !props.JSON || strings.Join(props.IndexedFields, ",") != "status,review.user,size" {
+		t.Fatalf("bad config modified properties: %v\n", props)
+	}
+	server.TestHTTP(t, "PUT", configURL, strings.NewReader(`{"IndexedFields": "status,tags"}`))
+	checkKeys(query(uuid2, "tags", "value=x"))
+	checkKeys(query(uuid2, "tags", "value=z"))
+	server.TestHTTP(t, "POST", fmt.Sprintf("%snode/%s/docs/reindex", server.WebAPIPath, uuid2), nil)
+	if err := datastore.BlockOnUpdating(uuid2, "docs"); err != nil {
+		t.Fatalf("Error blocking on reindex of docs: %v\n", err)
+	}
+	checkKeys(query(uuid2, "tags", "value=z"), "g")
+	v2, err := datastore.VersionFromUUID(uuid2)
+	if err != nil {
+		t.Fatalf("Unable to get version of node %s: %v\n", uuid2, err)
+	}
+	numKeys, err := kvData.Reindex(datastore.NewVersionedCtx(kvData, v2))
+	if err != nil {
+		t.Fatalf("Error reindexing docs: %v\n", err)
+	}
+	if numKeys != 4 {
+		t.Errorf("expected 4 reindexed keys, got %d\n", numKeys)
+	}
+	checkKeys(query(uuid2, "tags", "value=z"), "g")
+	server.TestHTTP(t, "POST", keyURL(uuid2, "f"), strings.NewReader(`{"tags": ["x", "y"]}`))
+	checkKeys(query(uuid2, "tags", "value=x"), "f")
+	checkKeys(query(uuid2, "status", "value=open"), "b", "e")
+	server.TestBadHTTP(t, "GET", fmt.Sprintf("%snode/%s/docs/query/size?min=0", server.WebAPIPath, uuid2), nil)
+}
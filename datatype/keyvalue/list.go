@@ -0,0 +1,145 @@
+/*
+	This file supports paginated and streaming listing of keys for the keyvalue data type.
+*/
+
+package keyvalue
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// DefaultListLimit is the maximum number of keys returned in one page of a JSON key listing
+// if no limit is given.
+const DefaultListLimit = 1000
+
+// errListLimit is used to stop a range query once the listing limit has been reached.
+var errListLimit = errors.New("key listing limit reached")
+
+// KeyInfo describes a key and, if metadata is requested, the size of its value in bytes and
+// its last modification time if the store supports timestamps.
+type KeyInfo struct {
+	Key      string
+	Size     *int   `json:",omitempty"`
+	Modified string `json:",omitempty"`
+}
+
+// KeyList is a page of a key listing, where NextCursor is set to the cursor for the next page
+// if there are more keys.
+type KeyList struct {
+	Keys       []KeyInfo
+	NextCursor string `json:",omitempty"`
+}
+
+// ListOptions specifies which keys are listed.
+type ListOptions struct {
+	Prefix string // only list keys with this prefix
+	Cursor string // only list keys after this key, usually the last key of a prior page
+	Limit  int    // maximum number of keys to list or 0 for no limit
+	Meta   bool   // get value size and modification time for each key
+}
+
+// prefixRange returns the range of type-specific keys for keys with the given prefix.
+func prefixRange(prefix string) (begTKey, endTKey storage.TKey) {
+	if prefix == "" {
+		return storage.MinTKey(keyStandard), storage.MaxTKey(keyStandard)
+	}
+	begTKey = storage.NewTKey(keyStandard, []byte(prefix))
+
+	// The end is the prefix incremented at its last byte that isn't 0xFF, which sorts after
+	// all keys with the prefix since every key is terminated by a 0 byte.
+	end := []byte(prefix)
+	for len(end) > 0 && end[len(end)-1] == 0xFF {
+		end = end[:len(end)-1]
+	}
+	if len(end) == 0 {
+		return begTKey, storage.MaxTKey(keyStandard)
+	}
+	end[len(end)-1]++
+	return begTKey, storage.NewTKey(keyStandard, end)
+}
+
+// ListKeys calls the given function for each key in order that satisfies the options.  If
+// the limit was reached before all keys were listed, a cursor for the next page is returned.
+func (d *Data) ListKeys(ctx storage.Context, opts ListOptions, f func(KeyInfo) error) (cursor string, err error) {
+	db, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return "", err
+	}
+	begTKey, endTKey := prefixRange(opts.Prefix)
+	if opts.Cursor != "" {
+		if cursorTKey, err := NewTKey(opts.Cursor); err != nil {
+			return "", err
+		} else if bytes.Compare(cursorTKey, begTKey) > 0 {
+			begTKey = cursorTKey
+		}
+	}
+	tsdb, hasTimestamps := db.(storage.KeyValueTimestampGetter)
+
+	var numKeys int
+	var lastKey string
+	err = db.ProcessRange(ctx, begTKey, endTKey, nil, func(c *storage.Chunk) error {
+		if c == nil || c.TKeyValue == nil {
+			return nil
+		}
+		key, err := DecodeTKey(c.K)
+		if err != nil {
+			return err
+		}
+		if (opts.Cursor != "" && key <= opts.Cursor) || !strings.HasPrefix(key, opts.Prefix) {
+			return nil
+		}
+		if opts.Limit > 0 && numKeys == opts.Limit {
+			cursor = lastKey
+			return errListLimit
+		}
+		info := KeyInfo{Key: key}
+		if opts.Meta {
+			value, _, err := dvid.DeserializeData(c.V, true)
+			if err != nil {
+				return err
+			}
+			size := len(value)
+			info.Size = &size
+			if hasTimestamps {
+				_, modTime, err := tsdb.GetWithTimestamp(ctx, c.K)
+				if err != nil {
+					return err
+				}
+				if !modTime.IsZero() {
+					info.Modified = modTime.Format(time.RFC3339)
+				}
+			}
+		}
+		if err := f(info); err != nil {
+			return err
+		}
+		numKeys++
+		lastKey = key
+		return nil
+	})
+	if err == errListLimit {
+		err = nil
+	}
+	return
+}
+
+// GetKeyList returns a page of keys satisfying the options.
+func (d *Data) GetKeyList(ctx storage.Context, opts ListOptions) (*KeyList, error) {
+	list := &KeyList{Keys: []KeyInfo{}}
+	cursor, err := d.ListKeys(ctx, opts, func(info KeyInfo) error {
+		list.Keys = append(list.Keys, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	list.NextCursor = cursor
+	return list, nil
+}
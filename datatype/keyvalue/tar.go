@@ -0,0 +1,215 @@
+/*
+	This file supports bulk export and import of keyvalue data as tar archives.
+*/
+
+package keyvalue
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// TarOptions specifies the keys exported to a tar archive.
+type TarOptions struct {
+	Prefix   string // only export keys with this prefix
+	StartKey string // if non-empty, only export keys lexicographically at or after this key
+	EndKey   string // if non-empty, only export keys lexicographically at or before this key
+	Gzip     bool   // compress the tar archive with gzip
+}
+
+// WriteTar writes a tar archive where each key satisfying the options is a file named by the
+// key with its value as content.  The number of files written is returned.
+func (d *Data) WriteTar(ctx storage.Context, w io.Writer, opts TarOptions) (numKeys int, err error) {
+	db, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return 0, err
+	}
+	begTKey, endTKey := prefixRange(opts.Prefix)
+	if opts.StartKey != "" {
+		if startTKey, err := NewTKey(opts.StartKey); err != nil {
+			return 0, err
+		} else if bytes.Compare(startTKey, begTKey) > 0 {
+			begTKey = startTKey
+		}
+	}
+	if opts.EndKey != "" {
+		if lastTKey, err := NewTKey(opts.EndKey); err != nil {
+			return 0, err
+		} else if bytes.Compare(lastTKey, endTKey) < 0 {
+			endTKey = lastTKey
+		}
+	}
+	tsdb, hasTimestamps := db.(storage.KeyValueTimestampGetter)
+
+	var gw *gzip.Writer
+	if opts.Gzip {
+		gw = gzip.NewWriter(w)
+		w = gw
+	}
+	tw := tar.NewWriter(w)
+	err = db.ProcessRange(ctx, begTKey, endTKey, nil, func(c *storage.Chunk) error {
+		if c == nil || c.TKeyValue == nil {
+			return nil
+		}
+		key, err := DecodeTKey(c.K)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(key, opts.Prefix) {
+			return nil
+		}
+		value, _, err := dvid.DeserializeData(c.V, true)
+		if err != nil {
+			return fmt.Errorf("unable to deserialize data for key %q: %v", key, err)
+		}
+		hdr := &tar.Header{
+			Name: key,
+			Size: int64(len(value)),
+			Mode: 0644,
+		}
+		if hasTimestamps {
+			if _, hdr.ModTime, err = tsdb.GetWithTimestamp(ctx, c.K); err != nil {
+				return err
+			}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(value); err != nil {
+			return err
+		}
+		numKeys++
+		return nil
+	})
+	if closeErr := tw.Close(); err == nil {
+		err = closeErr
+	}
+	if gw != nil {
+		if closeErr := gw.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return
+}
+
+// LoadTar stores each regular file in a tar archive, which may be gzip compressed, under a key
+// given by its file name.  The number of keys stored is returned.
+func (d *Data) LoadTar(ctx *datastore.VersionedCtx, r io.Reader) (numKeys int, err error) {
+	uuid, err := datastore.UUIDFromVersion(ctx.VersionID())
+	if err != nil {
+		return 0, err
+	}
+	br := bufio.NewReader(r)
+	var in io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return 0, err
+		}
+		defer gr.Close()
+		in = gr
+	}
+	tr := tar.NewReader(in)
+	for filenum := 1; ; filenum++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return numKeys, fmt.Errorf("bad tar file %d: %v", filenum, err)
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		if hdr.Name == "" {
+			return numKeys, fmt.Errorf("file %d in tar has no name", filenum)
+		}
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, tr); err != nil {
+			return numKeys, err
+		}
+		if err := d.PutData(ctx, hdr.Name, buf.Bytes()); err != nil {
+			return numKeys, err
+		}
+		d.logPost(uuid, hdr.Name, buf.Len())
+		numKeys++
+	}
+	return numKeys, nil
+}
+
+// logPost sends a Kafka message for the POST of a key.
+func (d *Data) logPost(uuid dvid.UUID, keyStr string, numBytes int) {
+	msginfo := map[string]interface{}{
+		"Action": "postkv",
+		"Key":    keyStr,
+		"Bytes":  numBytes,
+		"UUID":   string(uuid),
+	}
+	jsonmsg, _ := json.Marshal(msginfo)
+	if err := d.ProduceKafkaMsg(jsonmsg); err != nil {
+		dvid.Errorf("Error on sending keyvalue POST op to kafka: %v\n", err)
+	}
+}
+
+// exportTar handles an export command-line request.
+func (d *Data) exportTar(cmd datastore.Request, reply *datastore.Response) error {
+	if len(cmd.Command) < 5 {
+		return fmt.Errorf("the output file path must be specified after 'export'")
+	}
+	var uuidStr, dataName, cmdStr, outPath string
+	cmd.CommandArgs(1, &uuidStr, &dataName, &cmdStr, &outPath)
+
+	uuid, versionID, err := datastore.MatchingUUID(uuidStr)
+	if err != nil {
+		return err
+	}
+	if !d.Versioned() {
+		if versionID, err = datastore.GetRepoRootVersion(versionID); err != nil {
+			return err
+		}
+	}
+	config := cmd.Settings()
+	var opts TarOptions
+	if opts.Prefix, _, err = config.GetString("prefix"); err != nil {
+		return err
+	}
+	if opts.StartKey, _, err = config.GetString("start"); err != nil {
+		return err
+	}
+	if opts.EndKey, _, err = config.GetString("end"); err != nil {
+		return err
+	}
+	opts.Gzip = strings.HasSuffix(outPath, ".gz") || strings.HasSuffix(outPath, ".tgz")
+
+	go func() {
+		timedLog := dvid.NewTimeLog()
+		f, err := os.Create(outPath)
+		if err != nil {
+			dvid.Errorf("unable to create export file %q for keyvalue %q: %v\n", outPath, d.DataName(), err)
+			return
+		}
+		ctx := datastore.NewVersionedCtx(d, versionID)
+		numKeys, err := d.WriteTar(ctx, f, opts)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			dvid.Errorf("unable to export keyvalue %q, uuid %s to %q: %v\n", d.DataName(), uuid, outPath, err)
+			return
+		}
+		timedLog.Infof("Finished export of %d keys from keyvalue %q, uuid %s to %q", numKeys, d.DataName(), uuid, outPath)
+	}()
+	reply.Text = fmt.Sprintf("Asynchronously exporting keyvalue %q, uuid %s to tar file: %s\n", d.DataName(), uuid, outPath)
+	return nil
+}
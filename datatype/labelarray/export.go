@@ -0,0 +1,194 @@
+/*
+	This file supports export and import of labelarray data as Zarr or N5 chunked arrays.
+*/
+
+package labelarray
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/datatype/common/chunked"
+	"github.com/janelia-flyem/dvid/datatype/common/labels"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// ExportChunked writes all scales of a labelarray version into a directory-based Zarr or N5
+// store with arrays "s0", "s1", ... whose chunk shape is the block size.
+func (d *Data) ExportChunked(v dvid.VersionID, dirPath string, format chunked.Format, gzip bool) error {
+	timedLog := dvid.NewTimeLog()
+	ctx := datastore.NewVersionedCtx(d, v)
+	extents, err := d.GetExtents(ctx)
+	if err != nil {
+		return err
+	}
+	if extents.MinPoint == nil || extents.MaxPoint == nil {
+		return fmt.Errorf("labelarray %q has no extents to export", d.DataName())
+	}
+	minPt, ok1 := extents.MinPoint.(dvid.Point3d)
+	maxPt, ok2 := extents.MaxPoint.(dvid.Point3d)
+	blockSize, ok3 := d.BlockSize().(dvid.Point3d)
+	if !ok1 || !ok2 || !ok3 {
+		return fmt.Errorf("labelarray %q must be 3d to export", d.DataName())
+	}
+
+	db, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return err
+	}
+	numScales := d.MaxDownresLevel + 1
+	rootAttrs := chunked.MultiscaleAttrs(format, string(d.DataName()), d.Properties.Resolution, numScales)
+	store, err := chunked.Create(dirPath, format, rootAttrs)
+	if err != nil {
+		return err
+	}
+	for scale := uint8(0); scale < numScales; scale++ {
+		offset, size := chunked.ScaledBounds(minPt, maxPt, blockSize, scale)
+		meta := chunked.ArrayMeta{
+			Size:      size,
+			ChunkSize: blockSize,
+			DataType:  dvid.T_uint64,
+			Gzip:      gzip,
+			Attrs:     chunked.ScaleAttrs(format, d.Properties.Resolution, scale, offset),
+		}
+		arr, err := store.CreateArray(chunked.ScaleName(scale), meta)
+		if err != nil {
+			return err
+		}
+		offsetBlock := offset.Chunk(blockSize).(dvid.ChunkPoint3d)
+
+		var numBlocks int
+		begTKey := NewBlockTKeyByCoord(scale, dvid.MinIndexZYX.ToIZYXString())
+		endTKey := NewBlockTKeyByCoord(scale, dvid.MaxIndexZYX.ToIZYXString())
+		err = db.ProcessRange(ctx, begTKey, endTKey, nil, func(c *storage.Chunk) error {
+			if c == nil || c.TKeyValue == nil || c.V == nil {
+				return nil
+			}
+			_, idx, err := DecodeBlockTKey(c.K)
+			if err != nil {
+				return err
+			}
+			data, _, err := dvid.DeserializeData(c.V, true)
+			if err != nil {
+				return fmt.Errorf("unable to deserialize block %s in %q: %v", idx, d.DataName(), err)
+			}
+			var block labels.Block
+			if err := block.UnmarshalBinary(data); err != nil {
+				return err
+			}
+			lbls, _ := block.MakeLabelVolume()
+			bcoord := dvid.ChunkPoint3d(*idx)
+			chunkPt := dvid.ChunkPoint3d{
+				bcoord[0] - offsetBlock[0],
+				bcoord[1] - offsetBlock[1],
+				bcoord[2] - offsetBlock[2],
+			}
+			numBlocks++
+			return arr.WriteChunk(chunkPt, lbls)
+		})
+		if err != nil {
+			return fmt.Errorf("error exporting scale %d of labelarray %q: %v", scale, d.DataName(), err)
+		}
+		timedLog.Infof("Exported %d blocks at scale %d of labelarray %q to %s store %q", numBlocks, scale, d.DataName(), format, dirPath)
+	}
+	return nil
+}
+
+// ImportChunked ingests scale arrays "s0", "s1", ... from a Zarr or N5 store into the given
+// version.  Arrays must be 3d unsigned integers with chunk shape equal to the block size.
+// If the store only has a scale 0 array, lower-resolution scales are computed.  The voxel
+// offset of each scale is read from the array attributes if written by DVID.  Otherwise the
+// given offset is used for scale 0 and lower-resolution scales use the scale 0 offset
+// downsampled by the scale's factor, which must be block aligned.
+func (d *Data) ImportChunked(v dvid.VersionID, dirPath string, offset dvid.Point3d) error {
+	timedLog := dvid.NewTimeLog()
+	store, err := chunked.Open(dirPath)
+	if err != nil {
+		return err
+	}
+	if !store.HasArray(chunked.ScaleName(0)) {
+		return fmt.Errorf("store %q has no scale 0 array %q", dirPath, chunked.ScaleName(0))
+	}
+	blockSize, ok := d.BlockSize().(dvid.Point3d)
+	if !ok {
+		return fmt.Errorf("labelarray %q must be 3d to import", d.DataName())
+	}
+	ctx := datastore.NewVersionedCtx(d, v)
+	downscale := d.MaxDownresLevel > 0 && !store.HasArray(chunked.ScaleName(1))
+	for scale := uint8(0); scale <= d.MaxDownresLevel; scale++ {
+		name := chunked.ScaleName(scale)
+		if !store.HasArray(name) {
+			break
+		}
+		arr, err := store.OpenArray(name)
+		if err != nil {
+			return err
+		}
+		if arr.ChunkSize != blockSize {
+			return fmt.Errorf("array %q chunk size %s doesn't match labelarray %q block size %s", name, arr.ChunkSize, d.DataName(), blockSize)
+		}
+		scaleOffset, err := chunked.ScaleOffset(arr.Attrs, scale, offset, blockSize)
+		if err != nil {
+			return err
+		}
+		if scale == 0 {
+			offset = scaleOffset
+		}
+		offsetBlock := scaleOffset.Chunk(blockSize).(dvid.ChunkPoint3d)
+		chunks, err := arr.Chunks()
+		if err != nil {
+			return err
+		}
+
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(writeChunkedBlocks(pw, arr, chunks, offsetBlock))
+		}()
+		if err := d.ReceiveBlocks(ctx, pr, scale, downscale && scale == 0, "blocks"); err != nil {
+			pr.CloseWithError(err)
+			return fmt.Errorf("error importing array %q into labelarray %q: %v", name, d.DataName(), err)
+		}
+		timedLog.Infof("Imported %d chunks from %s array %q into scale %d of labelarray %q", len(chunks), store.Format, name, scale, d.DataName())
+	}
+	return nil
+}
+
+// writeChunkedBlocks converts array chunks into the streaming block format read by
+// ReceiveBlocks, translating chunk indices by the given block offset.
+func writeChunkedBlocks(w io.Writer, arr *chunked.Array, chunks []dvid.ChunkPoint3d, offsetBlock dvid.ChunkPoint3d) error {
+	hdr := make([]byte, 16)
+	for _, chunkPt := range chunks {
+		data, err := arr.ReadChunk(chunkPt)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			continue
+		}
+		if data, err = chunked.ToUint64(data, arr.DataType); err != nil {
+			return err
+		}
+		block, err := labels.MakeBlock(data, arr.ChunkSize)
+		if err != nil {
+			return err
+		}
+		gzipped, err := block.CompressGZIP()
+		if err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint32(hdr[0:4], uint32(chunkPt[0]+offsetBlock[0]))
+		binary.LittleEndian.PutUint32(hdr[4:8], uint32(chunkPt[1]+offsetBlock[1]))
+		binary.LittleEndian.PutUint32(hdr[8:12], uint32(chunkPt[2]+offsetBlock[2]))
+		binary.LittleEndian.PutUint32(hdr[12:16], uint32(len(gzipped)))
+		if _, err := w.Write(hdr); err != nil {
+			return err
+		}
+		if _, err := w.Write(gzipped); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -24,6 +24,7 @@ import (
 	"compress/gzip"
 
 	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/datatype/common/chunked"
 	"github.com/janelia-flyem/dvid/datatype/common/downres"
 	"github.com/janelia-flyem/dvid/datatype/common/labels"
 	"github.com/janelia-flyem/dvid/datatype/imageblk"
@@ -108,6 +109,53 @@ $ dvid node <UUID> <data name> composite <uint8 data name> <new rgba8 data name>
 
     UUID          Hexidecimal string with enough characters to uniquely identify a version node.
     data name     Name of data to add.
+
+$ dvid node <UUID> <data name> export <format> <directory path> <settings...>
+
+	Asynchronously exports all scales of the specified version of labelarray data into a
+	directory-based chunked array store.  Each scale is written as an array named "s0", "s1", ...
+	with chunk shape equal to the block size.  Voxel resolution, downsampling factors and the
+	DVID voxel offset of each array are written as attributes so the data can be imported back
+	into the same location.
+
+    Example: 
+
+    $ dvid node 3f8c superpixels export n5 /path/to/superpixels.n5
+
+    Arguments:
+
+    UUID            Hexidecimal string with enough characters to uniquely identify a version node.
+    data name       Name of data to export.
+    format          Either "zarr" (Zarr v2 with OME-NGFF multiscales metadata) or "n5".
+    directory path  Absolute path to a directory that the dvid server has write privileges to.
+
+    Configuration Settings (case-insensitive keys)
+
+    compression     "gzip" (default) or "raw" chunk compression.
+
+$ dvid node <UUID> <data name> import <directory path> <settings...>
+
+	Asynchronously imports a Zarr v2 or N5 store with 3d unsigned integer arrays named "s0", "s1", ...
+	whose chunk shape is equal to the block size of the labelarray.  The format is detected from
+	the store's metadata.  If only the "s0" array is present, lower resolution scales are computed.
+
+    Example: 
+
+    $ dvid node 3f8c superpixels import /path/to/superpixels.zarr offset=0,0,1024
+
+    Arguments:
+
+    UUID            Hexidecimal string with enough characters to uniquely identify a version node.
+    data name       Name of data to import into.
+    directory path  Absolute path to a store directory readable by the dvid server.
+
+    Configuration Settings (case-insensitive keys)
+
+    offset          Block-aligned voxel coordinate "x,y,z" of the first voxel of "s0".  Ignored
+                      if the arrays were exported by DVID and have a "dvidOffset" attribute.
+                      Arrays of lower scales without the attribute start at the "s0" offset
+                      divided by the scale's downsampling factor, which must be block aligned.
+                      (Default 0,0,0)
 	
 	
     ------------------
@@ -2294,6 +2342,69 @@ func (d *Data) DoRPC(req datastore.Request, reply *datastore.Response) error {
 		}
 		return d.CreateComposite(req, reply)
 
+	case "export":
+		if len(req.Command) < 6 {
+			return fmt.Errorf("Poorly formatted export command.  See command-line help.")
+		}
+		var uuidStr, dataName, cmdStr, formatStr, dirPath string
+		req.CommandArgs(1, &uuidStr, &dataName, &cmdStr, &formatStr, &dirPath)
+
+		format, err := chunked.ParseFormat(formatStr)
+		if err != nil {
+			return err
+		}
+		uuid, v, err := datastore.MatchingUUID(uuidStr)
+		if err != nil {
+			return err
+		}
+		compression, _, err := req.Settings().GetString("compression")
+		if err != nil {
+			return err
+		}
+		gzip, err := chunked.ParseCompression(compression)
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := d.ExportChunked(v, dirPath, format, gzip); err != nil {
+				dvid.Errorf("unable to export labelarray %q, uuid %s to %s store %q: %v\n", d.DataName(), uuid, format, dirPath, err)
+			}
+		}()
+		reply.Text = fmt.Sprintf("Asynchronously exporting data %q, uuid %s to %s store: %s\n", d.DataName(), uuid, format, dirPath)
+		return nil
+
+	case "import":
+		if len(req.Command) < 5 {
+			return fmt.Errorf("Poorly formatted import command.  See command-line help.")
+		}
+		var uuidStr, dataName, cmdStr, dirPath string
+		req.CommandArgs(1, &uuidStr, &dataName, &cmdStr, &dirPath)
+
+		uuid, v, err := datastore.MatchingUUID(uuidStr)
+		if err != nil {
+			return err
+		}
+		var offset dvid.Point3d
+		offsetStr, found, err := req.Settings().GetString("offset")
+		if err != nil {
+			return err
+		}
+		if found {
+			if offset, err = dvid.StringToPoint3d(offsetStr, ","); err != nil {
+				return fmt.Errorf("Illegal offset specification %q: %v", offsetStr, err)
+			}
+		}
+		if err = datastore.AddToNodeLog(uuid, []string{req.Command.String()}); err != nil {
+			return err
+		}
+		go func() {
+			if err := d.ImportChunked(v, dirPath, offset); err != nil {
+				dvid.Errorf("unable to import %q into labelarray %q, uuid %s: %v\n", dirPath, d.DataName(), uuid, err)
+			}
+		}()
+		reply.Text = fmt.Sprintf("Asynchronously importing %q into data %q, uuid %s\n", dirPath, d.DataName(), uuid)
+		return nil
+
 	default:
 		return fmt.Errorf("Unknown command.  Data type '%s' [%s] does not support '%s' command.",
 			d.DataName(), d.TypeName(), req.TypeCommand())
@@ -584,6 +584,7 @@ type blockChange struct {
 func (d *Data) aggregateBlockChanges(v dvid.VersionID, ch <-chan blockChange) {
 	var maxLabel uint64
 	ldm := make(map[uint64]blockDiffMap)
+	sizeChanges := make(map[uint64]int64)
 	for change := range ch {
 		for label, flag := range change.present {
 			bdm, found := ldm[label]
@@ -615,6 +616,7 @@ func (d *Data) aggregateBlockChanges(v dvid.VersionID, ch <-chan blockChange) {
 			}
 			diff := bdm[change.bcoord]
 			diff.delta += delta
+			sizeChanges[label] += int64(delta)
 		}
 	}
 	go func() {
@@ -628,6 +630,22 @@ func (d *Data) aggregateBlockChanges(v dvid.VersionID, ch <-chan blockChange) {
 			ChangeLabelIndex(d, v, label, bdm)
 		}
 	}
+
+	// Publish change in label sizes.
+	for label, change := range sizeChanges {
+		if label == 0 || change == 0 {
+			continue
+		}
+		delta := labels.DeltaModSize{
+			Label:      label,
+			SizeChange: change,
+		}
+		evt := datastore.SyncEvent{d.DataUUID(), labels.ChangeSizeEvent}
+		msg := datastore.SyncMessage{labels.ChangeSizeEvent, v, delta}
+		if err := datastore.NotifySubscribers(evt, msg); err != nil {
+			dvid.Errorf("can't notify subscribers for event %v: %v\n", evt, err)
+		}
+	}
 }
 
 type labelDiff struct {
@@ -0,0 +1,317 @@
+/*
+	This file supports body annotations, JSON documents stored per label that follow
+	the label through merges, cleaves and splits.
+*/
+
+package labelmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// ConflictsField is the body annotation field that holds, for each field whose values
+// differed among merged bodies, the list of differing values.  It is only written under
+// the FlagConflictAnnotation policy.
+const ConflictsField = "conflicts"
+
+// BodyAnnotationPolicy determines how body annotations are combined when labels are
+// merged and what annotation is given to new labels created by cleaves or splits.
+type BodyAnnotationPolicy uint8
+
+const (
+	// KeepTargetAnnotation keeps the annotation of a merge target and discards those of
+	// the merged labels.  Labels created by cleaves or splits get no annotation.
+	KeepTargetAnnotation BodyAnnotationPolicy = iota
+
+	// UnionAnnotation adds fields of merged labels that are missing from the annotation of
+	// the merge target.  Labels created by cleaves or splits get a copy of the annotation
+	// of the original label.
+	UnionAnnotation
+
+	// FlagConflictAnnotation is like UnionAnnotation but records fields with differing values
+	// in the ConflictsField of the merged annotation.
+	FlagConflictAnnotation
+)
+
+func (p BodyAnnotationPolicy) String() string {
+	switch p {
+	case KeepTargetAnnotation:
+		return "keep-target"
+	case UnionAnnotation:
+		return "union"
+	case FlagConflictAnnotation:
+		return "flag-conflict"
+	default:
+		return fmt.Sprintf("unknown policy %d", p)
+	}
+}
+
+// MarshalJSON returns the policy name as a JSON string.
+func (p BodyAnnotationPolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// ParseBodyAnnotationPolicy returns the policy corresponding to a name like "keep-target".
+func ParseBodyAnnotationPolicy(s string) (BodyAnnotationPolicy, error) {
+	switch s {
+	case "keep-target":
+		return KeepTargetAnnotation, nil
+	case "union":
+		return UnionAnnotation, nil
+	case "flag-conflict":
+		return FlagConflictAnnotation, nil
+	default:
+		return KeepTargetAnnotation, fmt.Errorf(`body annotation merge policy must be "keep-target", "union", or "flag-conflict", not %q`, s)
+	}
+}
+
+// BodyAnnotation is a JSON object stored for a label.
+type BodyAnnotation map[string]interface{}
+
+type labelSlice []uint64
+
+func (s labelSlice) Len() int           { return len(s) }
+func (s labelSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s labelSlice) Less(i, j int) bool { return s[i] < s[j] }
+
+// serializes modification of body annotations across all labelmap instances.
+var bodyAnnotationMu sync.Mutex
+
+func getBodyAnnotation(ctx *datastore.VersionedCtx, label uint64) (BodyAnnotation, error) {
+	store, err := datastore.GetKeyValueDB(ctx.Data())
+	if err != nil {
+		return nil, err
+	}
+	data, err := store.Get(ctx, NewBodyAnnotationTKey(label))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var annotation BodyAnnotation
+	if err := json.Unmarshal(data, &annotation); err != nil {
+		return nil, fmt.Errorf("bad body annotation stored for label %d: %v", label, err)
+	}
+	return annotation, nil
+}
+
+// stores the annotation for a label or deletes it if the annotation is empty.
+func putBodyAnnotation(ctx *datastore.VersionedCtx, label uint64, annotation BodyAnnotation) error {
+	store, err := datastore.GetKeyValueDB(ctx.Data())
+	if err != nil {
+		return err
+	}
+	if len(annotation) == 0 {
+		return store.Delete(ctx, NewBodyAnnotationTKey(label))
+	}
+	data, err := json.Marshal(annotation)
+	if err != nil {
+		return err
+	}
+	return store.Put(ctx, NewBodyAnnotationTKey(label), data)
+}
+
+// GetBodyAnnotation returns the annotation for a label or nil if there is none.
+func (d *Data) GetBodyAnnotation(v dvid.VersionID, label uint64) (BodyAnnotation, error) {
+	return getBodyAnnotation(datastore.NewVersionedCtx(d, v), label)
+}
+
+// PutBodyAnnotation replaces the annotation for a label.  An empty annotation deletes
+// any stored annotation.
+func (d *Data) PutBodyAnnotation(v dvid.VersionID, label uint64, annotation BodyAnnotation) error {
+	bodyAnnotationMu.Lock()
+	defer bodyAnnotationMu.Unlock()
+	return putBodyAnnotation(datastore.NewVersionedCtx(d, v), label, annotation)
+}
+
+// mergeBodyAnnotations combines the annotations of merged labels into the target label
+// using the instance's policy.  Annotations of the merged labels are deleted.
+func (d *Data) mergeBodyAnnotations(v dvid.VersionID, target uint64, merged []uint64) error {
+	bodyAnnotationMu.Lock()
+	defer bodyAnnotationMu.Unlock()
+
+	ctx := datastore.NewVersionedCtx(d, v)
+	annotation, err := getBodyAnnotation(ctx, target)
+	if err != nil {
+		return err
+	}
+	var changed bool
+	sorted := make(labelSlice, len(merged))
+	copy(sorted, merged)
+	sort.Sort(sorted)
+	for _, label := range sorted {
+		mergedAnnotation, err := getBodyAnnotation(ctx, label)
+		if err != nil {
+			return err
+		}
+		if mergedAnnotation == nil {
+			continue
+		}
+		if d.BodyAnnotationMerge != KeepTargetAnnotation {
+			if annotation == nil {
+				annotation = BodyAnnotation{}
+			}
+			annotation.merge(mergedAnnotation, d.BodyAnnotationMerge == FlagConflictAnnotation)
+			changed = true
+		}
+		if err := putBodyAnnotation(ctx, label, nil); err != nil {
+			return err
+		}
+	}
+	if changed {
+		return putBodyAnnotation(ctx, target, annotation)
+	}
+	return nil
+}
+
+// splitBodyAnnotation gives a label newly created from part of an original label a copy of
+// the original's annotation unless the policy only keeps annotations with the target.
+func (d *Data) splitBodyAnnotation(v dvid.VersionID, orig, newLabel uint64) error {
+	if d.BodyAnnotationMerge == KeepTargetAnnotation {
+		return nil
+	}
+	bodyAnnotationMu.Lock()
+	defer bodyAnnotationMu.Unlock()
+
+	ctx := datastore.NewVersionedCtx(d, v)
+	annotation, err := getBodyAnnotation(ctx, orig)
+	if err != nil || annotation == nil {
+		return err
+	}
+	return putBodyAnnotation(ctx, newLabel, annotation)
+}
+
+// merge adds fields of another annotation that are missing from the receiver.  If flagConflicts
+// is true, differing values of fields are recorded under the ConflictsField.
+func (a BodyAnnotation) merge(other BodyAnnotation, flagConflicts bool) {
+	for field, value := range other {
+		if field == ConflictsField {
+			continue
+		}
+		curValue, found := a[field]
+		if !found {
+			a[field] = value
+			continue
+		}
+		if flagConflicts && !reflect.DeepEqual(curValue, value) {
+			a.addConflict(field, value)
+		}
+	}
+	if !flagConflicts {
+		return
+	}
+	if otherConflicts, ok := other[ConflictsField].(map[string]interface{}); ok {
+		for field, values := range otherConflicts {
+			if valueList, ok := values.([]interface{}); ok {
+				for _, value := range valueList {
+					a.addConflict(field, value)
+				}
+			}
+		}
+	}
+}
+
+// addConflict records a value for a field under ConflictsField, starting the list with the
+// field's current value.
+func (a BodyAnnotation) addConflict(field string, value interface{}) {
+	conflicts, ok := a[ConflictsField].(map[string]interface{})
+	if !ok {
+		conflicts = make(map[string]interface{})
+		a[ConflictsField] = conflicts
+	}
+	values, _ := conflicts[field].([]interface{})
+	if len(values) == 0 {
+		if curValue, found := a[field]; found {
+			values = append(values, curValue)
+		}
+	}
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			conflicts[field] = values
+			return
+		}
+	}
+	conflicts[field] = append(values, value)
+}
+
+// matches returns true if the annotation has all the given fields with the given values.
+// Values are compared as strings, where non-string values are compared using their JSON
+// encoding, and an array field matches if any of its elements match.
+func (a BodyAnnotation) matches(query map[string]string) bool {
+	for field, want := range query {
+		value, found := a[field]
+		if !found {
+			return false
+		}
+		if list, isList := value.([]interface{}); isList {
+			var matched bool
+			for _, elem := range list {
+				if valueMatches(elem, want) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		} else if !valueMatches(value, want) {
+			return false
+		}
+	}
+	return true
+}
+
+func valueMatches(value interface{}, want string) bool {
+	if s, isString := value.(string); isString {
+		return s == want
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+	return string(encoded) == want
+}
+
+// QueryBodyAnnotations returns the sorted labels whose annotations have all the given
+// field values.
+func (d *Data) QueryBodyAnnotations(v dvid.VersionID, query map[string]string) ([]uint64, error) {
+	store, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return nil, err
+	}
+	ctx := datastore.NewVersionedCtx(d, v)
+	begTKey := NewBodyAnnotationTKey(0)
+	endTKey := NewBodyAnnotationTKey(math.MaxUint64)
+	labels := []uint64{}
+	err = store.ProcessRange(ctx, begTKey, endTKey, &storage.ChunkOp{}, func(c *storage.Chunk) error {
+		if c == nil || c.TKeyValue == nil || len(c.V) == 0 {
+			return nil
+		}
+		label, err := DecodeBodyAnnotationTKey(c.K)
+		if err != nil {
+			return err
+		}
+		var annotation BodyAnnotation
+		if err := json.Unmarshal(c.V, &annotation); err != nil {
+			return fmt.Errorf("bad body annotation stored for label %d: %v", label, err)
+		}
+		if annotation.matches(query) {
+			labels = append(labels, label)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
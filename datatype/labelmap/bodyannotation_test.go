@@ -0,0 +1,229 @@
+package labelmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/server"
+)
+
+func TestBodyAnnotationMerge(t *testing.T) {
+	target := BodyAnnotation{"status": "traced", "type": "KC"}
+	target.merge(BodyAnnotation{"status": "orphan", "notes": "near soma"}, false)
+	expected := BodyAnnotation{"status": "traced", "type": "KC", "notes": "near soma"}
+	if !reflect.DeepEqual(target, expected) {
+		t.Errorf("expected union merge %v, got %v\n", expected, target)
+	}
+
+	target = BodyAnnotation{"status": "traced", "type": "KC"}
+	target.merge(BodyAnnotation{"status": "orphan", "type": "KC"}, true)
+	target.merge(BodyAnnotation{"status": "leaves"}, true)
+	conflicts, ok := target[ConflictsField].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected conflicts field after merge, got %v\n", target)
+	}
+	if len(conflicts) != 1 {
+		t.Errorf("expected only status conflict, got %v\n", conflicts)
+	}
+	expectedValues := []interface{}{"traced", "orphan", "leaves"}
+	if !reflect.DeepEqual(conflicts["status"], expectedValues) {
+		t.Errorf("expected status conflicts %v, got %v\n", expectedValues, conflicts["status"])
+	}
+	if target["status"] != "traced" {
+		t.Errorf("expected target status to be kept, got %v\n", target["status"])
+	}
+}
+
+func TestBodyAnnotationMatches(t *testing.T) {
+	var annotation BodyAnnotation
+	if err := json.Unmarshal([]byte(`{"status": "traced", "verified": true, "tags": ["a", "b"], "count": 3}`), &annotation); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		query   map[string]string
+		matches bool
+	}{
+		{map[string]string{"status": "traced"}, true},
+		{map[string]string{"status": "orphan"}, false},
+		{map[string]string{"verified": "true"}, true},
+		{map[string]string{"count": "3", "tags": "b"}, true},
+		{map[string]string{"tags": "c"}, false},
+		{map[string]string{"missing": "x"}, false},
+	}
+	for _, tc := range tests {
+		if got := annotation.matches(tc.query); got != tc.matches {
+			t.Errorf("query %v on %v: expected %t, got %t\n", tc.query, annotation, tc.matches, got)
+		}
+	}
+}
+
+func postBodyAnnotation(t *testing.T, uuid dvid.UUID, label uint64, annotation string) {
+	reqStr := fmt.Sprintf("%snode/%s/labels/body-annotation/%d", server.WebAPIPath, uuid, label)
+	server.TestHTTP(t, "POST", reqStr, strings.NewReader(annotation))
+}
+
+func getBodyAnnotationHTTP(t *testing.T, uuid dvid.UUID, label uint64) BodyAnnotation {
+	reqStr := fmt.Sprintf("%snode/%s/labels/body-annotation/%d", server.WebAPIPath, uuid, label)
+	r := server.TestHTTPResponse(t, "GET", reqStr, nil)
+	if r.Code == 404 {
+		return nil
+	}
+	var annotation BodyAnnotation
+	if err := json.Unmarshal(r.Body.Bytes(), &annotation); err != nil {
+		t.Fatalf("bad body annotation for label %d: %v\n", label, err)
+	}
+	return annotation
+}
+
+func TestBodyAnnotationMutations(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+	var config dvid.Config
+	config.Set("BodyAnnotationMerge", "flag-conflict")
+	server.CreateTestInstance(t, uuid, "labelmap", "labels", config)
+
+	volume := newMigrationTestVolume()
+	volume.put(t, uuid, "labels")
+	if err := datastore.BlockOnUpdating(uuid, "labels"); err != nil {
+		t.Fatalf("Error blocking on update for labels: %v\n", err)
+	}
+
+	postBodyAnnotation(t, uuid, 1, `{"status": "traced", "type": "KC"}`)
+	postBodyAnnotation(t, uuid, 2, `{"status": "orphan", "notes": "check"}`)
+
+	mergeJSON("[1, 2]").send(t, uuid, "labels")
+	if err := datastore.BlockOnUpdating(uuid, "labels"); err != nil {
+		t.Fatalf("Error blocking on update for labels: %v\n", err)
+	}
+
+	if annotation := getBodyAnnotationHTTP(t, uuid, 2); annotation != nil {
+		t.Errorf("expected merged label 2 to have no annotation, got %v\n", annotation)
+	}
+	annotation := getBodyAnnotationHTTP(t, uuid, 1)
+	if annotation["status"] != "traced" || annotation["notes"] != "check" || annotation["type"] != "KC" {
+		t.Errorf("unexpected merged annotation: %v\n", annotation)
+	}
+	if _, found := annotation[ConflictsField]; !found {
+		t.Errorf("expected conflicts in merged annotation: %v\n", annotation)
+	}
+
+	reqStr := fmt.Sprintf("%snode/%s/labels/body-annotations/query?type=KC", server.WebAPIPath, uuid)
+	r := server.TestHTTP(t, "GET", reqStr, nil)
+	var labelList []uint64
+	if err := json.Unmarshal(r, &labelList); err != nil {
+		t.Fatal(err)
+	}
+	if len(labelList) != 1 || labelList[0] != 1 {
+		t.Errorf("expected query to return label 1, got %v\n", labelList)
+	}
+
+	reqStr = fmt.Sprintf("%snode/%s/labels/body-annotation/1", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "DELETE", reqStr, nil)
+	if annotation := getBodyAnnotationHTTP(t, uuid, 1); annotation != nil {
+		t.Errorf("expected deleted annotation for label 1, got %v\n", annotation)
+	}
+}
+
+func TestBodyAnnotationCleaveSplit(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+	var config dvid.Config
+	config.Set("BodyAnnotationMerge", "union")
+	server.CreateTestInstance(t, uuid, "labelmap", "labels", config)
+
+	volume := newMigrationTestVolume()
+	volume.put(t, uuid, "labels")
+	if err := datastore.BlockOnUpdating(uuid, "labels"); err != nil {
+		t.Fatalf("Error blocking on update for labels: %v\n", err)
+	}
+
+	postBodyAnnotation(t, uuid, 1, `{"status": "traced"}`)
+	postBodyAnnotation(t, uuid, 13, `{"status": "orphan", "type": "KC"}`)
+
+	mergeJSON("[1, 2]").send(t, uuid, "labels")
+	if err := datastore.BlockOnUpdating(uuid, "labels"); err != nil {
+		t.Fatalf("Error blocking on update for labels: %v\n", err)
+	}
+
+	// Cleave supervoxel 2 back out of body 1.
+	reqStr := fmt.Sprintf("%snode/%s/labels/cleave/1", server.WebAPIPath, uuid)
+	r := server.TestHTTP(t, "POST", reqStr, strings.NewReader("[2]"))
+	var cleaveResp struct {
+		CleavedLabel uint64
+	}
+	if err := json.Unmarshal(r, &cleaveResp); err != nil {
+		t.Fatalf("Unable to get new label from cleave.  Instead got: %s\n", string(r))
+	}
+	if err := datastore.BlockOnUpdating(uuid, "labels"); err != nil {
+		t.Fatalf("Error blocking on update for labels: %v\n", err)
+	}
+	expected := BodyAnnotation{"status": "traced"}
+	if annotation := getBodyAnnotationHTTP(t, uuid, cleaveResp.CleavedLabel); !reflect.DeepEqual(annotation, expected) {
+		t.Errorf("expected cleaved label %d to have annotation %v, got %v\n", cleaveResp.CleavedLabel, expected, annotation)
+	}
+	if annotation := getBodyAnnotationHTTP(t, uuid, 1); !reflect.DeepEqual(annotation, expected) {
+		t.Errorf("expected cleaved body 1 to keep annotation %v, got %v\n", expected, annotation)
+	}
+
+	// Split the lower half in x of label 13 into a new label.
+	var rles dvid.RLEs
+	for z := int32(40); z < 80; z++ {
+		for y := int32(40); y < 80; y++ {
+			rles = append(rles, dvid.NewRLE(dvid.Point3d{80, y, z}, 20))
+		}
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteByte(dvid.EncodingBinary)
+	binary.Write(buf, binary.LittleEndian, uint8(3))          // # of dimensions
+	binary.Write(buf, binary.LittleEndian, byte(0))           // dimension of run (X = 0)
+	buf.WriteByte(byte(0))                                    // reserved for later
+	binary.Write(buf, binary.LittleEndian, uint32(0))         // Placeholder for # voxels
+	binary.Write(buf, binary.LittleEndian, uint32(len(rles))) // Placeholder for # spans
+	rleBytes, err := rles.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Unable to serialize RLEs: %v\n", err)
+	}
+	buf.Write(rleBytes)
+
+	reqStr = fmt.Sprintf("%snode/%s/labels/split/13", server.WebAPIPath, uuid)
+	r = server.TestHTTP(t, "POST", reqStr, buf)
+	splitResp := make(map[string]uint64)
+	if err := json.Unmarshal(r, &splitResp); err != nil {
+		t.Fatalf("Unable to get new label from split.  Instead got: %s\n", string(r))
+	}
+	splitLabel, ok := splitResp["label"]
+	if !ok {
+		t.Fatalf("The split request did not yield label value.  Instead got: %v\n", splitResp)
+	}
+	if err := datastore.BlockOnUpdating(uuid, "labels"); err != nil {
+		t.Fatalf("Error blocking on update for labels: %v\n", err)
+	}
+	expected = BodyAnnotation{"status": "orphan", "type": "KC"}
+	if annotation := getBodyAnnotationHTTP(t, uuid, splitLabel); !reflect.DeepEqual(annotation, expected) {
+		t.Errorf("expected split label %d to have annotation %v, got %v\n", splitLabel, expected, annotation)
+	}
+	if annotation := getBodyAnnotationHTTP(t, uuid, 13); !reflect.DeepEqual(annotation, expected) {
+		t.Errorf("expected split body 13 to keep annotation %v, got %v\n", expected, annotation)
+	}
+	if annotation := getBodyAnnotationHTTP(t, uuid, cleaveResp.CleavedLabel); annotation["status"] != "traced" {
+		t.Errorf("expected split to leave cleaved label %d annotation alone, got %v\n", cleaveResp.CleavedLabel, annotation)
+	}
+	if annotation := getBodyAnnotationHTTP(t, uuid, 209); annotation != nil {
+		t.Errorf("expected unannotated label 209 to have no annotation, got %v\n", annotation)
+	}
+}
@@ -586,3 +586,31 @@ func addSupervoxelSplitToMapping(d dvid.Data, v dvid.VersionID, op labels.SplitS
 	}
 	return labels.LogMapping(d, v, mapOp)
 }
+
+// adds erased supervoxels, i.e., those without any remaining voxels, into the equivalence map
+// for a given instance version by mapping them to 0, and also records the mappings into the log.
+func addEraseToMapping(d dvid.Data, v dvid.VersionID, mutID uint64, supervoxels labels.Set) error {
+	if len(supervoxels) == 0 {
+		return nil
+	}
+	m, err := getMapping(d, v)
+	if err != nil {
+		return err
+	}
+	m.Lock()
+	vid, err := m.createShortVersion(v)
+	if err != nil {
+		m.Unlock()
+		return err
+	}
+	for supervoxel := range supervoxels {
+		m.setMapping(vid, supervoxel, 0)
+	}
+	m.Unlock()
+	mapOp := labels.MappingOp{
+		MutID:    mutID,
+		Mapped:   0,
+		Original: supervoxels,
+	}
+	return labels.LogMapping(d, v, mapOp)
+}
@@ -25,6 +25,9 @@ const (
 	// key = label.  value = datatype/common/proto/AffinityTable serialization
 	keyAffinities = 188
 
+	// key = label.  value = JSON body annotation
+	keyBodyAnnotation = 189
+
 	// Used to store max label on commit for each version of the instance.
 	keyLabelMax = 237
 
@@ -42,6 +45,8 @@ func (d *Data) DescribeTKeyClass(tkc storage.TKeyClass) string {
 		return "labelmap label index key"
 	case keyAffinities:
 		return "labelmap affinities key"
+	case keyBodyAnnotation:
+		return "labelmap body annotation key"
 	case keyLabelMax:
 		return "labelmap label max key"
 	case keyRepoLabelMax:
@@ -123,3 +128,20 @@ func DecodeAffinitiesTKey(tk storage.TKey) (label uint64, err error) {
 	label = binary.BigEndian.Uint64(ibytes[0:8])
 	return
 }
+
+// NewBodyAnnotationTKey returns a TKey corresponding to a label's body annotation.
+func NewBodyAnnotationTKey(label uint64) storage.TKey {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, label)
+	return storage.NewTKey(keyBodyAnnotation, buf)
+}
+
+// DecodeBodyAnnotationTKey parses a TKey and returns the corresponding label.
+func DecodeBodyAnnotationTKey(tk storage.TKey) (label uint64, err error) {
+	ibytes, err := tk.ClassBytes(keyBodyAnnotation)
+	if err != nil {
+		return
+	}
+	label = binary.BigEndian.Uint64(ibytes[0:8])
+	return
+}
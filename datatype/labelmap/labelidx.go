@@ -260,6 +260,36 @@ func GetSupervoxelBlocks(d dvid.Data, v dvid.VersionID, supervoxel uint64) (dvid
 	return blocks, nil
 }
 
+// GetLabelBlocks returns the coordinates of blocks at the given scale that contain any of the
+// labels, along with the size of those blocks in scale 0 voxels.  The blocks are computed from
+// the label indices so the scale need not be stored.  Implements the roi.LabelBlockSource interface.
+func (d *Data) GetLabelBlocks(v dvid.VersionID, lbls []uint64, scale uint8) (dvid.IZYXSlice, dvid.Point3d, error) {
+	blockSize, ok := d.BlockSize().(dvid.Point3d)
+	if !ok {
+		return nil, dvid.Point3d{}, fmt.Errorf("block size for data %q should be 3d, not: %s", d.DataName(), d.BlockSize())
+	}
+	if scale > 30 {
+		return nil, dvid.Point3d{}, fmt.Errorf("bad scale %d for label blocks", scale)
+	}
+	var blocks dvid.IZYXSlice
+	for _, label := range lbls {
+		idx, err := GetLabelIndex(d, v, label, false)
+		if err != nil {
+			return nil, dvid.Point3d{}, err
+		}
+		if idx == nil {
+			return nil, dvid.Point3d{}, fmt.Errorf("label %d not found in data %q", label, d.DataName())
+		}
+		indices, err := idx.GetProcessedBlockIndices(scale, dvid.Bounds{})
+		if err != nil {
+			return nil, dvid.Point3d{}, err
+		}
+		blocks = append(blocks, indices...)
+	}
+	mult := int32(1) << scale
+	return blocks, dvid.Point3d{blockSize[0] * mult, blockSize[1] * mult, blockSize[2] * mult}, nil
+}
+
 // GetLabelSize returns the # of voxels in the given label.  If isSupervoxel = true, the given
 // label is interpreted as a supervoxel id and the size is of a supervoxel.  If a label doesn't
 // exist, a zero (not error) is returned.
@@ -423,29 +453,64 @@ func CleaveIndex(d dvid.Data, v dvid.VersionID, op labels.CleaveOp, info dvid.Mo
 
 // ChangeLabelIndex applies changes to a label's index and then stores the result.
 // Supervoxel size changes for blocks should be passed into the function.  The passed
-// SupervoxelDelta can contain more supervoxels than the label index.
+// SupervoxelDelta can contain more supervoxels than the label index.  Any change in
+// the label's size is sent to subscribers as a ChangeSizeEvent.
 func ChangeLabelIndex(d dvid.Data, v dvid.VersionID, label uint64, delta labels.SupervoxelChanges) error {
+	oldSize, newSize, err := changeLabelIndex(d, v, label, delta)
+	if err != nil {
+		return err
+	}
+	if oldSize != newSize {
+		notifySizeChange(d, v, label, oldSize, newSize)
+	}
+	return nil
+}
+
+func changeLabelIndex(d dvid.Data, v dvid.VersionID, label uint64, delta labels.SupervoxelChanges) (oldSize, newSize uint64, err error) {
 	shard := label % numIndexShards
 	indexMu[shard].Lock()
 	defer indexMu[shard].Unlock()
 
-	idx, err := getCachedLabelIndex(d, v, label)
-	if err != nil {
-		return err
+	var idx *labels.Index
+	if idx, err = getCachedLabelIndex(d, v, label); err != nil {
+		return
 	}
 	if idx == nil {
 		idx = new(labels.Index)
 		idx.Label = label
 	}
+	oldSize = idx.NumVoxels()
 
-	if err := idx.ModifyBlocks(label, delta); err != nil {
-		return err
+	if err = idx.ModifyBlocks(label, delta); err != nil {
+		return
 	}
 
 	if len(idx.Blocks) == 0 {
-		return deleteCachedLabelIndex(d, v, label)
+		err = deleteCachedLabelIndex(d, v, label)
+		return
+	}
+	newSize = idx.NumVoxels()
+	err = putCachedLabelIndex(d, v, idx)
+	return
+}
+
+// notifySizeChange sends the appropriate size delta for a label to subscribers
+// of ChangeSizeEvent.
+func notifySizeChange(d dvid.Data, v dvid.VersionID, label, oldSize, newSize uint64) {
+	var delta interface{}
+	switch {
+	case newSize == 0:
+		delta = labels.DeltaDeleteSize{Label: label, OldSize: oldSize, OldKnown: true}
+	case oldSize == 0:
+		delta = labels.DeltaNewSize{Label: label, Size: newSize}
+	default:
+		delta = labels.DeltaReplaceSize{Label: label, OldSize: oldSize, NewSize: newSize}
+	}
+	evt := datastore.SyncEvent{d.DataUUID(), labels.ChangeSizeEvent}
+	msg := datastore.SyncMessage{labels.ChangeSizeEvent, v, delta}
+	if err := datastore.NotifySubscribers(evt, msg); err != nil {
+		dvid.Errorf("can't notify subscribers for event %v: %v\n", evt, err)
 	}
-	return putCachedLabelIndex(d, v, idx)
 }
 
 func (d *Data) verifyMappings(ctx *datastore.VersionedCtx, supervoxels, mapped []uint64) (verified []uint64, err error) {
@@ -1418,6 +1483,59 @@ func (d *Data) writeMappings(w io.Writer, v dvid.VersionID) error {
 	return nil
 }
 
+// writeMappingDiff streams all supervoxels whose mapped label differs between two versions,
+// along with the mapped label in each version.  Supervoxels without a mapping in a version
+// are considered mapped to themselves.  If format is "csv", each supervoxel is written as a
+// line "supervoxel,labelA,labelB".  Otherwise each supervoxel is written as three little-endian
+// uint64 in the same order.
+func (d *Data) writeMappingDiff(w io.Writer, vA, vB dvid.VersionID, format string) (numDiffs uint64, err error) {
+	svm, err := getMapping(d, vA)
+	if err != nil {
+		return 0, fmt.Errorf("unable to retrieve mappings for data %q, version %d: %v", d.DataName(), vA, err)
+	}
+	if _, err = getMapping(d, vB); err != nil {
+		return 0, fmt.Errorf("unable to retrieve mappings for data %q, version %d: %v", d.DataName(), vB, err)
+	}
+	ancestryA, err := svm.getLockedAncestry(vA)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get ancestry for data %q, version %d: %v", d.DataName(), vA, err)
+	}
+	ancestryB, err := svm.getLockedAncestry(vB)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get ancestry for data %q, version %d: %v", d.DataName(), vB, err)
+	}
+	svm.RLock()
+	defer svm.RUnlock()
+
+	buf := make([]byte, 24)
+	for supervoxel, vm := range svm.fm {
+		labelA, present := vm.value(ancestryA)
+		if !present {
+			labelA = supervoxel
+		}
+		labelB, present := vm.value(ancestryB)
+		if !present {
+			labelB = supervoxel
+		}
+		if labelA == labelB {
+			continue
+		}
+		if format == "csv" {
+			_, err = fmt.Fprintf(w, "%d,%d,%d\n", supervoxel, labelA, labelB)
+		} else {
+			binary.LittleEndian.PutUint64(buf[0:8], supervoxel)
+			binary.LittleEndian.PutUint64(buf[8:16], labelA)
+			binary.LittleEndian.PutUint64(buf[16:24], labelB)
+			_, err = w.Write(buf)
+		}
+		if err != nil {
+			return numDiffs, fmt.Errorf("unable to write mapping diff of supervoxel %d, data %q: %v", supervoxel, d.DataName(), err)
+		}
+		numDiffs++
+	}
+	return numDiffs, nil
+}
+
 func (d *Data) indexThread(f *os.File, mu *sync.Mutex, wg *sync.WaitGroup, chunkCh chan *storage.Chunk) {
 	for c := range chunkCh {
 		label, err := DecodeLabelIndexTKey(c.K)
@@ -3,6 +3,7 @@ package labelmap
 import (
 	"bytes"
 	"compress/gzip"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -336,3 +337,71 @@ func TestIngest2(t *testing.T) {
 	apiStr = fmt.Sprintf("%snode/%s/labels/blocks?noindexing=true", server.WebAPIPath, uuid)
 	server.TestBadHTTP(t, "POST", apiStr, &buf)
 }
+
+func TestMappingDiff(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	root, _ := initTestRepo()
+	var config dvid.Config
+	server.CreateTestInstance(t, root, "labelmap", "labels", config)
+
+	volume := newTestVolume(128, 128, 128)
+	volume.addSubvol(dvid.Point3d{40, 40, 40}, dvid.Point3d{40, 40, 40}, 1)
+	volume.addSubvol(dvid.Point3d{40, 40, 80}, dvid.Point3d{40, 40, 40}, 2)
+	volume.addSubvol(dvid.Point3d{80, 40, 40}, dvid.Point3d{40, 40, 40}, 3)
+	volume.put(t, root, "labels")
+	if err := datastore.BlockOnUpdating(root, "labels"); err != nil {
+		t.Fatalf("Error blocking on sync of labels: %v\n", err)
+	}
+	mergeJSON("[1, 3]").send(t, root, "labels")
+
+	payload := bytes.NewBufferString(`{"note": "first merge"}`)
+	commitReq := fmt.Sprintf("%snode/%s/commit", server.WebAPIPath, root)
+	server.TestHTTP(t, "POST", commitReq, payload)
+
+	newVersionReq := fmt.Sprintf("%snode/%s/newversion", server.WebAPIPath, root)
+	respData := server.TestHTTP(t, "POST", newVersionReq, nil)
+	resp := struct {
+		Child string `json:"child"`
+	}{}
+	if err := json.Unmarshal(respData, &resp); err != nil {
+		t.Fatalf("Expected 'child' JSON response.  Got %s\n", string(respData))
+	}
+	child := dvid.UUID(resp.Child)
+	mergeJSON("[2, 1]").send(t, child, "labels")
+
+	reqStr := fmt.Sprintf("%snode/%s/labels/mapping-diff/%s?format=csv", server.WebAPIPath, root, child)
+	r := server.TestHTTP(t, "GET", reqStr, nil)
+	lines := strings.Split(strings.TrimSpace(string(r)), "\n")
+	expected := map[string]bool{"1,1,2": true, "3,1,2": true}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines in mapping diff, got: %s\n", len(expected), string(r))
+	}
+	for _, line := range lines {
+		if !expected[line] {
+			t.Errorf("unexpected mapping diff line %q, full diff: %s\n", line, string(r))
+		}
+	}
+
+	reqStr = fmt.Sprintf("%snode/%s/labels/mapping-diff/%s", server.WebAPIPath, child, root)
+	r = server.TestHTTP(t, "GET", reqStr, nil)
+	if len(r) != 2*24 {
+		t.Fatalf("expected 2 binary mapping diff records, got %d bytes\n", len(r))
+	}
+	for i := 0; i < 2; i++ {
+		supervoxel := binary.LittleEndian.Uint64(r[i*24 : i*24+8])
+		labelA := binary.LittleEndian.Uint64(r[i*24+8 : i*24+16])
+		labelB := binary.LittleEndian.Uint64(r[i*24+16 : i*24+24])
+		if (supervoxel != 1 && supervoxel != 3) || labelA != 2 || labelB != 1 {
+			t.Errorf("bad binary mapping diff record: supervoxel %d, labels %d -> %d\n", supervoxel, labelA, labelB)
+		}
+	}
+
+	reqStr = fmt.Sprintf("%snode/%s/labels/mapping-diff/%s", server.WebAPIPath, root, root)
+	if r = server.TestHTTP(t, "GET", reqStr, nil); len(r) != 0 {
+		t.Errorf("expected no mapping diff for same version, got %d bytes\n", len(r))
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"compress/gzip"
 
 	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/datatype/common/chunked"
 	"github.com/janelia-flyem/dvid/datatype/common/downres"
 	"github.com/janelia-flyem/dvid/datatype/common/labels"
 	"github.com/janelia-flyem/dvid/datatype/common/proto"
@@ -77,6 +78,8 @@ $ dvid repo <UUID> new labelmap <data name> <settings...>
     VoxelUnits      Resolution units (default: "nanometers")
 	IndexedLabels   "false" if no sparse volume support is required (default "true")
 	MaxDownresLevel  The maximum down-res level supported.  Each down-res is factor of 2.
+	BodyAnnotationMerge  How body annotations are combined on merges: "keep-target" (default),
+	                  "union", or "flag-conflict".  See the body-annotation endpoint.
 
 $ dvid node <UUID> <data name> load <offset> <image glob> <settings...>
 
@@ -142,6 +145,79 @@ $ dvid node <UUID> <data name> dump <dump type> <file path>
 	data name     Name of data to add.
 	dump type     One of "svcount", "mappings", or "indices".
 	file path     Absolute path to a writable file that the dvid server has write privileges to.
+
+$ dvid node <UUID> <data name> export <format> <directory path> <settings...>
+
+	Asynchronously exports all scales of the specified version of labelmap data into a
+	directory-based chunked array store.  Each scale is written as an array named "s0", "s1", ...
+	with chunk shape equal to the block size.  Voxel resolution, downsampling factors and the
+	DVID voxel offset of each array are written as attributes so the data can be imported back
+	into the same location.  Agglomerated body labels are exported unless "supervoxels=true".
+
+    Example: 
+
+    $ dvid node 3f8c segmentation export zarr /path/to/segmentation.zarr compression=raw
+
+    Arguments:
+
+    UUID            Hexidecimal string with enough characters to uniquely identify a version node.
+	data name       Name of data to export.
+	format          Either "zarr" (Zarr v2 with OME-NGFF multiscales metadata) or "n5".
+	directory path  Absolute path to a directory that the dvid server has write privileges to.
+
+    Configuration Settings (case-insensitive keys)
+
+	compression     "gzip" (default) or "raw" chunk compression.
+	supervoxels     If "true", exports unmapped supervoxel ids.  (Default "false")
+
+$ dvid node <UUID> <data name> import <directory path> <settings...>
+
+	Asynchronously imports a Zarr v2 or N5 store with 3d unsigned integer arrays named "s0", "s1", ...
+	whose chunk shape is equal to the block size of the labelmap.  The format is detected from
+	the store's metadata.  If only the "s0" array is present, lower resolution scales are computed.
+	Label indices are computed from the scale 0 data.
+
+    Example: 
+
+    $ dvid node 3f8c segmentation import /path/to/segmentation.n5 offset=0,0,1024
+
+    Arguments:
+
+    UUID            Hexidecimal string with enough characters to uniquely identify a version node.
+	data name       Name of data to import into.
+	directory path  Absolute path to a store directory readable by the dvid server.
+
+    Configuration Settings (case-insensitive keys)
+
+	offset          Block-aligned voxel coordinate "x,y,z" of the first voxel of "s0".  Ignored
+	                  if the arrays were exported by DVID and have a "dvidOffset" attribute.
+	                  Arrays of lower scales without the attribute start at the "s0" offset
+	                  divided by the scale's downsampling factor, which must be block aligned.
+	                  (Default 0,0,0)
+
+$ dvid node <UUID> <data name> migrate <source name> <settings...>
+
+	Asynchronously migrates a legacy labelblk or labelarray instance in the same repo into this
+	labelmap, which must be empty and have the same block size.  The source labels as seen at the
+	given version become supervoxels with identity mapping, and label indices are computed.
+	A labelarray's downres levels are copied and require an equal MaxDownresLevel, while a labelblk's
+	downres levels are computed.  If a labelvol instance is specified or synced to a labelblk source,
+	the computed indices are checked against its sparse volumes.  After migration, any annotation or
+	labelsz instance synced to the legacy instances is re-synced to this labelmap.
+
+    Example: 
+
+    $ dvid node 3f8c segmentation migrate bodies labelvol=sparsevols
+
+    Arguments:
+
+    UUID            Hexidecimal string with enough characters to uniquely identify a version node.
+	data name       Name of an empty labelmap to receive the migrated data.
+	source name     Name of the labelblk or labelarray instance to migrate.
+
+    Configuration Settings (case-insensitive keys)
+
+	labelvol        Name of a labelvol instance holding sparse volumes of the source labels.
 	
 	
     ------------------
@@ -493,6 +569,40 @@ GET <api URL>/node/<UUID>/<data name>/mapping[?queryopts]
 	                the label indices.  Only use this if supervoxels were known to exist at some time.
     hash          MD5 hash of request body content in hexidecimal string format.
 
+GET <api URL>/node/<UUID>/<data name>/body-annotation/<label>
+POST <api URL>/node/<UUID>/<data name>/body-annotation/<label>
+DELETE <api URL>/node/<UUID>/<data name>/body-annotation/<label>
+
+	Gets, stores, or deletes a JSON object holding metadata for a body label, e.g.,
+	{"status": "traced", "type": "KC"}.  A GET returns status code 404 (Not Found) if 
+	the label has no annotation.  A POST replaces any existing annotation.
+
+	Body annotations follow labels through mutations according to the instance's 
+	"BodyAnnotationMerge" policy, which can be set on creation or by POSTing a
+	configuration to the data instance:
+
+	keep-target     On merge, the target label keeps its annotation and the annotations
+	                  of merged labels are deleted.  New labels from cleaves or splits
+	                  have no annotation.  (Default)
+	union           On merge, fields of merged labels missing from the target annotation
+	                  are added.  New labels from cleaves or splits get a copy of the 
+	                  original label's annotation.
+	flag-conflict   Like "union", but any field with differing values keeps the target's 
+	                  value and all values are listed under that field name in the 
+	                  "conflicts" object, e.g., "conflicts": {"status": ["traced", "orphan"]}
+
+GET <api URL>/node/<UUID>/<data name>/body-annotations
+
+	Returns a JSON array of body annotations, with null for any label without annotation,
+	for the labels given as a JSON array in the request body, e.g., [1, 23, 40].
+
+GET <api URL>/node/<UUID>/<data name>/body-annotations/query?<field>=<value>...
+
+	Returns a sorted JSON array of labels whose body annotations have all the given field
+	values.  String fields are compared directly while other values are compared using 
+	their JSON encoding, e.g., "?status=traced&verified=true".  A field holding an array 
+	matches if any element matches.
+
 GET <api URL>/node/<UUID>/<data name>/supervoxel-splits
 
 	Returns JSON for all supervoxel splits that have occured up to this version of the
@@ -1026,6 +1136,51 @@ POST <api URL>/node/<UUID>/<data name>/cleave/<label>
 	}
 
 
+POST <api URL>/node/<UUID>/<data name>/erase/<label>
+
+	Erases a label, e.g., a glia or artifact segment, by setting all its voxels to 0 (background)
+	across all scales.  Its label index is removed and its supervoxels are mapped to 0 so they
+	no longer exist in this and descendant versions.  Returns the following JSON:
+
+		{ "ErasedVoxels": <number of voxels set to 0> }
+
+	A bad request error (status 400) will be returned if the label does not exist.
+
+	Query-string Options:
+
+	supervoxels   If "true", the label is a supervoxel id and only that supervoxel is erased
+	                from its body.
+	minx    Only erase voxels with X coordinate >= minx.
+	maxx    Only erase voxels with X coordinate <= maxx.
+	miny    Only erase voxels with Y coordinate >= miny.
+	maxy    Only erase voxels with Y coordinate <= maxy.
+	minz    Only erase voxels with Z coordinate >= minz.
+	maxz    Only erase voxels with Z coordinate <= maxz.
+
+	If bounds are given, only voxels within the bounds are erased.  The label index is 
+	modified accordingly and only supervoxels without any remaining voxels are mapped to 0.
+	Each erase, bounded or not, is recorded in the mutation log with its label, bounds,
+	erased supervoxels, and mutation ID.
+
+	Kafka JSON message generated by this request at its initiation:
+		{ 
+			"Action": "erase",
+			"Label": <erased label>,
+			"IsSupervoxel": <true if label is a supervoxel>,
+			"Supervoxels": [<supervoxel 1>, <supervoxel 2>, ...],
+			"Bounds": { "minx": <minx>, ... },  (only present if bounds were given)
+			"UUID": <UUID on which erase was done>,
+			"MutationID": <unique id for mutation>
+		}
+
+	After the erase is successfully completed, the following JSON message is logged with Kafka:
+	{ 
+		"Action": "erase-complete",
+		"UUID": <UUID on which erase was done>,
+		"MutationID": <unique id for mutation>
+	}
+
+
 POST <api URL>/node/<UUID>/<data name>/split-supervoxel/<supervoxel>
 
 	Splits a portion of a supervoxel into two new supervoxels, both of which will still be mapped
@@ -1193,6 +1348,25 @@ GET <api URL>/node/<UUID>/<data name>/mappings
 	
 	Note that only non-identity mappings are transmitted.
 
+GET <api URL>/node/<UUID>/<data name>/mapping-diff/<UUID B>[?format=csv]
+
+	Streams all supervoxels whose mapped label differs between the given UUID (A) and
+	UUID B, e.g., to review agglomeration changes between two nodes.  Supervoxels without 
+	a mapping in a version map to themselves, so supervoxels split in one version are listed 
+	with a 0 label for that version.  Supervoxels are streamed in no particular order.
+
+	By default, the data is a binary stream where each supervoxel is described by
+	three little-endian uint64:
+
+		uint64 supervoxel
+		uint64 mapped label in UUID A
+		uint64 mapped label in UUID B
+
+	Query-string Options:
+
+	format    If "csv", each supervoxel is written as a line "supervoxel,labelA,labelB".
+	            The default "binary" gives the stream described above.
+
 POST <api URL>/node/<UUID>/<data name>/mappings
 
 	Allows direct storing of merge maps for a particular UUID.  Typically, merge
@@ -1389,6 +1563,9 @@ type Data struct {
 	// the higher level.
 	MaxDownresLevel uint8
 
+	// How body annotations are combined on merges and propagated on cleaves and splits.
+	BodyAnnotationMerge BodyAnnotationPolicy
+
 	updates  []uint32 // tracks updating to each scale of labelmap [0:MaxDownresLevel+1]
 	updateMu sync.RWMutex
 
@@ -1454,6 +1631,7 @@ func (d *Data) CopyPropertiesFrom(src datastore.DataService, fs storage.FilterSp
 
 	d.IndexedLabels = d2.IndexedLabels
 	d.MaxDownresLevel = d2.MaxDownresLevel
+	d.BodyAnnotationMerge = d2.BodyAnnotationMerge
 
 	return d.Data.CopyPropertiesFrom(d2.Data, fs)
 }
@@ -1496,9 +1674,21 @@ func NewData(uuid dvid.UUID, id dvid.InstanceID, name dvid.InstanceName, c dvid.
 	}
 	data.updates = make([]uint32, downresLevels+1)
 
+	policy := KeepTargetAnnotation
+	s, found, err := c.GetString("BodyAnnotationMerge")
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		if policy, err = ParseBodyAnnotationPolicy(s); err != nil {
+			return nil, err
+		}
+	}
+
 	data.MaxLabel = make(map[dvid.VersionID]uint64)
 	data.IndexedLabels = indexedLabels
 	data.MaxDownresLevel = downresLevels
+	data.BodyAnnotationMerge = policy
 
 	data.Initialize()
 	return data, nil
@@ -1506,10 +1696,11 @@ func NewData(uuid dvid.UUID, id dvid.InstanceID, name dvid.InstanceName, c dvid.
 
 type propsJSON struct {
 	imageblk.Properties
-	MaxLabel        map[dvid.VersionID]uint64
-	MaxRepoLabel    uint64
-	IndexedLabels   bool
-	MaxDownresLevel uint8
+	MaxLabel            map[dvid.VersionID]uint64
+	MaxRepoLabel        uint64
+	IndexedLabels       bool
+	MaxDownresLevel     uint8
+	BodyAnnotationMerge BodyAnnotationPolicy
 }
 
 func (d *Data) MarshalJSON() ([]byte, error) {
@@ -1519,11 +1710,12 @@ func (d *Data) MarshalJSON() ([]byte, error) {
 	}{
 		d.Data.Data,
 		propsJSON{
-			Properties:      d.Data.Properties,
-			MaxLabel:        d.MaxLabel,
-			MaxRepoLabel:    d.MaxRepoLabel,
-			IndexedLabels:   d.IndexedLabels,
-			MaxDownresLevel: d.MaxDownresLevel,
+			Properties:          d.Data.Properties,
+			MaxLabel:            d.MaxLabel,
+			MaxRepoLabel:        d.MaxRepoLabel,
+			IndexedLabels:       d.IndexedLabels,
+			MaxDownresLevel:     d.MaxDownresLevel,
+			BodyAnnotationMerge: d.BodyAnnotationMerge,
 		},
 	})
 }
@@ -1550,11 +1742,12 @@ func (d *Data) MarshalJSONExtents(ctx *datastore.VersionedCtx) ([]byte, error) {
 	}{
 		d.Data.Data,
 		propsJSON{
-			Properties:      props,
-			MaxLabel:        d.MaxLabel,
-			MaxRepoLabel:    d.MaxRepoLabel,
-			IndexedLabels:   d.IndexedLabels,
-			MaxDownresLevel: d.MaxDownresLevel,
+			Properties:          props,
+			MaxLabel:            d.MaxLabel,
+			MaxRepoLabel:        d.MaxRepoLabel,
+			IndexedLabels:       d.IndexedLabels,
+			MaxDownresLevel:     d.MaxDownresLevel,
+			BodyAnnotationMerge: d.BodyAnnotationMerge,
 		},
 		extentsJSON,
 	})
@@ -1574,6 +1767,10 @@ func (d *Data) GobDecode(b []byte) error {
 		dvid.Errorf("Decoding labelmap %q: no MaxDownresLevel, setting to 7", d.DataName())
 		d.MaxDownresLevel = 7
 	}
+	if err := dec.Decode(&(d.BodyAnnotationMerge)); err != nil {
+		dvid.Infof("Decoding labelmap %q: no BodyAnnotationMerge, setting to %s\n", d.DataName(), KeepTargetAnnotation)
+		d.BodyAnnotationMerge = KeepTargetAnnotation
+	}
 	d.updates = make([]uint32, d.MaxDownresLevel+1)
 	return nil
 }
@@ -1590,9 +1787,29 @@ func (d *Data) GobEncode() ([]byte, error) {
 	if err := enc.Encode(d.MaxDownresLevel); err != nil {
 		return nil, err
 	}
+	if err := enc.Encode(d.BodyAnnotationMerge); err != nil {
+		return nil, err
+	}
 	return buf.Bytes(), nil
 }
 
+// ModifyConfig sets the body annotation merge policy if given and passes other
+// settings to the embedded imageblk data.
+func (d *Data) ModifyConfig(config dvid.Config) error {
+	s, found, err := config.GetString("BodyAnnotationMerge")
+	if err != nil {
+		return err
+	}
+	if found {
+		policy, err := ParseBodyAnnotationPolicy(s)
+		if err != nil {
+			return err
+		}
+		d.BodyAnnotationMerge = policy
+	}
+	return d.Data.ModifyConfig(config)
+}
+
 // makes database call for any update
 func (d *Data) updateMaxLabel(v dvid.VersionID, label uint64) error {
 	var changed bool
@@ -2614,6 +2831,100 @@ func (d *Data) DoRPC(req datastore.Request, reply *datastore.Response) error {
 		}
 		return nil
 
+	case "export":
+		if len(req.Command) < 6 {
+			return fmt.Errorf("poorly formatted export command.  See command-line help")
+		}
+		var uuidStr, dataName, cmdStr, formatStr, dirPath string
+		req.CommandArgs(1, &uuidStr, &dataName, &cmdStr, &formatStr, &dirPath)
+
+		format, err := chunked.ParseFormat(formatStr)
+		if err != nil {
+			return err
+		}
+		uuid, v, err := datastore.MatchingUUID(uuidStr)
+		if err != nil {
+			return err
+		}
+		config := req.Settings()
+		compression, _, err := config.GetString("compression")
+		if err != nil {
+			return err
+		}
+		gzip, err := chunked.ParseCompression(compression)
+		if err != nil {
+			return err
+		}
+		supervoxels, _, err := config.GetBool("supervoxels")
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := d.ExportChunked(v, dirPath, format, gzip, supervoxels); err != nil {
+				dvid.Errorf("unable to export labelmap %q, uuid %s to %s store %q: %v\n", d.DataName(), uuid, format, dirPath, err)
+			}
+		}()
+		reply.Text = fmt.Sprintf("Asynchronously exporting data %q, uuid %s to %s store: %s\n", d.DataName(), uuid, format, dirPath)
+		return nil
+
+	case "import":
+		if len(req.Command) < 5 {
+			return fmt.Errorf("poorly formatted import command.  See command-line help")
+		}
+		var uuidStr, dataName, cmdStr, dirPath string
+		req.CommandArgs(1, &uuidStr, &dataName, &cmdStr, &dirPath)
+
+		uuid, v, err := datastore.MatchingUUID(uuidStr)
+		if err != nil {
+			return err
+		}
+		var offset dvid.Point3d
+		offsetStr, found, err := req.Settings().GetString("offset")
+		if err != nil {
+			return err
+		}
+		if found {
+			if offset, err = dvid.StringToPoint3d(offsetStr, ","); err != nil {
+				return fmt.Errorf("illegal offset specification %q: %v", offsetStr, err)
+			}
+		}
+		if err = datastore.AddToNodeLog(uuid, []string{req.Command.String()}); err != nil {
+			return err
+		}
+		go func() {
+			if err := d.ImportChunked(v, dirPath, offset); err != nil {
+				dvid.Errorf("unable to import %q into labelmap %q, uuid %s: %v\n", dirPath, d.DataName(), uuid, err)
+			}
+		}()
+		reply.Text = fmt.Sprintf("Asynchronously importing %q into data %q, uuid %s\n", dirPath, d.DataName(), uuid)
+		return nil
+
+	case "migrate":
+		if len(req.Command) < 5 {
+			return fmt.Errorf("poorly formatted migrate command.  See command-line help")
+		}
+		var uuidStr, dataName, cmdStr, sourceName string
+		req.CommandArgs(1, &uuidStr, &dataName, &cmdStr, &sourceName)
+
+		uuid, v, err := datastore.MatchingUUID(uuidStr)
+		if err != nil {
+			return err
+		}
+		labelvolName, _, err := req.Settings().GetString("labelvol")
+		if err != nil {
+			return err
+		}
+		if err = datastore.AddToNodeLog(uuid, []string{req.Command.String()}); err != nil {
+			return err
+		}
+		go func() {
+			if err := d.MigrateLegacy(uuid, v, dvid.InstanceName(sourceName), dvid.InstanceName(labelvolName)); err != nil {
+				dvid.Errorf("unable to migrate %q into labelmap %q, uuid %s: %v\n", sourceName, d.DataName(), uuid, err)
+			}
+		}()
+		reply.Text = fmt.Sprintf("Asynchronously migrating %q into data %q, uuid %s\n", sourceName, d.DataName(), uuid)
+		return nil
+
 	default:
 		return fmt.Errorf("unknown command.  Data type '%s' [%s] does not support '%s' command",
 			d.DataName(), d.TypeName(), req.TypeCommand())
@@ -3058,6 +3369,12 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 	case "mapping":
 		d.handleMapping(ctx, w, r)
 
+	case "body-annotation":
+		d.handleBodyAnnotation(ctx, w, r, parts)
+
+	case "body-annotations":
+		d.handleBodyAnnotations(ctx, w, r, parts)
+
 	case "supervoxel-splits":
 		d.handleSupervoxelSplits(ctx, w, r)
 
@@ -3111,6 +3428,9 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 	case "cleave":
 		d.handleCleave(ctx, w, r, parts)
 
+	case "erase":
+		d.handleErase(ctx, w, r, parts)
+
 	case "split":
 		d.handleSplit(ctx, w, r, parts)
 
@@ -3126,6 +3446,9 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 	case "mappings":
 		d.handleMappings(ctx, w, r)
 
+	case "mapping-diff":
+		d.handleMappingDiff(ctx, w, r, parts)
+
 	default:
 		server.BadAPIRequest(w, r, d)
 	}
@@ -3549,6 +3872,41 @@ func (d *Data) handleMappings(ctx *datastore.VersionedCtx, w http.ResponseWriter
 	}
 }
 
+func (d *Data) handleMappingDiff(ctx *datastore.VersionedCtx, w http.ResponseWriter, r *http.Request, parts []string) {
+	// GET <api URL>/node/<UUID>/<data name>/mapping-diff/<UUID B>[?format=csv]
+	if strings.ToLower(r.Method) != "get" {
+		server.BadRequest(w, r, "only GET action allowed for /mapping-diff endpoint")
+		return
+	}
+	if len(parts) < 5 {
+		server.BadRequest(w, r, "ERROR: DVID requires UUID to follow 'mapping-diff' command")
+		return
+	}
+	timedLog := dvid.NewTimeLog()
+
+	uuidB, vB, err := datastore.MatchingUUID(parts[4])
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "csv":
+		w.Header().Set("Content-type", "text/csv")
+	case "", "binary":
+		w.Header().Set("Content-type", "application/octet-stream")
+	default:
+		server.BadRequest(w, r, "format for /mapping-diff must be \"binary\" or \"csv\", not %q", format)
+		return
+	}
+	numDiffs, err := d.writeMappingDiff(w, ctx.VersionID(), vB, format)
+	if err != nil {
+		server.BadRequest(w, r, "unable to write mapping diff: %v", err)
+		return
+	}
+	timedLog.Infof("HTTP GET mapping diff with %s: %d supervoxels differ (%s)", uuidB, numDiffs, r.URL)
+}
+
 func (d *Data) handlePseudocolor(ctx *datastore.VersionedCtx, w http.ResponseWriter, r *http.Request, parts []string) {
 	if len(parts) < 7 {
 		server.BadRequest(w, r, "'%s' must be followed by shape/size/offset", parts[3])
@@ -3929,6 +4287,133 @@ func (d *Data) handleSizes(ctx *datastore.VersionedCtx, w http.ResponseWriter, r
 	timedLog.Infof("HTTP GET batch sizes query (%s)", r.URL)
 }
 
+func (d *Data) handleBodyAnnotation(ctx *datastore.VersionedCtx, w http.ResponseWriter, r *http.Request, parts []string) {
+	// GET <api URL>/node/<UUID>/<data name>/body-annotation/<label>
+	// POST <api URL>/node/<UUID>/<data name>/body-annotation/<label>
+	// DELETE <api URL>/node/<UUID>/<data name>/body-annotation/<label>
+	if len(parts) < 5 {
+		server.BadRequest(w, r, "DVID requires label to follow 'body-annotation' command")
+		return
+	}
+	timedLog := dvid.NewTimeLog()
+
+	label, err := strconv.ParseUint(parts[4], 10, 64)
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	if label == 0 {
+		server.BadRequest(w, r, "Label 0 is protected background value and cannot be annotated as body.\n")
+		return
+	}
+
+	switch strings.ToLower(r.Method) {
+	case "get":
+		annotation, err := d.GetBodyAnnotation(ctx.VersionID(), label)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		if annotation == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		jsonBytes, err := json.Marshal(annotation)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-type", "application/json")
+		fmt.Fprint(w, string(jsonBytes))
+
+	case "post":
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		var annotation BodyAnnotation
+		if err := json.Unmarshal(data, &annotation); err != nil {
+			server.BadRequest(w, r, "body annotation for label %d must be a JSON object: %v", label, err)
+			return
+		}
+		if err := d.PutBodyAnnotation(ctx.VersionID(), label, annotation); err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+
+	case "delete":
+		if err := d.PutBodyAnnotation(ctx.VersionID(), label, nil); err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+
+	default:
+		server.BadRequest(w, r, "body-annotation endpoint does not support %q action", r.Method)
+		return
+	}
+	timedLog.Infof("HTTP %s body annotation for label %d (%s)", r.Method, label, r.URL)
+}
+
+func (d *Data) handleBodyAnnotations(ctx *datastore.VersionedCtx, w http.ResponseWriter, r *http.Request, parts []string) {
+	// GET <api URL>/node/<UUID>/<data name>/body-annotations
+	// GET <api URL>/node/<UUID>/<data name>/body-annotations/query?<field>=<value>...
+	if strings.ToLower(r.Method) != "get" {
+		server.BadRequest(w, r, "body-annotations endpoint only supports GET requests")
+		return
+	}
+	timedLog := dvid.NewTimeLog()
+
+	if len(parts) > 4 && parts[4] == "query" {
+		query := make(map[string]string)
+		for field, values := range r.URL.Query() {
+			if len(values) != 0 {
+				query[field] = values[0]
+			}
+		}
+		labelList, err := d.QueryBodyAnnotations(ctx.VersionID(), query)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		jsonBytes, err := json.Marshal(labelList)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-type", "application/json")
+		fmt.Fprint(w, string(jsonBytes))
+		timedLog.Infof("HTTP GET body annotation query found %d labels (%s)", len(labelList), r.URL)
+		return
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		server.BadRequest(w, r, "Bad GET request body for batch body annotations: %v", err)
+		return
+	}
+	var labelList []uint64
+	if err := json.Unmarshal(data, &labelList); err != nil {
+		server.BadRequest(w, r, fmt.Sprintf("Bad body annotations request JSON: %v", err))
+		return
+	}
+	annotations := make([]BodyAnnotation, len(labelList))
+	for i, label := range labelList {
+		if annotations[i], err = d.GetBodyAnnotation(ctx.VersionID(), label); err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+	}
+	jsonBytes, err := json.Marshal(annotations)
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	fmt.Fprint(w, string(jsonBytes))
+	timedLog.Infof("HTTP GET batch body annotations for %d labels (%s)", len(labelList), r.URL)
+}
+
 func (d *Data) handleSparsevolSize(ctx *datastore.VersionedCtx, w http.ResponseWriter, r *http.Request, parts []string) {
 	// GET <api URL>/node/<UUID>/<data name>/sparsevol-size/<label>
 	if len(parts) < 5 {
@@ -4328,6 +4813,45 @@ func (d *Data) handleCleave(ctx *datastore.VersionedCtx, w http.ResponseWriter,
 	timedLog.Infof("HTTP cleave of label %d request (%s)", label, r.URL)
 }
 
+func (d *Data) handleErase(ctx *datastore.VersionedCtx, w http.ResponseWriter, r *http.Request, parts []string) {
+	// POST <api URL>/node/<UUID>/<data name>/erase/<label>
+	if strings.ToLower(r.Method) != "post" {
+		server.BadRequest(w, r, "Erase requests must be POST actions.")
+		return
+	}
+	if len(parts) < 5 {
+		server.BadRequest(w, r, "ERROR: DVID requires label ID to follow 'erase' command")
+		return
+	}
+	timedLog := dvid.NewTimeLog()
+
+	label, err := strconv.ParseUint(parts[4], 10, 64)
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	if label == 0 {
+		server.BadRequest(w, r, "Label 0 is protected background value and cannot be erased\n")
+		return
+	}
+	bounds, err := dvid.OptionalBoundsFromQueryString(r)
+	if err != nil {
+		server.BadRequest(w, r, "error parsing bounds from query string: %v", err)
+		return
+	}
+	isSupervoxel := r.URL.Query().Get("supervoxels") == "true"
+	modInfo := dvid.GetModInfo(r)
+	erased, err := d.EraseLabel(ctx.VersionID(), label, isSupervoxel, bounds, modInfo)
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"ErasedVoxels": %d}`, erased)
+
+	timedLog.Infof("HTTP erase of label %d (supervoxel %t) request (%s)", label, isSupervoxel, r.URL)
+}
+
 func (d *Data) handleSplit(ctx *datastore.VersionedCtx, w http.ResponseWriter, r *http.Request, parts []string) {
 	// POST <api URL>/node/<UUID>/<data name>/split/<label>[?splitlabel=X]
 	if strings.ToLower(r.Method) != "post" {
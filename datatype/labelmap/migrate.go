@@ -0,0 +1,327 @@
+/*
+	This file supports migration of legacy labelblk+labelvol and labelarray instances into labelmap.
+*/
+
+package labelmap
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/datatype/common/labels"
+	"github.com/janelia-flyem/dvid/datatype/labelarray"
+	"github.com/janelia-flyem/dvid/datatype/labelblk"
+	"github.com/janelia-flyem/dvid/datatype/labelvol"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// MigrateLegacy copies the labels of a legacy labelblk or labelarray instance as seen at the
+// given version into this labelmap, which must be empty and have the same block size.  Each
+// legacy label becomes a supervoxel with identity mapping.  Label indices are computed from
+// the scale 0 blocks and, if given, checked against the sparse volumes of a labelvol instance.
+// For a labelblk source, the labelvol synced to it is used if none is specified.  Downres
+// levels are copied from a labelarray or computed for a labelblk.  Finally, any annotation
+// or labelsz instance synced to the legacy instances is re-pointed to this labelmap.
+func (d *Data) MigrateLegacy(uuid dvid.UUID, v dvid.VersionID, source, sparsevol dvid.InstanceName) error {
+	timedLog := dvid.NewTimeLog()
+	ctx := datastore.NewVersionedCtx(d, v)
+	extents, err := d.GetExtents(ctx)
+	if err != nil {
+		return err
+	}
+	if extents.MinPoint != nil {
+		return fmt.Errorf("labelmap %q must be empty to receive migrated data", d.DataName())
+	}
+	src, err := datastore.GetDataByUUIDName(uuid, source)
+	if err != nil {
+		return err
+	}
+	instances, err := datastore.GetDataInstances(uuid)
+	if err != nil {
+		return err
+	}
+	var lv *labelvol.Data
+	if sparsevol != "" {
+		if lv, err = labelvol.GetByUUIDName(uuid, sparsevol); err != nil {
+			return err
+		}
+	} else if _, isLabelblk := src.(*labelblk.Data); isLabelblk {
+		for _, data := range instances {
+			candidate, ok := data.(*labelvol.Data)
+			if !ok {
+				continue
+			}
+			if _, found := candidate.SyncedData()[src.DataUUID()]; found {
+				lv = candidate
+				break
+			}
+		}
+	}
+
+	legacy := dvid.UUIDSet{src.DataUUID(): struct{}{}}
+	var maxRepoLabel uint64
+	switch s := src.(type) {
+	case *labelblk.Data:
+		if err := d.checkLegacyBlockSize(s.BlockSize(), source); err != nil {
+			return err
+		}
+		downscale := d.MaxDownresLevel > 0
+		err = d.receiveLegacyBlocks(ctx, 0, downscale, func(w io.Writer) error {
+			return writeLabelblkBlocks(w, s, v)
+		})
+		if err != nil {
+			return err
+		}
+	case *labelarray.Data:
+		if err := d.checkLegacyBlockSize(s.BlockSize(), source); err != nil {
+			return err
+		}
+		if s.MaxDownresLevel != d.MaxDownresLevel {
+			return fmt.Errorf("labelmap %q has MaxDownresLevel %d but labelarray %q has %d", d.DataName(), d.MaxDownresLevel, source, s.MaxDownresLevel)
+		}
+		for scale := uint8(0); scale <= s.MaxDownresLevel; scale++ {
+			err = d.receiveLegacyBlocks(ctx, scale, false, func(w io.Writer) error {
+				return writeLabelarrayBlocks(w, s, v, scale)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		maxRepoLabel = s.MaxRepoLabel
+	default:
+		return fmt.Errorf("can only migrate labelblk or labelarray instances, not %q of type %q", source, src.TypeName())
+	}
+	timedLog.Infof("Migrated blocks of %s %q into labelmap %q", src.TypeName(), source, d.DataName())
+
+	if lv != nil {
+		legacy[lv.DataUUID()] = struct{}{}
+		if lv.MaxRepoLabel > maxRepoLabel {
+			maxRepoLabel = lv.MaxRepoLabel
+		}
+		if d.IndexedLabels {
+			numLabels, numBad, err := d.verifyLabelvolIndices(v, lv)
+			if err != nil {
+				return err
+			}
+			if numBad != 0 {
+				return fmt.Errorf("%d of %d label indices in labelmap %q differ from labelvol %q", numBad, numLabels, d.DataName(), lv.DataName())
+			}
+			timedLog.Infof("Verified %d label indices of labelmap %q against labelvol %q", numLabels, d.DataName(), lv.DataName())
+		}
+	}
+
+	// Keep any labels allocated but not stored in the legacy instances from being reused.
+	if maxRepoLabel != 0 {
+		if err := d.updateMaxLabel(v, maxRepoLabel); err != nil {
+			return err
+		}
+	}
+	return d.repointSyncs(instances, legacy)
+}
+
+// returns an error if the legacy block size differs from this labelmap's block size.
+func (d *Data) checkLegacyBlockSize(blockSize dvid.Point, source dvid.InstanceName) error {
+	srcSize, ok1 := blockSize.(dvid.Point3d)
+	dstSize, ok2 := d.BlockSize().(dvid.Point3d)
+	if !ok1 || !ok2 || !srcSize.Equals(dstSize) {
+		return fmt.Errorf("block size %s of %q doesn't match labelmap %q block size %s", blockSize, source, d.DataName(), d.BlockSize())
+	}
+	return nil
+}
+
+// receiveLegacyBlocks pipes blocks written by the given function into ReceiveBlocks
+// for the given scale, indexing the labels of scale 0.
+func (d *Data) receiveLegacyBlocks(ctx *datastore.VersionedCtx, scale uint8, downscale bool, write func(io.Writer) error) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(write(pw))
+	}()
+	if err := d.ReceiveBlocks(ctx, pr, scale, downscale, "blocks", d.IndexedLabels && scale == 0); err != nil {
+		pr.CloseWithError(err)
+		return fmt.Errorf("error migrating scale %d into labelmap %q: %v", scale, d.DataName(), err)
+	}
+	return nil
+}
+
+// writeLabelblkBlocks streams all blocks of a labelblk version in the format read by
+// ReceiveBlocks.
+func writeLabelblkBlocks(w io.Writer, src *labelblk.Data, v dvid.VersionID) error {
+	blockSize, ok := src.BlockSize().(dvid.Point3d)
+	if !ok {
+		return fmt.Errorf("labelblk %q must be 3d to migrate", src.DataName())
+	}
+	db, err := datastore.GetOrderedKeyValueDB(src)
+	if err != nil {
+		return err
+	}
+	ctx := datastore.NewVersionedCtx(src, v)
+	begTKey := labelblk.NewTKey(&dvid.MinIndexZYX)
+	endTKey := labelblk.NewTKey(&dvid.MaxIndexZYX)
+	return db.ProcessRange(ctx, begTKey, endTKey, &storage.ChunkOp{}, func(c *storage.Chunk) error {
+		if c == nil || c.TKeyValue == nil || c.V == nil {
+			return nil
+		}
+		idx, err := labelblk.DecodeTKey(c.K)
+		if err != nil {
+			return err
+		}
+		data, _, err := dvid.DeserializeData(c.V, true)
+		if err != nil {
+			return fmt.Errorf("unable to deserialize block %s in %q: %v", idx, src.DataName(), err)
+		}
+		if len(data) == 0 {
+			return nil
+		}
+		block, err := labels.MakeBlock(data, blockSize)
+		if err != nil {
+			return err
+		}
+		return writeStreamedBlock(w, dvid.ChunkPoint3d(*idx), block)
+	})
+}
+
+// writeLabelarrayBlocks streams all blocks at a scale of a labelarray version in the
+// format read by ReceiveBlocks.
+func writeLabelarrayBlocks(w io.Writer, src *labelarray.Data, v dvid.VersionID, scale uint8) error {
+	db, err := datastore.GetOrderedKeyValueDB(src)
+	if err != nil {
+		return err
+	}
+	ctx := datastore.NewVersionedCtx(src, v)
+	begTKey := labelarray.NewBlockTKeyByCoord(scale, dvid.MinIndexZYX.ToIZYXString())
+	endTKey := labelarray.NewBlockTKeyByCoord(scale, dvid.MaxIndexZYX.ToIZYXString())
+	return db.ProcessRange(ctx, begTKey, endTKey, &storage.ChunkOp{}, func(c *storage.Chunk) error {
+		if c == nil || c.TKeyValue == nil || c.V == nil {
+			return nil
+		}
+		_, idx, err := labelarray.DecodeBlockTKey(c.K)
+		if err != nil {
+			return err
+		}
+		data, _, err := dvid.DeserializeData(c.V, true)
+		if err != nil {
+			return fmt.Errorf("unable to deserialize block %s in %q: %v", idx, src.DataName(), err)
+		}
+		var block labels.Block
+		if err := block.UnmarshalBinary(data); err != nil {
+			return err
+		}
+		return writeStreamedBlock(w, dvid.ChunkPoint3d(*idx), &block)
+	})
+}
+
+// verifyLabelvolIndices compares the per-block voxel counts of every label in a labelvol
+// version with the label indices of the same version of this labelmap.
+func (d *Data) verifyLabelvolIndices(v dvid.VersionID, lv *labelvol.Data) (numLabels, numBad int, err error) {
+	db, err := datastore.GetOrderedKeyValueDB(lv)
+	if err != nil {
+		return
+	}
+	var curLabel uint64
+	var counts map[uint64]uint32
+	checkLabel := func() error {
+		if counts == nil {
+			return nil
+		}
+		numLabels++
+		idx, err := GetLabelIndex(d, v, curLabel, false)
+		if err != nil {
+			return err
+		}
+		if !indexMatchesCounts(idx, curLabel, counts) {
+			dvid.Errorf("label %d index in labelmap %q doesn't match labelvol %q sparse volume\n", curLabel, d.DataName(), lv.DataName())
+			numBad++
+		}
+		return nil
+	}
+
+	ctx := datastore.NewVersionedCtx(lv, v)
+	begTKey := labelvol.NewTKey(1, dvid.MinIndexZYX.ToIZYXString())
+	endTKey := labelvol.NewTKey(math.MaxUint64, dvid.MaxIndexZYX.ToIZYXString())
+	err = db.ProcessRange(ctx, begTKey, endTKey, &storage.ChunkOp{}, func(c *storage.Chunk) error {
+		if c == nil || c.TKeyValue == nil || c.V == nil {
+			return nil
+		}
+		label, izyx, err := labelvol.DecodeTKey(c.K)
+		if err != nil {
+			return err
+		}
+		if counts == nil || label != curLabel {
+			if err := checkLabel(); err != nil {
+				return err
+			}
+			curLabel = label
+			counts = make(map[uint64]uint32)
+		}
+		var rles dvid.RLEs
+		if err := rles.UnmarshalBinary(c.V); err != nil {
+			return fmt.Errorf("unable to unmarshal RLEs for label %d, block %s: %v", label, izyx, err)
+		}
+		bcoord, err := izyx.ToChunkPoint3d()
+		if err != nil {
+			return err
+		}
+		numVoxels, _ := rles.Stats()
+		counts[labels.EncodeBlockIndex(bcoord[0], bcoord[1], bcoord[2])] += uint32(numVoxels)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	err = checkLabel()
+	return
+}
+
+// indexMatchesCounts returns true if the index has exactly the given blocks with the
+// given voxel counts for a label.
+func indexMatchesCounts(idx *labels.Index, label uint64, counts map[uint64]uint32) bool {
+	if idx == nil {
+		return len(counts) == 0
+	}
+	if len(idx.Blocks) != len(counts) {
+		return false
+	}
+	for zyx, svc := range idx.Blocks {
+		if svc == nil || svc.Counts == nil || svc.Counts[label] != counts[zyx] {
+			return false
+		}
+	}
+	return true
+}
+
+// repointSyncs changes syncs of any annotation or labelsz instance from the legacy
+// instances to this labelmap.
+func (d *Data) repointSyncs(instances []datastore.DataService, legacy dvid.UUIDSet) error {
+	for _, data := range instances {
+		switch data.TypeName() {
+		case "annotation", "labelsz":
+		default:
+			continue
+		}
+		syncer, ok := data.(datastore.Syncer)
+		if !ok {
+			continue
+		}
+		var repoint bool
+		syncs := make(dvid.UUIDSet)
+		for dataUUID := range syncer.SyncedData() {
+			if _, found := legacy[dataUUID]; found {
+				repoint = true
+			} else {
+				syncs[dataUUID] = struct{}{}
+			}
+		}
+		if !repoint {
+			continue
+		}
+		syncs[d.DataUUID()] = struct{}{}
+		if err := datastore.SetSyncData(data, syncs, true); err != nil {
+			return fmt.Errorf("unable to re-point syncs of %q to labelmap %q: %v", data.DataName(), d.DataName(), err)
+		}
+		dvid.Infof("Re-pointed syncs of %s %q to labelmap %q\n", data.TypeName(), data.DataName(), d.DataName())
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package labelmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/datatype/common/downres"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/server"
+)
+
+func newMigrationTestVolume() *testVolume {
+	volume := newTestVolume(128, 128, 128)
+	volume.addSubvol(dvid.Point3d{40, 40, 40}, dvid.Point3d{40, 40, 40}, 1)
+	volume.addSubvol(dvid.Point3d{40, 40, 80}, dvid.Point3d{40, 40, 40}, 2)
+	volume.addSubvol(dvid.Point3d{80, 40, 40}, dvid.Point3d{40, 40, 40}, 13)
+	volume.addSubvol(dvid.Point3d{40, 80, 40}, dvid.Point3d{40, 40, 40}, 209)
+	return volume
+}
+
+func checkMigratedLabels(t *testing.T, uuid dvid.UUID, name string, expected *testVolume) {
+	got := newTestVolume(128, 128, 128)
+	got.get(t, uuid, name, true)
+	if err := got.equals(expected); err != nil {
+		t.Errorf("migrated labels in %q not equal to legacy labels: %v\n", name, err)
+	}
+	for _, label := range []uint64{1, 2, 13, 209} {
+		reqStr := fmt.Sprintf("%snode/%s/%s/size/%d", server.WebAPIPath, uuid, name, label)
+		r := server.TestHTTP(t, "GET", reqStr, nil)
+		var jsonVal struct {
+			Voxels uint64 `json:"voxels"`
+		}
+		if err := json.Unmarshal(r, &jsonVal); err != nil {
+			t.Fatalf("unable to get size for label %d: %v", label, err)
+		}
+		if jsonVal.Voxels != 40*40*40 {
+			t.Errorf("expected migrated label %d to have %d voxels, got %d\n", label, 40*40*40, jsonVal.Voxels)
+		}
+	}
+	downres1 := newTestVolume(64, 64, 64)
+	downres1.getScale(t, uuid, name, 1, true)
+	expected1 := newTestVolume(64, 64, 64)
+	expected1.addSubvol(dvid.Point3d{20, 20, 20}, dvid.Point3d{20, 20, 20}, 1)
+	expected1.addSubvol(dvid.Point3d{20, 20, 40}, dvid.Point3d{20, 20, 20}, 2)
+	expected1.addSubvol(dvid.Point3d{40, 20, 20}, dvid.Point3d{20, 20, 20}, 13)
+	expected1.addSubvol(dvid.Point3d{20, 40, 20}, dvid.Point3d{20, 20, 20}, 209)
+	if err := downres1.equals(expected1); err != nil {
+		t.Errorf("1st downres of migrated %q isn't what is expected: %v\n", name, err)
+	}
+}
+
+func TestMigrateLabelblk(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, v := initTestRepo()
+	var config dvid.Config
+	config.Set("BlockSize", "64,64,64")
+	server.CreateTestInstance(t, uuid, "labelblk", "legacy", config)
+	server.CreateTestInstance(t, uuid, "labelvol", "bodies", config)
+	server.CreateTestSync(t, uuid, "legacy", "bodies")
+	server.CreateTestSync(t, uuid, "bodies", "legacy")
+	config.Set("MaxDownresLevel", "1")
+	server.CreateTestInstance(t, uuid, "labelmap", "labels", config)
+
+	volume := newMigrationTestVolume()
+	volume.put(t, uuid, "legacy")
+	if err := datastore.BlockOnUpdating(uuid, "bodies"); err != nil {
+		t.Fatalf("Error blocking on sync of legacy -> bodies: %v\n", err)
+	}
+
+	d, err := GetByUUIDName(uuid, "labels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.MigrateLegacy(uuid, v, "legacy", ""); err != nil {
+		t.Fatalf("unable to migrate labelblk: %v\n", err)
+	}
+	if err := downres.BlockOnUpdating(uuid, "labels"); err != nil {
+		t.Fatalf("Error blocking on update for labels: %v\n", err)
+	}
+	checkMigratedLabels(t, uuid, "labels", volume)
+
+	if err := d.MigrateLegacy(uuid, v, "legacy", ""); err == nil {
+		t.Errorf("expected error migrating into non-empty labelmap")
+	}
+}
+
+func TestMigrateLabelarray(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, v := initTestRepo()
+	var config dvid.Config
+	config.Set("MaxDownresLevel", "1")
+	server.CreateTestInstance(t, uuid, "labelarray", "legacy", config)
+	server.CreateTestInstance(t, uuid, "labelmap", "labels", config)
+	config.Set("MaxDownresLevel", "2")
+	server.CreateTestInstance(t, uuid, "labelmap", "labels2", config)
+
+	volume := newMigrationTestVolume()
+	volume.put(t, uuid, "legacy")
+	if err := downres.BlockOnUpdating(uuid, "legacy"); err != nil {
+		t.Fatalf("Error blocking on update for legacy: %v\n", err)
+	}
+
+	d2, err := GetByUUIDName(uuid, "labels2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d2.MigrateLegacy(uuid, v, "legacy", ""); err == nil {
+		t.Errorf("expected error migrating labelarray with different MaxDownresLevel")
+	}
+
+	d, err := GetByUUIDName(uuid, "labels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.MigrateLegacy(uuid, v, "legacy", ""); err != nil {
+		t.Fatalf("unable to migrate labelarray: %v\n", err)
+	}
+	checkMigratedLabels(t, uuid, "labels", volume)
+}
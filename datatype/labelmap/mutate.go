@@ -8,6 +8,7 @@
 package labelmap
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -116,6 +117,9 @@ func (d *Data) MergeLabels(v dvid.VersionID, op labels.MergeOp, info dvid.ModInf
 	if err := labels.LogMerge(d, v, op); err != nil {
 		return err
 	}
+	if err := d.mergeBodyAnnotations(v, op.Target, lbls); err != nil {
+		dvid.Errorf("can't merge body annotations into label %d for %q: %v\n", op.Target, d.DataName(), err)
+	}
 
 	dvid.Infof("merged label %d: supervoxels %v, %d blocks\n", op.Target, mergeIdx.GetSupervoxels(), len(mergeIdx.Blocks))
 
@@ -210,12 +214,20 @@ func (d *Data) CleaveLabel(v dvid.VersionID, label uint64, info dvid.ModInfo, r
 	if err = CleaveIndex(d, v, op, info); err != nil {
 		return
 	}
+	var cleavedSize uint64
+	if cleavedSize, err = GetLabelSize(d, v, cleaveLabel, false); err != nil {
+		return
+	}
+	d.notifyCleaveSizes(v, op, cleavedSize)
 	if err = addCleaveToMapping(d, v, op); err != nil {
 		return
 	}
 	if err = labels.LogCleave(d, v, op); err != nil {
 		return
 	}
+	if err := d.splitBodyAnnotation(v, label, cleaveLabel); err != nil {
+		dvid.Errorf("can't copy body annotation of label %d to cleaved label %d for %q: %v\n", label, cleaveLabel, d.DataName(), err)
+	}
 
 	msginfo = map[string]interface{}{
 		"Action":     "cleave-complete",
@@ -229,6 +241,28 @@ func (d *Data) CleaveLabel(v dvid.VersionID, label uint64, info dvid.ModInfo, r
 	return
 }
 
+// notifyCleaveSizes publishes the change in label sizes due to a cleave.
+func (d *Data) notifyCleaveSizes(v dvid.VersionID, op labels.CleaveOp, cleavedSize uint64) {
+	deltaNewSize := labels.DeltaNewSize{
+		Label: op.CleavedLabel,
+		Size:  cleavedSize,
+	}
+	evt := datastore.SyncEvent{d.DataUUID(), labels.ChangeSizeEvent}
+	msg := datastore.SyncMessage{labels.ChangeSizeEvent, v, deltaNewSize}
+	if err := datastore.NotifySubscribers(evt, msg); err != nil {
+		dvid.Errorf("can't notify subscribers for event %v: %v\n", evt, err)
+	}
+
+	deltaModSize := labels.DeltaModSize{
+		Label:      op.Target,
+		SizeChange: -int64(cleavedSize),
+	}
+	msg = datastore.SyncMessage{labels.ChangeSizeEvent, v, deltaModSize}
+	if err := datastore.NotifySubscribers(evt, msg); err != nil {
+		dvid.Errorf("can't notify subscribers for event %v: %v\n", evt, err)
+	}
+}
+
 // created while iterating over all split RLEs and computing what the
 // split supervoxels should be and the # voxels split for each supervoxel per block.
 type blockSplitsMap map[uint64]map[uint64]labels.SVSplitCount
@@ -616,6 +650,9 @@ func (d *Data) SplitLabels(v dvid.VersionID, fromLabel uint64, r io.ReadCloser,
 	if err = labels.LogSplit(d, v, op); err != nil {
 		return
 	}
+	if err := d.splitBodyAnnotation(v, fromLabel, toLabel); err != nil {
+		dvid.Errorf("can't copy body annotation of label %d to split label %d for %q: %v\n", fromLabel, toLabel, d.DataName(), err)
+	}
 	if err = downresMut.Execute(); err != nil {
 		return
 	}
@@ -907,3 +944,307 @@ func (d *Data) splitSupervoxelThread(ctx *datastore.VersionedCtx, downresMut *do
 		}
 	}
 }
+
+// returns the set bounds as a map suitable for JSON encoding, e.g., in kafka messages.
+func boundsMap(bounds *dvid.OptionalBounds) map[string]int32 {
+	m := make(map[string]int32)
+	if x, ok := bounds.MinX(); ok {
+		m["minx"] = x
+	}
+	if x, ok := bounds.MaxX(); ok {
+		m["maxx"] = x
+	}
+	if y, ok := bounds.MinY(); ok {
+		m["miny"] = y
+	}
+	if y, ok := bounds.MaxY(); ok {
+		m["maxy"] = y
+	}
+	if z, ok := bounds.MinZ(); ok {
+		m["minz"] = z
+	}
+	if z, ok := bounds.MaxZ(); ok {
+		m["maxz"] = z
+	}
+	return m
+}
+
+// EraseLabel sets all voxels of a label, or a supervoxel if isSupervoxel is true, to 0 across
+// all scales.  If bounds are set, only voxels within the bounds are erased.  The label index
+// is modified to remove the erased voxels and any supervoxels left without voxels are mapped
+// to 0.  Returns the number of erased voxels.
+func (d *Data) EraseLabel(v dvid.VersionID, label uint64, isSupervoxel bool, bounds *dvid.OptionalBounds, info dvid.ModInfo) (erased uint64, err error) {
+	timedLog := dvid.NewTimeLog()
+	if !bounds.IsSet() {
+		bounds = nil
+	}
+
+	var mapping *SVMap
+	if mapping, err = getMapping(d, v); err != nil {
+		return
+	}
+	bodyLabel := label
+	if isSupervoxel {
+		if mapped, found := mapping.MappedLabel(v, label); found {
+			if mapped == 0 {
+				err = fmt.Errorf("cannot erase supervoxel %d, which has been split or erased and doesn't exist anymore", label)
+				return
+			}
+			bodyLabel = mapped
+		}
+	}
+	shard := bodyLabel % numIndexShards
+	indexMu[shard].Lock()
+	defer indexMu[shard].Unlock()
+
+	idx, err := getCachedLabelIndex(d, v, bodyLabel)
+	if err != nil {
+		err = fmt.Errorf("erase label index for data %q, label %d: %v", d.DataName(), bodyLabel, err)
+		return
+	}
+	if idx == nil {
+		err = fmt.Errorf("unable to erase label %d for data %q: missing label index %d", label, d.DataName(), bodyLabel)
+		return
+	}
+	var supervoxels labels.Set
+	if isSupervoxel {
+		if idx.GetSupervoxelCount(label) == 0 {
+			err = fmt.Errorf("unable to erase supervoxel %d for data %q: not in label index %d", label, d.DataName(), bodyLabel)
+			return
+		}
+		supervoxels = labels.Set{label: struct{}{}}
+	} else {
+		supervoxels = idx.GetSupervoxels()
+	}
+
+	blockSize, ok := d.BlockSize().(dvid.Point3d)
+	if !ok {
+		err = fmt.Errorf("can't do erase because block size for instance %s is not 3d: %v", d.DataName(), d.BlockSize())
+		return
+	}
+	blockBounds := bounds.Divide(blockSize)
+
+	// Only do voxel-based mutations one at a time.
+	d.voxelMu.Lock()
+	defer d.voxelMu.Unlock()
+
+	// send kafka erase event to instance-uuid topic
+	mutID := d.NewMutationID()
+	versionuuid, _ := datastore.UUIDFromVersion(v)
+	svlist := make([]uint64, 0, len(supervoxels))
+	for supervoxel := range supervoxels {
+		svlist = append(svlist, supervoxel)
+	}
+	msginfo := map[string]interface{}{
+		"Action":       "erase",
+		"Label":        label,
+		"IsSupervoxel": isSupervoxel,
+		"Supervoxels":  svlist,
+		"MutationID":   mutID,
+		"UUID":         string(versionuuid),
+	}
+	if bounds != nil {
+		msginfo["Bounds"] = boundsMap(bounds)
+	}
+	jsonmsg, _ := json.Marshal(msginfo)
+	if err = d.ProduceKafkaMsg(jsonmsg); err != nil {
+		dvid.Errorf("error on sending erase op to kafka: %v", err)
+	}
+
+	d.StartUpdate()
+	defer d.StopUpdate()
+
+	ctx := datastore.NewVersionedCtx(d, v)
+	downresMut := downres.NewMutation(d, v, mutID)
+	svChanges := make(labels.SupervoxelChanges)
+	eraseMapping := make(map[uint64]uint64, len(supervoxels))
+	for supervoxel := range supervoxels {
+		eraseMapping[supervoxel] = 0
+	}
+	var scale uint8
+	var numBlocks int
+	for zyx, svc := range idx.Blocks {
+		var inBlock bool
+		for supervoxel := range supervoxels {
+			if svc.Counts[supervoxel] != 0 {
+				inBlock = true
+				break
+			}
+		}
+		if !inBlock {
+			continue
+		}
+		x, y, z := labels.DecodeBlockIndex(zyx)
+		chunkPt := dvid.ChunkPoint3d{x, y, z}
+		if blockBounds != nil && blockBounds.Outside(chunkPt) {
+			continue
+		}
+		bcoord := chunkPt.ToIZYXString()
+		var pb *labels.PositionedBlock
+		if pb, err = d.getLabelBlock(ctx, scale, bcoord); err != nil {
+			return
+		}
+		if pb == nil {
+			dvid.Errorf("erase of label %d: block %s should have been erased but was nil\n", label, bcoord)
+			continue
+		}
+		var block *labels.Block
+		if bounds == nil || blockInsideBounds(chunkPt, blockSize, bounds) {
+			block, _, err = pb.ReplaceLabels(eraseMapping)
+		} else {
+			block, err = eraseBoundedVoxels(pb, chunkPt, supervoxels, bounds)
+		}
+		if err != nil {
+			err = fmt.Errorf("unable to erase label %d in block %s: %v", label, bcoord, err)
+			return
+		}
+		delta := block.CalcNumLabels(&(pb.Block))
+		var changed bool
+		for supervoxel, diff := range delta {
+			if diff >= 0 {
+				continue
+			}
+			if _, found := supervoxels[supervoxel]; !found {
+				continue
+			}
+			blockChanges, found := svChanges[supervoxel]
+			if !found {
+				blockChanges = make(map[dvid.IZYXString]int32)
+				svChanges[supervoxel] = blockChanges
+			}
+			blockChanges[bcoord] += diff
+			erased += uint64(-diff)
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+		erasedpb := labels.PositionedBlock{Block: *block, BCoord: bcoord}
+		if err = d.putLabelBlock(ctx, scale, &erasedpb); err != nil {
+			err = fmt.Errorf("unable to put block %s in erase of label %d, data %q: %v", bcoord, label, d.DataName(), err)
+			return
+		}
+		if err = downresMut.BlockMutated(bcoord, block); err != nil {
+			err = fmt.Errorf("data %q publishing downres, block %s: %v", d.DataName(), bcoord, err)
+			return
+		}
+		evt := datastore.SyncEvent{d.DataUUID(), labels.MutateBlockEvent}
+		msg := datastore.SyncMessage{labels.MutateBlockEvent, v, MutatedBlock{mutID, bcoord, &(pb.Block), block}}
+		if err := datastore.NotifySubscribers(evt, msg); err != nil {
+			dvid.Errorf("unable to notify subscribers of event %s in %s\n", evt, d.DataName())
+		}
+		numBlocks++
+	}
+
+	// modify the label index and map supervoxels that no longer exist to 0.
+	oldSize := idx.NumVoxels()
+	if err = idx.ModifyBlocks(bodyLabel, svChanges); err != nil {
+		return
+	}
+	var newSize uint64
+	removed := make(labels.Set)
+	for supervoxel := range supervoxels {
+		if idx.GetSupervoxelCount(supervoxel) == 0 {
+			removed[supervoxel] = struct{}{}
+		}
+	}
+	if len(idx.Blocks) == 0 {
+		if err = deleteCachedLabelIndex(d, v, bodyLabel); err != nil {
+			return
+		}
+		if err = d.PutBodyAnnotation(v, bodyLabel, nil); err != nil {
+			return
+		}
+	} else {
+		newSize = idx.NumVoxels()
+		idx.LastMutId = mutID
+		idx.LastModUser = info.User
+		idx.LastModTime = info.Time
+		idx.LastModApp = info.App
+		if err = putCachedLabelIndex(d, v, idx); err != nil {
+			return
+		}
+	}
+	if oldSize != newSize {
+		notifySizeChange(d, v, bodyLabel, oldSize, newSize)
+	}
+	if err = addEraseToMapping(d, v, mutID, removed); err != nil {
+		return
+	}
+	erasedSupervoxels := make([]uint64, 0, len(svChanges))
+	for supervoxel := range svChanges {
+		erasedSupervoxels = append(erasedSupervoxels, supervoxel)
+	}
+	op := labels.EraseOp{
+		MutID:      mutID,
+		Label:      label,
+		Supervoxel: isSupervoxel,
+		Erased:     erasedSupervoxels,
+		Voxels:     erased,
+		Bounds:     bounds,
+	}
+	if err = labels.LogErase(d, v, op); err != nil {
+		return
+	}
+
+	if err = downresMut.Execute(); err != nil {
+		dvid.Criticalf("down-res compute of erased label %d failed with error: %v\n", label, err)
+		return
+	}
+	timedLog.Infof("Erased label %d (supervoxel %t), data %q: %d voxels in %d blocks", label, isSupervoxel, d.DataName(), erased, numBlocks)
+
+	msginfo = map[string]interface{}{
+		"Action":     "erase-complete",
+		"MutationID": mutID,
+		"UUID":       string(versionuuid),
+	}
+	jsonmsg, _ = json.Marshal(msginfo)
+	if err = d.ProduceKafkaMsg(jsonmsg); err != nil {
+		dvid.Errorf("error on sending erase complete op to kafka: %v", err)
+	}
+	return erased, nil
+}
+
+// returns true if all voxels of the given block are within the bounds.
+func blockInsideBounds(chunkPt dvid.ChunkPoint3d, blockSize dvid.Point3d, bounds *dvid.OptionalBounds) bool {
+	for dim := 0; dim < 3; dim++ {
+		beg := chunkPt[dim] * blockSize[dim]
+		end := beg + blockSize[dim] - 1
+		var outside bool
+		switch dim {
+		case 0:
+			outside = bounds.OutsideX(beg) || bounds.OutsideX(end)
+		case 1:
+			outside = bounds.OutsideY(beg) || bounds.OutsideY(end)
+		case 2:
+			outside = bounds.OutsideZ(beg) || bounds.OutsideZ(end)
+		}
+		if outside {
+			return false
+		}
+	}
+	return true
+}
+
+// returns a new block where voxels of the given supervoxels within the bounds are set to 0.
+func eraseBoundedVoxels(pb *labels.PositionedBlock, chunkPt dvid.ChunkPoint3d, supervoxels labels.Set, bounds *dvid.OptionalBounds) (*labels.Block, error) {
+	lblarray, size := pb.MakeLabelVolume()
+	offset := dvid.Point3d{chunkPt[0] * size[0], chunkPt[1] * size[1], chunkPt[2] * size[2]}
+	var i int
+	for z := int32(0); z < size[2]; z++ {
+		outsideZ := bounds.OutsideZ(offset[2] + z)
+		for y := int32(0); y < size[1]; y++ {
+			outsideY := outsideZ || bounds.OutsideY(offset[1]+y)
+			for x := int32(0); x < size[0]; x++ {
+				if !outsideY && !bounds.OutsideX(offset[0]+x) {
+					label := binary.LittleEndian.Uint64(lblarray[i : i+8])
+					if _, found := supervoxels[label]; found {
+						binary.LittleEndian.PutUint64(lblarray[i:i+8], 0)
+					}
+				}
+				i += 8
+			}
+		}
+	}
+	return labels.MakeBlock(lblarray, size)
+}
@@ -1033,6 +1033,96 @@ func TestSplitLabel(t *testing.T) {
 	}
 }
 
+func TestEraseLabel(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+	var config dvid.Config
+	config.Set("MaxDownresLevel", "1")
+	server.CreateTestInstance(t, uuid, "labelmap", "labels", config)
+
+	volume := newTestVolume(128, 128, 128)
+	volume.addSubvol(dvid.Point3d{40, 40, 40}, dvid.Point3d{40, 40, 40}, 1)
+	volume.addSubvol(dvid.Point3d{40, 40, 80}, dvid.Point3d{40, 40, 40}, 2)
+	volume.addSubvol(dvid.Point3d{80, 40, 40}, dvid.Point3d{40, 40, 40}, 13)
+	volume.put(t, uuid, "labels")
+	if err := downres.BlockOnUpdating(uuid, "labels"); err != nil {
+		t.Fatalf("Error blocking on update for labels: %v\n", err)
+	}
+
+	// erase all of label 13
+	reqStr := fmt.Sprintf("%snode/%s/labels/erase/13", server.WebAPIPath, uuid)
+	r := server.TestHTTP(t, "POST", reqStr, nil)
+	var jsonVal struct {
+		ErasedVoxels uint64
+	}
+	if err := json.Unmarshal(r, &jsonVal); err != nil {
+		t.Fatalf("unable to parse erase response %q: %v\n", string(r), err)
+	}
+	if jsonVal.ErasedVoxels != 40*40*40 {
+		t.Errorf("expected %d erased voxels for label 13, got %d\n", 40*40*40, jsonVal.ErasedVoxels)
+	}
+
+	// erase label 1 within z bounds
+	reqStr = fmt.Sprintf("%snode/%s/labels/erase/1?maxz=59", server.WebAPIPath, uuid)
+	r = server.TestHTTP(t, "POST", reqStr, nil)
+	if err := json.Unmarshal(r, &jsonVal); err != nil {
+		t.Fatalf("unable to parse erase response %q: %v\n", string(r), err)
+	}
+	if jsonVal.ErasedVoxels != 40*40*20 {
+		t.Errorf("expected %d erased voxels for label 1, got %d\n", 40*40*20, jsonVal.ErasedVoxels)
+	}
+	if err := downres.BlockOnUpdating(uuid, "labels"); err != nil {
+		t.Fatalf("Error blocking on update for labels: %v\n", err)
+	}
+
+	expected := newTestVolume(128, 128, 128)
+	expected.addSubvol(dvid.Point3d{40, 40, 60}, dvid.Point3d{40, 40, 20}, 1)
+	expected.addSubvol(dvid.Point3d{40, 40, 80}, dvid.Point3d{40, 40, 40}, 2)
+	got := newTestVolume(128, 128, 128)
+	got.get(t, uuid, "labels", false)
+	if err := got.equals(expected); err != nil {
+		t.Errorf("labels after erase not as expected: %v\n", err)
+	}
+
+	expected1 := newTestVolume(64, 64, 64)
+	expected1.addSubvol(dvid.Point3d{20, 20, 30}, dvid.Point3d{20, 20, 10}, 1)
+	expected1.addSubvol(dvid.Point3d{20, 20, 40}, dvid.Point3d{20, 20, 20}, 2)
+	got1 := newTestVolume(64, 64, 64)
+	got1.getScale(t, uuid, "labels", 1, false)
+	if err := got1.equals(expected1); err != nil {
+		t.Errorf("1st downres after erase not as expected: %v\n", err)
+	}
+
+	reqStr = fmt.Sprintf("%snode/%s/labels/size/1", server.WebAPIPath, uuid)
+	r = server.TestHTTP(t, "GET", reqStr, nil)
+	var sizeVal struct {
+		Voxels uint64 `json:"voxels"`
+	}
+	if err := json.Unmarshal(r, &sizeVal); err != nil {
+		t.Fatalf("unable to get size for label 1: %v\n", err)
+	}
+	if sizeVal.Voxels != 40*40*20 {
+		t.Errorf("expected label 1 to have %d voxels after bounded erase, got %d\n", 40*40*20, sizeVal.Voxels)
+	}
+	reqStr = fmt.Sprintf("%snode/%s/labels/index/13", server.WebAPIPath, uuid)
+	if resp := server.TestHTTPResponse(t, "GET", reqStr, nil); resp.Code != http.StatusNotFound {
+		t.Errorf("expected no label index for erased label 13, got status %d\n", resp.Code)
+	}
+
+	reqStr = fmt.Sprintf("%snode/%s/labels/mapping", server.WebAPIPath, uuid)
+	r = server.TestHTTP(t, "GET", reqStr, bytes.NewBufferString("[1,2,13]"))
+	if string(r) != "[1,2,0]" {
+		t.Errorf("bad /mapping result after erase.  got: %s\n", string(r))
+	}
+
+	reqStr = fmt.Sprintf("%snode/%s/labels/erase/13", server.WebAPIPath, uuid)
+	server.TestBadHTTP(t, "POST", reqStr, nil)
+}
+
 func getIndex(t *testing.T, uuid dvid.UUID, name string, label uint64) *labels.Index {
 	url := fmt.Sprintf("http://%snode/%s/%s/index/%d", server.WebAPIPath, uuid, name, label)
 	data := server.TestHTTP(t, "GET", url, nil)
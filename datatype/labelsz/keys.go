@@ -23,6 +23,12 @@ const (
 
 	// key is index type + label, with value equal to size, necessary to delete old indices.
 	keyTypeLabel = 98
+
+	// key is partition ROI + index type + size + label
+	keyROITypeSizeLabel = 99
+
+	// key is partition ROI + index type + label, with value equal to size.
+	keyROITypeLabel = 100
 )
 
 // DescribeTKeyClass returns a string explanation of what a particular TKeyClass
@@ -33,6 +39,10 @@ func (d *Data) DescribeTKeyClass(tkc storage.TKeyClass) string {
 		return "labelsz index type + label key"
 	case keyTypeSizeLabel:
 		return "labelsz index type + size + label key"
+	case keyROITypeLabel:
+		return "labelsz partition ROI + index type + label key"
+	case keyROITypeSizeLabel:
+		return "labelsz partition ROI + index type + size + label key"
 	default:
 	}
 	return "unknown labelsz key"
@@ -191,3 +201,88 @@ func DecodeTypeLabelTKey(tk storage.TKey) (i IndexType, label uint64, err error)
 	label = binary.BigEndian.Uint64(ibytes[1:])
 	return
 }
+
+// can be used as map index and holds serialized (partition ROI, IndexType, Label)
+type roiIndexedLabel string
+
+func (rl roiIndexedLabel) String() string {
+	r := uint8(rl[0])
+	i := IndexType(rl[1])
+	label := binary.BigEndian.Uint64([]byte(rl[2:]))
+	return fmt.Sprintf("[roi %d, label %d, index %s]", r, label, i.String())
+}
+
+func newROIIndexedLabel(r uint8, i IndexType, label uint64) roiIndexedLabel {
+	buf := make([]byte, 10)
+	buf[0] = r
+	buf[1] = byte(i)
+	binary.BigEndian.PutUint64(buf[2:], label)
+	return roiIndexedLabel(buf)
+}
+
+func decodeROIIndexedLabel(rl roiIndexedLabel) (r uint8, i IndexType, label uint64, err error) {
+	if len(rl) != 10 {
+		err = fmt.Errorf("roi indexed label %v is supposed to be 10 bytes but is %d bytes", rl, len(rl))
+		return
+	}
+	r = uint8(rl[0])
+	i = IndexType(rl[1])
+	label = binary.BigEndian.Uint64([]byte(rl[2:]))
+	return
+}
+
+// NewROITypeSizeLabelTKey returns a type-specific key for the (partition ROI, index type, size, label) tuple.
+func NewROITypeSizeLabelTKey(r uint8, i IndexType, sz uint32, label uint64) storage.TKey {
+	rsz := math.MaxUint32 - sz
+
+	buf := make([]byte, 1+1+4+8)
+	buf[0] = r
+	buf[1] = byte(i)
+	binary.BigEndian.PutUint32(buf[2:6], rsz)
+	binary.BigEndian.PutUint64(buf[6:], label)
+	return storage.NewTKey(keyROITypeSizeLabel, buf)
+}
+
+// DecodeROITypeSizeLabelTKey decodes a type-specific key into a (partition ROI, index type, size, label) tuple.
+func DecodeROITypeSizeLabelTKey(tk storage.TKey) (r uint8, i IndexType, sz uint32, label uint64, err error) {
+	var ibytes []byte
+	ibytes, err = tk.ClassBytes(keyROITypeSizeLabel)
+	if err != nil {
+		return
+	}
+	if len(ibytes) != 14 {
+		err = fmt.Errorf("labelsz roi element size type-specific key is wrong size: expected 14, got %d bytes", len(ibytes))
+		return
+	}
+	r = ibytes[0]
+	i = IndexType(ibytes[1])
+	sz = math.MaxUint32 - binary.BigEndian.Uint32(ibytes[2:6])
+	label = binary.BigEndian.Uint64(ibytes[6:])
+	return
+}
+
+// NewROITypeLabelTKey returns a type-specific key for the (partition ROI, index type, label) tuple.
+func NewROITypeLabelTKey(r uint8, i IndexType, label uint64) storage.TKey {
+	buf := make([]byte, 1+1+8)
+	buf[0] = r
+	buf[1] = byte(i)
+	binary.BigEndian.PutUint64(buf[2:], label)
+	return storage.NewTKey(keyROITypeLabel, buf)
+}
+
+// DecodeROITypeLabelTKey decodes a type-specific key into a (partition ROI, index type, label) tuple.
+func DecodeROITypeLabelTKey(tk storage.TKey) (r uint8, i IndexType, label uint64, err error) {
+	var ibytes []byte
+	ibytes, err = tk.ClassBytes(keyROITypeLabel)
+	if err != nil {
+		return
+	}
+	if len(ibytes) != 10 {
+		err = fmt.Errorf("labelsz roi label size type-specific key is wrong size: expected 10, got %d bytes", len(ibytes))
+		return
+	}
+	r = ibytes[0]
+	i = IndexType(ibytes[1])
+	label = binary.BigEndian.Uint64(ibytes[2:])
+	return
+}
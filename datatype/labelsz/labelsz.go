@@ -1,5 +1,5 @@
 /*
-	Package labelsz supports ranking labels by # annotations of each type.
+	Package labelsz supports ranking labels by # annotations of each type or # voxels.
 */
 package labelsz
 
@@ -19,6 +19,8 @@ import (
 
 	"github.com/janelia-flyem/dvid/datastore"
 	"github.com/janelia-flyem/dvid/datatype/annotation"
+	"github.com/janelia-flyem/dvid/datatype/labelarray"
+	"github.com/janelia-flyem/dvid/datatype/labelmap"
 	"github.com/janelia-flyem/dvid/datatype/roi"
 	"github.com/janelia-flyem/dvid/dvid"
 	"github.com/janelia-flyem/dvid/server"
@@ -56,6 +58,10 @@ $ dvid repo <UUID> new labelsz <data name> <settings...>
     ROI            Value must be in "<roiname>,<uuid>" format where <roiname> is the name of the
 				   static ROI that defines the extent of tracking and <uuid> is the immutable
 				   version used for this labelsz.
+    ROIs           Value must be in "<roiname>,<uuid>;<roiname>,<uuid>;..." format listing static
+                   ROIs for which element counts are additionally partitioned.  Partitioned counts
+                   are limited to elements within the ROI setting if given, and voxel counts are
+                   not partitioned.
 	
     ------------------
 
@@ -124,14 +130,16 @@ POST <api URL>/node/<UUID>/<data name>/sync?<options>
 			   Default operation is false.
 
 
-GET <api URL>/node/<UUID>/<data name>/count/<label>/<index type>
+GET <api URL>/node/<UUID>/<data name>/count/<label>/<index type>[?roi=<roiname>]
 
 	Returns the count of the given annotation element type for the given label.
+	If "roi" is given, the count is within the named partition ROI from the ROIs setting.
 	The index type may be any annotation element type ("PostSyn", "PreSyn", "Gap", "Note"),
 	the catch-all for synapses "AllSyn", or the number of voxels "Voxels".
 
 	For synapse indexing, the labelsz data instance must be synced with an annotations instance.
-	(future) For # voxel indexing, the labelsz data instance must be synced with a labelvol instance.
+	For # voxel indexing, the labelsz data instance must be synced with a labelmap or labelarray
+	instance.  Voxel counts are not restricted by any ROI and saturate at 4,294,967,295.
 
 	Example:
 
@@ -144,14 +152,16 @@ GET <api URL>/node/<UUID>/<data name>/count/<label>/<index type>
 Note: For the following URL endpoints that return and accept POSTed JSON values, see the JSON format
 at end of this documentation.
 
-GET <api URL>/node/<UUID>/<data name>/top/<N>/<index type>
+GET <api URL>/node/<UUID>/<data name>/top/<N>/<index type>[?roi=<roiname>]
 
 	Returns a list of the top N labels with respect to number of the specified index type.
+	If "roi" is given, labels are ranked by counts within the named partition ROI.
 	The index type may be any annotation element type ("PostSyn", "PreSyn", "Gap", "Note"),
 	the catch-all for synapses "AllSyn", or the number of voxels "Voxels".
 
 	For synapse indexing, the labelsz data instance must be synced with an annotations instance.
-	(future) For # voxel indexing, the labelsz data instance must be synced with a labelvol instance.
+	For # voxel indexing, the labelsz data instance must be synced with a labelmap or labelarray
+	instance.  Voxel counts are not restricted by any ROI and saturate at 4,294,967,295.
 
 	Example:
 
@@ -172,12 +182,14 @@ GET <api URL>/node/<UUID>/<data name>/threshold/<T>/<index type>[?<options>]
 	the catch-all for synapses "AllSyn", or the number of voxels "Voxels".
 
 	For synapse indexing, the labelsz data instance must be synced with an annotations instance.
-	(future) For # voxel indexing, the labelsz data instance must be synced with a labelvol instance.
+	For # voxel indexing, the labelsz data instance must be synced with a labelmap or labelarray
+	instance.  Voxel counts are not restricted by any ROI and saturate at 4,294,967,295.
 
     GET Query-string Options:
 
     offset  The starting rank in the sorted list (in descending order) of labels with # given element types >= T.
     n       Number of labels to return.
+    roi     Name of a partition ROI from the ROIs setting.  If given, counts are within that ROI.
 
 	Example:
 
@@ -190,9 +202,35 @@ GET <api URL>/node/<UUID>/<data name>/threshold/<T>/<index type>[?<options>]
 	In the above example, the query returns the labels ranked #10,001 to #10,003 in the sorted list, in
 	descending order of # PreSyn >= 10.
 
+GET <api URL>/node/<UUID>/<data name>/composite/<N>?weights=<weights>[&roi=<roiname>]
+
+	Returns a list of the top N labels (up to 10,000) ranked by a weighted sum of counts for
+	the given index types.  Each returned label includes its score, the count of each weighted
+	index type, and, if the labelsz has partition ROIs, the counts within each partition ROI.
+	Labels with equal scores are ordered by ascending label ID.  Voxel counts are not partitioned so "Voxels" cannot be weighted when "roi" is given.
+
+    GET Query-string Options:
+
+    weights  Comma-separated list of "<index type>:<weight>", e.g., "PreSyn:1,PostSyn:0.2".
+             An index type without a weight has weight 1.  Weights must be non-negative.
+    roi      Name of a partition ROI from the ROIs setting.  If given, scores only use counts
+             within that ROI.
+
+	Example:
+
+	GET <api URL>/node/3f8c/labelrankings/composite/2?weights=PreSyn:1,PostSyn:0.2
+
+	Returns:
+
+	[ { "Label": 188, "Score": 97.2, "Counts": { "PreSyn": 81, "PostSyn": 81 },
+	    "ROIs": { "medulla": { "PreSyn": 60, "PostSyn": 12 }, "lobula": { "PreSyn": 21, "PostSyn": 69 } } },
+	  { "Label": 23, "Score": 70, "Counts": { "PreSyn": 65, "PostSyn": 25 },
+	    "ROIs": { "medulla": { "PreSyn": 0, "PostSyn": 25 }, "lobula": { "PreSyn": 65, "PostSyn": 0 } } } ]
+
 POST <api URL>/node/<UUID>/<data name>/reload
 
-	Forces asynchornous denormalization from its synced annotations instance.  Can be 
+	Forces asynchornous denormalization from its synced annotations instance and, if synced
+	with a labelmap or labelarray instance, the voxel counts of its labels.  Can be 
 	used to initialize a newly added instance.  Note that the labelsz will be locked until
 	the denormalization is finished with a log message.
 `
@@ -262,6 +300,16 @@ func NewData(uuid dvid.UUID, id dvid.InstanceID, name dvid.InstanceName, c dvid.
 			return nil, fmt.Errorf("bad ROI value (%q) expected %q", roistr, "<roiname>,<uuid>")
 		}
 	}
+	var partitions []string
+	roisStr, found, err := c.GetString("ROIs")
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		if partitions, err = parsePartitionROIs(roisStr); err != nil {
+			return nil, err
+		}
+	}
 
 	// Initialize the Data for this data type
 	basedata, err := datastore.NewDataService(dtype, uuid, id, name, c)
@@ -271,7 +319,8 @@ func NewData(uuid dvid.UUID, id dvid.InstanceID, name dvid.InstanceName, c dvid.
 	data := &Data{
 		Data: basedata,
 		Properties: Properties{
-			StaticROI: roistr,
+			StaticROI:     roistr,
+			PartitionROIs: partitions,
 		},
 	}
 	return data, nil
@@ -298,6 +347,11 @@ type Properties struct {
 	// StaticROI is an optional static ROI specification of the form "<roiname>,<uuid>"
 	// Note that it *cannot* mutate after the labelsz instance is created.
 	StaticROI string
+
+	// PartitionROIs are optional static ROI specifications of the form "<roiname>,<uuid>"
+	// for which counts are additionally kept per ROI.  Like StaticROI, they cannot mutate
+	// after the labelsz instance is created.
+	PartitionROIs []string `json:",omitempty"`
 }
 
 // Data instance of labelvol, label sparse volumes.
@@ -313,6 +367,10 @@ type Data struct {
 	iROI       *roi.Immutable
 	roiChecked bool
 
+	// cache of immutable partition ROIs, guarded by iMutex.
+	partitionROIs     []*roi.Immutable
+	partitionsChecked bool
+
 	syncCh   chan datastore.SyncMessage
 	syncDone chan *sync.WaitGroup
 
@@ -328,11 +386,30 @@ func (d *Data) Equals(d2 *Data) bool {
 
 func (d *Data) GetSyncedAnnotation() *annotation.Data {
 	for dataUUID := range d.SyncedData() {
-		source, err := annotation.GetByDataUUID(dataUUID)
-		if err == nil {
+		source, err := datastore.GetDataByDataUUID(dataUUID)
+		if err != nil {
+			dvid.Errorf("Got error accessing synced data %s: %v\n", dataUUID, err)
+			continue
+		}
+		if annot, ok := source.(*annotation.Data); ok {
+			return annot
+		}
+	}
+	return nil
+}
+
+// GetSyncedLabels returns the synced labelmap or labelarray instance or nil if there is none.
+func (d *Data) GetSyncedLabels() dvid.Data {
+	for dataUUID := range d.SyncedData() {
+		source, err := datastore.GetDataByDataUUID(dataUUID)
+		if err != nil {
+			dvid.Errorf("Got error accessing synced data %s: %v\n", dataUUID, err)
+			continue
+		}
+		switch source.(type) {
+		case *labelmap.Data, *labelarray.Data:
 			return source
 		}
-		dvid.Errorf("Got error accessing synced annotation %s: %v\n", dataUUID, err)
 	}
 	return nil
 }
@@ -388,53 +465,30 @@ func (d *Data) GetCountElementType(ctx *datastore.VersionedCtx, label uint64, i
 
 // GetTopElementType returns a sorted list of the top N labels that have the given ElementType.
 func (d *Data) GetTopElementType(ctx *datastore.VersionedCtx, n int, i IndexType) (LabelSizes, error) {
+	return d.GetROITopElementType(ctx, "", n, i)
+}
+
+// GetROITopElementType returns a sorted list of the top N labels that have the given ElementType
+// within the named partition ROI.  If roiName is empty, counts across all partitions are used.
+func (d *Data) GetROITopElementType(ctx *datastore.VersionedCtx, roiName string, n int, i IndexType) (LabelSizes, error) {
 	if n < 0 {
 		return nil, fmt.Errorf("bad N (%d) in top request", n)
 	}
 	if n == 0 {
 		return LabelSizes{}, nil
 	}
-
-	store, err := datastore.GetOrderedKeyValueDB(d)
-	if err != nil {
-		return nil, err
-	}
-
-	// Setup key range for iterating through keys of this ElementType.
-	begTKey := NewTypeSizeLabelTKey(i, math.MaxUint32-1, 0)
-	endTKey := NewTypeSizeLabelTKey(i, 0, math.MaxUint64)
-
-	d.RLock()
-	defer d.RUnlock()
-
-	// Iterate through the first N kv then abort.
-	shortCircuitErr := fmt.Errorf("Found data, aborting.")
-	lsz := make(LabelSizes, n)
-	rank := 0
-	err = store.ProcessRange(ctx, begTKey, endTKey, nil, func(chunk *storage.Chunk) error {
-		idxType, sz, label, err := DecodeTypeSizeLabelTKey(chunk.K)
-		if err != nil {
-			return err
-		}
-		if idxType != i {
-			return fmt.Errorf("bad iteration of keys: expected index type %s, got %s", i, idxType)
-		}
-		lsz[rank] = LabelSize{Label: label, Size: sz}
-		rank++
-		if rank >= n {
-			return shortCircuitErr
-		}
-		return nil
-	})
-	if err != shortCircuitErr && err != nil {
-		return nil, err
-	}
-	return lsz[:rank], nil
+	return d.getRanked(ctx, roiName, i, 0, 0, n)
 }
 
 // GetLabelsByThreshold returns a sorted list of labels that meet the given minSize threshold.
 // We allow a maximum of MaxLabelsReturned returned labels and start with rank "offset".
 func (d *Data) GetLabelsByThreshold(ctx *datastore.VersionedCtx, i IndexType, minSize uint32, offset, num int) (LabelSizes, error) {
+	return d.GetROILabelsByThreshold(ctx, "", i, minSize, offset, num)
+}
+
+// GetROILabelsByThreshold returns a sorted list of labels that meet the given minSize threshold
+// within the named partition ROI.  If roiName is empty, counts across all partitions are used.
+func (d *Data) GetROILabelsByThreshold(ctx *datastore.VersionedCtx, roiName string, i IndexType, minSize uint32, offset, num int) (LabelSizes, error) {
 	var nReturns int
 	if num == 0 {
 		nReturns = MaxLabelsReturned
@@ -443,16 +497,32 @@ func (d *Data) GetLabelsByThreshold(ctx *datastore.VersionedCtx, i IndexType, mi
 	} else {
 		nReturns = num
 	}
+	return d.getRanked(ctx, roiName, i, minSize, offset, nReturns)
+}
+
+// getRanked returns up to nReturns labels in descending order of size, starting at rank offset,
+// with sizes >= minSize.
+func (d *Data) getRanked(ctx *datastore.VersionedCtx, roiName string, i IndexType, minSize uint32, offset, nReturns int) (LabelSizes, error) {
+	// Setup key range for iterating through keys of this ElementType.
+	var begTKey, endTKey storage.TKey
+	var r uint8
+	if roiName == "" {
+		begTKey = NewTypeSizeLabelTKey(i, math.MaxUint32-1, 0)
+		endTKey = NewTypeSizeLabelTKey(i, 0, math.MaxUint64)
+	} else {
+		var err error
+		if r, err = d.partitionIndex(roiName); err != nil {
+			return nil, err
+		}
+		begTKey = NewROITypeSizeLabelTKey(r, i, math.MaxUint32-1, 0)
+		endTKey = NewROITypeSizeLabelTKey(r, i, 0, math.MaxUint64)
+	}
 
 	store, err := datastore.GetOrderedKeyValueDB(d)
 	if err != nil {
 		return nil, err
 	}
 
-	// Setup key range for iterating through keys of this ElementType.
-	begTKey := NewTypeSizeLabelTKey(i, math.MaxUint32-1, 0)
-	endTKey := NewTypeSizeLabelTKey(i, 0, math.MaxUint64)
-
 	d.RLock()
 	defer d.RUnlock()
 
@@ -462,7 +532,15 @@ func (d *Data) GetLabelsByThreshold(ctx *datastore.VersionedCtx, i IndexType, mi
 	rank := 0
 	saved := 0
 	err = store.ProcessRange(ctx, begTKey, endTKey, nil, func(chunk *storage.Chunk) error {
-		idxType, sz, label, err := DecodeTypeSizeLabelTKey(chunk.K)
+		var idxType IndexType
+		var sz uint32
+		var label uint64
+		var err error
+		if roiName == "" {
+			idxType, sz, label, err = DecodeTypeSizeLabelTKey(chunk.K)
+		} else {
+			_, idxType, sz, label, err = DecodeROITypeSizeLabelTKey(chunk.K)
+		}
 		if err != nil {
 			return err
 		}
@@ -633,7 +711,12 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 			server.BadRequest(w, r, fmt.Errorf("unknown index type specified (%q)", parts[5]))
 			return
 		}
-		count, err := d.GetCountElementType(ctx, label, i)
+		var count uint32
+		if roiName := r.URL.Query().Get("roi"); roiName != "" {
+			count, err = d.GetROICountElementType(ctx, roiName, label, i)
+		} else {
+			count, err = d.GetCountElementType(ctx, label, i)
+		}
 		if err != nil {
 			server.BadRequest(w, r, err)
 			return
@@ -665,7 +748,7 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 			server.BadRequest(w, r, fmt.Errorf("unknown index type specified (%q)", parts[5]))
 			return
 		}
-		labelSizes, err := d.GetTopElementType(ctx, int(n), i)
+		labelSizes, err := d.GetROITopElementType(ctx, r.URL.Query().Get("roi"), int(n), i)
 		if err != nil {
 			server.BadRequest(w, r, err)
 			return
@@ -722,7 +805,7 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 			}
 		}
 
-		labels, err := d.GetLabelsByThreshold(ctx, i, minSize, offset, num)
+		labels, err := d.GetROILabelsByThreshold(ctx, queryStrings.Get("roi"), i, minSize, offset, num)
 		if err != nil {
 			server.BadRequest(w, r, err)
 			return
@@ -739,6 +822,44 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 		}
 		timedLog.Infof("HTTP %s: get %d labels for index type %s with threshold %d: %s", r.Method, num, i, t, r.URL)
 
+	case "composite":
+		// GET <api URL>/node/<UUID>/<data name>/composite/<N>?weights=...
+		if action != "get" {
+			server.BadRequest(w, r, "Only GET action is available on 'composite' endpoint.")
+			return
+		}
+		if len(parts) < 5 {
+			server.BadRequest(w, r, "Must include N after 'composite' endpoint.")
+			return
+		}
+		n, err := strconv.ParseUint(parts[4], 10, 32)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		queryStrings := r.URL.Query()
+		weights, err := ParseIndexWeights(queryStrings.Get("weights"))
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		ranked, err := d.GetTopComposite(ctx, int(n), weights, queryStrings.Get("roi"))
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-type", "application/json")
+		jsonBytes, err := json.Marshal(ranked)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		if _, err := w.Write(jsonBytes); err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		timedLog.Infof("HTTP %s: get top %d labels for composite of %d index types: %s", r.Method, n, len(weights), r.URL)
+
 	case "reload":
 		// POST <api URL>/node/<UUID>/<data name>/reload
 		if action != "post" {
@@ -757,13 +878,15 @@ func (d *Data) ReloadData(ctx *datastore.VersionedCtx) {
 	dvid.Infof("Started recalculation of labelsz %q...\n", d.DataName())
 }
 
-// Get all labeled annotations from synced annotation instance and repopulate the labelsz.
+// Get all labeled annotations from synced annotation instance and label sizes from synced
+// labelmap or labelarray instance, and repopulate the labelsz.
 func (d *Data) resync(ctx *datastore.VersionedCtx) {
 	timedLog := dvid.NewTimeLog()
 
 	annot := d.GetSyncedAnnotation()
-	if annot == nil {
-		dvid.Errorf("Unable to get synced annotation.  Aborting reload of labelsz %q.\n", d.DataName())
+	lblData := d.GetSyncedLabels()
+	if annot == nil && lblData == nil {
+		dvid.Errorf("Unable to get synced annotation or labels.  Aborting reload of labelsz %q.\n", d.DataName())
 		return
 	}
 
@@ -774,37 +897,100 @@ func (d *Data) resync(ctx *datastore.VersionedCtx) {
 	}
 
 	d.StartUpdate()
+	defer d.StopUpdate()
 	d.Lock()
+	defer d.Unlock()
+
+	if annot != nil {
+		if err := deleteIndexTypes(ctx, store, UnknownIndex, AllSyn); err != nil {
+			dvid.Errorf("Unable to delete annotation denormalization for labelsz %q: %v\n", d.DataName(), err)
+			return
+		}
+		if err := deleteROIIndices(ctx, store); err != nil {
+			dvid.Errorf("Unable to delete ROI-partitioned denormalization for labelsz %q: %v\n", d.DataName(), err)
+			return
+		}
+		totLabels, err := d.resyncAnnotation(ctx, store, annot)
+		if err != nil {
+			dvid.Errorf("Error in reload of labelsz %q: %v\n", d.DataName(), err)
+		}
+		timedLog.Infof("Completed labelsz %q reload of %d labels from annotation %q", d.DataName(), totLabels, annot.DataName())
+	}
+
+	if lblData != nil {
+		if err := deleteIndexTypes(ctx, store, Voxels, Voxels); err != nil {
+			dvid.Errorf("Unable to delete voxel denormalization for labelsz %q: %v\n", d.DataName(), err)
+			return
+		}
+		totLabels, err := d.resyncVoxels(ctx, store, lblData)
+		if err != nil {
+			dvid.Errorf("Error in voxel reload of labelsz %q: %v\n", d.DataName(), err)
+		}
+		timedLog.Infof("Completed labelsz %q reload of %d label sizes from %q", d.DataName(), totLabels, lblData.DataName())
+	}
+}
 
-	minTSLTKey := storage.MinTKey(keyTypeSizeLabel)
-	maxTSLTKey := storage.MaxTKey(keyTypeSizeLabel)
+// deleteIndexTypes deletes all denormalizations for the index types from first to last inclusive.
+func deleteIndexTypes(ctx *datastore.VersionedCtx, store storage.OrderedKeyValueDB, first, last IndexType) error {
+	minTSLTKey := NewTypeSizeLabelTKey(first, math.MaxUint32, 0)
+	maxTSLTKey := NewTypeSizeLabelTKey(last, 0, math.MaxUint64)
 	if err := store.DeleteRange(ctx, minTSLTKey, maxTSLTKey); err != nil {
-		dvid.Errorf("Unable to delete type-size-label denormalization for labelsz %q: %v\n", d.DataName(), err)
-		d.Unlock()
-		d.StopUpdate()
-		return
+		return err
 	}
+	minTypeTKey := NewTypeLabelTKey(first, 0)
+	maxTypeTKey := NewTypeLabelTKey(last, math.MaxUint64)
+	return store.DeleteRange(ctx, minTypeTKey, maxTypeTKey)
+}
 
-	minTypeTKey := storage.MinTKey(keyTypeLabel)
-	maxTypeTKey := storage.MaxTKey(keyTypeLabel)
-	if err := store.DeleteRange(ctx, minTypeTKey, maxTypeTKey); err != nil {
-		dvid.Errorf("Unable to delete type-label denormalization for labelsz %q: %v\n", d.DataName(), err)
-		d.Unlock()
-		d.StopUpdate()
-		return
+// deleteROIIndices deletes all ROI-partitioned denormalizations.
+func deleteROIIndices(ctx *datastore.VersionedCtx, store storage.OrderedKeyValueDB) error {
+	minTKey := storage.MinTKey(keyROITypeSizeLabel)
+	maxTKey := storage.MaxTKey(keyROITypeSizeLabel)
+	if err := store.DeleteRange(ctx, minTKey, maxTKey); err != nil {
+		return err
 	}
+	minTKey = storage.MinTKey(keyROITypeLabel)
+	maxTKey = storage.MaxTKey(keyROITypeLabel)
+	return store.DeleteRange(ctx, minTKey, maxTKey)
+}
 
+func (d *Data) resyncAnnotation(ctx *datastore.VersionedCtx, store storage.OrderedKeyValueDB, annot *annotation.Data) (totLabels uint64, err error) {
 	buf := make([]byte, 4)
 	var indexMap [AllSyn]uint32
-	var totLabels uint64
+	roiIndexMaps := make([][AllSyn]uint32, len(d.PartitionROIs))
 	err = annot.ProcessLabelAnnotations(ctx.VersionID(), func(label uint64, elems annotation.ElementsNR) {
 		totLabels++
 		for i := IndexType(0); i < AllSyn; i++ {
 			indexMap[i] = 0
 		}
+		for r := range roiIndexMaps {
+			roiIndexMaps[r] = [AllSyn]uint32{}
+		}
 		for _, elem := range elems {
 			if d.inROI(elem.Pos) {
-				indexMap[elementToIndexType(elem.Kind)]++
+				i := elementToIndexType(elem.Kind)
+				indexMap[i]++
+				for _, r := range d.partitionsWithin(elem.Pos) {
+					roiIndexMaps[r][i]++
+				}
+			}
+		}
+		for r, roiIndexMap := range roiIndexMaps {
+			var allsyn uint32
+			for i := IndexType(0); i < AllSyn; i++ {
+				if roiIndexMap[i] > 0 {
+					binary.LittleEndian.PutUint32(buf, roiIndexMap[i])
+					store.Put(ctx, NewROITypeLabelTKey(uint8(r), i, label), buf)
+					store.Put(ctx, NewROITypeSizeLabelTKey(uint8(r), i, roiIndexMap[i], label), nil)
+					if i == PostSyn || i == PreSyn || i == Gap {
+						allsyn += roiIndexMap[i]
+					}
+				}
+			}
+			if allsyn > 0 {
+				binary.LittleEndian.PutUint32(buf, allsyn)
+				store.Put(ctx, NewROITypeLabelTKey(uint8(r), AllSyn, label), buf)
+				store.Put(ctx, NewROITypeSizeLabelTKey(uint8(r), AllSyn, allsyn, label), nil)
 			}
 		}
 		var allsyn uint32
@@ -820,11 +1006,64 @@ func (d *Data) resync(ctx *datastore.VersionedCtx) {
 		store.Put(ctx, NewTypeLabelTKey(AllSyn, label), buf)
 		store.Put(ctx, NewTypeSizeLabelTKey(AllSyn, allsyn, label), nil)
 	})
-	if err != nil {
-		dvid.Errorf("Error in reload of labelsz %q: %v\n", d.DataName(), err)
+	return
+}
+
+// resyncVoxels stores the voxel count of every label indexed by the synced labelmap or labelarray.
+func (d *Data) resyncVoxels(ctx *datastore.VersionedCtx, store storage.OrderedKeyValueDB, lblData dvid.Data) (totLabels uint64, err error) {
+	v := ctx.VersionID()
+	var lblStore storage.OrderedKeyValueDB
+	if lblStore, err = datastore.GetOrderedKeyValueDB(lblData); err != nil {
+		return
+	}
+	lblCtx := datastore.NewVersionedCtx(lblData, v)
+
+	var begTKey, endTKey storage.TKey
+	var decode func(storage.TKey) (uint64, error)
+	var getSize func(uint64) (uint64, error)
+	switch lblData.(type) {
+	case *labelmap.Data:
+		begTKey, endTKey = labelmap.NewLabelIndexTKey(1), labelmap.NewLabelIndexTKey(math.MaxUint64)
+		decode = labelmap.DecodeLabelIndexTKey
+		getSize = func(label uint64) (uint64, error) {
+			return labelmap.GetLabelSize(lblData, v, label, false)
+		}
+	case *labelarray.Data:
+		begTKey, endTKey = labelarray.NewLabelIndexTKey(1), labelarray.NewLabelIndexTKey(math.MaxUint64)
+		decode = labelarray.DecodeLabelIndexTKey
+		getSize = func(label uint64) (uint64, error) {
+			meta, err := labelarray.GetLabelIndex(lblData, v, label)
+			if err != nil || meta == nil {
+				return 0, err
+			}
+			return meta.Voxels, nil
+		}
+	default:
+		err = fmt.Errorf("labelsz can't get voxel counts from data %q of type %s", lblData.DataName(), lblData.TypeName())
+		return
 	}
-	d.Unlock()
-	d.StopUpdate()
 
-	timedLog.Infof("Completed labelsz %q reload of %d labels from annotation %q", d.DataName(), totLabels, annot.DataName())
+	var tks []storage.TKey
+	if tks, err = lblStore.KeysInRange(lblCtx, begTKey, endTKey); err != nil {
+		return
+	}
+	buf := make([]byte, 4)
+	for _, tk := range tks {
+		var label, size uint64
+		if label, err = decode(tk); err != nil {
+			return
+		}
+		if size, err = getSize(label); err != nil {
+			return
+		}
+		if size == 0 {
+			continue
+		}
+		totLabels++
+		count := d.voxelCount(label, size)
+		binary.LittleEndian.PutUint32(buf, count)
+		store.Put(ctx, NewTypeLabelTKey(Voxels, label), buf)
+		store.Put(ctx, NewTypeSizeLabelTKey(Voxels, count, label), nil)
+	}
+	return
 }
@@ -623,3 +623,249 @@ func TestLabelsResync(t *testing.T) {
 
 	checkSequencing(t, uuid)
 }
+
+func checkVoxelRanking(t *testing.T, uuid dvid.UUID, name, expected string) {
+	if err := datastore.BlockOnUpdating(uuid, "labels"); err != nil {
+		t.Fatalf("Error blocking on sync of labels: %v\n", err)
+	}
+	if err := datastore.BlockOnUpdating(uuid, dvid.InstanceName(name)); err != nil {
+		t.Fatalf("Error blocking on sync of %s labelsz: %v\n", name, err)
+	}
+	url := fmt.Sprintf("%snode/%s/%s/top/5/Voxels", server.WebAPIPath, uuid, name)
+	data := server.TestHTTP(t, "GET", url, nil)
+	if string(data) != expected {
+		t.Errorf("Got back incorrect Voxels ranking for %s:\n%s\nExpected:\n%s\n", name, string(data), expected)
+	}
+}
+
+func TestVoxelRankings(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := datastore.NewTestRepo()
+	var config dvid.Config
+	server.CreateTestInstance(t, uuid, "labelmap", "labels", config)
+	server.CreateTestInstance(t, uuid, "labelsz", "sizes", config)
+	server.CreateTestSync(t, uuid, "sizes", "labels")
+
+	_ = createLabelTestVolume(t, uuid, "labels")
+	checkVoxelRanking(t, uuid, "sizes", `[{"Label":100,"Size":1048576},{"Label":200,"Size":524288},{"Label":300,"Size":524288}]`)
+
+	url := fmt.Sprintf("%snode/%s/sizes/count/200/Voxels", server.WebAPIPath, uuid)
+	data := server.TestHTTP(t, "GET", url, nil)
+	if string(data) != `{"Label":200,"Voxels":524288}` {
+		t.Errorf("Got back incorrect Voxels count for label 200: %s\n", string(data))
+	}
+
+	// Merge should remove label 300 and add its voxels to label 200.
+	testMerge := mergeJSON(`[200, 300]`)
+	testMerge.send(t, uuid, "labels")
+	checkVoxelRanking(t, uuid, "sizes", `[{"Label":100,"Size":1048576},{"Label":200,"Size":1048576}]`)
+
+	url = fmt.Sprintf("%snode/%s/sizes/threshold/1048576/Voxels", server.WebAPIPath, uuid)
+	data = server.TestHTTP(t, "GET", url, nil)
+	if string(data) != `[{"Label":100,"Size":1048576},{"Label":200,"Size":1048576}]` {
+		t.Errorf("Got back incorrect Voxels threshold after merge: %s\n", string(data))
+	}
+
+	// Cleave supervoxel 300 back out of label 200.
+	url = fmt.Sprintf("%snode/%s/labels/cleave/200", server.WebAPIPath, uuid)
+	data = server.TestHTTP(t, "POST", url, bytes.NewBufferString("[300]"))
+	var cleaveResp struct {
+		CleavedLabel uint64
+	}
+	if err := json.Unmarshal(data, &cleaveResp); err != nil {
+		t.Fatalf("couldn't parse cleave response %q: %v\n", string(data), err)
+	}
+	expected := fmt.Sprintf(`[{"Label":100,"Size":1048576},{"Label":200,"Size":524288},{"Label":%d,"Size":524288}]`, cleaveResp.CleavedLabel)
+	checkVoxelRanking(t, uuid, "sizes", expected)
+
+	// Overwrite part of label 100 with a new label.
+	volume := newTestVolume(64, 64, 64)
+	volume.add(400, 0, 0, 0, 64, 64, 64)
+	volume.put(t, uuid, "labels")
+	expected = fmt.Sprintf(`[{"Label":100,"Size":786432},{"Label":200,"Size":524288},{"Label":%d,"Size":524288},{"Label":400,"Size":262144}]`, cleaveResp.CleavedLabel)
+	checkVoxelRanking(t, uuid, "sizes", expected)
+
+	// Erase the cleaved label and half of label 400.
+	url = fmt.Sprintf("%snode/%s/labels/erase/%d", server.WebAPIPath, uuid, cleaveResp.CleavedLabel)
+	server.TestHTTP(t, "POST", url, nil)
+	url = fmt.Sprintf("%snode/%s/labels/erase/400?maxz=31", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", url, nil)
+	expected = `[{"Label":100,"Size":786432},{"Label":200,"Size":524288},{"Label":400,"Size":131072}]`
+	checkVoxelRanking(t, uuid, "sizes", expected)
+
+	// A labelsz synced after ingestion should get the same rankings after reload.
+	server.CreateTestInstance(t, uuid, "labelsz", "resized", config)
+	server.CreateTestSync(t, uuid, "resized", "labels")
+	url = fmt.Sprintf("%snode/%s/resized/reload", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", url, nil)
+	time.Sleep(100 * time.Millisecond)
+	checkVoxelRanking(t, uuid, "resized", expected)
+}
+
+func TestPartitionROIs(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := datastore.NewTestRepo()
+	var config dvid.Config
+	server.CreateTestInstance(t, uuid, "labelmap", "labels", config)
+	_ = createLabelTestVolume(t, uuid, "labels")
+	if err := datastore.BlockOnUpdating(uuid, "labels"); err != nil {
+		t.Fatalf("Error blocking on sync of labels: %v\n", err)
+	}
+
+	server.CreateTestInstance(t, uuid, "annotation", "mysynapses", config)
+	server.CreateTestSync(t, uuid, "mysynapses", "labels")
+
+	// roiA is the test ROI from (32, 32, 32) to (95, 95, 95) and roiB is the z < 32 slab.
+	server.CreateTestInstance(t, uuid, "roi", "roiA", config)
+	server.TestHTTP(t, "POST", fmt.Sprintf("%snode/%s/roiA/roi", server.WebAPIPath, uuid), getROIReader())
+	server.CreateTestInstance(t, uuid, "roi", "roiB", config)
+	roiB := `[[0,0,0,3],[0,1,0,3],[0,2,0,3],[0,3,0,3]]`
+	server.TestHTTP(t, "POST", fmt.Sprintf("%snode/%s/roiB/roi", server.WebAPIPath, uuid), strings.NewReader(roiB))
+
+	partitions := fmt.Sprintf("roiA,%s;roiB,%s", uuid, uuid)
+	config.Set("ROIs", partitions)
+	server.CreateTestInstance(t, uuid, "labelsz", "parts", config)
+	server.CreateTestSync(t, uuid, "parts", "mysynapses")
+
+	elements := `[
+		{"Pos":[40,40,40],"Kind":"PreSyn"},
+		{"Pos":[10,10,10],"Kind":"PreSyn"},
+		{"Pos":[50,50,50],"Kind":"PostSyn"},
+		{"Pos":[70,40,40],"Kind":"PostSyn"},
+		{"Pos":[80,10,10],"Kind":"PostSyn"},
+		{"Pos":[90,50,20],"Kind":"PostSyn"},
+		{"Pos":[100,100,100],"Kind":"PreSyn"},
+		{"Pos":[70,60,60],"Kind":"PreSyn"}
+	]`
+	url := fmt.Sprintf("%snode/%s/mysynapses/elements", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", url, strings.NewReader(elements))
+
+	checkGet := func(name, endpoint, expected string) {
+		if err := datastore.BlockOnUpdating(uuid, "mysynapses"); err != nil {
+			t.Fatalf("Error blocking on sync of synapses: %v\n", err)
+		}
+		if err := datastore.BlockOnUpdating(uuid, dvid.InstanceName(name)); err != nil {
+			t.Fatalf("Error blocking on sync of %s labelsz: %v\n", name, err)
+		}
+		url := fmt.Sprintf("%snode/%s/%s/%s", server.WebAPIPath, uuid, name, endpoint)
+		data := server.TestHTTP(t, "GET", url, nil)
+		if string(data) != expected {
+			t.Errorf("Got back incorrect response for %s %s:\n%s\nExpected:\n%s\n", name, endpoint, string(data), expected)
+		}
+	}
+
+	checkGet("parts", "top/3/PostSyn?roi=roiB", `[{"Label":200,"Size":2}]`)
+	checkGet("parts", "top/5/PreSyn?roi=roiA", `[{"Label":100,"Size":1},{"Label":200,"Size":1}]`)
+	checkGet("parts", "top/5/AllSyn?roi=roiA", `[{"Label":100,"Size":2},{"Label":200,"Size":2}]`)
+	checkGet("parts", "threshold/2/AllSyn?roi=roiB", `[{"Label":200,"Size":2}]`)
+	checkGet("parts", "count/200/PostSyn?roi=roiA", `{"Label":200,"PostSyn":1}`)
+	checkGet("parts", "top/3/PreSyn", `[{"Label":100,"Size":2},{"Label":200,"Size":1},{"Label":300,"Size":1}]`)
+
+	composite := `[{"Label":100,"Score":2.5,"Counts":{"PostSyn":1,"PreSyn":2},"ROIs":{"roiA":{"PostSyn":1,"PreSyn":1},"roiB":{"PostSyn":0,"PreSyn":1}}},` +
+		`{"Label":200,"Score":2.5,"Counts":{"PostSyn":3,"PreSyn":1},"ROIs":{"roiA":{"PostSyn":1,"PreSyn":1},"roiB":{"PostSyn":2,"PreSyn":0}}},` +
+		`{"Label":300,"Score":1,"Counts":{"PostSyn":0,"PreSyn":1},"ROIs":{"roiA":{"PostSyn":0,"PreSyn":0},"roiB":{"PostSyn":0,"PreSyn":0}}}]`
+	checkGet("parts", "composite/5?weights=PreSyn,PostSyn:0.5", composite)
+	checkGet("parts", "composite/1?weights=PostSyn:2&roi=roiB", `[{"Label":200,"Score":4,"Counts":{"PostSyn":2},"ROIs":{"roiA":{"PostSyn":1},"roiB":{"PostSyn":2}}}]`)
+
+	badURLs := []string{
+		"top/3/PostSyn?roi=roiC",
+		"composite/3?weights=Voxels&roi=roiA",
+		"composite/3?weights=PreSyn:abc",
+		"composite/3?weights=PreSyn:-1",
+		"composite/3?weights=PreSyn:NaN",
+		"composite/3",
+	}
+	for _, endpoint := range badURLs {
+		server.TestBadHTTP(t, "GET", fmt.Sprintf("%snode/%s/parts/%s", server.WebAPIPath, uuid, endpoint), nil)
+	}
+
+	// Moving a PostSyn from roiB to roiA should change the partitioned counts.
+	url = fmt.Sprintf("%snode/%s/mysynapses/move/80_10_10/80_40_40", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", url, nil)
+	checkGet("parts", "top/3/PostSyn?roi=roiB", `[{"Label":200,"Size":1}]`)
+	checkGet("parts", "count/200/PostSyn?roi=roiA", `{"Label":200,"PostSyn":2}`)
+
+	// A labelsz created after the annotations should get the same partitioned counts on reload.
+	server.CreateTestInstance(t, uuid, "labelsz", "parts2", config)
+	server.CreateTestSync(t, uuid, "parts2", "mysynapses")
+	server.TestHTTP(t, "POST", fmt.Sprintf("%snode/%s/parts2/reload", server.WebAPIPath, uuid), nil)
+	time.Sleep(100 * time.Millisecond)
+	checkGet("parts2", "top/3/PostSyn?roi=roiA", `[{"Label":200,"Size":2},{"Label":100,"Size":1}]`)
+	checkGet("parts2", "top/5/AllSyn?roi=roiB", `[{"Label":100,"Size":1},{"Label":200,"Size":1}]`)
+}
+
+func TestCompositeTies(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, v := datastore.NewTestRepo()
+	var config dvid.Config
+	server.CreateTestInstance(t, uuid, "labelsz", "ties", config)
+	d, err := GetByUUIDName(uuid, "ties")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := datastore.NewVersionedCtx(d, v)
+	putCount := func(i IndexType, label uint64, count uint32) {
+		buf := make([]byte, 4)
+		binary.LittleEndian.PutUint32(buf, count)
+		if err := store.Put(ctx, NewTypeLabelTKey(i, label), buf); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Put(ctx, NewTypeSizeLabelTKey(i, count, label), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Fill more than a batch with higher scores, then tie at score 2 between high labels
+	// read in the PreSyn scan and low labels read in the PostSyn scan.
+	for label := uint64(1000); label < 1250; label++ {
+		putCount(PreSyn, label, 3)
+	}
+	for label := uint64(500); label < 650; label++ {
+		putCount(PreSyn, label, 1)
+	}
+	for label := uint64(1); label <= 150; label++ {
+		putCount(PostSyn, label, 2)
+	}
+
+	weights := []IndexWeight{{PreSyn, 2}, {PostSyn, 1}}
+	for _, n := range []int{252, 400, 450} {
+		ranked, err := d.GetTopComposite(ctx, n, weights, "")
+		if err != nil {
+			t.Fatalf("error getting top %d composite: %v\n", n, err)
+		}
+		if len(ranked) != n {
+			t.Fatalf("expected %d ranked labels, got %d\n", n, len(ranked))
+		}
+		for k, cl := range ranked {
+			var expected uint64
+			switch {
+			case k < 250:
+				expected = 1000 + uint64(k)
+			case k < 400:
+				expected = 1 + uint64(k-250)
+			default:
+				expected = 500 + uint64(k-400)
+			}
+			if cl.Label != expected {
+				t.Errorf("top %d composite: expected label %d at rank %d, got %d (score %g)\n", n, expected, k, cl.Label, cl.Score)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,469 @@
+/*
+	This file supports counts partitioned by ROIs and rankings by weighted combinations of index types.
+*/
+
+package labelsz
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/datatype/roi"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// MaxPartitionROIs is the maximum number of partition ROIs for a labelsz instance.
+const MaxPartitionROIs = 256
+
+// parsePartitionROIs parses a list of ROI specifications of the form
+// "<roiname>,<uuid>;<roiname>,<uuid>;..." where each ROI name must be unique.
+func parsePartitionROIs(s string) ([]string, error) {
+	var specs []string
+	names := make(map[string]struct{})
+	for _, spec := range strings.Split(s, ";") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		parts := strings.Split(spec, ",")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("bad ROIs value (%q) expected %q", spec, "<roiname>,<uuid>")
+		}
+		if _, found := names[parts[0]]; found {
+			return nil, fmt.Errorf("ROI %q is specified more than once in ROIs", parts[0])
+		}
+		names[parts[0]] = struct{}{}
+		specs = append(specs, spec)
+	}
+	if len(specs) > MaxPartitionROIs {
+		return nil, fmt.Errorf("%d ROIs specified, exceeding maximum of %d", len(specs), MaxPartitionROIs)
+	}
+	return specs, nil
+}
+
+// PartitionNames returns the names of the partition ROIs in index order.
+func (d *Data) PartitionNames() []string {
+	names := make([]string, len(d.PartitionROIs))
+	for r, spec := range d.PartitionROIs {
+		names[r] = strings.Split(spec, ",")[0]
+	}
+	return names
+}
+
+// partitionIndex returns the index of the named partition ROI.
+func (d *Data) partitionIndex(name string) (uint8, error) {
+	for r, partName := range d.PartitionNames() {
+		if partName == name {
+			return uint8(r), nil
+		}
+	}
+	return 0, fmt.Errorf("labelsz %q has no partition ROI %q", d.DataName(), name)
+}
+
+// getPartitionROIs returns the immutable partition ROIs, loading them on first use.
+// A partition ROI that cannot be loaded is nil and contains nothing.
+func (d *Data) getPartitionROIs() []*roi.Immutable {
+	d.iMutex.Lock()
+	defer d.iMutex.Unlock()
+	if !d.partitionsChecked {
+		d.partitionsChecked = true
+		d.partitionROIs = make([]*roi.Immutable, len(d.PartitionROIs))
+		for r, spec := range d.PartitionROIs {
+			iROI, err := roi.ImmutableBySpec(spec)
+			if err != nil {
+				dvid.Errorf("could not load immutable partition ROI by spec %q: %v\n", spec, err)
+				continue
+			}
+			d.partitionROIs[r] = iROI
+		}
+	}
+	return d.partitionROIs
+}
+
+// partitionsWithin returns the indices of the partition ROIs that contain the given point.
+func (d *Data) partitionsWithin(pos dvid.Point3d) []uint8 {
+	if len(d.PartitionROIs) == 0 {
+		return nil
+	}
+	var within []uint8
+	for r, iROI := range d.getPartitionROIs() {
+		if iROI != nil && iROI.VoxelWithin(pos) {
+			within = append(within, uint8(r))
+		}
+	}
+	return within
+}
+
+// returned map will only include ROI-partitioned labels that had previously been seen (has key)
+func (d *Data) getROICounts(ctx *datastore.VersionedCtx, mods map[roiIndexedLabel]int32) (counts map[roiIndexedLabel]uint32, err error) {
+	var store storage.OrderedKeyValueDB
+	store, err = datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return
+	}
+
+	counts = make(map[roiIndexedLabel]uint32, len(mods))
+	for rl := range mods {
+		var r uint8
+		var i IndexType
+		var label uint64
+		if r, i, label, err = decodeROIIndexedLabel(rl); err != nil {
+			return
+		}
+		var val []byte
+		if val, err = store.Get(ctx, NewROITypeLabelTKey(r, i, label)); err != nil {
+			return
+		}
+		if val == nil {
+			continue
+		}
+		if len(val) != 4 {
+			err = fmt.Errorf("bad size in value for roi %d, index type %s, label %d: value has length %d", r, i, label, len(val))
+			return
+		}
+		counts[rl] = binary.LittleEndian.Uint32(val)
+	}
+	return
+}
+
+// batchROIMods adds the modifications of ROI-partitioned counts to the batch.
+func (d *Data) batchROIMods(batch storage.Batch, counts map[roiIndexedLabel]uint32, mods map[roiIndexedLabel]int32) {
+	for rl, change := range mods {
+		if change == 0 {
+			continue
+		}
+		r, i, label, err := decodeROIIndexedLabel(rl)
+		if err != nil {
+			dvid.Criticalf("couldn't decode roiIndexedLabel %s for modify elements sync of %s: %v\n", rl, d.DataName(), err)
+			continue
+		}
+		count, found := counts[rl]
+		if found {
+			batch.Delete(NewROITypeSizeLabelTKey(r, i, count, label))
+		}
+		if change < 0 && -change > int32(count) {
+			dvid.Criticalf("labelsz %q received element mod that would subtract %d with only count %d in roi %d!  Setting floor at 0.\n", d.DataName(), -change, count, r)
+			change = int32(-count)
+		}
+		newcount := uint32(int32(count) + change)
+		if newcount == 0 {
+			batch.Delete(NewROITypeLabelTKey(r, i, label))
+			continue
+		}
+		buf := make([]byte, 4)
+		binary.LittleEndian.PutUint32(buf, newcount)
+		batch.Put(NewROITypeLabelTKey(r, i, label), buf)
+		batch.Put(NewROITypeSizeLabelTKey(r, i, newcount, label), nil)
+	}
+}
+
+// GetROICountElementType returns a count of the given ElementType for a given label within
+// the named partition ROI.
+func (d *Data) GetROICountElementType(ctx *datastore.VersionedCtx, roiName string, label uint64, i IndexType) (uint32, error) {
+	r, err := d.partitionIndex(roiName)
+	if err != nil {
+		return 0, err
+	}
+	store, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return 0, err
+	}
+
+	d.RLock()
+	defer d.RUnlock()
+
+	val, err := store.Get(ctx, NewROITypeLabelTKey(r, i, label))
+	if err != nil {
+		return 0, err
+	}
+	if val == nil {
+		return 0, nil
+	}
+	if len(val) != 4 {
+		return 0, fmt.Errorf("bad size in value for roi %q, index type %s, label %d: value has length %d", roiName, i, label, len(val))
+	}
+	return binary.LittleEndian.Uint32(val), nil
+}
+
+// IndexWeight is the weight given an index type in a composite ranking.
+type IndexWeight struct {
+	Index  IndexType
+	Weight float64
+}
+
+// ParseIndexWeights parses a string of the form "PreSyn:1,PostSyn:0.2" into index weights.
+// An index type without a weight, e.g., "PreSyn,PostSyn:0.2", has weight 1.
+func ParseIndexWeights(s string) ([]IndexWeight, error) {
+	var weights []IndexWeight
+	seen := make(map[IndexType]struct{})
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		parts := strings.Split(term, ":")
+		if len(parts) > 2 {
+			return nil, fmt.Errorf("bad weight term %q, expected %q", term, "<index type>:<weight>")
+		}
+		i := StringToIndexType(parts[0])
+		if i == UnknownIndex {
+			return nil, fmt.Errorf("unknown index type specified (%q)", parts[0])
+		}
+		if _, found := seen[i]; found {
+			return nil, fmt.Errorf("index type %s given more than once in weights", i)
+		}
+		seen[i] = struct{}{}
+		w := IndexWeight{Index: i, Weight: 1}
+		if len(parts) == 2 {
+			var err error
+			if w.Weight, err = strconv.ParseFloat(parts[1], 64); err != nil {
+				return nil, fmt.Errorf("bad weight for index type %s: %v", i, err)
+			}
+			if !(w.Weight >= 0) || math.IsInf(w.Weight, 1) {
+				return nil, fmt.Errorf("weight for index type %s must be a non-negative number, got %s", i, parts[1])
+			}
+		}
+		weights = append(weights, w)
+	}
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("no index type weights given")
+	}
+	return weights, nil
+}
+
+// CompositeLabel is a label's score for a weighted combination of index types, along with
+// the counts that make up the score and their breakdown by partition ROI.
+type CompositeLabel struct {
+	Label  uint64
+	Score  float64
+	Counts map[string]uint32
+	ROIs   map[string]map[string]uint32 `json:",omitempty"`
+}
+
+// CompositeLabels is a slice of CompositeLabel sorted by descending score then label.
+type CompositeLabels []CompositeLabel
+
+func (s CompositeLabels) Len() int {
+	return len(s)
+}
+
+func (s CompositeLabels) Less(i, j int) bool {
+	if s[i].Score != s[j].Score {
+		return s[i].Score > s[j].Score
+	}
+	return s[i].Label < s[j].Label
+}
+
+func (s CompositeLabels) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// minCompositeBatch is the minimum number of labels read from each index per batch when
+// ranking composite scores.
+const minCompositeBatch = 100
+
+// compositeScan is a batched read of one weighted index type's labels in descending order
+// of count.
+type compositeScan struct {
+	weight IndexWeight
+	next   storage.TKey // first key of the next batch
+	last   uint32       // count of the last label read, which bounds any unread count
+	done   bool
+}
+
+// read returns the labels of up to batchSize keys following the last batch.
+func (scan *compositeScan) read(ctx *datastore.VersionedCtx, store storage.OrderedKeyValueDB, batchSize int, roiName string, sizeTKey func(IndexType, uint32, uint64) storage.TKey) ([]uint64, error) {
+	i := scan.weight.Index
+	shortCircuitErr := fmt.Errorf("Read batch, aborting.")
+	labels := make([]uint64, 0, batchSize)
+	var lastLabel uint64
+	err := store.ProcessRange(ctx, scan.next, sizeTKey(i, 0, math.MaxUint64), nil, func(chunk *storage.Chunk) error {
+		var idxType IndexType
+		var sz uint32
+		var label uint64
+		var err error
+		if roiName == "" {
+			idxType, sz, label, err = DecodeTypeSizeLabelTKey(chunk.K)
+		} else {
+			_, idxType, sz, label, err = DecodeROITypeSizeLabelTKey(chunk.K)
+		}
+		if err != nil {
+			return err
+		}
+		if idxType != i {
+			return fmt.Errorf("bad iteration of keys: expected index type %s, got %s", i, idxType)
+		}
+		labels = append(labels, label)
+		scan.last, lastLabel = sz, label
+		if len(labels) == batchSize {
+			return shortCircuitErr
+		}
+		return nil
+	})
+	if err != shortCircuitErr && err != nil {
+		return nil, err
+	}
+	switch {
+	case len(labels) < batchSize:
+		scan.done = true
+	case lastLabel < math.MaxUint64:
+		scan.next = sizeTKey(i, scan.last, lastLabel+1)
+	case scan.last > 0:
+		scan.next = sizeTKey(i, scan.last-1, 0)
+	default:
+		scan.done = true
+	}
+	return labels, nil
+}
+
+// GetTopComposite returns the top N labels ranked by a weighted sum of counts for the given
+// index types with non-negative weights.  If roiName is not empty, only counts within that
+// partition ROI are used.  Labels with equal scores, including those tied at the Nth score,
+// are ordered by ascending label ID.
+// Each returned label includes the counts of each weighted index type in each partition ROI.
+func (d *Data) GetTopComposite(ctx *datastore.VersionedCtx, n int, weights []IndexWeight, roiName string) (CompositeLabels, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("bad N (%d) in composite request", n)
+	}
+	if n > MaxLabelsReturned {
+		return nil, fmt.Errorf("N (%d) in composite request exceeds maximum of %d labels", n, MaxLabelsReturned)
+	}
+	for _, w := range weights {
+		if !(w.Weight >= 0) || math.IsInf(w.Weight, 1) {
+			return nil, fmt.Errorf("weight for index type %s must be a non-negative number, got %g", w.Index, w.Weight)
+		}
+	}
+	if n == 0 {
+		return CompositeLabels{}, nil
+	}
+	var r uint8
+	if roiName != "" {
+		var err error
+		if r, err = d.partitionIndex(roiName); err != nil {
+			return nil, err
+		}
+		for _, w := range weights {
+			if w.Index == Voxels {
+				return nil, fmt.Errorf("voxel counts are not partitioned by ROI")
+			}
+		}
+	}
+
+	store, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return nil, err
+	}
+
+	sizeTKey := func(i IndexType, sz uint32, label uint64) storage.TKey {
+		if roiName == "" {
+			return NewTypeSizeLabelTKey(i, sz, label)
+		}
+		return NewROITypeSizeLabelTKey(r, i, sz, label)
+	}
+	labelTKey := func(i IndexType, label uint64) storage.TKey {
+		if roiName == "" {
+			return NewTypeLabelTKey(i, label)
+		}
+		return NewROITypeLabelTKey(r, i, label)
+	}
+
+	d.RLock()
+	defer d.RUnlock()
+
+	// Read the size-sorted index of each weighted type in lockstep batches.  Since weights are
+	// non-negative, no unread label can score more than the weighted sum of the last counts
+	// read, so the scan stops once the Nth best score exceeds that threshold.
+	scans := make([]*compositeScan, len(weights))
+	for k, w := range weights {
+		scans[k] = &compositeScan{weight: w, next: sizeTKey(w.Index, math.MaxUint32, 0), last: math.MaxUint32}
+	}
+	batchSize := n
+	if batchSize < minCompositeBatch {
+		batchSize = minCompositeBatch
+	}
+	scored := make(map[uint64]struct{})
+	var ranked CompositeLabels
+	for {
+		var threshold float64
+		var remaining bool
+		for _, scan := range scans {
+			if !scan.done {
+				labels, err := scan.read(ctx, store, batchSize, roiName, sizeTKey)
+				if err != nil {
+					return nil, err
+				}
+				for _, label := range labels {
+					if _, found := scored[label]; found {
+						continue
+					}
+					scored[label] = struct{}{}
+					cl := CompositeLabel{Label: label, Counts: make(map[string]uint32, len(weights))}
+					for _, w := range weights {
+						val, err := store.Get(ctx, labelTKey(w.Index, label))
+						if err != nil {
+							return nil, err
+						}
+						var count uint32
+						if val != nil {
+							if len(val) != 4 {
+								return nil, fmt.Errorf("bad size in value for index type %s, label %d: value has length %d", w.Index, label, len(val))
+							}
+							count = binary.LittleEndian.Uint32(val)
+						}
+						cl.Counts[w.Index.String()] = count
+						cl.Score += w.Weight * float64(count)
+					}
+					ranked = append(ranked, cl)
+				}
+			}
+			if !scan.done {
+				remaining = true
+				threshold += scan.weight.Weight * float64(scan.last)
+			}
+		}
+		sort.Sort(ranked)
+		if len(ranked) > n {
+			ranked = ranked[:n]
+		}
+		if !remaining || (len(ranked) == n && ranked[n-1].Score > threshold) {
+			break
+		}
+	}
+	if ranked == nil {
+		ranked = CompositeLabels{}
+	}
+
+	// Add the breakdown by partition ROI for the returned labels.
+	names := d.PartitionNames()
+	if len(names) == 0 {
+		return ranked, nil
+	}
+	for k := range ranked {
+		ranked[k].ROIs = make(map[string]map[string]uint32, len(names))
+		for r, name := range names {
+			roiCounts := make(map[string]uint32, len(weights))
+			for _, w := range weights {
+				if w.Index == Voxels {
+					continue
+				}
+				val, err := store.Get(ctx, NewROITypeLabelTKey(uint8(r), w.Index, ranked[k].Label))
+				if err != nil {
+					return nil, err
+				}
+				var count uint32
+				if len(val) == 4 {
+					count = binary.LittleEndian.Uint32(val)
+				}
+				roiCounts[w.Index.String()] = count
+			}
+			ranked[k].ROIs[name] = roiCounts
+		}
+	}
+	return ranked, nil
+}
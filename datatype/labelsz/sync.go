@@ -3,10 +3,14 @@ package labelsz
 import (
 	"encoding/binary"
 	"fmt"
+	"math"
 	"sync"
 
 	"github.com/janelia-flyem/dvid/datastore"
 	"github.com/janelia-flyem/dvid/datatype/annotation"
+	"github.com/janelia-flyem/dvid/datatype/common/labels"
+	"github.com/janelia-flyem/dvid/datatype/labelarray"
+	"github.com/janelia-flyem/dvid/datatype/labelmap"
 	"github.com/janelia-flyem/dvid/dvid"
 	"github.com/janelia-flyem/dvid/storage"
 )
@@ -40,7 +44,9 @@ func (d *Data) Shutdown(wg *sync.WaitGroup) {
 }
 
 // GetSyncSubs implements the datastore.Syncer interface.  Returns a list of subscriptions
-// to the sync data instance that will notify the receiver.
+// to the sync data instance that will notify the receiver.  Syncs to a labelmap or labelarray
+// instance maintain the Voxels index, while syncs to an annotation instance maintain the
+// element indices.
 func (d *Data) GetSyncSubs(synced dvid.Data) (datastore.SyncSubs, error) {
 	if d.syncCh == nil {
 		if err := d.InitDataHandlers(); err != nil {
@@ -48,6 +54,27 @@ func (d *Data) GetSyncSubs(synced dvid.Data) (datastore.SyncSubs, error) {
 		}
 	}
 
+	var evts []string
+	switch synced.(type) {
+	case *labelmap.Data:
+		// labelmap sends split sizes only through DeltaSplit.
+		evts = []string{labels.ChangeSizeEvent, labels.MergeBlockEvent, labels.SplitLabelEvent}
+	case *labelarray.Data:
+		// labelarray sends split sizes through ChangeSizeEvent so DeltaSplit would double count.
+		evts = []string{labels.ChangeSizeEvent, labels.MergeBlockEvent}
+	}
+	if len(evts) != 0 {
+		subs := make(datastore.SyncSubs, len(evts))
+		for i, evt := range evts {
+			subs[i] = datastore.SyncSub{
+				Event:  datastore.SyncEvent{synced.DataUUID(), evt},
+				Notify: d.DataUUID(),
+				Ch:     d.syncCh,
+			}
+		}
+		return subs, nil
+	}
+
 	subs := datastore.SyncSubs{
 		datastore.SyncSub{
 			Event:  datastore.SyncEvent{synced.DataUUID(), annotation.ModifyElementsEvent},
@@ -63,7 +90,7 @@ func (d *Data) GetSyncSubs(synced dvid.Data) (datastore.SyncSubs, error) {
 	return subs, nil
 }
 
-// If annotation elements are added or deleted, adjust the label counts.
+// If annotation elements are added or deleted or label sizes change, adjust the label counts.
 func (d *Data) processEvents() {
 	batcher, err := datastore.GetKeyValueBatcher(d)
 	if err != nil {
@@ -90,8 +117,27 @@ func (d *Data) processEvents() {
 			switch delta := msg.Delta.(type) {
 			case annotation.DeltaModifyElements:
 				d.modifyElements(ctx, delta, batcher)
+			case labels.DeltaNewSize:
+				d.modifyVoxels(ctx, map[uint64]uint64{delta.Label: delta.Size}, nil, batcher)
+			case labels.DeltaModSize:
+				d.modifyVoxels(ctx, nil, map[uint64]int64{delta.Label: delta.SizeChange}, batcher)
+			case labels.DeltaReplaceSize:
+				d.modifyVoxels(ctx, map[uint64]uint64{delta.Label: delta.NewSize}, nil, batcher)
+			case labels.DeltaDeleteSize:
+				d.modifyVoxels(ctx, map[uint64]uint64{delta.Label: 0}, nil, batcher)
+			case labels.DeltaMerge:
+				sizes := make(map[uint64]uint64, len(delta.Merged)+1)
+				for merged := range delta.Merged {
+					sizes[merged] = 0
+				}
+				sizes[delta.Target] = delta.TargetVoxels + delta.MergedVoxels
+				d.modifyVoxels(ctx, sizes, nil, batcher)
+			case labels.DeltaSplit:
+				sizes := map[uint64]uint64{delta.NewLabel: delta.SplitVoxels}
+				changes := map[uint64]int64{delta.OldLabel: -int64(delta.SplitVoxels)}
+				d.modifyVoxels(ctx, sizes, changes, batcher)
 			default:
-				dvid.Criticalf("Cannot sync annotations from modify element.  Got unexpected delta: %v\n", msg)
+				dvid.Criticalf("Cannot sync labelsz %q.  Got unexpected delta: %v\n", d.DataName(), msg)
 			}
 			d.StopUpdate()
 
@@ -140,6 +186,7 @@ func (d *Data) getCounts(ctx *datastore.VersionedCtx, labels map[indexedLabel]in
 
 func (d *Data) modifyElements(ctx *datastore.VersionedCtx, delta annotation.DeltaModifyElements, batcher storage.KeyValueBatcher) {
 	mods := make(map[indexedLabel]int32)
+	roiMods := make(map[roiIndexedLabel]int32)
 	for _, elemPos := range delta.Add {
 		if d.inROI(elemPos.Pos) {
 			i := toIndexedLabel(elemPos)
@@ -148,6 +195,12 @@ func (d *Data) modifyElements(ctx *datastore.VersionedCtx, delta annotation.Delt
 				i = newIndexedLabel(AllSyn, elemPos.Label)
 				mods[i]++
 			}
+			for _, r := range d.partitionsWithin(elemPos.Pos) {
+				roiMods[newROIIndexedLabel(r, elementToIndexType(elemPos.Kind), elemPos.Label)]++
+				if elemPos.Kind.IsSynaptic() {
+					roiMods[newROIIndexedLabel(r, AllSyn, elemPos.Label)]++
+				}
+			}
 		}
 	}
 	for _, elemPos := range delta.Del {
@@ -158,6 +211,12 @@ func (d *Data) modifyElements(ctx *datastore.VersionedCtx, delta annotation.Delt
 				i = newIndexedLabel(AllSyn, elemPos.Label)
 				mods[i]--
 			}
+			for _, r := range d.partitionsWithin(elemPos.Pos) {
+				roiMods[newROIIndexedLabel(r, elementToIndexType(elemPos.Kind), elemPos.Label)]--
+				if elemPos.Kind.IsSynaptic() {
+					roiMods[newROIIndexedLabel(r, AllSyn, elemPos.Label)]--
+				}
+			}
 		}
 	}
 
@@ -170,6 +229,11 @@ func (d *Data) modifyElements(ctx *datastore.VersionedCtx, delta annotation.Delt
 		dvid.Errorf("labelsz %q couldn't get counts for modified labels: %v\n", d.DataName(), err)
 		return
 	}
+	roiCounts, err := d.getROICounts(ctx, roiMods)
+	if err != nil {
+		dvid.Errorf("labelsz %q couldn't get ROI counts for modified labels: %v\n", d.DataName(), err)
+		return
+	}
 
 	// Modify the keys based on the change in counts, then delete or store.
 	batch := batcher.NewBatch(ctx)
@@ -209,6 +273,7 @@ func (d *Data) modifyElements(ctx *datastore.VersionedCtx, delta annotation.Delt
 		batch.Put(NewTypeLabelTKey(i, label), buf)
 		batch.Put(NewTypeSizeLabelTKey(i, newcount, label), nil)
 	}
+	d.batchROIMods(batch, roiCounts, roiMods)
 
 	if err := batch.Commit(); err != nil {
 		dvid.Criticalf("bad commit in labelsz %q during sync of modify elements: %v\n", d.DataName(), err)
@@ -216,6 +281,85 @@ func (d *Data) modifyElements(ctx *datastore.VersionedCtx, delta annotation.Delt
 	}
 }
 
+// voxelCount returns a voxel count of a label for storage, which is capped at the maximum
+// uint32.  Saturation is logged since later relative changes to the stored count will drift
+// from the true size until the label's size is set again.
+func (d *Data) voxelCount(label, size uint64) uint32 {
+	if size > math.MaxUint32 {
+		dvid.Errorf("labelsz %q voxel count %d for label %d saturated at %d; count is inaccurate until its size is reset\n", d.DataName(), size, label, uint32(math.MaxUint32))
+		return math.MaxUint32
+	}
+	return uint32(size)
+}
+
+// modifyVoxels sets the Voxels index to the given sizes for some labels and adds the given
+// changes for other labels.  A zero size removes the label from the Voxels index.
+// Since counts are stored as uint32, voxel counts saturate at the maximum uint32, which is
+// logged.
+func (d *Data) modifyVoxels(ctx *datastore.VersionedCtx, sizes map[uint64]uint64, changes map[uint64]int64, batcher storage.KeyValueBatcher) {
+	mods := make(map[indexedLabel]int32, len(sizes)+len(changes))
+	for label := range sizes {
+		mods[newIndexedLabel(Voxels, label)] = 0
+	}
+	for label := range changes {
+		mods[newIndexedLabel(Voxels, label)] = 0
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	counts, err := d.getCounts(ctx, mods)
+	if err != nil {
+		dvid.Errorf("labelsz %q couldn't get voxel counts for modified labels: %v\n", d.DataName(), err)
+		return
+	}
+
+	batch := batcher.NewBatch(ctx)
+	for il := range mods {
+		_, label, err := decodeIndexedLabel(il)
+		if err != nil {
+			dvid.Criticalf("couldn't decode indexedLabel %s for voxel sync of %s: %v\n", il, d.DataName(), err)
+			continue
+		}
+		if label == 0 {
+			continue
+		}
+		count, found := counts[il]
+		var newcount uint32
+		if size, isSet := sizes[label]; isSet {
+			newcount = d.voxelCount(label, size)
+		} else {
+			change := changes[label]
+			if count == math.MaxUint32 && change != 0 {
+				dvid.Errorf("labelsz %q applying voxel change of %d to saturated count of label %d, so the result is inaccurate\n", d.DataName(), change, label)
+			}
+			if change < 0 && uint64(-change) > uint64(count) {
+				dvid.Criticalf("labelsz %q received voxel change of %d for label %d with only count %d!  Setting floor at 0.\n", d.DataName(), change, label, count)
+				change = -int64(count)
+			}
+			newcount = d.voxelCount(label, uint64(int64(count)+change))
+		}
+		if found {
+			if newcount == count {
+				continue
+			}
+			batch.Delete(NewTypeSizeLabelTKey(Voxels, count, label))
+		}
+		if newcount == 0 {
+			batch.Delete(NewTypeLabelTKey(Voxels, label))
+			continue
+		}
+		buf := make([]byte, 4)
+		binary.LittleEndian.PutUint32(buf, newcount)
+		batch.Put(NewTypeLabelTKey(Voxels, label), buf)
+		batch.Put(NewTypeSizeLabelTKey(Voxels, newcount, label), nil)
+	}
+
+	if err := batch.Commit(); err != nil {
+		dvid.Criticalf("bad commit in labelsz %q during sync of voxel counts: %v\n", d.DataName(), err)
+	}
+}
+
 /*
 func (d *Data) syncSet(in <-chan datastore.SyncMessage, done <-chan struct{}) {
 	batcher, err := datastore.GetKeyValueBatcher(d)
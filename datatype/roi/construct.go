@@ -0,0 +1,152 @@
+/*
+	This file supports construction of ROIs from label bodies and annotation points.
+*/
+
+package roi
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/dvid"
+)
+
+// LabelBlockSource is implemented by label data that can list the blocks occupied by labels.
+type LabelBlockSource interface {
+	// GetLabelBlocks returns the coordinates of blocks at the given scale that contain any
+	// of the labels as well as the size of those blocks in scale 0 voxels.
+	GetLabelBlocks(v dvid.VersionID, lbls []uint64, scale uint8) (dvid.IZYXSlice, dvid.Point3d, error)
+}
+
+// PointSource is implemented by data that can list the positions of annotated points.
+type PointSource interface {
+	// GetElementPoints returns the voxel positions of elements of the given comma-separated
+	// kinds with the given tag, within the given label if nonzero.  Empty kinds or tag are
+	// not used for filtering.
+	GetElementPoints(v dvid.VersionID, label uint64, tag, kinds string) ([]dvid.Point3d, error)
+}
+
+// ConstructRequest describes how to construct an ROI from a labelmap or annotation instance.
+type ConstructRequest struct {
+	// Source is the name of a labelmap or annotation instance at the version of the request.
+	Source dvid.InstanceName
+
+	// Bodies and Scale select the blocks of a labelmap at the given scale occupied by bodies.
+	Bodies []uint64
+	Scale  uint8
+
+	// Label, Tag, and Kinds select annotation elements, where Kinds is a comma-separated
+	// list of element kinds.  Any that are empty or zero are not used to filter elements.
+	Label uint64
+	Tag   string
+	Kinds string
+
+	// Hull fills the 3d convex hull of the selected blocks.
+	Hull bool
+
+	// Dilate is the radius of a structuring element of the given Shape ("cube" or "sphere")
+	// that dilates the selected blocks after any convex hull.
+	Dilate int32
+	Shape  string
+}
+
+// labelBlockSpans returns the normalized spans of ROI blocks that intersect label blocks with
+// the given size in voxels.
+func labelBlockSpans(blocks dvid.IZYXSlice, labelBlockSize, blockSize dvid.Point3d) (dvid.Spans, error) {
+	var spans dvid.Spans
+	for _, izyx := range blocks {
+		bcoord, err := izyx.ToChunkPoint3d()
+		if err != nil {
+			return nil, err
+		}
+		var minBlock, maxBlock dvid.ChunkPoint3d
+		for i := 0; i < 3; i++ {
+			minVoxel := int64(bcoord[i]) * int64(labelBlockSize[i])
+			maxVoxel := minVoxel + int64(labelBlockSize[i]) - 1
+			minBlock[i] = int32(floorDiv(minVoxel, int64(blockSize[i])))
+			maxBlock[i] = int32(floorDiv(maxVoxel, int64(blockSize[i])))
+		}
+		spans = append(spans, boundsSpans(minBlock, maxBlock)...)
+	}
+	return spans.Normalize(), nil
+}
+
+// pointSpans returns the normalized spans of ROI blocks containing the points.
+func pointSpans(pts []dvid.Point3d, blockSize dvid.Point3d) dvid.Spans {
+	spans := make(dvid.Spans, len(pts))
+	for i, pt := range pts {
+		bcoord := pt.Chunk(blockSize).(dvid.ChunkPoint3d)
+		spans[i] = dvid.Span{bcoord[2], bcoord[1], bcoord[0], bcoord[0]}
+	}
+	return spans.Normalize()
+}
+
+// ConstructSpans returns the normalized spans of an ROI with the given block size that is
+// constructed from a labelmap or annotation instance at the given version.
+func ConstructSpans(req ConstructRequest, blockSize dvid.Point3d, uuid dvid.UUID, v dvid.VersionID) (dvid.Spans, error) {
+	if req.Source == "" {
+		return nil, fmt.Errorf("ROI construction requires a Source data instance")
+	}
+	var rows []elementRow
+	if req.Dilate != 0 {
+		var err error
+		if rows, err = getElementRows(req.Shape, req.Dilate); err != nil {
+			return nil, err
+		}
+	}
+	source, err := datastore.GetDataByUUIDName(uuid, req.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	var spans dvid.Spans
+	if len(req.Bodies) != 0 {
+		labelSource, ok := source.(LabelBlockSource)
+		if !ok {
+			return nil, fmt.Errorf("data %q cannot supply blocks for bodies", req.Source)
+		}
+		blocks, labelBlockSize, err := labelSource.GetLabelBlocks(v, req.Bodies, req.Scale)
+		if err != nil {
+			return nil, err
+		}
+		if spans, err = labelBlockSpans(blocks, labelBlockSize, blockSize); err != nil {
+			return nil, err
+		}
+	} else {
+		ptSource, ok := source.(PointSource)
+		if !ok {
+			return nil, fmt.Errorf("data %q cannot supply annotation points; use Bodies for label data", req.Source)
+		}
+		pts, err := ptSource.GetElementPoints(v, req.Label, req.Tag, req.Kinds)
+		if err != nil {
+			return nil, err
+		}
+		spans = pointSpans(pts, blockSize)
+	}
+
+	if req.Hull {
+		spans = hullSpans(spans)
+	}
+	if rows != nil {
+		spans = dilateSpans(spans, rows)
+	}
+	return spans, nil
+}
+
+// PutConstruct constructs an ROI given a JSON ConstructRequest and replaces this ROI's spans
+// at the given version with the result.
+func (d *Data) PutConstruct(uuid dvid.UUID, v dvid.VersionID, jsonBytes []byte) (*SetOpResult, error) {
+	var req ConstructRequest
+	if err := json.Unmarshal(jsonBytes, &req); err != nil {
+		return nil, fmt.Errorf("bad ROI construction JSON: %v", err)
+	}
+	spans, err := ConstructSpans(req, d.BlockSize, uuid, v)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.PutSpans(v, spans, true); err != nil {
+		return nil, err
+	}
+	return &SetOpResult{Spans: len(spans), Blocks: spans.Count()}, nil
+}
@@ -0,0 +1,270 @@
+/*
+	This file computes the 3d convex hull of ROI blocks using exact integer arithmetic.
+*/
+
+package roi
+
+import (
+	"sort"
+
+	"github.com/janelia-flyem/dvid/dvid"
+)
+
+// hullPoint is a point in doubled block coordinates so block corners and centers are integral.
+type hullPoint [3]int64
+
+func (a hullPoint) sub(b hullPoint) hullPoint {
+	return hullPoint{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func (a hullPoint) cross(b hullPoint) hullPoint {
+	return hullPoint{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+func (a hullPoint) dot(b hullPoint) int64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+// hullFace is a triangle whose vertices are counter-clockwise when viewed from outside.
+type hullFace [3]hullPoint
+
+func (f hullFace) normal() hullPoint {
+	return f[1].sub(f[0]).cross(f[2].sub(f[0]))
+}
+
+// orient returns a positive number if p is outside the face's plane, zero if on it.
+func (f hullFace) orient(p hullPoint) int64 {
+	return f.normal().dot(p.sub(f[0]))
+}
+
+// containsCoplanar returns true if p, which must be coplanar with the face, is within or on
+// the edges of the face triangle.  The test projects onto the plane most aligned with the face.
+func (f hullFace) containsCoplanar(p hullPoint) bool {
+	n := f.normal()
+	drop := 0
+	for i := 1; i < 3; i++ {
+		if abs64(n[i]) > abs64(n[drop]) {
+			drop = i
+		}
+	}
+	u, v := (drop+1)%3, (drop+2)%3
+	orient2d := func(a, b, c hullPoint) int64 {
+		return (b[u]-a[u])*(c[v]-a[v]) - (b[v]-a[v])*(c[u]-a[u])
+	}
+	sign := orient2d(f[0], f[1], f[2])
+	for i := 0; i < 3; i++ {
+		if orient2d(f[i], f[(i+1)%3], p)*sign < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func abs64(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// floorDiv returns the floor of a/b for b != 0.
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+// ceilDiv returns the ceiling of a/b for b != 0.
+func ceilDiv(a, b int64) int64 {
+	return -floorDiv(-a, b)
+}
+
+type hullPoints2d []hullPoint
+
+func (p hullPoints2d) Len() int      { return len(p) }
+func (p hullPoints2d) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p hullPoints2d) Less(i, j int) bool {
+	if p[i][0] != p[j][0] {
+		return p[i][0] < p[j][0]
+	}
+	return p[i][1] < p[j][1]
+}
+
+// planarHull returns the vertices of the 2d convex hull in x and y of points that share the
+// same z, dropping any collinear points.
+func planarHull(pts hullPoints2d) []hullPoint {
+	if len(pts) < 3 {
+		return pts
+	}
+	sort.Sort(pts)
+	turn := func(o, a, b hullPoint) int64 {
+		return (a[0]-o[0])*(b[1]-o[1]) - (a[1]-o[1])*(b[0]-o[0])
+	}
+	hull := make([]hullPoint, 0, 2*len(pts))
+	for _, p := range pts {
+		for len(hull) >= 2 && turn(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	lower := len(hull) + 1
+	for i := len(pts) - 2; i >= 0; i-- {
+		p := pts[i]
+		for len(hull) >= lower && turn(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	return hull[:len(hull)-1]
+}
+
+// convexHull returns the triangulated faces of the convex hull of the points, or nil if the
+// points are all coplanar.  Points are added incrementally, and a face is replaced if the new
+// point is outside its plane or in its plane but outside the triangle.
+func convexHull(pts []hullPoint) []hullFace {
+	if len(pts) < 4 {
+		return nil
+	}
+	a := pts[0]
+	var b, c, d hullPoint
+	var i int
+	for i = 1; i < len(pts) && pts[i] == a; i++ {
+	}
+	if i == len(pts) {
+		return nil
+	}
+	b = pts[i]
+	for i = 1; i < len(pts); i++ {
+		if b.sub(a).cross(pts[i].sub(a)) != (hullPoint{}) {
+			break
+		}
+	}
+	if i == len(pts) {
+		return nil
+	}
+	c = pts[i]
+	for i = 1; i < len(pts); i++ {
+		if (hullFace{a, b, c}).orient(pts[i]) != 0 {
+			break
+		}
+	}
+	if i == len(pts) {
+		return nil
+	}
+	d = pts[i]
+
+	// Initial tetrahedron with every face oriented away from its opposite vertex.
+	var faces []hullFace
+	for _, tri := range [][4]hullPoint{{a, b, c, d}, {a, b, d, c}, {a, c, d, b}, {b, c, d, a}} {
+		face := hullFace{tri[0], tri[1], tri[2]}
+		if face.orient(tri[3]) > 0 {
+			face[1], face[2] = face[2], face[1]
+		}
+		faces = append(faces, face)
+	}
+
+	for _, p := range pts {
+		var outside bool
+		for _, face := range faces {
+			if face.orient(p) > 0 {
+				outside = true
+				break
+			}
+		}
+		if !outside {
+			continue
+		}
+		edges := make(map[[2]hullPoint]struct{})
+		kept := faces[:0]
+		var visible []hullFace
+		for _, face := range faces {
+			o := face.orient(p)
+			if o > 0 || (o == 0 && !face.containsCoplanar(p)) {
+				visible = append(visible, face)
+				for j := 0; j < 3; j++ {
+					edges[[2]hullPoint{face[j], face[(j+1)%3]}] = struct{}{}
+				}
+			} else {
+				kept = append(kept, face)
+			}
+		}
+		faces = kept
+		for _, face := range visible {
+			for j := 0; j < 3; j++ {
+				u, v := face[j], face[(j+1)%3]
+				if _, found := edges[[2]hullPoint{v, u}]; !found {
+					faces = append(faces, hullFace{u, v, p})
+				}
+			}
+		}
+	}
+	return faces
+}
+
+// hullSpans returns the normalized spans of all blocks whose centers are within the convex hull
+// of the given blocks.
+func hullSpans(spans dvid.Spans) dvid.Spans {
+	if len(spans) == 0 {
+		return dvid.Spans{}
+	}
+
+	// Only the 2d hull vertices of each plane of block corners can be 3d hull vertices.
+	planes := make(map[int64]hullPoints2d)
+	for _, span := range spans {
+		x0, x1 := 2*int64(span[2]), 2*int64(span[3])+2
+		y0, z0 := 2*int64(span[1]), 2*int64(span[0])
+		for _, z := range []int64{z0, z0 + 2} {
+			for _, y := range []int64{y0, y0 + 2} {
+				planes[z] = append(planes[z], hullPoint{x0, y, z}, hullPoint{x1, y, z})
+			}
+		}
+	}
+	var pts []hullPoint
+	for _, planePts := range planes {
+		pts = append(pts, planarHull(planePts)...)
+	}
+	faces := convexHull(pts)
+
+	offset, size := spans.Extents()
+	type halfSpace struct {
+		n      hullPoint
+		offset int64
+	}
+	halfSpaces := make([]halfSpace, len(faces))
+	for i, face := range faces {
+		n := face.normal()
+		halfSpaces[i] = halfSpace{n, n.dot(face[0])}
+	}
+	var hull dvid.Spans
+	for z := offset[2]; z < offset[2]+size[2]; z++ {
+		for y := offset[1]; y < offset[1]+size[1]; y++ {
+			x0, x1 := int64(offset[0]), int64(offset[0]+size[0]-1)
+			cy, cz := 2*int64(y)+1, 2*int64(z)+1
+			for _, hs := range halfSpaces {
+				// Block center x satisfies nx * (2x+1) <= c.
+				c := hs.offset - hs.n[1]*cy - hs.n[2]*cz
+				switch {
+				case hs.n[0] > 0:
+					if maxX := floorDiv(floorDiv(c, hs.n[0])-1, 2); maxX < x1 {
+						x1 = maxX
+					}
+				case hs.n[0] < 0:
+					if minX := ceilDiv(ceilDiv(c, hs.n[0])-1, 2); minX > x0 {
+						x0 = minX
+					}
+				case c < 0:
+					x1 = x0 - 1
+				}
+				if x1 < x0 {
+					break
+				}
+			}
+			if x0 <= x1 {
+				hull = append(hull, dvid.Span{z, y, int32(x0), int32(x1)})
+			}
+		}
+	}
+	return hull
+}
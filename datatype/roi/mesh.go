@@ -0,0 +1,142 @@
+/*
+	This file supports export of ROI surfaces as OBJ or PLY meshes.
+*/
+
+package roi
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/janelia-flyem/dvid/dvid"
+)
+
+// roiMesh is a quad mesh of the exposed block faces of an ROI, where vertices are block
+// corners in block coordinates and are shared between adjacent faces.
+type roiMesh struct {
+	verts []dvid.Point3d
+	index map[dvid.Point3d]int
+	quads [][4]int
+}
+
+func (m *roiMesh) vertex(pt dvid.Point3d) int {
+	i, found := m.index[pt]
+	if !found {
+		i = len(m.verts)
+		m.index[pt] = i
+		m.verts = append(m.verts, pt)
+	}
+	return i
+}
+
+// quad adds a face with vertices given in counter-clockwise order when viewed from outside.
+func (m *roiMesh) quad(a, b, c, d dvid.Point3d) {
+	m.quads = append(m.quads, [4]int{m.vertex(a), m.vertex(b), m.vertex(c), m.vertex(d)})
+}
+
+// newROIMesh returns a mesh with one quad per exposed block face of the given spans.
+func newROIMesh(spans dvid.Spans) *roiMesh {
+	m := &roiMesh{index: make(map[dvid.Point3d]int)}
+	norm := spans.Normalize()
+	for _, span := range norm {
+		z, y, x0, x1 := span[0], span[1], span[2], span[3]+1
+		m.quad(dvid.Point3d{x0, y, z}, dvid.Point3d{x0, y, z + 1}, dvid.Point3d{x0, y + 1, z + 1}, dvid.Point3d{x0, y + 1, z})
+		m.quad(dvid.Point3d{x1, y, z}, dvid.Point3d{x1, y + 1, z}, dvid.Point3d{x1, y + 1, z + 1}, dvid.Point3d{x1, y, z + 1})
+	}
+	// Blocks whose neighbor in -y, +y, -z, or +z is not in the ROI.
+	for _, span := range subtractSpans(norm, shiftSpans(norm, 1, 0)) {
+		z, y := span[0], span[1]
+		for x := span[2]; x <= span[3]; x++ {
+			m.quad(dvid.Point3d{x, y, z}, dvid.Point3d{x + 1, y, z}, dvid.Point3d{x + 1, y, z + 1}, dvid.Point3d{x, y, z + 1})
+		}
+	}
+	for _, span := range subtractSpans(norm, shiftSpans(norm, -1, 0)) {
+		z, y := span[0], span[1]+1
+		for x := span[2]; x <= span[3]; x++ {
+			m.quad(dvid.Point3d{x, y, z}, dvid.Point3d{x, y, z + 1}, dvid.Point3d{x + 1, y, z + 1}, dvid.Point3d{x + 1, y, z})
+		}
+	}
+	for _, span := range subtractSpans(norm, shiftSpans(norm, 0, 1)) {
+		z, y := span[0], span[1]
+		for x := span[2]; x <= span[3]; x++ {
+			m.quad(dvid.Point3d{x, y, z}, dvid.Point3d{x, y + 1, z}, dvid.Point3d{x + 1, y + 1, z}, dvid.Point3d{x + 1, y, z})
+		}
+	}
+	for _, span := range subtractSpans(norm, shiftSpans(norm, 0, -1)) {
+		z, y := span[0]+1, span[1]
+		for x := span[2]; x <= span[3]; x++ {
+			m.quad(dvid.Point3d{x, y, z}, dvid.Point3d{x + 1, y, z}, dvid.Point3d{x + 1, y + 1, z}, dvid.Point3d{x, y + 1, z})
+		}
+	}
+	return m
+}
+
+// vertexString returns the coordinates of a block corner after scaling by the given
+// per-dimension factors, e.g., block size times voxel size.
+func vertexString(pt dvid.Point3d, scale [3]float64) string {
+	return strconv.FormatFloat(float64(pt[0])*scale[0], 'g', -1, 64) + " " +
+		strconv.FormatFloat(float64(pt[1])*scale[1], 'g', -1, 64) + " " +
+		strconv.FormatFloat(float64(pt[2])*scale[2], 'g', -1, 64)
+}
+
+func (m *roiMesh) writeOBJ(w io.Writer, name string, scale [3]float64) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# dvid roi %q: %d vertices, %d faces\n", name, len(m.verts), len(m.quads))
+	fmt.Fprintf(bw, "o %s\n", name)
+	for _, pt := range m.verts {
+		fmt.Fprintf(bw, "v %s\n", vertexString(pt, scale))
+	}
+	for _, q := range m.quads {
+		fmt.Fprintf(bw, "f %d %d %d %d\n", q[0]+1, q[1]+1, q[2]+1, q[3]+1)
+	}
+	return bw.Flush()
+}
+
+func (m *roiMesh) writePLY(w io.Writer, name string, scale [3]float64) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "ply\nformat ascii 1.0\ncomment dvid roi %q\n", name)
+	fmt.Fprintf(bw, "element vertex %d\nproperty float x\nproperty float y\nproperty float z\n", len(m.verts))
+	fmt.Fprintf(bw, "element face %d\nproperty list uchar int vertex_indices\nend_header\n", len(m.quads))
+	for _, pt := range m.verts {
+		fmt.Fprintf(bw, "%s\n", vertexString(pt, scale))
+	}
+	for _, q := range m.quads {
+		fmt.Fprintf(bw, "4 %d %d %d %d\n", q[0], q[1], q[2], q[3])
+	}
+	return bw.Flush()
+}
+
+// WriteMesh writes a mesh of the ROI block surface in "obj" or "ply" format.  Coordinates are
+// in voxels unless physical is true, in which case the voxel size of synced data is used.
+func (d *Data) WriteMesh(w io.Writer, v dvid.VersionID, format string, physical bool) error {
+	d.RLock()
+	spans, err := d.GetSpans(v)
+	d.RUnlock()
+	if err != nil {
+		return err
+	}
+	scale := [3]float64{float64(d.BlockSize[0]), float64(d.BlockSize[1]), float64(d.BlockSize[2])}
+	if physical {
+		res, err := d.GetResolution()
+		if err != nil {
+			return err
+		}
+		if res == nil {
+			return fmt.Errorf("ROI %q is not synced to data with a voxel resolution", d.DataName())
+		}
+		for dim := 0; dim < 3; dim++ {
+			scale[dim] *= float64(res.VoxelSize[dim])
+		}
+	}
+	m := newROIMesh(dvid.Spans(spans))
+	switch format {
+	case "", "obj":
+		return m.writeOBJ(w, string(d.DataName()), scale)
+	case "ply":
+		return m.writePLY(w, string(d.DataName()), scale)
+	default:
+		return fmt.Errorf("unknown mesh format %q, expected \"obj\" or \"ply\"", format)
+	}
+}
@@ -0,0 +1,217 @@
+/*
+	This file supports morphological operations on ROIs at block resolution.
+*/
+
+package roi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/janelia-flyem/dvid/dvid"
+)
+
+// MaxMorphRadius is the maximum radius in blocks of a structuring element.
+const MaxMorphRadius = 64
+
+// Shapes of structuring elements for morphological operations.
+const (
+	CubeElement   = "cube"
+	SphereElement = "sphere"
+)
+
+// elementRow is one (z, y) row of a structuring element centered at the origin, which
+// covers x offsets from -dx to dx.
+type elementRow struct {
+	dz, dy, dx int32
+}
+
+// getElementRows returns the rows of a cube or sphere structuring element with the given
+// radius in blocks.  A cube of radius r has sides of 2r+1 blocks.
+func getElementRows(shape string, radius int32) ([]elementRow, error) {
+	if radius < 0 || radius > MaxMorphRadius {
+		return nil, fmt.Errorf("structuring element radius must be between 0 and %d, got %d", MaxMorphRadius, radius)
+	}
+	var rows []elementRow
+	switch strings.ToLower(shape) {
+	case "", CubeElement:
+		for dz := -radius; dz <= radius; dz++ {
+			for dy := -radius; dy <= radius; dy++ {
+				rows = append(rows, elementRow{dz, dy, radius})
+			}
+		}
+	case SphereElement:
+		r2 := radius * radius
+		for dz := -radius; dz <= radius; dz++ {
+			for dy := -radius; dy <= radius; dy++ {
+				rem := r2 - dz*dz - dy*dy
+				if rem < 0 {
+					continue
+				}
+				var dx int32
+				for (dx+1)*(dx+1) <= rem {
+					dx++
+				}
+				rows = append(rows, elementRow{dz, dy, dx})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown structuring element shape %q", shape)
+	}
+	return rows, nil
+}
+
+// rowKeys are (z, y) block rows sorted by z then y.
+type rowKeys [][2]int32
+
+func (k rowKeys) Len() int      { return len(k) }
+func (k rowKeys) Swap(i, j int) { k[i], k[j] = k[j], k[i] }
+func (k rowKeys) Less(i, j int) bool {
+	if k[i][0] != k[j][0] {
+		return k[i][0] < k[j][0]
+	}
+	return k[i][1] < k[j][1]
+}
+
+// dilateSpans returns the normalized dilation of spans by the structuring element.  Each
+// output row is built and normalized from the input rows it covers, so memory is
+// proportional to the result rather than the number of spans times element rows.
+func dilateSpans(spans dvid.Spans, rows []elementRow) dvid.Spans {
+	inRows := make(map[[2]int32]dvid.Spans)
+	for _, span := range spans {
+		key := [2]int32{span[0], span[1]}
+		inRows[key] = append(inRows[key], span)
+	}
+	outRows := make(map[[2]int32]struct{})
+	for key := range inRows {
+		for _, row := range rows {
+			outRows[[2]int32{key[0] + row.dz, key[1] + row.dy}] = struct{}{}
+		}
+	}
+	keys := make(rowKeys, 0, len(outRows))
+	for key := range outRows {
+		keys = append(keys, key)
+	}
+	sort.Sort(keys)
+
+	dilated := dvid.Spans{}
+	var rowSpans dvid.Spans
+	for _, key := range keys {
+		rowSpans = rowSpans[:0]
+		for _, row := range rows {
+			for _, span := range inRows[[2]int32{key[0] - row.dz, key[1] - row.dy}] {
+				rowSpans = append(rowSpans, dvid.Span{key[0], key[1], span[2] - row.dx, span[3] + row.dx})
+			}
+		}
+		dilated = append(dilated, rowSpans.Normalize()...)
+	}
+	return dilated
+}
+
+// paddedComplement returns the normalized complement of the spans within their bounding
+// box padded by the given number of blocks on each side.
+func paddedComplement(spans dvid.Spans, pad int32) dvid.Spans {
+	offset, size := spans.Extents()
+	minBlock := dvid.ChunkPoint3d{offset[0] - pad, offset[1] - pad, offset[2] - pad}
+	maxBlock := dvid.ChunkPoint3d{offset[0] + size[0] - 1 + pad, offset[1] + size[1] - 1 + pad, offset[2] + size[2] - 1 + pad}
+	return subtractSpans(boundsSpans(minBlock, maxBlock), spans)
+}
+
+// erodeSpans returns the erosion of normalized spans by a symmetric structuring element
+// of the given radius, computed as the spans minus the dilation of their complement.
+func erodeSpans(spans dvid.Spans, rows []elementRow, radius int32) dvid.Spans {
+	if len(spans) == 0 {
+		return dvid.Spans{}
+	}
+	return subtractSpans(spans, dilateSpans(paddedComplement(spans, radius), rows))
+}
+
+// fillHoleSpans returns normalized spans with all enclosed holes filled, where a hole is
+// a 6-connected component of blocks outside the ROI that cannot reach the ROI bounds.
+func fillHoleSpans(spans dvid.Spans) dvid.Spans {
+	if len(spans) == 0 {
+		return dvid.Spans{}
+	}
+
+	// The complement within a box padded by one block is sorted by row, and its first
+	// span lies in the padding, which is connected to everything outside the ROI.
+	comp := paddedComplement(spans, 1)
+	parent := make([]int, len(comp))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+
+	rowStart := make(map[[2]int32]int)
+	for i := len(comp) - 1; i >= 0; i-- {
+		rowStart[[2]int32{comp[i][0], comp[i][1]}] = i
+	}
+	for i, span := range comp {
+		for _, next := range [][2]int32{{span[0], span[1] + 1}, {span[0] + 1, span[1]}} {
+			j, found := rowStart[next]
+			if !found {
+				continue
+			}
+			for ; j < len(comp) && comp[j][0] == next[0] && comp[j][1] == next[1] && comp[j][2] <= span[3]; j++ {
+				if comp[j][3] >= span[2] {
+					parent[find(j)] = find(i)
+				}
+			}
+		}
+	}
+
+	outside := find(0)
+	filled := make(dvid.Spans, len(spans), len(spans)+len(comp))
+	copy(filled, spans)
+	for i, span := range comp {
+		if find(i) != outside {
+			filled = append(filled, span)
+		}
+	}
+	return filled.Normalize()
+}
+
+// MorphSpans returns the normalized result of a morphological operation on spans.  The op
+// is one of "erode", "dilate", "open", "close", or "fillholes", where the structuring
+// element shape and radius are ignored for "fillholes".
+func MorphSpans(spans dvid.Spans, op, shape string, radius int32) (dvid.Spans, error) {
+	norm := spans.Normalize()
+	if op == "fillholes" {
+		return fillHoleSpans(norm), nil
+	}
+	rows, err := getElementRows(shape, radius)
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "erode":
+		return erodeSpans(norm, rows, radius), nil
+	case "dilate":
+		return dilateSpans(norm, rows), nil
+	case "open":
+		return dilateSpans(erodeSpans(norm, rows, radius), rows), nil
+	case "close":
+		return erodeSpans(dilateSpans(norm, rows), rows, radius), nil
+	default:
+		return nil, fmt.Errorf("unknown morphological operation %q", op)
+	}
+}
+
+// Morph returns the result of a morphological operation on this ROI at the given version.
+func (d *Data) Morph(v dvid.VersionID, op, shape string, radius int32) (dvid.Spans, error) {
+	d.RLock()
+	spans, err := d.GetSpans(v)
+	d.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return MorphSpans(spans, op, shape, radius)
+}
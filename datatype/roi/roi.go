@@ -56,6 +56,8 @@ $ dvid repo <UUID> new roi <data name> <settings...>
 
     Versioned      "true" or "false" (default)
     BlockSize      Size in pixels  (default: %d)
+    VoxelPrecision "true" or "false" (default).  If true, blocks on the ROI boundary can store
+                     a binary mask of voxels within the ROI via POST of "mask".
 	
     ------------------
 
@@ -132,6 +134,17 @@ GET <api URL>/node/<UUID>/<data name>/mask/0_1_2/<size>/<offset>
 	Returns a binary volume with non-zero elements for voxels within ROI.  The binary volume
 	has size 512 x 512 x 256 voxels and an offset of (100, 200, 300).
 
+POST <api URL>/node/<UUID>/<data name>/mask/0_1_2/<size>/<offset>
+
+	Modifies a voxel-precision ROI (created with VoxelPrecision=true) within the given subvolume
+	using a POSTed binary volume in ZYX order, where non-zero voxels are within the ROI and zero
+	voxels are outside it.  The ROI outside the subvolume is unchanged.  Blocks that end up
+	partially within the ROI store a compressed binary mask, while blocks entirely within the
+	ROI remain span-encoded without a mask.  The "roi" endpoint returns all blocks with any
+	voxels in the ROI, and the "mask", "ptquery", and "partition" endpoints as well as ROI
+	filtering of annotations use voxel precision.  Operations that write spans, e.g., POST of
+	"roi", "setop", or "construct", replace the ROI with whole blocks.
+
 
 POST <api URL>/node/<UUID>/<data name>/ptquery
 
@@ -159,17 +172,190 @@ GET <api URL>/node/<UUID>/<data name>/partition?batchsize=8
     optimized   If "true" or "on", partioning returns non-fixed sized subvolumes where the coverage
                   is better in terms of subvolumes having more active blocks.
 
-TODO (API endpoints that are planned in near future)
+    For voxel-precision ROIs, the JSON also includes "NumActiveVoxels" and "ActiveVoxels" for
+    each subvolume, the number of voxels within the ROI.
+
+POST <api URL>/node/<UUID>/<data name>/setop
+
+	Replaces this ROI with the result of a set operation on other ROIs, which may be at other
+	versions and may include this ROI.  All ROIs must have the same block size as this ROI.
+	To store the result in a new ROI, first create the roi instance.  The POSTed JSON is:
+
+	{
+		"Op": "difference",
+		"ROIs": ["medulla,3f8c", "glialshell"],
+		"MinBlock": [0, 0, 0],
+		"MaxBlock": [100, 200, 300]
+	}
+
+	"Op" is one of:
+
+	union          Blocks in any of the ROIs.
+	intersection   Blocks in all of the ROIs.
+	difference     Blocks in the first ROI but not in any of the other ROIs.
+	complement     Blocks within the bounds that are not in any of the ROIs.
 
-GET  <api URL>/node/<UUID>/<data name>/erode/<element size>
+	Each ROI is given as "<roiname>,<uuid>" where the uuid is optional and defaults to the
+	UUID of this request.  Union, intersection, and difference require at least two ROIs.
+	"MinBlock" and "MaxBlock" are inclusive block coordinate bounds that are required for
+	complement and clip the result of any other operation if given.  Complement bounds can
+	span at most 4194304 block rows in y and z; larger bounds return a 400 error.
 
-    Returns a ROI that has been eroded with a cubic structuring element of the given size.
+	Returns JSON with the number of spans and blocks in the resulting ROI:
+
+	{ "Spans": 1093, "Blocks": 23091 }
+
+POST <api URL>/node/<UUID>/<data name>/construct
+
+	Replaces this ROI with blocks occupied by labelmap bodies or annotation elements at the
+	version of the request.  The POSTed JSON is:
+
+	{
+		"Source": "segmentation",
+		"Bodies": [23, 1093],
+		"Scale": 2,
+		"Hull": true,
+		"Dilate": 1,
+		"Shape": "sphere"
+	}
+
+	or for an annotation instance:
+
+	{
+		"Source": "synapses",
+		"Label": 23,
+		"Tag": "mushroom-body",
+		"Kinds": "PreSyn,PostSyn",
+		"Hull": true
+	}
+
+	"Source" is the name of a labelmap or annotation instance.  For a labelmap, "Bodies" lists
+	the bodies whose blocks at the given "Scale" (default 0) form the ROI.  Blocks at scale s have
+	2^s times the size of scale 0 blocks, and any ROI block intersecting them is included.  For
+	annotations, any ROI block containing an element that passes all given filters is included,
+	where "Label" is a body id, "Tag" is an element tag, and "Kinds" is a comma-separated list of
+	element kinds.  If no filters are given, all elements are used.
+
+	If "Hull" is true, the ROI becomes all blocks whose centers are within the 3d convex hull of
+	the selected blocks.  If "Dilate" is a radius in blocks, the ROI is dilated afterward by a
+	structuring element of the given "Shape" ("cube" default or "sphere") as for "dilate" below.
+
+	Returns JSON with the number of spans and blocks in the resulting ROI as for "setop".
+
+GET  <api URL>/node/<UUID>/<data name>/erode/<radius>
+POST <api URL>/node/<UUID>/<data name>/erode/<radius>
+GET  <api URL>/node/<UUID>/<data name>/dilate/<radius>
+POST <api URL>/node/<UUID>/<data name>/dilate/<radius>
+GET  <api URL>/node/<UUID>/<data name>/open/<radius>
+POST <api URL>/node/<UUID>/<data name>/open/<radius>
+GET  <api URL>/node/<UUID>/<data name>/close/<radius>
+POST <api URL>/node/<UUID>/<data name>/close/<radius>
+GET  <api URL>/node/<UUID>/<data name>/fillholes
+POST <api URL>/node/<UUID>/<data name>/fillholes
+
+    Performs a morphological operation on the ROI at block resolution.  GET returns JSON
+    for the resulting ROI in the same format as the "roi" endpoint.  POST replaces the
+    ROI given by the "target" query string, or this ROI if no target is given, with the
+    result and returns JSON with the number of spans and blocks as for "setop".
 
     Example: 
 
-    GET <api URL>/node/3f8c/medulla/erode/1
+    GET <api URL>/node/3f8c/medulla/erode/1?shape=sphere
+
+    This returns JSON for an ROI that has been eroded by a sphere with radius of 1 block.
 
-    This returns JSON for an ROI that has been eroded by 1 block.
+    POST <api URL>/node/3f8c/medulla/close/2?target=medulla-closed
+
+    This replaces the "medulla-closed" ROI with a closing of "medulla" by a 5x5x5 block cube.
+
+    Operations:
+
+    erode         Removes blocks whose structuring element is not entirely within the ROI.
+    dilate        Adds all blocks within the structuring element of any ROI block.
+    open          Erosion followed by dilation, which removes small protrusions.
+    close         Dilation followed by erosion, which fills small gaps.
+    fillholes     Adds all blocks enclosed by the ROI, i.e., blocks that can't reach outside
+                    the ROI bounds through face-connected blocks outside the ROI.
+
+    Arguments:
+
+    UUID          Hexidecimal string with enough characters to uniquely identify a version node.
+    data name     Name of ROI data.
+    radius        Radius of structuring element in blocks, from 0 to 64.  A cube of radius r
+                    has sides of 2r+1 blocks.
+
+    Query-string Options:
+
+    shape         Structuring element shape: "cube" (default) or "sphere".
+    target        For POST, the name of an existing roi instance with the same block size
+                    that will be replaced by the result.
+
+POST <api URL>/node/<UUID>/<data name>/sync?<options>
+
+    Establishes the data instance whose voxel resolution is used for physical units in the
+    "stats" and "mesh" endpoints.  Expects JSON to be POSTed with the following format:
+
+    { "sync": "grayscale" }
+
+	To delete syncs, pass an empty string of names with query string "replace=true":
+
+	{ "sync": "" }
+
+    The roi data type only accepts syncs to data with a voxel resolution, e.g., uint8blk or
+    labelmap instances.  If more than one is synced, the first with a 3d resolution is used.
+
+    POST Query-string Options:
+
+    replace    Set to "true" if you want passed syncs to replace and not be appended to current syncs.
+			   Default operation is false.
+
+GET <api URL>/node/<UUID>/<data name>/stats
+
+    Returns JSON with statistics on the size and shape of the ROI:
+
+	{
+		"BlockSize": [32, 32, 32],
+		"NumSpans": 2,
+		"NumBlocks": 5,
+		"NumVoxels": 163840,
+		"MinBlock": [10, 3, 2],
+		"MaxBlock": [12, 4, 2],
+		"MinVoxel": [320, 96, 64],
+		"MaxVoxel": [415, 159, 95],
+		"ZSlabs": [ { "Z": 2, "Blocks": 5, "Voxels": 163840 } ],
+		"SurfaceFaces": 20,
+		"SurfaceArea": 20480,
+		"Resolution": { "VoxelSize": [8, 8, 8], "VoxelUnits": ["nanometers", "nanometers", "nanometers"] },
+		"PhysicalVolume": 83886080,
+		"PhysicalSurfaceArea": 1310720
+	}
+
+    The bounding box is inclusive.  "ZSlabs" gives the number of blocks and voxels for each
+    Z block coordinate of the ROI.  The surface is that of the ROI blocks, where "SurfaceFaces"
+    is the number of block faces not adjacent to another ROI block and "SurfaceArea" is their
+    area in square voxels.  For voxel-precision ROIs, "NumBoundaryBlocks" gives the number of
+    blocks with a voxel mask and the voxel counts and bounds use the masks.
+
+    "Resolution" and the physical volume and surface area, in units of the voxel size, are only
+    returned if the ROI is synced to data with a voxel resolution.
+
+GET <api URL>/node/<UUID>/<data name>/mesh?<options>
+
+    Returns a mesh of the ROI block surface with one quad per block face not adjacent to another
+    ROI block.  Vertices are shared between faces and quads are ordered counter-clockwise when
+    viewed from outside the ROI.
+
+    Example: 
+
+    GET <api URL>/node/3f8c/medulla/mesh?format=ply&physical=true
+
+    Returns an ASCII PLY mesh of the "medulla" ROI in physical units.
+
+    Query-string Options:
+
+    format        "obj" (default) for Wavefront OBJ or "ply" for ASCII PLY.
+    physical      If "true", vertex coordinates are scaled by the voxel size of synced data.
+                    By default, coordinates are in voxels.
 
 `
 
@@ -225,9 +411,18 @@ func (dtype *Type) NewDataService(uuid dvid.UUID, id dvid.InstanceID, name dvid.
 	} else {
 		blockSize = dvid.Point3d{DefaultBlockSize, DefaultBlockSize, DefaultBlockSize}
 	}
+	voxelPrecision, _, err := c.GetBool("VoxelPrecision")
+	if err != nil {
+		return nil, err
+	}
 	d := &Data{
-		Data:       basedata,
-		Properties: Properties{blockSize, math.MaxInt32, math.MinInt32},
+		Data: basedata,
+		Properties: Properties{
+			BlockSize:      blockSize,
+			MinZ:           math.MaxInt32,
+			MaxZ:           math.MinInt32,
+			VoxelPrecision: voxelPrecision,
+		},
 	}
 	return d, nil
 }
@@ -246,6 +441,9 @@ type Properties struct {
 
 	// Maximum Block Coord Z for ROI
 	MaxZ int32
+
+	// VoxelPrecision is true if blocks on the ROI boundary can have binary masks.
+	VoxelPrecision bool
 }
 
 // Immutable is an ROI fixed to a particular version that you can check
@@ -254,12 +452,18 @@ type Immutable struct {
 	version   dvid.VersionID
 	blockSize dvid.Point3d
 	blocks    map[dvid.IZYXString]struct{}
+	masks     map[dvid.IZYXString]BlockMask
 }
 
 func (i Immutable) VoxelWithin(p dvid.Point3d) bool {
 	izyx := p.ToBlockIZYXString(i.blockSize)
-	_, found := i.blocks[izyx]
-	return found
+	if _, found := i.blocks[izyx]; !found {
+		return false
+	}
+	if mask, found := i.masks[izyx]; found {
+		return mask.Get(voxelIndex(p, i.blockSize))
+	}
+	return true
 }
 
 // ImmutableBySpec returns an Immutable ROI (or nil if not available) given
@@ -278,12 +482,17 @@ func ImmutableBySpec(spec string) (*Immutable, error) {
 	if err != nil {
 		return nil, err
 	}
+	masks, err := d.GetBlockMasks(v)
+	if err != nil {
+		return nil, err
+	}
 
 	// Setup the immutable.
 	im := Immutable{
 		version:   v,
 		blockSize: d.BlockSize,
 		blocks:    make(map[dvid.IZYXString]struct{}),
+		masks:     masks,
 	}
 	for _, span := range spans {
 		z, y, x0, x1 := span[0], span[1], span[2], span[3]
@@ -303,6 +512,10 @@ type Data struct {
 	Properties
 
 	sync.RWMutex
+
+	// writeMu serializes writes of spans and block masks so a voxel mask write, which reads
+	// and then modifies the ROI, isn't interleaved with other writes.
+	writeMu sync.Mutex
 }
 
 // IsMutationRequest overrides the default behavior to specify POST /ptquery as an immutable
@@ -318,6 +531,9 @@ func (d *Data) IsMutationRequest(action, endpoint string) bool {
 // DescribeTKeyClass returns a string explanation of what a particular TKeyClass
 // is used for.  Implements the datastore.TKeyClassDescriber interface.
 func (d *Data) DescribeTKeyClass(tkc storage.TKeyClass) string {
+	if tkc == keyMask {
+		return "ROI boundary block mask key"
+	}
 	return "ROI block + span key"
 }
 
@@ -333,6 +549,7 @@ func (d *Data) CopyPropertiesFrom(src datastore.DataService, fs storage.FilterSp
 	// TODO -- Handle mutable data that could be potentially altered by filter.
 	d.Properties.MinZ = d2.Properties.MinZ
 	d.Properties.MaxZ = d2.Properties.MaxZ
+	d.Properties.VoxelPrecision = d2.Properties.VoxelPrecision
 
 	return nil
 }
@@ -440,6 +657,9 @@ const (
 
 	// keyROI are keys for ROI RLEs
 	keyROI = 90
+
+	// keyMask are keys for binary masks of boundary blocks in voxel-precision ROIs
+	keyMask = 91
 )
 
 var (
@@ -565,6 +785,18 @@ func Get(ctx *datastore.VersionedCtx) ([]byte, error) {
 
 // Delete removes an ROI.
 func (d *Data) Delete(ctx storage.VersionedCtx) error {
+	if err := d.deleteSpans(ctx); err != nil {
+		return err
+	}
+	db, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return err
+	}
+	return db.DeleteRange(ctx, storage.MinTKey(keyMask), storage.MaxTKey(keyMask))
+}
+
+// deleteSpans removes the spans of an ROI but not any boundary block masks.
+func (d *Data) deleteSpans(ctx storage.VersionedCtx) error {
 	db, err := datastore.GetOrderedKeyValueDB(d)
 	if err != nil {
 		return err
@@ -597,8 +829,17 @@ func (d *Data) Delete(ctx storage.VersionedCtx) error {
 
 // PutSpans saves a slice of spans representing an ROI into the datastore.
 // If the init parameter is true, all previous spans of this ROI are deleted before
-// writing these spans.
+// writing these spans.  Any blocks in the given spans are entirely within the ROI,
+// so boundary block masks of a voxel-precision ROI are removed for these blocks.
 func (d *Data) PutSpans(versionID dvid.VersionID, spans []dvid.Span, init bool) error {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+	return d.putSpans(versionID, spans, init, nil)
+}
+
+// putSpans saves spans as in PutSpans.  The caller must hold writeMu.  If masks is non-nil, the given block masks are
+// stored (or deleted if nil) and all other masks are kept even if init is true.
+func (d *Data) putSpans(versionID dvid.VersionID, spans []dvid.Span, init bool, masks map[dvid.IZYXString]BlockMask) error {
 	ctx := datastore.NewVersionedCtx(d, versionID)
 	db, err := datastore.GetOrderedKeyValueDB(d)
 	if err != nil {
@@ -611,10 +852,14 @@ func (d *Data) PutSpans(versionID dvid.VersionID, spans []dvid.Span, init bool)
 	defer d.Unlock()
 
 	// Delete the old key/values
-	if init {
+	if init && masks == nil {
 		if err := d.Delete(ctx); err != nil {
 			return err
 		}
+	} else if init {
+		if err := d.deleteSpans(ctx); err != nil {
+			return err
+		}
 	}
 
 	// Make sure our small data store can do batching.
@@ -667,6 +912,34 @@ func (d *Data) PutSpans(versionID dvid.VersionID, spans []dvid.Span, init bool)
 			return fmt.Errorf("Error on last batch PUT: %v\n", err)
 		}
 	}
+
+	// Blocks added to a voxel-precision ROI are entirely within it.
+	if !init && masks == nil && d.VoxelPrecision {
+		for _, span := range spans {
+			beg := dvid.ChunkPoint3d{span[2], span[1], span[0]}.ToIZYXString()
+			end := dvid.ChunkPoint3d{span[3], span[1], span[0]}.ToIZYXString()
+			if err := db.DeleteRange(ctx, maskTKey(beg), maskTKey(end)); err != nil {
+				return err
+			}
+		}
+	}
+	if len(masks) != 0 {
+		batch = batcher.NewBatch(ctx)
+		for izyx, mask := range masks {
+			if mask == nil {
+				batch.Delete(maskTKey(izyx))
+				continue
+			}
+			data, err := d.serializeMask(mask)
+			if err != nil {
+				return err
+			}
+			batch.Put(maskTKey(izyx), data)
+		}
+		if err := batch.Commit(); err != nil {
+			return fmt.Errorf("Error on batch PUT of block masks: %v\n", err)
+		}
+	}
 	return nil
 }
 
@@ -756,6 +1029,36 @@ func (d *Data) GetMask(ctx *datastore.VersionedCtx, subvol *dvid.Subvolume) ([]b
 			}
 		}
 	}
+
+	// Clear voxels outside the ROI in boundary blocks.
+	masks, err := d.getBlockMasks(ctx, minBlockZ, maxBlockZ)
+	if err != nil {
+		return nil, err
+	}
+	for izyx, mask := range masks {
+		bcoord, err := izyx.ToChunkPoint3d()
+		if err != nil {
+			return nil, err
+		}
+		if bcoord[1] < minBlockY || bcoord[1] > maxBlockY || bcoord[0] < minBlockX || bcoord[0] > maxBlockX {
+			continue
+		}
+		x0, x1 := voxelRange(d.BlockSize[0], bcoord[0], bcoord[0], pt0.Value(0), pt1.Value(0))
+		y0, y1 := voxelRange(d.BlockSize[1], bcoord[1], bcoord[1], pt0.Value(1), pt1.Value(1))
+		z0, z1 := voxelRange(d.BlockSize[2], bcoord[2], bcoord[2], pt0.Value(2), pt1.Value(2))
+		for z := z0; z <= z1; z++ {
+			for y := y0; y <= y1; y++ {
+				i := z*nxy + y*nx + x0
+				for x := x0; x <= x1; x++ {
+					pt := dvid.Point3d{x + pt0.Value(0), y + pt0.Value(1), z + pt0.Value(2)}
+					if !mask.Get(voxelIndex(pt, d.BlockSize)) {
+						data[i] = 0
+					}
+					i++
+				}
+			}
+		}
+	}
 	return data, nil
 }
 
@@ -809,6 +1112,31 @@ func (d *Data) PointQuery(ctx *datastore.VersionedCtx, jsonBytes []byte) ([]byte
 		inclusions[origIndex] = included
 	}
 
+	// Check voxels in boundary blocks of voxel-precision ROIs.
+	if d.VoxelPrecision {
+		var pts []dvid.Point3d
+		if err := json.Unmarshal(jsonBytes, &pts); err != nil {
+			return nil, err
+		}
+		masks := make(map[dvid.IZYXString]BlockMask)
+		for i, pt := range pts {
+			if !inclusions[i] {
+				continue
+			}
+			izyx := pt.ToBlockIZYXString(d.BlockSize)
+			mask, found := masks[izyx]
+			if !found {
+				if mask, err = d.getBlockMask(ctx, izyx); err != nil {
+					return nil, err
+				}
+				masks[izyx] = mask
+			}
+			if mask != nil {
+				inclusions[i] = mask.Get(voxelIndex(pt, d.BlockSize))
+			}
+		}
+	}
+
 	// Convert to JSON
 	inclusionsJSON, err := json.Marshal(inclusions)
 	if err != nil {
@@ -820,6 +1148,7 @@ func (d *Data) PointQuery(ctx *datastore.VersionedCtx, jsonBytes []byte) ([]byte
 type subvolumesT struct {
 	NumTotalBlocks  uint64
 	NumActiveBlocks uint64
+	NumActiveVoxels uint64 `json:",omitempty"`
 	NumSubvolumes   int32
 	ROI             dvid.ChunkExtents3d
 	Subvolumes      []subvolumeT
@@ -830,6 +1159,7 @@ type subvolumeT struct {
 	dvid.ChunkExtents3d
 	TotalBlocks  uint64
 	ActiveBlocks uint64
+	ActiveVoxels uint64 `json:",omitempty"`
 }
 
 type layerT struct {
@@ -1150,6 +1480,9 @@ func (d *Data) Partition(ctx storage.Context, batchsize int32) ([]byte, error) {
 		d.addSubvolumes(layer, &subvolumes, batchsize, merge)
 	}
 	subvolumes.NumSubvolumes = int32(len(subvolumes.Subvolumes))
+	if err := d.addActiveVoxels(ctx, &subvolumes); err != nil {
+		return nil, err
+	}
 
 	// Encode as JSON
 	jsonBytes, err := json.MarshalIndent(subvolumes, "", "    ")
@@ -1286,6 +1619,9 @@ func (d *Data) SimplePartition(ctx storage.Context, batchsize int32) ([]byte, er
 		d.addSubvolumesGrid(layer, &subvolumes, batchsize)
 	}
 	subvolumes.NumSubvolumes = int32(len(subvolumes.Subvolumes))
+	if err := d.addActiveVoxels(ctx, &subvolumes); err != nil {
+		return nil, err
+	}
 
 	// Encode as JSON
 	jsonBytes, err := json.MarshalIndent(subvolumes, "", "    ")
@@ -1372,15 +1708,18 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 			}
 			comment = fmt.Sprintf("HTTP POST ROI %q: %d bytes", d.DataName(), len(data))
 		case "delete":
-			if err := d.Delete(ctx); err != nil {
+			d.writeMu.Lock()
+			err := d.Delete(ctx)
+			d.writeMu.Unlock()
+			if err != nil {
 				server.BadRequest(w, r, err)
 				return
 			}
 			comment = fmt.Sprintf("HTTP DELETE ROI %q", d.DataName())
 		}
 	case "mask":
-		if method != "get" {
-			server.BadRequest(w, r, "ROI mask only supports GET")
+		if method != "get" && method != "post" {
+			server.BadRequest(w, r, "ROI mask only supports GET or POST")
 			return
 		}
 		if len(parts) < 7 {
@@ -1401,6 +1740,19 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 				server.BadRequest(w, r, err)
 				return
 			}
+			if method == "post" {
+				data, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					server.BadRequest(w, r, err)
+					return
+				}
+				if err := d.PutMask(ctx, subvol, data); err != nil {
+					server.BadRequest(w, r, err)
+					return
+				}
+				comment = fmt.Sprintf("HTTP POST mask %q: %s", d.DataName(), subvol)
+				break
+			}
 			data, err := d.GetMask(ctx, subvol)
 			if err != nil {
 				server.BadRequest(w, r, err)
@@ -1436,6 +1788,156 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 			fmt.Fprintf(w, string(jsonBytes))
 			comment = fmt.Sprintf("HTTP POST ptquery '%s'", d.DataName())
 		}
+	case "setop":
+		if method != "post" {
+			server.BadRequest(w, r, "setop only supports POST request")
+			return
+		}
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		result, err := d.PutSetOp(uuid, ctx.VersionID(), data)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		jsonBytes, err := json.Marshal(result)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, string(jsonBytes))
+		comment = fmt.Sprintf("HTTP POST setop %q: %d spans", d.DataName(), result.Spans)
+	case "construct":
+		if method != "post" {
+			server.BadRequest(w, r, "construct only supports POST request")
+			return
+		}
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		result, err := d.PutConstruct(uuid, ctx.VersionID(), data)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		jsonBytes, err := json.Marshal(result)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, string(jsonBytes))
+		comment = fmt.Sprintf("HTTP POST construct %q: %d spans", d.DataName(), result.Spans)
+	case "erode", "dilate", "open", "close", "fillholes":
+		var radius int64
+		if command != "fillholes" {
+			if len(parts) < 5 {
+				server.BadRequest(w, r, "%s requires structuring element radius", command)
+				return
+			}
+			var err error
+			if radius, err = strconv.ParseInt(parts[4], 10, 32); err != nil {
+				server.BadRequest(w, r, "bad structuring element radius %q: %v", parts[4], err)
+				return
+			}
+		}
+		queryStrings := r.URL.Query()
+		spans, err := d.Morph(ctx.VersionID(), command, queryStrings.Get("shape"), int32(radius))
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		switch method {
+		case "get":
+			jsonBytes, err := json.Marshal(spans)
+			if err != nil {
+				server.BadRequest(w, r, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, string(jsonBytes))
+			comment = fmt.Sprintf("HTTP GET %s on ROI %q: %d spans", command, d.DataName(), len(spans))
+		case "post":
+			target := d
+			if name := queryStrings.Get("target"); name != "" {
+				if target, err = GetByUUIDName(uuid, dvid.InstanceName(name)); err != nil {
+					server.BadRequest(w, r, err)
+					return
+				}
+				if !target.BlockSize.Equals(d.BlockSize) {
+					server.BadRequest(w, r, "target ROI %q has block size %s, which differs from %s", name, target.BlockSize, d.BlockSize)
+					return
+				}
+			}
+			if err := target.PutSpans(ctx.VersionID(), spans, true); err != nil {
+				server.BadRequest(w, r, err)
+				return
+			}
+			jsonBytes, err := json.Marshal(SetOpResult{Spans: len(spans), Blocks: spans.Count()})
+			if err != nil {
+				server.BadRequest(w, r, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, string(jsonBytes))
+			comment = fmt.Sprintf("HTTP POST %s on ROI %q into %q: %d spans", command, d.DataName(), target.DataName(), len(spans))
+		default:
+			server.BadRequest(w, r, "%s only supports GET or POST requests", command)
+			return
+		}
+	case "sync":
+		if method != "post" {
+			server.BadRequest(w, r, "Only POST allowed to sync endpoint")
+			return
+		}
+		replace := r.URL.Query().Get("replace") == "true"
+		if err := datastore.SetSyncByJSON(d, uuid, replace, r.Body); err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		comment = fmt.Sprintf("HTTP POST sync on ROI %q", d.DataName())
+	case "stats":
+		if method != "get" {
+			server.BadRequest(w, r, "stats only supports GET request")
+			return
+		}
+		stats, err := d.GetStats(ctx.VersionID())
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		jsonBytes, err := json.Marshal(stats)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, string(jsonBytes))
+		comment = fmt.Sprintf("HTTP GET stats on ROI %q: %d blocks", d.DataName(), stats.NumBlocks)
+	case "mesh":
+		if method != "get" {
+			server.BadRequest(w, r, "mesh only supports GET request")
+			return
+		}
+		queryStrings := r.URL.Query()
+		var buf bytes.Buffer
+		format := queryStrings.Get("format")
+		if err := d.WriteMesh(&buf, ctx.VersionID(), format, queryStrings.Get("physical") == "true"); err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		comment = fmt.Sprintf("HTTP GET %s mesh of ROI %q", format, d.DataName())
 	case "partition":
 		if method != "get" {
 			server.BadRequest(w, r, "partition only supports GET request")
@@ -2,6 +2,7 @@ package roi
 
 import (
 	"bytes"
+	"encoding/gob"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,12 +10,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 
 	"github.com/janelia-flyem/dvid/datastore"
 	"github.com/janelia-flyem/dvid/dvid"
 	"github.com/janelia-flyem/dvid/server"
+	"github.com/janelia-flyem/dvid/storage"
 )
 
 var (
@@ -123,6 +126,57 @@ func initTestRepo() (dvid.UUID, dvid.VersionID) {
 	return datastore.NewTestRepo()
 }
 
+// resType is a test datatype with an anisotropic voxel resolution that ROIs can sync to.
+type resType struct {
+	datastore.Type
+}
+
+func (dtype *resType) NewDataService(uuid dvid.UUID, id dvid.InstanceID, name dvid.InstanceName, c dvid.Config) (datastore.DataService, error) {
+	basedata, err := datastore.NewDataService(dtype, uuid, id, name, c)
+	if err != nil {
+		return nil, err
+	}
+	return &resData{basedata}, nil
+}
+
+func (dtype *resType) Help() string {
+	return "test datatype with a voxel resolution"
+}
+
+type resData struct {
+	*datastore.Data
+}
+
+func (d *resData) Resolution() dvid.Resolution {
+	return dvid.Resolution{
+		VoxelSize:  dvid.NdFloat32{4, 8, 10},
+		VoxelUnits: dvid.NdString{"nanometers", "nanometers", "nanometers"},
+	}
+}
+
+func (d *resData) Help() string {
+	return "test data with a voxel resolution"
+}
+
+func (d *resData) DoRPC(request datastore.Request, reply *datastore.Response) error {
+	return fmt.Errorf("unknown command")
+}
+
+func (d *resData) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.ResponseWriter, r *http.Request) {
+	server.BadRequest(w, r, "no HTTP API for test resolution data")
+}
+
+func init() {
+	datastore.Register(&resType{datastore.Type{
+		Name:         "restest",
+		URL:          "github.com/janelia-flyem/dvid/datatype/roi/restest",
+		Version:      "0.1",
+		Requirements: &storage.Requirements{Batcher: true},
+	}})
+	gob.Register(&resType{})
+	gob.Register(&resData{})
+}
+
 func TestTuples(t *testing.T) {
 	tup := dvid.Span{10, 11, 20, 30}
 	if tup.LessChunkPoint3d(dvid.ChunkPoint3d{20, 11, 10}) {
@@ -972,3 +1026,685 @@ func TestROIRepoPersistence(t *testing.T) {
 		t.Errorf("Expected %v, got %v\n", oldData, *roi2new)
 	}
 }
+
+func TestSpanSetOps(t *testing.T) {
+	a := dvid.Spans{{0, 0, 0, 10}, {0, 1, 0, 10}, {1, 0, 5, 8}}
+	b := dvid.Spans{{0, 0, 3, 4}, {0, 0, 8, 12}, {0, 2, 0, 10}, {1, 0, 0, 5}}
+
+	union := unionSpans(a, b)
+	expected := dvid.Spans{{0, 0, 0, 12}, {0, 1, 0, 10}, {0, 2, 0, 10}, {1, 0, 0, 8}}
+	if !reflect.DeepEqual(union, expected) {
+		t.Errorf("Bad union:\n%v\nExpected:\n%v\n", union, expected)
+	}
+
+	intersection := intersectSpans(a, b)
+	expected = dvid.Spans{{0, 0, 3, 4}, {0, 0, 8, 10}, {1, 0, 5, 5}}
+	if !reflect.DeepEqual(intersection, expected) {
+		t.Errorf("Bad intersection:\n%v\nExpected:\n%v\n", intersection, expected)
+	}
+
+	difference := subtractSpans(a, b)
+	expected = dvid.Spans{{0, 0, 0, 2}, {0, 0, 5, 7}, {0, 1, 0, 10}, {1, 0, 6, 8}}
+	if !reflect.DeepEqual(difference, expected) {
+		t.Errorf("Bad difference:\n%v\nExpected:\n%v\n", difference, expected)
+	}
+
+	difference = subtractSpans(b, a)
+	expected = dvid.Spans{{0, 0, 11, 12}, {0, 2, 0, 10}, {1, 0, 0, 4}}
+	if !reflect.DeepEqual(difference, expected) {
+		t.Errorf("Bad reverse difference:\n%v\nExpected:\n%v\n", difference, expected)
+	}
+
+	complement := subtractSpans(boundsSpans(dvid.ChunkPoint3d{0, 0, 0}, dvid.ChunkPoint3d{12, 1, 0}), a)
+	expected = dvid.Spans{{0, 0, 11, 12}, {0, 1, 11, 12}}
+	if !reflect.DeepEqual(complement, expected) {
+		t.Errorf("Bad complement:\n%v\nExpected:\n%v\n", complement, expected)
+	}
+}
+
+func TestROISetOp(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+	config := dvid.NewConfig()
+	for _, name := range []dvid.InstanceName{"a", "b", "result"} {
+		if _, err := datastore.NewData(uuid, roitype, name, config); err != nil {
+			t.Fatalf("Error creating new roi instance %q: %v\n", name, err)
+		}
+	}
+	config.Set("BlockSize", "16,16,16")
+	if _, err := datastore.NewData(uuid, roitype, "small", config); err != nil {
+		t.Fatalf("Error creating new roi instance: %v\n", err)
+	}
+
+	roiA := `[[0,0,0,10],[0,1,0,10],[1,0,5,8]]`
+	roiB := `[[0,0,3,4],[0,0,8,12],[0,2,0,10],[1,0,0,5]]`
+	server.TestHTTP(t, "POST", fmt.Sprintf("%snode/%s/a/roi", server.WebAPIPath, uuid), bytes.NewBufferString(roiA))
+	server.TestHTTP(t, "POST", fmt.Sprintf("%snode/%s/b/roi", server.WebAPIPath, uuid), bytes.NewBufferString(roiB))
+
+	setopURL := fmt.Sprintf("%snode/%s/result/setop", server.WebAPIPath, uuid)
+	roiURL := fmt.Sprintf("%snode/%s/result/roi", server.WebAPIPath, uuid)
+	tests := []struct {
+		req      string
+		response string
+		spans    string
+	}{
+		{
+			`{"Op":"union","ROIs":["a","b"]}`,
+			`{"Spans":4,"Blocks":44}`,
+			`[[0,0,0,12],[0,1,0,10],[0,2,0,10],[1,0,0,8]]`,
+		},
+		{
+			`{"Op":"intersection","ROIs":["a","b"]}`,
+			`{"Spans":3,"Blocks":6}`,
+			`[[0,0,3,4],[0,0,8,10],[1,0,5,5]]`,
+		},
+		{
+			fmt.Sprintf(`{"Op":"difference","ROIs":["a,%s","b"]}`, uuid),
+			`{"Spans":4,"Blocks":20}`,
+			`[[0,0,0,2],[0,0,5,7],[0,1,0,10],[1,0,6,8]]`,
+		},
+		{
+			`{"Op":"complement","ROIs":["a","b"],"MinBlock":[0,0,0],"MaxBlock":[12,3,0]}`,
+			`{"Spans":3,"Blocks":17}`,
+			`[[0,1,11,12],[0,2,11,12],[0,3,0,12]]`,
+		},
+		{
+			`{"Op":"union","ROIs":["a","b"],"MinBlock":[0,0,1],"MaxBlock":[6,2,1]}`,
+			`{"Spans":1,"Blocks":7}`,
+			`[[1,0,0,6]]`,
+		},
+		{
+			`{"Op":"union","ROIs":["a","b"],"MinBlock":[-2000000000,-2000000000,1],"MaxBlock":[2000000000,2000000000,2000000000]}`,
+			`{"Spans":1,"Blocks":9}`,
+			`[[1,0,0,8]]`,
+		},
+	}
+	for _, tc := range tests {
+		resp := server.TestHTTP(t, "POST", setopURL, bytes.NewBufferString(tc.req))
+		if string(resp) != tc.response {
+			t.Errorf("Bad response for setop %s: got %s, expected %s\n", tc.req, string(resp), tc.response)
+		}
+		spans := server.TestHTTP(t, "GET", roiURL, nil)
+		if string(spans) != tc.spans {
+			t.Errorf("Bad spans for setop %s:\n%s\nExpected:\n%s\n", tc.req, string(spans), tc.spans)
+		}
+	}
+
+	badReqs := []string{
+		`{"Op":"xor","ROIs":["a","b"]}`,
+		`{"Op":"union","ROIs":["a"]}`,
+		`{"Op":"complement","ROIs":["a"]}`,
+		`{"Op":"union","ROIs":["a","small"]}`,
+		`{"Op":"union","ROIs":["a","nonexistent"]}`,
+		`{"Op":"union","ROIs":["a","b"],"MinBlock":[5,0,0],"MaxBlock":[4,0,0]}`,
+		`{"Op":"complement","ROIs":["a"],"MinBlock":[0,0,0],"MaxBlock":[0,2048,2048]}`,
+	}
+	for _, req := range badReqs {
+		server.TestBadHTTP(t, "POST", setopURL, bytes.NewBufferString(req))
+	}
+
+	// Use ROIs across versions: the child's "a" minus the parent's "a".
+	server.TestHTTP(t, "POST", fmt.Sprintf("%snode/%s/commit", server.WebAPIPath, uuid), bytes.NewBufferString(`{"note": "first ROIs"}`))
+	respData := server.TestHTTP(t, "POST", fmt.Sprintf("%snode/%s/newversion", server.WebAPIPath, uuid), nil)
+	var newVersion struct {
+		Child string `json:"child"`
+	}
+	if err := json.Unmarshal(respData, &newVersion); err != nil {
+		t.Fatalf("Expected 'child' JSON response.  Got %s\n", string(respData))
+	}
+	child := dvid.UUID(newVersion.Child)
+
+	server.TestHTTP(t, "POST", fmt.Sprintf("%snode/%s/a/roi", server.WebAPIPath, child), bytes.NewBufferString(`[[0,0,0,12],[2,2,2,3]]`))
+	req := fmt.Sprintf(`{"Op":"difference","ROIs":["a","a,%s"]}`, uuid)
+	server.TestHTTP(t, "POST", fmt.Sprintf("%snode/%s/result/setop", server.WebAPIPath, child), bytes.NewBufferString(req))
+	spans := server.TestHTTP(t, "GET", fmt.Sprintf("%snode/%s/result/roi", server.WebAPIPath, child), nil)
+	if string(spans) != `[[0,0,11,12],[2,2,2,3]]` {
+		t.Errorf("Bad spans for setop across versions: %s\n", string(spans))
+	}
+}
+
+func TestMorphSpans(t *testing.T) {
+	// A 5x5x5 block cube with a one-block hole in its center and a one-block protrusion.
+	var cube dvid.Spans
+	for z := int32(0); z < 5; z++ {
+		for y := int32(0); y < 5; y++ {
+			if z == 2 && y == 2 {
+				cube = append(cube, dvid.Span{z, y, 0, 1}, dvid.Span{z, y, 3, 4})
+			} else {
+				cube = append(cube, dvid.Span{z, y, 0, 4})
+			}
+		}
+	}
+	cube = append(cube, dvid.Span{2, 2, 6, 6}, dvid.Span{2, 2, 5, 5})
+
+	filled, err := MorphSpans(cube, "fillholes", "", 0)
+	if err != nil {
+		t.Fatalf("error filling holes: %v\n", err)
+	}
+	if filled.Count() != 127 {
+		t.Errorf("expected 127 blocks after filling holes, got %d: %v\n", filled.Count(), filled)
+	}
+
+	eroded, err := MorphSpans(cube, "erode", "cube", 1)
+	if err != nil {
+		t.Fatalf("error eroding: %v\n", err)
+	}
+	if len(eroded) != 0 {
+		t.Errorf("expected erosion to remove all blocks since the hole touches the center: %v\n", eroded)
+	}
+	eroded, err = MorphSpans(filled, "erode", "cube", 1)
+	if err != nil {
+		t.Fatalf("error eroding: %v\n", err)
+	}
+	expected := dvid.Spans{{1, 1, 1, 3}, {1, 2, 1, 3}, {1, 3, 1, 3}, {2, 1, 1, 3}, {2, 2, 1, 3}, {2, 3, 1, 3}, {3, 1, 1, 3}, {3, 2, 1, 3}, {3, 3, 1, 3}}
+	if !reflect.DeepEqual(eroded, expected) {
+		t.Errorf("bad cube erosion:\n%v\nExpected:\n%v\n", eroded, expected)
+	}
+
+	opened, err := MorphSpans(filled, "open", "cube", 1)
+	if err != nil {
+		t.Fatalf("error opening: %v\n", err)
+	}
+	if opened.Count() != 125 {
+		t.Errorf("expected opening to remove protrusion, got %d blocks: %v\n", opened.Count(), opened)
+	}
+
+	closed, err := MorphSpans(cube, "close", "sphere", 1)
+	if err != nil {
+		t.Fatalf("error closing: %v\n", err)
+	}
+	if missing := subtractSpans(filled, closed); len(missing) != 0 {
+		t.Errorf("expected closing to fill center hole but missing %v: %v\n", missing, closed)
+	}
+
+	single := dvid.Spans{{0, 0, 0, 0}}
+	dilated, err := MorphSpans(single, "dilate", "sphere", 1)
+	if err != nil {
+		t.Fatalf("error dilating: %v\n", err)
+	}
+	expected = dvid.Spans{{-1, 0, 0, 0}, {0, -1, 0, 0}, {0, 0, -1, 1}, {0, 1, 0, 0}, {1, 0, 0, 0}}
+	if !reflect.DeepEqual(dilated, expected) {
+		t.Errorf("bad sphere dilation:\n%v\nExpected:\n%v\n", dilated, expected)
+	}
+	dilated, err = MorphSpans(single, "dilate", "cube", 1)
+	if err != nil {
+		t.Fatalf("error dilating: %v\n", err)
+	}
+	if dilated.Count() != 27 || len(dilated) != 9 {
+		t.Errorf("bad cube dilation: %v\n", dilated)
+	}
+	dilated, err = MorphSpans(single, "dilate", "sphere", 2)
+	if err != nil {
+		t.Fatalf("error dilating: %v\n", err)
+	}
+	if dilated.Count() != 33 {
+		t.Errorf("expected 33 blocks in sphere of radius 2, got %d\n", dilated.Count())
+	}
+
+	if _, err := MorphSpans(single, "erode", "pyramid", 1); err == nil {
+		t.Errorf("expected error on bad structuring element shape\n")
+	}
+	if _, err := MorphSpans(single, "erode", "cube", MaxMorphRadius+1); err == nil {
+		t.Errorf("expected error on too large structuring element\n")
+	}
+	if _, err := MorphSpans(single, "thin", "cube", 1); err == nil {
+		t.Errorf("expected error on bad operation\n")
+	}
+}
+
+func TestDilateSpans(t *testing.T) {
+	spans := dvid.Spans{{0, 0, 0, 2}, {0, 0, 6, 6}, {0, 3, 1, 1}, {4, -2, 10, 12}}.Normalize()
+	rows, err := getElementRows("sphere", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Dilate each block separately and compare the union with the row-wise dilation.
+	var expected dvid.Spans
+	for _, span := range spans {
+		for x := span[2]; x <= span[3]; x++ {
+			for _, row := range rows {
+				expected = append(expected, dvid.Span{span[0] + row.dz, span[1] + row.dy, x - row.dx, x + row.dx})
+			}
+		}
+	}
+	expected = expected.Normalize()
+	if dilated := dilateSpans(spans, rows); !reflect.DeepEqual(dilated, expected) {
+		t.Errorf("bad dilation:\n%v\nExpected:\n%v\n", dilated, expected)
+	}
+	if dilated := dilateSpans(dvid.Spans{}, rows); len(dilated) != 0 {
+		t.Errorf("expected empty dilation of empty spans, got %v\n", dilated)
+	}
+}
+
+func TestROIMorphHTTP(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+	config := dvid.NewConfig()
+	for _, name := range []dvid.InstanceName{"src", "dest"} {
+		if _, err := datastore.NewData(uuid, roitype, name, config); err != nil {
+			t.Fatalf("Error creating new roi instance %q: %v\n", name, err)
+		}
+	}
+	config.Set("BlockSize", "16,16,16")
+	if _, err := datastore.NewData(uuid, roitype, "small", config); err != nil {
+		t.Fatalf("Error creating new roi instance: %v\n", err)
+	}
+
+	// A 3x3x3 cube with hollow center.
+	src := `[[0,0,0,2],[0,1,0,2],[0,2,0,2],[1,0,0,2],[1,1,0,0],[1,1,2,2],[1,2,0,2],[2,0,0,2],[2,1,0,2],[2,2,0,2]]`
+	server.TestHTTP(t, "POST", fmt.Sprintf("%snode/%s/src/roi", server.WebAPIPath, uuid), bytes.NewBufferString(src))
+
+	url := fmt.Sprintf("%snode/%s/src/fillholes", server.WebAPIPath, uuid)
+	resp := server.TestHTTP(t, "GET", url, nil)
+	filled := `[[0,0,0,2],[0,1,0,2],[0,2,0,2],[1,0,0,2],[1,1,0,2],[1,2,0,2],[2,0,0,2],[2,1,0,2],[2,2,0,2]]`
+	if string(resp) != filled {
+		t.Errorf("bad fillholes GET: %s\n", string(resp))
+	}
+
+	url = fmt.Sprintf("%snode/%s/src/fillholes?target=dest", server.WebAPIPath, uuid)
+	resp = server.TestHTTP(t, "POST", url, nil)
+	if string(resp) != `{"Spans":9,"Blocks":27}` {
+		t.Errorf("bad fillholes POST response: %s\n", string(resp))
+	}
+	url = fmt.Sprintf("%snode/%s/dest/erode/1?shape=sphere", server.WebAPIPath, uuid)
+	resp = server.TestHTTP(t, "GET", url, nil)
+	if string(resp) != `[[1,1,1,1]]` {
+		t.Errorf("bad erode GET: %s\n", string(resp))
+	}
+
+	// Erode in place.
+	url = fmt.Sprintf("%snode/%s/dest/erode/1", server.WebAPIPath, uuid)
+	resp = server.TestHTTP(t, "POST", url, nil)
+	if string(resp) != `{"Spans":1,"Blocks":1}` {
+		t.Errorf("bad erode POST response: %s\n", string(resp))
+	}
+	resp = server.TestHTTP(t, "GET", fmt.Sprintf("%snode/%s/dest/roi", server.WebAPIPath, uuid), nil)
+	if string(resp) != `[[1,1,1,1]]` {
+		t.Errorf("bad ROI after in-place erosion: %s\n", string(resp))
+	}
+	url = fmt.Sprintf("%snode/%s/dest/dilate/1?shape=sphere", server.WebAPIPath, uuid)
+	resp = server.TestHTTP(t, "GET", url, nil)
+	if string(resp) != `[[0,1,1,1],[1,0,1,1],[1,1,0,2],[1,2,1,1],[2,1,1,1]]` {
+		t.Errorf("bad dilate GET: %s\n", string(resp))
+	}
+
+	badURLs := []string{
+		fmt.Sprintf("%snode/%s/src/erode", server.WebAPIPath, uuid),
+		fmt.Sprintf("%snode/%s/src/erode/x", server.WebAPIPath, uuid),
+		fmt.Sprintf("%snode/%s/src/dilate/1?shape=cone", server.WebAPIPath, uuid),
+		fmt.Sprintf("%snode/%s/src/open/1000", server.WebAPIPath, uuid),
+	}
+	for _, url := range badURLs {
+		server.TestBadHTTP(t, "GET", url, nil)
+	}
+	server.TestBadHTTP(t, "POST", fmt.Sprintf("%snode/%s/src/close/1?target=small", server.WebAPIPath, uuid), nil)
+	server.TestBadHTTP(t, "POST", fmt.Sprintf("%snode/%s/src/close/1?target=nonexistent", server.WebAPIPath, uuid), nil)
+}
+
+func TestHullSpans(t *testing.T) {
+	hull := hullSpans(dvid.Spans{{0, 0, 0, 0}, {0, 0, 4, 4}})
+	expected := dvid.Spans{{0, 0, 0, 4}}
+	if !reflect.DeepEqual(hull, expected) {
+		t.Errorf("bad hull of collinear blocks:\n%v\nExpected:\n%v\n", hull, expected)
+	}
+
+	hull = hullSpans(dvid.Spans{{0, 0, 0, 0}, {2, 2, 2, 2}})
+	expected = dvid.Spans{{0, 0, 0, 1}, {0, 1, 0, 1}, {1, 0, 0, 1}, {1, 1, 0, 2}, {1, 2, 1, 2}, {2, 1, 1, 2}, {2, 2, 1, 2}}
+	if !reflect.DeepEqual(hull, expected) {
+		t.Errorf("bad hull of diagonal blocks:\n%v\nExpected:\n%v\n", hull, expected)
+	}
+
+	// Hull of a hollow cube is the filled cube.
+	var hollow dvid.Spans
+	for z := int32(0); z < 4; z++ {
+		for y := int32(0); y < 4; y++ {
+			if z == 0 || z == 3 || y == 0 || y == 3 {
+				hollow = append(hollow, dvid.Span{z, y, 0, 3})
+			} else {
+				hollow = append(hollow, dvid.Span{z, y, 0, 0}, dvid.Span{z, y, 3, 3})
+			}
+		}
+	}
+	hull = hullSpans(hollow)
+	if hull.Count() != 64 || len(hull) != 16 {
+		t.Errorf("bad hull of hollow cube: %v\n", hull)
+	}
+
+	// Hull of corner blocks of a tetrahedron includes the corner blocks.
+	corners := dvid.Spans{{0, 0, 0, 0}, {0, 0, 4, 4}, {0, 4, 0, 0}, {4, 0, 0, 0}}
+	hull = hullSpans(corners)
+	if missing := subtractSpans(corners, hull); len(missing) != 0 {
+		t.Errorf("hull is missing original blocks %v\n", missing)
+	}
+	if hull.Count() != 53 {
+		t.Errorf("expected 53 blocks in tetrahedron hull, got %d: %v\n", hull.Count(), hull)
+	}
+	if len(hullSpans(dvid.Spans{})) != 0 {
+		t.Errorf("expected empty hull for no blocks\n")
+	}
+}
+
+func TestLabelBlockSpans(t *testing.T) {
+	blocks := dvid.IZYXSlice{
+		dvid.ChunkPoint3d{0, 0, 0}.ToIZYXString(),
+		dvid.ChunkPoint3d{-1, 2, 0}.ToIZYXString(),
+	}
+	spans, err := labelBlockSpans(blocks, dvid.Point3d{64, 64, 64}, dvid.Point3d{32, 32, 32})
+	if err != nil {
+		t.Fatalf("error getting label block spans: %v\n", err)
+	}
+	expected := dvid.Spans{{0, 0, 0, 1}, {0, 1, 0, 1}, {0, 4, -2, -1}, {0, 5, -2, -1}, {1, 0, 0, 1}, {1, 1, 0, 1}, {1, 4, -2, -1}, {1, 5, -2, -1}}
+	if !reflect.DeepEqual(spans, expected) {
+		t.Errorf("bad label block spans:\n%v\nExpected:\n%v\n", spans, expected)
+	}
+	spans, err = labelBlockSpans(blocks, dvid.Point3d{64, 64, 64}, dvid.Point3d{100, 100, 100})
+	if err != nil {
+		t.Fatalf("error getting label block spans: %v\n", err)
+	}
+	expected = dvid.Spans{{0, 0, 0, 0}, {0, 1, -1, -1}}
+	if !reflect.DeepEqual(spans, expected) {
+		t.Errorf("bad label block spans:\n%v\nExpected:\n%v\n", spans, expected)
+	}
+}
+
+func TestVoxelPrecision(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, v := initTestRepo()
+	config := dvid.NewConfig()
+	config.Set("BlockSize", "8,8,8")
+	if _, err := datastore.NewData(uuid, roitype, "blocky", config); err != nil {
+		t.Fatalf("Error creating new roi instance: %v\n", err)
+	}
+	config.Set("VoxelPrecision", "true")
+	dataservice, err := datastore.NewData(uuid, roitype, "layer", config)
+	if err != nil {
+		t.Fatalf("Error creating new roi instance: %v\n", err)
+	}
+	data, ok := dataservice.(*Data)
+	if !ok {
+		t.Fatalf("Can't cast data service into roi.Data\n")
+	}
+	if !data.VoxelPrecision {
+		t.Fatalf("expected roi to have voxel precision\n")
+	}
+
+	// Voxels with x < 5 within a 16x16x8 subvolume.
+	mask := make([]byte, 16*16*8)
+	for z := 0; z < 8; z++ {
+		for y := 0; y < 16; y++ {
+			for x := 0; x < 5; x++ {
+				mask[z*256+y*16+x] = 1
+			}
+		}
+	}
+	maskURL := fmt.Sprintf("%snode/%s/layer/mask/0_1_2/16_16_8/0_0_0", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", maskURL, bytes.NewBuffer(mask))
+	server.TestBadHTTP(t, "POST", maskURL, bytes.NewBuffer(mask[:100]))
+	badURL := fmt.Sprintf("%snode/%s/blocky/mask/0_1_2/16_16_8/0_0_0", server.WebAPIPath, uuid)
+	server.TestBadHTTP(t, "POST", badURL, bytes.NewBuffer(mask))
+
+	roiURL := fmt.Sprintf("%snode/%s/layer/roi", server.WebAPIPath, uuid)
+	if spans := server.TestHTTP(t, "GET", roiURL, nil); string(spans) != "[[0,0,0,0],[0,1,0,0]]" {
+		t.Errorf("bad spans for voxel-precision ROI: %s\n", string(spans))
+	}
+	if returned := server.TestHTTP(t, "GET", maskURL, nil); !bytes.Equal(returned, mask) {
+		t.Errorf("returned mask differs from POSTed mask\n")
+	}
+
+	ptqueryURL := fmt.Sprintf("%snode/%s/layer/ptquery", server.WebAPIPath, uuid)
+	inclusions := server.TestHTTP(t, "POST", ptqueryURL, bytes.NewBufferString("[[4,3,2],[5,3,2],[2,12,7],[9,9,1],[-1,0,0]]"))
+	if string(inclusions) != "[true,false,true,false,false]" {
+		t.Errorf("bad ptquery for voxel-precision ROI: %s\n", string(inclusions))
+	}
+
+	iROI, err := ImmutableBySpec("layer," + string(uuid))
+	if err != nil {
+		t.Fatalf("error getting immutable ROI: %v\n", err)
+	}
+	if !iROI.VoxelWithin(dvid.Point3d{4, 15, 0}) || iROI.VoxelWithin(dvid.Point3d{5, 15, 0}) {
+		t.Errorf("bad voxel inclusion for immutable voxel-precision ROI\n")
+	}
+
+	// Fill block (1,0,0) so it is span-encoded without a mask.
+	full := bytes.Repeat([]byte{1}, 512)
+	server.TestHTTP(t, "POST", fmt.Sprintf("%snode/%s/layer/mask/0_1_2/8_8_8/8_0_0", server.WebAPIPath, uuid), bytes.NewBuffer(full))
+	if spans := server.TestHTTP(t, "GET", roiURL, nil); string(spans) != "[[0,0,0,1],[0,1,0,0]]" {
+		t.Errorf("bad spans after filling block: %s\n", string(spans))
+	}
+	masks, err := data.GetBlockMasks(v)
+	if err != nil {
+		t.Fatalf("error getting block masks: %v\n", err)
+	}
+	if len(masks) != 2 {
+		t.Errorf("expected 2 block masks, got %d\n", len(masks))
+	}
+	for _, m := range masks {
+		if m.Count() != 320 {
+			t.Errorf("expected 320 voxels in block mask, got %d\n", m.Count())
+		}
+	}
+
+	partitionURL := fmt.Sprintf("%snode/%s/layer/partition?batchsize=2", server.WebAPIPath, uuid)
+	var subvolJSON subvolumesT
+	if err := json.Unmarshal(server.TestHTTP(t, "GET", partitionURL, nil), &subvolJSON); err != nil {
+		t.Fatalf("error decoding partition: %v\n", err)
+	}
+	if subvolJSON.NumActiveBlocks != 3 || subvolJSON.NumActiveVoxels != 1152 {
+		t.Errorf("expected 3 active blocks with 1152 voxels, got %d blocks, %d voxels\n", subvolJSON.NumActiveBlocks, subvolJSON.NumActiveVoxels)
+	}
+
+	// Clear block (0,1,0), fill block (0,0,0), and leave block (1,0,0) unchanged.
+	modified := make([]byte, 8*16*8)
+	for z := 0; z < 8; z++ {
+		for x := 0; x < 8; x++ {
+			for y := 0; y < 8; y++ {
+				modified[z*128+y*8+x] = 1
+			}
+		}
+	}
+	server.TestHTTP(t, "POST", fmt.Sprintf("%snode/%s/layer/mask/0_1_2/8_16_8/0_0_0", server.WebAPIPath, uuid), bytes.NewBuffer(modified))
+	if spans := server.TestHTTP(t, "GET", roiURL, nil); string(spans) != "[[0,0,0,1]]" {
+		t.Errorf("bad spans after clearing block: %s\n", string(spans))
+	}
+	if masks, err = data.GetBlockMasks(v); err != nil || len(masks) != 0 {
+		t.Errorf("expected no block masks, got %d: %v\n", len(masks), err)
+	}
+	inclusions = server.TestHTTP(t, "POST", ptqueryURL, bytes.NewBufferString("[[5,3,2],[15,7,7],[2,12,7]]"))
+	if string(inclusions) != "[true,true,false]" {
+		t.Errorf("bad ptquery after mask modification: %s\n", string(inclusions))
+	}
+
+	// Writing spans replaces the ROI with whole blocks.
+	server.TestHTTP(t, "POST", maskURL, bytes.NewBuffer(mask))
+	server.TestHTTP(t, "POST", roiURL, bytes.NewBufferString("[[0,0,0,0]]"))
+	inclusions = server.TestHTTP(t, "POST", ptqueryURL, bytes.NewBufferString("[[5,3,2],[9,3,2]]"))
+	if string(inclusions) != "[true,false]" {
+		t.Errorf("bad ptquery after replacing voxel-precision ROI with spans: %s\n", string(inclusions))
+	}
+
+	// Concurrent mask writes to different planes of block (2,0,0) must all be kept.
+	ctx := datastore.NewVersionedCtx(data, v)
+	plane := bytes.Repeat([]byte{1}, 64)
+	errs := make(chan error, 8)
+	var wg sync.WaitGroup
+	for z := int32(0); z < 8; z++ {
+		wg.Add(1)
+		go func(z int32) {
+			defer wg.Done()
+			subvol := dvid.NewSubvolume(dvid.Point3d{16, 0, z}, dvid.Point3d{8, 8, 1})
+			errs <- data.PutMask(ctx, subvol, plane)
+		}(z)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("error on concurrent mask write: %v\n", err)
+		}
+	}
+	if spans := server.TestHTTP(t, "GET", roiURL, nil); string(spans) != "[[0,0,0,0],[0,0,2,2]]" {
+		t.Errorf("bad spans after concurrent mask writes: %s\n", string(spans))
+	}
+}
+
+func TestROIMesh(t *testing.T) {
+	// An L-shaped slab of 5 blocks and a 3x3x3 cube with hollow center.
+	tests := []struct {
+		spans  dvid.Spans
+		nVerts int
+		nQuads int
+	}{
+		{dvid.Spans{{2, 3, 10, 12}, {2, 4, 10, 11}}, 22, 20},
+		{dvid.Spans{{0, 0, 0, 2}, {0, 1, 0, 2}, {0, 2, 0, 2}, {1, 0, 0, 2}, {1, 1, 0, 0}, {1, 1, 2, 2}, {1, 2, 0, 2}, {2, 0, 0, 2}, {2, 1, 0, 2}, {2, 2, 0, 2}}, 64, 60},
+	}
+	for i, tc := range tests {
+		m := newROIMesh(tc.spans)
+		if len(m.verts) != tc.nVerts || len(m.quads) != tc.nQuads {
+			t.Errorf("test %d: expected %d vertices and %d quads, got %d and %d\n", i, tc.nVerts, tc.nQuads, len(m.verts), len(m.quads))
+		}
+		// A closed, consistently oriented surface has each directed edge exactly once along
+		// with its reverse.
+		edges := make(map[[2]int]int)
+		for _, q := range m.quads {
+			for j := 0; j < 4; j++ {
+				edges[[2]int{q[j], q[(j+1)%4]}]++
+			}
+		}
+		for edge, n := range edges {
+			if n != 1 || edges[[2]int{edge[1], edge[0]}] != 1 {
+				t.Errorf("test %d: mesh is not closed and consistently oriented at edge %v\n", i, edge)
+				break
+			}
+		}
+	}
+}
+
+func TestROIStatsHTTP(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+	config := dvid.NewConfig()
+	if _, err := datastore.NewData(uuid, roitype, "slab", config); err != nil {
+		t.Fatalf("Error creating new roi instance: %v\n", err)
+	}
+	server.TestHTTP(t, "POST", fmt.Sprintf("%snode/%s/slab/roi", server.WebAPIPath, uuid), bytes.NewBufferString("[[2,3,10,12],[2,4,10,11]]"))
+
+	var stats Stats
+	resp := server.TestHTTP(t, "GET", fmt.Sprintf("%snode/%s/slab/stats", server.WebAPIPath, uuid), nil)
+	if err := json.Unmarshal(resp, &stats); err != nil {
+		t.Fatalf("couldn't unmarshal stats %s: %v\n", string(resp), err)
+	}
+	if stats.NumSpans != 2 || stats.NumBlocks != 5 || stats.NumVoxels != 5*32*32*32 || stats.NumBoundaryBlocks != 0 {
+		t.Errorf("bad stats counts: %s\n", string(resp))
+	}
+	if stats.MinBlock == nil || *stats.MinBlock != (dvid.ChunkPoint3d{10, 3, 2}) || *stats.MaxBlock != (dvid.ChunkPoint3d{12, 4, 2}) {
+		t.Errorf("bad stats block bounds: %s\n", string(resp))
+	}
+	if stats.MinVoxel == nil || *stats.MinVoxel != (dvid.Point3d{320, 96, 64}) || *stats.MaxVoxel != (dvid.Point3d{415, 159, 95}) {
+		t.Errorf("bad stats voxel bounds: %s\n", string(resp))
+	}
+	if !reflect.DeepEqual(stats.ZSlabs, []ZSlabStats{{Z: 2, Blocks: 5, Voxels: 5 * 32 * 32 * 32}}) {
+		t.Errorf("bad stats z slabs: %s\n", string(resp))
+	}
+	if stats.SurfaceFaces != 20 || stats.SurfaceArea != 20*32*32 {
+		t.Errorf("bad stats surface: %s\n", string(resp))
+	}
+	if stats.Resolution != nil || stats.PhysicalVolume != 0 {
+		t.Errorf("expected no physical units for unsynced ROI: %s\n", string(resp))
+	}
+
+	resp = server.TestHTTP(t, "GET", fmt.Sprintf("%snode/%s/slab/mesh", server.WebAPIPath, uuid), nil)
+	lines := strings.Split(strings.TrimSpace(string(resp)), "\n")
+	var nVerts, nFaces int
+	for _, line := range lines {
+		if strings.HasPrefix(line, "v ") {
+			nVerts++
+		} else if strings.HasPrefix(line, "f ") {
+			nFaces++
+		}
+	}
+	if nVerts != 22 || nFaces != 20 {
+		t.Errorf("expected OBJ with 22 vertices and 20 faces, got %d and %d\n", nVerts, nFaces)
+	}
+	if !strings.Contains(string(resp), "\nv 320 96 64\n") {
+		t.Errorf("expected OBJ vertex in voxel coordinates, got:\n%s\n", string(resp))
+	}
+	resp = server.TestHTTP(t, "GET", fmt.Sprintf("%snode/%s/slab/mesh?format=ply", server.WebAPIPath, uuid), nil)
+	if !strings.HasPrefix(string(resp), "ply\nformat ascii 1.0\n") || !strings.Contains(string(resp), "element vertex 22\n") ||
+		!strings.Contains(string(resp), "element face 20\n") {
+		t.Errorf("bad PLY mesh:\n%s\n", string(resp))
+	}
+
+	// Voxel-precision stats use the block masks.
+	config.Set("BlockSize", "8,8,8")
+	config.Set("VoxelPrecision", "true")
+	if _, err := datastore.NewData(uuid, roitype, "precise", config); err != nil {
+		t.Fatalf("Error creating new roi instance: %v\n", err)
+	}
+	server.TestHTTP(t, "POST", fmt.Sprintf("%snode/%s/precise/roi", server.WebAPIPath, uuid), bytes.NewBufferString("[[0,0,0,1]]"))
+	mask := make([]byte, 8*8*8)
+	for i := range mask {
+		if i%8 >= 4 {
+			mask[i] = 1
+		}
+	}
+	server.TestHTTP(t, "POST", fmt.Sprintf("%snode/%s/precise/mask/0_1_2/8_8_8/0_0_0", server.WebAPIPath, uuid), bytes.NewBuffer(mask))
+	resp = server.TestHTTP(t, "GET", fmt.Sprintf("%snode/%s/precise/stats", server.WebAPIPath, uuid), nil)
+	stats = Stats{}
+	if err := json.Unmarshal(resp, &stats); err != nil {
+		t.Fatalf("couldn't unmarshal stats %s: %v\n", string(resp), err)
+	}
+	if stats.NumBlocks != 2 || stats.NumBoundaryBlocks != 1 || stats.NumVoxels != 256+512 {
+		t.Errorf("bad voxel-precision stats counts: %s\n", string(resp))
+	}
+	if stats.MinVoxel == nil || *stats.MinVoxel != (dvid.Point3d{4, 0, 0}) || *stats.MaxVoxel != (dvid.Point3d{15, 7, 7}) {
+		t.Errorf("bad voxel-precision stats bounds: %s\n", string(resp))
+	}
+
+	// ROIs can only sync to data with a voxel resolution.
+	syncURL := fmt.Sprintf("%snode/%s/slab/sync", server.WebAPIPath, uuid)
+	server.TestBadHTTP(t, "POST", syncURL, bytes.NewBufferString(`{"sync": "precise"}`))
+	server.TestBadHTTP(t, "POST", syncURL, bytes.NewBufferString(`{"sync": "nonexistent"}`))
+	server.TestBadHTTP(t, "GET", fmt.Sprintf("%snode/%s/slab/mesh?physical=true", server.WebAPIPath, uuid), nil)
+	server.TestBadHTTP(t, "GET", fmt.Sprintf("%snode/%s/slab/mesh?format=stl", server.WebAPIPath, uuid), nil)
+
+	// Syncing to data with a voxel resolution gives stats and meshes in physical units.
+	restype, err := datastore.TypeServiceByName("restest")
+	if err != nil {
+		t.Fatalf("can't get test resolution type: %v\n", err)
+	}
+	if _, err := datastore.NewData(uuid, restype, "grayres", dvid.NewConfig()); err != nil {
+		t.Fatalf("Error creating test resolution instance: %v\n", err)
+	}
+	server.TestHTTP(t, "POST", syncURL, bytes.NewBufferString(`{"sync": "grayres"}`))
+	resp = server.TestHTTP(t, "GET", fmt.Sprintf("%snode/%s/slab/stats", server.WebAPIPath, uuid), nil)
+	stats = Stats{}
+	if err := json.Unmarshal(resp, &stats); err != nil {
+		t.Fatalf("couldn't unmarshal stats %s: %v\n", string(resp), err)
+	}
+	if stats.Resolution == nil || stats.PhysicalVolume != 5*32*32*32*4*8*10 {
+		t.Errorf("bad physical stats after sync: %s\n", string(resp))
+	}
+	if stats.PhysicalSurfaceArea != 4*32*32*8*10+6*32*32*4*10+10*32*32*4*8 {
+		t.Errorf("bad physical surface area after sync: %s\n", string(resp))
+	}
+	resp = server.TestHTTP(t, "GET", fmt.Sprintf("%snode/%s/slab/mesh?physical=true", server.WebAPIPath, uuid), nil)
+	if !strings.Contains(string(resp), "\nv 1280 768 640\n") {
+		t.Errorf("expected OBJ vertex in physical units, got:\n%s\n", string(resp))
+	}
+}
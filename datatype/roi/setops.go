@@ -0,0 +1,265 @@
+/*
+	This file supports boolean set operations between ROIs.
+*/
+
+package roi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/janelia-flyem/dvid/dvid"
+)
+
+// MaxComplementRows is the maximum number of (z, y) block rows within the bounds of a
+// complement, which limits the number of spans that must be allocated for the bounds.
+const MaxComplementRows = 1 << 22
+
+// SetOpRequest describes a set operation on ROIs that are given as "<roiname>,<uuid>"
+// specifications.  If the uuid is omitted, the version of the request is used.
+type SetOpRequest struct {
+	// Op is one of "union", "intersection", "difference", or "complement".
+	Op string
+
+	// ROIs are the operands.  For "difference", all but the first ROI are subtracted from
+	// the first.  For "complement", the union of the ROIs is subtracted from the bounds.
+	ROIs []string
+
+	// MinBlock and MaxBlock are optional inclusive bounds in block coordinates that clip
+	// the result.  They are required for "complement", where the bounds can span at most
+	// MaxComplementRows block rows in y and z.
+	MinBlock *dvid.ChunkPoint3d `json:",omitempty"`
+	MaxBlock *dvid.ChunkPoint3d `json:",omitempty"`
+}
+
+// SetOpResult describes the ROI written by a set operation.
+type SetOpResult struct {
+	Spans  int
+	Blocks uint64
+}
+
+// compareRows returns -1, 0, or 1 if the (z, y) row of span a is before, the same as,
+// or after that of span b.
+func compareRows(a, b dvid.Span) int {
+	switch {
+	case a[0] < b[0]:
+		return -1
+	case a[0] > b[0]:
+		return 1
+	case a[1] < b[1]:
+		return -1
+	case a[1] > b[1]:
+		return 1
+	}
+	return 0
+}
+
+// unionSpans returns the normalized union of the given spans.
+func unionSpans(spans ...dvid.Spans) dvid.Spans {
+	var all dvid.Spans
+	for _, s := range spans {
+		all = append(all, s...)
+	}
+	return all.Normalize()
+}
+
+// intersectSpans returns the intersection of two normalized span lists.
+func intersectSpans(a, b dvid.Spans) dvid.Spans {
+	out := dvid.Spans{}
+	var i, j int
+	for i < len(a) && j < len(b) {
+		switch compareRows(a[i], b[j]) {
+		case -1:
+			i++
+		case 1:
+			j++
+		default:
+			x0, x1 := a[i][2], a[i][3]
+			if b[j][2] > x0 {
+				x0 = b[j][2]
+			}
+			if b[j][3] < x1 {
+				x1 = b[j][3]
+			}
+			if x0 <= x1 {
+				out = append(out, dvid.Span{a[i][0], a[i][1], x0, x1})
+			}
+			if a[i][3] < b[j][3] {
+				i++
+			} else {
+				j++
+			}
+		}
+	}
+	return out
+}
+
+// subtractSpans returns the normalized span list a with all of normalized spans b removed.
+func subtractSpans(a, b dvid.Spans) dvid.Spans {
+	out := dvid.Spans{}
+	var j int
+	for _, span := range a {
+		x0 := span[2]
+		for j < len(b) {
+			cmp := compareRows(b[j], span)
+			if cmp < 0 || (cmp == 0 && b[j][3] < x0) {
+				j++
+			} else {
+				break
+			}
+		}
+		for k := j; k < len(b) && compareRows(b[k], span) == 0 && b[k][2] <= span[3]; k++ {
+			if b[k][2] > x0 {
+				out = append(out, dvid.Span{span[0], span[1], x0, b[k][2] - 1})
+			}
+			if b[k][3] >= x0 {
+				x0 = b[k][3] + 1
+			}
+		}
+		if x0 <= span[3] {
+			out = append(out, dvid.Span{span[0], span[1], x0, span[3]})
+		}
+	}
+	return out
+}
+
+// boundsSpans returns the spans of all blocks within the inclusive block bounds.
+func boundsSpans(minBlock, maxBlock dvid.ChunkPoint3d) dvid.Spans {
+	spans := dvid.Spans{}
+	for z := minBlock[2]; z <= maxBlock[2]; z++ {
+		for y := minBlock[1]; y <= maxBlock[1]; y++ {
+			spans = append(spans, dvid.Span{z, y, minBlock[0], maxBlock[0]})
+		}
+	}
+	return spans
+}
+
+// clipSpans returns the portions of the normalized spans within the inclusive block bounds.
+func clipSpans(spans dvid.Spans, minBlock, maxBlock dvid.ChunkPoint3d) dvid.Spans {
+	clipped := dvid.Spans{}
+	for _, span := range spans {
+		if span[0] < minBlock[2] || span[0] > maxBlock[2] || span[1] < minBlock[1] || span[1] > maxBlock[1] {
+			continue
+		}
+		if span[3] < minBlock[0] || span[2] > maxBlock[0] {
+			continue
+		}
+		x0, x1 := span[2], span[3]
+		if x0 < minBlock[0] {
+			x0 = minBlock[0]
+		}
+		if x1 > maxBlock[0] {
+			x1 = maxBlock[0]
+		}
+		clipped = append(clipped, dvid.Span{span[0], span[1], x0, x1})
+	}
+	return clipped
+}
+
+// getSpansBySpec returns the spans and block size of the ROI given by a "<roiname>,<uuid>"
+// specification, where a specification without a uuid uses the given default uuid.
+func getSpansBySpec(spec string, uuid dvid.UUID) (dvid.Spans, dvid.Point3d, error) {
+	if !strings.Contains(spec, ",") {
+		spec = fmt.Sprintf("%s,%s", spec, uuid)
+	}
+	d, v, found, err := DataBySpec(spec)
+	if err != nil {
+		return nil, dvid.Point3d{}, err
+	}
+	if !found {
+		return nil, dvid.Point3d{}, fmt.Errorf("ROI %q not found", spec)
+	}
+	d.RLock()
+	spans, err := d.GetSpans(v)
+	d.RUnlock()
+	if err != nil {
+		return nil, dvid.Point3d{}, err
+	}
+	return dvid.Spans(spans).Normalize(), d.BlockSize, nil
+}
+
+// CombineROIs returns the normalized spans resulting from the set operation.  All ROIs must
+// have the given block size.  The uuid is used for ROI specifications without a uuid.
+func CombineROIs(req SetOpRequest, blockSize dvid.Point3d, uuid dvid.UUID) (dvid.Spans, error) {
+	op := strings.ToLower(req.Op)
+	switch op {
+	case "union", "intersection", "difference":
+		if len(req.ROIs) < 2 {
+			return nil, fmt.Errorf("ROI %s requires at least two ROIs, got %d", op, len(req.ROIs))
+		}
+	case "complement":
+		if len(req.ROIs) < 1 {
+			return nil, fmt.Errorf("ROI complement requires at least one ROI")
+		}
+		if req.MinBlock == nil || req.MaxBlock == nil {
+			return nil, fmt.Errorf("ROI complement requires MinBlock and MaxBlock bounds")
+		}
+	default:
+		return nil, fmt.Errorf("unknown ROI set operation %q", req.Op)
+	}
+	if (req.MinBlock == nil) != (req.MaxBlock == nil) {
+		return nil, fmt.Errorf("both MinBlock and MaxBlock must be given for bounds")
+	}
+	if req.MinBlock != nil {
+		for i := 0; i < 3; i++ {
+			if req.MinBlock[i] > req.MaxBlock[i] {
+				return nil, fmt.Errorf("bad bounds: MinBlock %s exceeds MaxBlock %s", *req.MinBlock, *req.MaxBlock)
+			}
+		}
+	}
+	if op == "complement" {
+		rows := (int64(req.MaxBlock[2]) - int64(req.MinBlock[2]) + 1) * (int64(req.MaxBlock[1]) - int64(req.MinBlock[1]) + 1)
+		if rows > MaxComplementRows {
+			return nil, fmt.Errorf("ROI complement bounds %s to %s span %d block rows, exceeding maximum of %d", *req.MinBlock, *req.MaxBlock, rows, MaxComplementRows)
+		}
+	}
+
+	operands := make([]dvid.Spans, len(req.ROIs))
+	for i, spec := range req.ROIs {
+		spans, roiBlockSize, err := getSpansBySpec(spec, uuid)
+		if err != nil {
+			return nil, err
+		}
+		if !roiBlockSize.Equals(blockSize) {
+			return nil, fmt.Errorf("ROI %q has block size %s, which differs from %s", spec, roiBlockSize, blockSize)
+		}
+		operands[i] = spans
+	}
+
+	var result dvid.Spans
+	switch op {
+	case "union":
+		result = unionSpans(operands...)
+	case "intersection":
+		result = operands[0]
+		for _, spans := range operands[1:] {
+			result = intersectSpans(result, spans)
+		}
+	case "difference":
+		result = subtractSpans(operands[0], unionSpans(operands[1:]...))
+	case "complement":
+		result = subtractSpans(boundsSpans(*req.MinBlock, *req.MaxBlock), unionSpans(operands...))
+	}
+	if req.MinBlock != nil && op != "complement" {
+		result = clipSpans(result, *req.MinBlock, *req.MaxBlock)
+	}
+	return result, nil
+}
+
+// PutSetOp computes a set operation on ROIs given as JSON and replaces this ROI's spans
+// at the given version with the result.
+func (d *Data) PutSetOp(uuid dvid.UUID, v dvid.VersionID, jsonBytes []byte) (*SetOpResult, error) {
+	var req SetOpRequest
+	if err := json.Unmarshal(jsonBytes, &req); err != nil {
+		return nil, fmt.Errorf("bad ROI set operation JSON: %v", err)
+	}
+	spans, err := CombineROIs(req, d.BlockSize, uuid)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.PutSpans(v, spans, true); err != nil {
+		return nil, err
+	}
+	return &SetOpResult{Spans: len(spans), Blocks: spans.Count()}, nil
+}
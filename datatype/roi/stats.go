@@ -0,0 +1,218 @@
+/*
+	This file supports ROI statistics and syncing an ROI to data with a voxel resolution.
+*/
+
+package roi
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/dvid"
+)
+
+// ResolutionSource is implemented by data with a voxel resolution, e.g., imageblk and
+// labelmap instances, that an ROI can be synced to for physical units.
+type ResolutionSource interface {
+	Resolution() dvid.Resolution
+}
+
+// GetSyncSubs implements the datastore.Syncer interface.  An ROI only syncs to data with
+// a voxel resolution and doesn't subscribe to any events.
+func (d *Data) GetSyncSubs(synced dvid.Data) (datastore.SyncSubs, error) {
+	if _, ok := synced.(ResolutionSource); !ok {
+		return nil, fmt.Errorf("roi %q can only be synced with data that has a voxel resolution, not %q", d.DataName(), synced.DataName())
+	}
+	return datastore.SyncSubs{}, nil
+}
+
+// GetResolution returns the resolution of the first synced data with a 3d resolution or nil
+// if there is none.
+func (d *Data) GetResolution() (*dvid.Resolution, error) {
+	for dataUUID := range d.SyncedData() {
+		synced, err := datastore.GetDataByDataUUID(dataUUID)
+		if err != nil {
+			return nil, err
+		}
+		if src, ok := synced.(ResolutionSource); ok {
+			res := src.Resolution()
+			if len(res.VoxelSize) == 3 {
+				return &res, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// ZSlabStats gives the number of blocks and voxels in the ROI for one Z block coordinate.
+type ZSlabStats struct {
+	Z      int32
+	Blocks uint64
+	Voxels uint64
+}
+
+// Stats describes the size and shape of an ROI.  Physical measures are only given if the ROI
+// is synced to data with a voxel resolution.
+type Stats struct {
+	BlockSize dvid.Point3d
+	NumSpans  int
+	NumBlocks uint64
+
+	// NumBoundaryBlocks is the number of blocks with a voxel mask for voxel-precision ROIs.
+	NumBoundaryBlocks int `json:",omitempty"`
+
+	// NumVoxels is the number of voxels in the ROI, using masks for voxel-precision ROIs.
+	NumVoxels uint64
+
+	// Inclusive bounding box in block and voxel coordinates, where the voxel bounds use masks
+	// for voxel-precision ROIs.
+	MinBlock *dvid.ChunkPoint3d `json:",omitempty"`
+	MaxBlock *dvid.ChunkPoint3d `json:",omitempty"`
+	MinVoxel *dvid.Point3d      `json:",omitempty"`
+	MaxVoxel *dvid.Point3d      `json:",omitempty"`
+
+	ZSlabs []ZSlabStats
+
+	// SurfaceFaces is the number of block faces not adjacent to another block of the ROI and
+	// SurfaceArea is their total area in square voxels.
+	SurfaceFaces uint64
+	SurfaceArea  uint64
+
+	Resolution          *dvid.Resolution `json:",omitempty"`
+	PhysicalVolume      float64          `json:",omitempty"`
+	PhysicalSurfaceArea float64          `json:",omitempty"`
+}
+
+// shiftSpans returns spans translated by the given number of blocks in y and z.
+func shiftSpans(spans dvid.Spans, dy, dz int32) dvid.Spans {
+	shifted := make(dvid.Spans, len(spans))
+	for i, span := range spans {
+		shifted[i] = dvid.Span{span[0] + dz, span[1] + dy, span[2], span[3]}
+	}
+	return shifted
+}
+
+// extents returns the inclusive voxel bounds of the mask within a block, or false if empty.
+func (m BlockMask) extents(blockSize dvid.Point3d) (minPt, maxPt dvid.Point3d, found bool) {
+	minPt = dvid.Point3d{math.MaxInt32, math.MaxInt32, math.MaxInt32}
+	maxPt = dvid.Point3d{math.MinInt32, math.MinInt32, math.MinInt32}
+	i := 0
+	for z := int32(0); z < blockSize[2]; z++ {
+		for y := int32(0); y < blockSize[1]; y++ {
+			for x := int32(0); x < blockSize[0]; x++ {
+				if m.Get(i) {
+					found = true
+					pt := dvid.Point3d{x, y, z}
+					for dim := 0; dim < 3; dim++ {
+						if pt[dim] < minPt[dim] {
+							minPt[dim] = pt[dim]
+						}
+						if pt[dim] > maxPt[dim] {
+							maxPt[dim] = pt[dim]
+						}
+					}
+				}
+				i++
+			}
+		}
+	}
+	return
+}
+
+// GetStats returns statistics for the ROI at the given version.
+func (d *Data) GetStats(v dvid.VersionID) (*Stats, error) {
+	d.RLock()
+	spans, err := d.GetSpans(v)
+	var masks map[dvid.IZYXString]BlockMask
+	if err == nil {
+		masks, err = d.GetBlockMasks(v)
+	}
+	d.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	norm := dvid.Spans(spans).Normalize()
+
+	blockVoxels := uint64(d.BlockSize.Prod())
+	stats := &Stats{
+		BlockSize:         d.BlockSize,
+		NumSpans:          len(norm),
+		NumBlocks:         norm.Count(),
+		NumBoundaryBlocks: len(masks),
+		ZSlabs:            []ZSlabStats{},
+	}
+	if len(norm) == 0 {
+		return stats, nil
+	}
+
+	// Count voxels, and get voxel bounds from unmasked blocks.
+	minVoxel := dvid.Point3d{math.MaxInt32, math.MaxInt32, math.MaxInt32}
+	maxVoxel := dvid.Point3d{math.MinInt32, math.MinInt32, math.MinInt32}
+	extendVoxel := func(minPt, maxPt dvid.Point3d) {
+		for dim := 0; dim < 3; dim++ {
+			if minPt[dim] < minVoxel[dim] {
+				minVoxel[dim] = minPt[dim]
+			}
+			if maxPt[dim] > maxVoxel[dim] {
+				maxVoxel[dim] = maxPt[dim]
+			}
+		}
+	}
+	for _, span := range norm {
+		n := len(stats.ZSlabs)
+		if n == 0 || stats.ZSlabs[n-1].Z != span[0] {
+			stats.ZSlabs = append(stats.ZSlabs, ZSlabStats{Z: span[0]})
+			n++
+		}
+		slab := &(stats.ZSlabs[n-1])
+		for x := span[2]; x <= span[3]; x++ {
+			bcoord := dvid.ChunkPoint3d{x, span[1], span[0]}
+			slab.Blocks++
+			mask, found := masks[bcoord.ToIZYXString()]
+			if !found {
+				slab.Voxels += blockVoxels
+				extendVoxel(bcoord.MinPoint(d.BlockSize).(dvid.Point3d), bcoord.MaxPoint(d.BlockSize).(dvid.Point3d))
+				continue
+			}
+			slab.Voxels += mask.Count()
+			if minPt, maxPt, found := mask.extents(d.BlockSize); found {
+				offset := bcoord.MinPoint(d.BlockSize).(dvid.Point3d)
+				extendVoxel(offset.Add3d(minPt), offset.Add3d(maxPt))
+			}
+		}
+	}
+	for _, slab := range stats.ZSlabs {
+		stats.NumVoxels += slab.Voxels
+	}
+	offset, size := norm.Extents()
+	minBlock := dvid.ChunkPoint3d(offset)
+	maxBlock := dvid.ChunkPoint3d{offset[0] + size[0] - 1, offset[1] + size[1] - 1, offset[2] + size[2] - 1}
+	stats.MinBlock, stats.MaxBlock = &minBlock, &maxBlock
+	if minVoxel[0] <= maxVoxel[0] {
+		stats.MinVoxel, stats.MaxVoxel = &minVoxel, &maxVoxel
+	}
+
+	// Each span has exposed faces at both x ends, while exposed y and z faces are blocks not
+	// covered by the adjacent row.
+	facesX := 2 * uint64(len(norm))
+	facesY := 2 * (stats.NumBlocks - intersectSpans(norm, shiftSpans(norm, 1, 0)).Count())
+	facesZ := 2 * (stats.NumBlocks - intersectSpans(norm, shiftSpans(norm, 0, 1)).Count())
+	bs := d.BlockSize
+	stats.SurfaceFaces = facesX + facesY + facesZ
+	stats.SurfaceArea = facesX*uint64(bs[1]*bs[2]) + facesY*uint64(bs[0]*bs[2]) + facesZ*uint64(bs[0]*bs[1])
+
+	res, err := d.GetResolution()
+	if err != nil {
+		return nil, err
+	}
+	if res != nil {
+		vs := res.VoxelSize
+		stats.Resolution = res
+		stats.PhysicalVolume = float64(stats.NumVoxels) * float64(vs[0]) * float64(vs[1]) * float64(vs[2])
+		stats.PhysicalSurfaceArea = float64(facesX)*float64(bs[1])*float64(vs[1])*float64(bs[2])*float64(vs[2]) +
+			float64(facesY)*float64(bs[0])*float64(vs[0])*float64(bs[2])*float64(vs[2]) +
+			float64(facesZ)*float64(bs[0])*float64(vs[0])*float64(bs[1])*float64(vs[1])
+	}
+	return stats, nil
+}
@@ -0,0 +1,290 @@
+/*
+	This file supports voxel-precision ROIs, where blocks on the ROI boundary store a binary
+	mask of the voxels within the ROI and interior blocks remain span-encoded.
+*/
+
+package roi
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// BlockMask is a bit mask of the voxels within a block that are inside an ROI.  Voxels are
+// in ZYX order, i.e., x varies fastest, with the first voxel in the lowest bit of the first byte.
+type BlockMask []byte
+
+func newBlockMask(blockSize dvid.Point3d) BlockMask {
+	return make(BlockMask, (blockSize.Prod()+7)/8)
+}
+
+// Get returns true if the voxel with given index in the block is inside the ROI.
+func (m BlockMask) Get(i int) bool {
+	return m[i>>3]&(1<<uint(i&7)) != 0
+}
+
+// Set marks the voxel with given index in the block as inside or outside the ROI.
+func (m BlockMask) Set(i int, inside bool) {
+	if inside {
+		m[i>>3] |= 1 << uint(i&7)
+	} else {
+		m[i>>3] &^= 1 << uint(i&7)
+	}
+}
+
+// VoxelWithin returns true if the voxel, which must be in the mask's block, is inside the ROI.
+func (m BlockMask) VoxelWithin(pt, blockSize dvid.Point3d) bool {
+	return m.Get(voxelIndex(pt, blockSize))
+}
+
+// Count returns the number of voxels inside the ROI.
+func (m BlockMask) Count() uint64 {
+	var count uint64
+	for _, b := range m {
+		for ; b != 0; b &= b - 1 {
+			count++
+		}
+	}
+	return count
+}
+
+// fullBlockMask returns a mask with all voxels of a block inside the ROI.
+func fullBlockMask(blockSize dvid.Point3d) BlockMask {
+	m := newBlockMask(blockSize)
+	n := int(blockSize.Prod())
+	for i := 0; i < n; i++ {
+		m.Set(i, true)
+	}
+	return m
+}
+
+// floorMod returns a mod b in [0, b) for positive b.
+func floorMod(a, b int32) int32 {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
+
+// voxelIndex returns the index within its block's mask of a voxel.
+func voxelIndex(pt, blockSize dvid.Point3d) int {
+	x := floorMod(pt[0], blockSize[0])
+	y := floorMod(pt[1], blockSize[1])
+	z := floorMod(pt[2], blockSize[2])
+	return int((z*blockSize[1]+y)*blockSize[0] + x)
+}
+
+func maskTKey(izyx dvid.IZYXString) storage.TKey {
+	return storage.NewTKey(keyMask, []byte(izyx))
+}
+
+func (d *Data) serializeMask(m BlockMask) ([]byte, error) {
+	return dvid.SerializeData(m, d.Compression(), d.Checksum())
+}
+
+func (d *Data) deserializeMask(b []byte) (BlockMask, error) {
+	data, _, err := dvid.DeserializeData(b, true)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != (d.BlockSize.Prod()+7)/8 {
+		return nil, fmt.Errorf("block mask for ROI %q has %d bytes, expected %d for block size %s",
+			d.DataName(), len(data), (d.BlockSize.Prod()+7)/8, d.BlockSize)
+	}
+	return BlockMask(data), nil
+}
+
+// getBlockMask returns the mask for a block or nil if the block has no mask.
+func (d *Data) getBlockMask(ctx *datastore.VersionedCtx, izyx dvid.IZYXString) (BlockMask, error) {
+	db, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return nil, err
+	}
+	data, err := db.Get(ctx, maskTKey(izyx))
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return d.deserializeMask(data)
+}
+
+// getBlockMasks returns the masks for blocks with Z coordinates within the given range.
+func (d *Data) getBlockMasks(ctx *datastore.VersionedCtx, minZ, maxZ int32) (map[dvid.IZYXString]BlockMask, error) {
+	masks := make(map[dvid.IZYXString]BlockMask)
+	if !d.VoxelPrecision {
+		return masks, nil
+	}
+	db, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return nil, err
+	}
+	begIndex := dvid.IndexZYX{math.MinInt32, math.MinInt32, minZ}
+	endIndex := dvid.IndexZYX{math.MaxInt32, math.MaxInt32, maxZ}
+	err = db.ProcessRange(ctx, maskTKey(begIndex.ToIZYXString()), maskTKey(endIndex.ToIZYXString()), &storage.ChunkOp{}, func(chunk *storage.Chunk) error {
+		ibytes, err := chunk.K.ClassBytes(keyMask)
+		if err != nil {
+			return err
+		}
+		mask, err := d.deserializeMask(chunk.V)
+		if err != nil {
+			return err
+		}
+		masks[dvid.IZYXString(ibytes)] = mask
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return masks, nil
+}
+
+// GetBlockMasks returns the masks of all boundary blocks for a voxel-precision ROI.  Blocks
+// within the ROI spans that have no mask are entirely within the ROI.  An empty map is
+// returned if the ROI does not have voxel precision.
+func (d *Data) GetBlockMasks(v dvid.VersionID) (map[dvid.IZYXString]BlockMask, error) {
+	ctx := datastore.NewVersionedCtx(d, v)
+	return d.getBlockMasks(ctx, math.MinInt32, math.MaxInt32)
+}
+
+// PutMask modifies a voxel-precision ROI within the given subvolume, where voxels with non-zero
+// values in the binary volume are set within the ROI and zero voxels are set outside the ROI.
+// The ROI outside the subvolume is unchanged.
+func (d *Data) PutMask(ctx *datastore.VersionedCtx, subvol *dvid.Subvolume, data []byte) error {
+	if !d.VoxelPrecision {
+		return fmt.Errorf("ROI %q does not have voxel precision; create it with VoxelPrecision=true", d.DataName())
+	}
+	if int64(len(data)) != subvol.NumVoxels() {
+		return fmt.Errorf("expected %d bytes for mask of size %s, got %d bytes", subvol.NumVoxels(), subvol.Size(), len(data))
+	}
+	pt0 := subvol.StartPoint().(dvid.Point3d)
+	pt1 := subvol.EndPoint().(dvid.Point3d)
+	minBlock := pt0.Chunk(d.BlockSize).(dvid.ChunkPoint3d)
+	maxBlock := pt1.Chunk(d.BlockSize).(dvid.ChunkPoint3d)
+
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+
+	d.RLock()
+	spans, err := GetSpans(ctx)
+	var masks map[dvid.IZYXString]BlockMask
+	if err == nil {
+		masks, err = d.getBlockMasks(ctx, minBlock[2], maxBlock[2])
+	}
+	d.RUnlock()
+	if err != nil {
+		return err
+	}
+	norm := dvid.Spans(spans).Normalize()
+	inROI := make(map[dvid.IZYXString]struct{})
+	for _, span := range clipSpans(norm, minBlock, maxBlock) {
+		for x := span[2]; x <= span[3]; x++ {
+			inROI[dvid.ChunkPoint3d{x, span[1], span[0]}.ToIZYXString()] = struct{}{}
+		}
+	}
+
+	size := subvol.Size().(dvid.Point3d)
+	nx, nxy := size[0], size[0]*size[1]
+	blockVoxels := uint64(d.BlockSize.Prod())
+	var added, removed dvid.Spans
+	changes := make(map[dvid.IZYXString]BlockMask)
+	for bz := minBlock[2]; bz <= maxBlock[2]; bz++ {
+		for by := minBlock[1]; by <= maxBlock[1]; by++ {
+			for bx := minBlock[0]; bx <= maxBlock[0]; bx++ {
+				bcoord := dvid.ChunkPoint3d{bx, by, bz}
+				izyx := bcoord.ToIZYXString()
+				mask, masked := masks[izyx]
+				_, inside := inROI[izyx]
+				switch {
+				case masked && inside:
+				case inside:
+					mask = fullBlockMask(d.BlockSize)
+				default:
+					mask = newBlockMask(d.BlockSize)
+				}
+
+				// Modify the voxels of the block within the subvolume.
+				blockMin := bcoord.MinPoint(d.BlockSize).(dvid.Point3d)
+				blockMax := bcoord.MaxPoint(d.BlockSize).(dvid.Point3d)
+				var beg, end dvid.Point3d
+				for i := 0; i < 3; i++ {
+					beg[i], end[i] = blockMin[i], blockMax[i]
+					if beg[i] < pt0[i] {
+						beg[i] = pt0[i]
+					}
+					if end[i] > pt1[i] {
+						end[i] = pt1[i]
+					}
+				}
+				for z := beg[2]; z <= end[2]; z++ {
+					for y := beg[1]; y <= end[1]; y++ {
+						p := (z-pt0[2])*nxy + (y-pt0[1])*nx
+						for x := beg[0]; x <= end[0]; x++ {
+							mask.Set(voxelIndex(dvid.Point3d{x, y, z}, d.BlockSize), data[p+x-pt0[0]] != 0)
+						}
+					}
+				}
+
+				span := dvid.Span{bz, by, bx, bx}
+				switch count := mask.Count(); {
+				case count == 0:
+					removed = append(removed, span)
+					if masked {
+						changes[izyx] = nil
+					}
+				case count == blockVoxels:
+					added = append(added, span)
+					if masked {
+						changes[izyx] = nil
+					}
+				default:
+					added = append(added, span)
+					changes[izyx] = mask
+				}
+			}
+		}
+	}
+	newSpans := subtractSpans(unionSpans(norm, added), removed.Normalize())
+	return d.putSpans(ctx.VersionID(), newSpans, true, changes)
+}
+
+// addActiveVoxels sets the number of voxels within the ROI for each subvolume of a partition.
+func (d *Data) addActiveVoxels(ctx storage.Context, subvolumes *subvolumesT) error {
+	if !d.VoxelPrecision {
+		return nil
+	}
+	masks, err := d.GetBlockMasks(ctx.VersionID())
+	if err != nil {
+		return err
+	}
+	type maskedBlock struct {
+		bcoord  dvid.ChunkPoint3d
+		outside uint64
+	}
+	blockVoxels := uint64(d.BlockSize.Prod())
+	masked := make([]maskedBlock, 0, len(masks))
+	for izyx, mask := range masks {
+		bcoord, err := izyx.ToChunkPoint3d()
+		if err != nil {
+			return err
+		}
+		masked = append(masked, maskedBlock{bcoord, blockVoxels - mask.Count()})
+	}
+	for i, subvolume := range subvolumes.Subvolumes {
+		active := subvolume.ActiveBlocks * blockVoxels
+		minChunk, maxChunk := subvolume.MinChunk, subvolume.MaxChunk
+		for _, mb := range masked {
+			c := mb.bcoord
+			if c[0] >= minChunk[0] && c[0] <= maxChunk[0] && c[1] >= minChunk[1] && c[1] <= maxChunk[1] &&
+				c[2] >= minChunk[2] && c[2] <= maxChunk[2] {
+				active -= mb.outside
+			}
+		}
+		subvolumes.Subvolumes[i].ActiveVoxels = active
+		subvolumes.NumActiveVoxels += active
+	}
+	return nil
+}